@@ -0,0 +1,277 @@
+package main
+
+import (
+	"errors"
+	"expvar"
+	"net/http"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/models"
+	"github.com/Vadim-Makhnev/snippetbox/internal/validator"
+)
+
+// maxBulkIDs bounds the snippets one bulk action can touch, which all stay
+// locked until its transaction ends.
+const maxBulkIDs = 100
+
+type adminBulkRequest struct {
+	Action string `json:"action"`
+	IDs    []int  `json:"ids"`
+}
+
+// adminSnippetsBulk godoc
+// @Summary      Apply an action to many snippets
+// @Description  Delete, hide or unhide a batch of snippets in one transaction, for moderation. The result lists the outcome for each ID; IDs with no snippet are reported as not_found without affecting the rest. Admins only; the session's CSRF token goes in the X-CSRF-Token header.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        X-CSRF-Token header string true "CSRF token"
+// @Param        payload body adminBulkRequest true "Action (delete, hide or unhide) and up to 100 snippet IDs"
+// @Success      200 {object} map[string]any "Outcome per snippet ID"
+// @Failure      400 {object} problemDetails "Bad request - malformed JSON"
+// @Failure      403 {string} string "Forbidden - not an admin"
+// @Failure      422 {object} problemDetails "Unprocessable entity - invalid action or IDs"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /admin/snippets/bulk [post]
+func (app *application) adminSnippetsBulk(w http.ResponseWriter, r *http.Request) {
+	var input adminBulkRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.problem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var v validator.Validator
+
+	v.CheckField(validator.PermittedValue(input.Action, models.BulkDelete, models.BulkHide, models.BulkUnhide), "action", "This field must equal delete, hide or unhide")
+	v.CheckField(len(input.IDs) > 0, "ids", "This field must contain at least one ID")
+	v.CheckField(len(input.IDs) <= maxBulkIDs, "ids", "This field must not contain more than 100 IDs")
+
+	if !v.Valid() {
+		app.validationProblem(w, r, v.FieldErrors)
+		return
+	}
+
+	results, err := app.snippets.Bulk(input.Action, input.IDs)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.logger.Info("admin bulk action", "user_id", app.authenticatedUserID(r), "action", input.Action, "ids", input.IDs)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"results": results}, nil)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+type adminUsersForm struct {
+	Q                   string
+	Sort                string
+	PageSize            int
+	validator.Validator `form:"-"`
+}
+
+// adminUsersSortSafelist are the orders the admin user list can be sorted in.
+var adminUsersSortSafelist = []string{"id", "-id", "name", "-name", "email", "-email", "created", "-created"}
+
+type adminFeaturedRequest struct {
+	Featured bool `json:"featured"`
+}
+
+// adminSnippetFeatured godoc
+// @Summary      Feature or un-feature a snippet
+// @Description  Mark a snippet as featured, so it is listed in the Featured section of the home page, or remove the mark. Only live public snippets can be featured; a featured snippet drops out of the section once it expires. Admins only; the session's CSRF token goes in the X-CSRF-Token header.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        X-CSRF-Token header string true "CSRF token"
+// @Param        id path string true "Snippet ID, or its opaque code when -obfuscate-ids is set"
+// @Param        payload body adminFeaturedRequest true "Whether the snippet is featured"
+// @Success      200 {object} map[string]any "Snippet ID and featured state"
+// @Failure      400 {object} problemDetails "Bad request - malformed JSON"
+// @Failure      403 {string} string "Forbidden - not an admin"
+// @Failure      404 {object} problemDetails "Snippet not found"
+// @Failure      422 {object} problemDetails "Unprocessable entity - snippet is private or expired"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /admin/snippets/{id}/featured [post]
+func (app *application) adminSnippetFeatured(w http.ResponseWriter, r *http.Request) {
+	id, ok := snippetIDs.decode(r.PathValue("id"))
+	if !ok {
+		app.problem(w, r, http.StatusNotFound, "snippet not found")
+		return
+	}
+
+	var input adminFeaturedRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.problem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = app.snippets.SetFeatured(id, input.Featured)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			app.problem(w, r, http.StatusNotFound, "snippet not found")
+		case errors.Is(err, models.ErrNotFeaturable):
+			app.problem(w, r, http.StatusUnprocessableEntity, "only live public snippets can be featured")
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.logger.Info("admin featured snippet", "user_id", app.authenticatedUserID(r), "snippet", id, "featured", input.Featured)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"id": id, "featured": input.Featured}, nil)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// adminDashboard godoc
+// @Summary      Admin dashboard
+// @Description  Show the numbers from /admin/stats.json as a page. It is where admins land after logging in, unless -admin-login-redirect says otherwise. Admins only.
+// @Tags         admin
+// @Produce      html
+// @Success      200 {string} string "Admin dashboard"
+// @Failure      403 {string} string "Forbidden - not an admin"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /admin [get]
+func (app *application) adminDashboard(w http.ResponseWriter, r *http.Request) {
+	users, err := app.users.Counts()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	snippets, err := app.snippets.Counts()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.UserCounts = users
+	data.SnippetCounts = snippets
+
+	app.render(w, r, http.StatusOK, "admin.tmpl", data)
+}
+
+// adminUsers godoc
+// @Summary      List users
+// @Description  List user accounts, paginated and in order of signing up unless sorted otherwise, optionally only those whose name or email contains the search term. A page number out of range shows the first or last page. Password hashes are never shown. Admins only.
+// @Tags         admin
+// @Produce      html
+// @Param        q query string false "Search term, matched against names and emails ignoring case"
+// @Param        page query int false "Page number" default(1)
+// @Param        page_size query int false "Users per page, within -page-size-search (1-50 by default)" default(20)
+// @Param        sort query string false "Order, descending with a leading -" Enums(id, -id, name, -name, email, -email, created, -created) default(id)
+// @Success      200 {string} string "User list"
+// @Failure      403 {string} string "Forbidden - not an admin"
+// @Failure      422 {string} string "Unprocessable entity - invalid page size or sort"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /admin/users [get]
+func (app *application) adminUsers(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	form := adminUsersForm{Q: qs.Get("q")}
+
+	filters := app.readFilters(qs, "id", adminUsersSortSafelist, app.config.pageSizes.search, &form.Validator)
+	form.Sort = filters.Sort
+	form.PageSize = filters.PageSize
+
+	// A bad page number shows the nearest page rather than an error, the
+	// way Search handles pages past the end.
+	if _, ok := form.FieldErrors["page"]; ok {
+		delete(form.FieldErrors, "page")
+		filters.Page = max(1, min(filters.Page, 10_000_000))
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+
+	if !form.Valid() {
+		app.render(w, r, http.StatusUnprocessableEntity, "users.tmpl", data)
+		return
+	}
+
+	users, err := app.users.Search(form.Q, filters)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data.Users = users.Users
+	data.UserCounts.Total = users.Total
+	data.Page = users.Page
+	data.LastPage = users.LastPage
+
+	app.render(w, r, http.StatusOK, "users.tmpl", data)
+}
+
+// adminStats godoc
+// @Summary      Site statistics
+// @Description  Aggregate numbers of users and snippets for an admin dashboard: users in total and activated, snippets in total, live and expired, and snippets created in the last 24 hours and 7 days. Hidden and private snippets are counted. Admins only.
+// @Tags         admin
+// @Produce      json
+// @Success      200 {object} map[string]any "User and snippet counts"
+// @Failure      403 {string} string "Forbidden - not an admin"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /admin/stats.json [get]
+func (app *application) adminStats(w http.ResponseWriter, r *http.Request) {
+	users, err := app.users.Counts()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	snippets, err := app.snippets.Counts()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"users": users, "snippets": snippets}, nil)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// adminDebugVars godoc
+// @Summary      Runtime variables
+// @Description  The variables published through expvar: memory statistics, the command line and the hit and miss counters of the in-memory caches under caches. Admins only.
+// @Tags         admin
+// @Produce      json
+// @Success      200 {object} map[string]any "Published variables"
+// @Failure      403 {string} string "Forbidden - not an admin"
+// @Router       /admin/debug/vars [get]
+func (app *application) adminDebugVars(w http.ResponseWriter, r *http.Request) {
+	expvar.Handler().ServeHTTP(w, r)
+}
+
+// adminInviteCreate godoc
+// @Summary      Create an invite code
+// @Description  Generate a single-use invite code for signing up when -require-invite is set. The code is only shown in this response. Admins only; the session's CSRF token goes in the X-CSRF-Token header.
+// @Tags         admin
+// @Produce      json
+// @Param        X-CSRF-Token header string true "CSRF token"
+// @Success      201 {object} map[string]any "The invite code"
+// @Failure      403 {string} string "Forbidden - not an admin"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /admin/invites [post]
+func (app *application) adminInviteCreate(w http.ResponseWriter, r *http.Request) {
+	invite, err := app.invites.New(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"invite": invite}, nil)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
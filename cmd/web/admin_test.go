@@ -0,0 +1,517 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/assert"
+	"github.com/Vadim-Makhnev/snippetbox/internal/models"
+	"github.com/Vadim-Makhnev/snippetbox/internal/models/mocks"
+)
+
+type nonAdminUserModel struct {
+	mocks.UserModel
+}
+
+func (m *nonAdminUserModel) IsAdmin(id int) (bool, error) {
+	return false, nil
+}
+
+func TestAdminSnippetsBulk(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantCode    int
+		wantResults []models.BulkResult
+		wantErrors  []string
+	}{
+		{
+			name:     "Bulk delete",
+			body:     `{"action": "delete", "ids": [1, 3]}`,
+			wantCode: http.StatusOK,
+			wantResults: []models.BulkResult{
+				{ID: 1, Status: "deleted"},
+				{ID: 3, Status: "deleted"},
+			},
+		},
+		{
+			name:     "Mixed batch",
+			body:     `{"action": "hide", "ids": [1, 99, 3]}`,
+			wantCode: http.StatusOK,
+			wantResults: []models.BulkResult{
+				{ID: 1, Status: "hidden"},
+				{ID: 99, Status: "not_found"},
+				{ID: 3, Status: "hidden"},
+			},
+		},
+		{
+			name:       "Invalid action",
+			body:       `{"action": "archive", "ids": [1]}`,
+			wantCode:   http.StatusUnprocessableEntity,
+			wantErrors: []string{"action"},
+		},
+		{
+			name:       "No IDs",
+			body:       `{"action": "delete", "ids": []}`,
+			wantCode:   http.StatusUnprocessableEntity,
+			wantErrors: []string{"ids"},
+		},
+	}
+
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippet/create")
+
+	header := http.Header{}
+	header.Set("X-CSRF-Token", extractCSRFToken(t, body))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.postJSON(t, "/admin/snippets/bulk", header, tt.body)
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantCode != http.StatusOK {
+				var problem problemDetails
+
+				err := json.Unmarshal([]byte(body), &problem)
+				assert.NilError(t, err)
+
+				for _, field := range tt.wantErrors {
+					assert.Equal(t, problem.Errors[field] != "", true)
+				}
+				return
+			}
+
+			var resp struct {
+				Results []models.BulkResult `json:"results"`
+			}
+
+			err := json.Unmarshal([]byte(body), &resp)
+			assert.NilError(t, err)
+
+			assert.Equal(t, len(resp.Results), len(tt.wantResults))
+			for i, want := range tt.wantResults {
+				assert.Equal(t, resp.Results[i], want)
+			}
+		})
+	}
+}
+
+func TestAdminSnippetsBulkRequiresAdmin(t *testing.T) {
+	app := newTestApplication(t)
+	app.users = &nonAdminUserModel{}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippet/create")
+
+	csrf := http.Header{}
+	csrf.Set("X-CSRF-Token", extractCSRFToken(t, body))
+
+	code, _, _ := ts.postJSON(t, "/admin/snippets/bulk", csrf, `{"action": "delete", "ids": [1]}`)
+	assert.Equal(t, code, http.StatusForbidden)
+}
+
+func TestAdminSnippetFeatured(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Feature",
+			path:     "/admin/snippets/1/featured",
+			body:     `{"featured": true}`,
+			wantCode: http.StatusOK,
+			wantBody: `"featured":true`,
+		},
+		{
+			name:     "Un-feature",
+			path:     "/admin/snippets/1/featured",
+			body:     `{"featured": false}`,
+			wantCode: http.StatusOK,
+			wantBody: `"featured":false`,
+		},
+		{
+			name:     "Private snippet",
+			path:     "/admin/snippets/5/featured",
+			body:     `{"featured": true}`,
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "only live public snippets can be featured",
+		},
+		{
+			name:     "Un-feature private snippet",
+			path:     "/admin/snippets/5/featured",
+			body:     `{"featured": false}`,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Missing snippet",
+			path:     "/admin/snippets/99/featured",
+			body:     `{"featured": true}`,
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippet/create")
+
+	header := http.Header{}
+	header.Set("X-CSRF-Token", extractCSRFToken(t, body))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.postJSON(t, tt.path, header, tt.body)
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
+
+type featuredSnippetModel struct {
+	mocks.SnippetModel
+}
+
+func (m *featuredSnippetModel) Featured(limit int) ([]models.Snippet, error) {
+	s, err := m.Get(3)
+	return []models.Snippet{s}, err
+}
+
+func TestHomeFeatured(t *testing.T) {
+	app := newTestApplication(t)
+	app.snippets = &featuredSnippetModel{}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/")
+
+	featured := strings.Index(body, "<h2>Featured</h2>")
+	latest := strings.Index(body, "<h2>Latest Snippets</h2>")
+
+	assert.Equal(t, featured != -1 && featured < latest, true)
+	assert.StringContains(t, body[featured:latest], "<a href='/snippet/view/3'>Markup</a>")
+
+	app.config.featuredLimit = 0
+
+	_, _, body = ts.get(t, "/")
+	assert.Equal(t, strings.Contains(body, "<h2>Featured</h2>"), false)
+}
+
+type markupFeaturedSnippetModel struct {
+	mocks.SnippetModel
+}
+
+func (m *markupFeaturedSnippetModel) Featured(limit int) ([]models.Snippet, error) {
+	return []models.Snippet{{ID: 3, Title: "<i>Markup</i>"}}, nil
+}
+
+func TestHomeFeaturedEscapesTitle(t *testing.T) {
+	app := newTestApplication(t)
+	app.snippets = &markupFeaturedSnippetModel{}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/")
+	assert.StringContains(t, body, "<a href='/snippet/view/3'>&lt;i&gt;Markup&lt;/i&gt;</a>")
+}
+
+func TestAdminStats(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Seed a second user through the API.
+	code, _, _ := ts.postJSON(t, "/api/v1/users", nil, `{"name": "Bob", "email": "bob@example.com", "password": "pa$$word123"}`)
+	assert.Equal(t, code, http.StatusCreated)
+
+	ts.login(t)
+
+	code, _, body := ts.get(t, "/admin/stats.json")
+	assert.Equal(t, code, http.StatusOK)
+
+	var resp struct {
+		Users    models.UserCounts    `json:"users"`
+		Snippets models.SnippetCounts `json:"snippets"`
+	}
+
+	err := json.Unmarshal([]byte(body), &resp)
+	assert.NilError(t, err)
+
+	assert.Equal(t, resp.Users, models.UserCounts{Total: 2, Activated: 2})
+	assert.Equal(t, resp.Snippets, models.SnippetCounts{Total: 4, Live: 4, CreatedDay: 4, CreatedWeek: 4})
+	assert.StringContains(t, body, `"created_last_24h":4`)
+
+	code, _, body = ts.get(t, "/admin")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<td>2 (2 activated)</td>")
+
+	app.users = &nonAdminUserModel{}
+
+	code, _, _ = ts.get(t, "/admin/stats.json")
+	assert.Equal(t, code, http.StatusForbidden)
+}
+
+func TestUserSignupInvite(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.requireInvite = true
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippet/create")
+
+	header := http.Header{}
+	header.Set("X-CSRF-Token", extractCSRFToken(t, body))
+
+	code, _, body := ts.postJSON(t, "/admin/invites", header, "")
+	assert.Equal(t, code, http.StatusCreated)
+
+	var resp struct {
+		Invite struct {
+			Code string `json:"code"`
+		} `json:"invite"`
+	}
+
+	err := json.Unmarshal([]byte(body), &resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		email    string
+		invite   string
+		wantCode int
+	}{
+		{
+			name:     "Missing code",
+			email:    "bob@example.com",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "Unknown code",
+			email:    "bob@example.com",
+			invite:   "NOTANINVITE",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "Valid code",
+			email:    "bob@example.com",
+			invite:   resp.Invite.Code,
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Reused code",
+			email:    "carol@example.com",
+			invite:   resp.Invite.Code,
+			wantCode: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, body := ts.get(t, "/user/signup")
+			assert.StringContains(t, body, "name='invite'")
+
+			form := url.Values{}
+			form.Add("name", "Bob")
+			form.Add("email", tt.email)
+			form.Add("password", "validPa$$word")
+			form.Add("invite", tt.invite)
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, _, body := ts.postForm(t, "/user/signup", form)
+
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantCode == http.StatusUnprocessableEntity {
+				assert.StringContains(t, body, "This invite code is invalid or has already been used")
+			}
+		})
+	}
+}
+
+// racingInviteModel reports every invite as valid, as it is to signups that
+// both check a code before either uses it.
+type racingInviteModel struct {
+	mocks.InviteModel
+}
+
+func (m *racingInviteModel) WithTx(tx *sql.Tx) models.InviteModelInterface {
+	return m
+}
+
+func (m *racingInviteModel) Valid(code string) (bool, error) {
+	return true, nil
+}
+
+func TestUserSignupInviteRace(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.requireInvite = true
+
+	invites := &racingInviteModel{}
+	app.invites = invites
+
+	invite, err := invites.New(1)
+	assert.NilError(t, err)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	var wg sync.WaitGroup
+	codes := make([]int, 2)
+	bodies := make([]string, 2)
+
+	for i, email := range []string{"bob@example.com", "carol@example.com"} {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			body := fmt.Sprintf(`{"name": "Bob", "email": %q, "password": "validPa$$word", "invite": %q}`, email, invite.Code)
+			codes[i], _, bodies[i] = ts.postJSON(t, "/api/v1/users", nil, body)
+		}()
+	}
+
+	wg.Wait()
+
+	slices.Sort(codes)
+	assert.Equal(t, codes[0], http.StatusCreated)
+	assert.Equal(t, codes[1], http.StatusUnprocessableEntity)
+	assert.StringContains(t, strings.Join(bodies, ""), "This invite code is invalid or has already been used")
+
+	counts, err := app.users.Counts()
+	assert.NilError(t, err)
+	assert.Equal(t, counts.Total, 2)
+}
+
+func TestAdminUsers(t *testing.T) {
+	app := newTestApplication(t)
+
+	// With Alice there are 25 users: two pages, the second holding 5.
+	for i := range 24 {
+		err := app.users.Insert(fmt.Sprintf("User %02d", i), fmt.Sprintf("user%02d@example.org", i), "pa$$word", false)
+		assert.NilError(t, err)
+	}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	tests := []struct {
+		name      string
+		urlPath   string
+		wantBody  []string
+		wantNoRow []string
+	}{
+		{
+			name:      "Email substring",
+			urlPath:   "/admin/users?q=ALICE%40example",
+			wantBody:  []string{"<td>alice@example.com</td>", "<p>1 user</p>"},
+			wantNoRow: []string{"user00@example.org"},
+		},
+		{
+			name:      "First page",
+			urlPath:   "/admin/users",
+			wantBody:  []string{"<p>25 users</p>", "<td>alice@example.com</td>", "<td>user18@example.org</td>", "<strong>1</strong>"},
+			wantNoRow: []string{"user19@example.org"},
+		},
+		{
+			name:      "Second page",
+			urlPath:   "/admin/users?page=2",
+			wantBody:  []string{"<td>user19@example.org</td>", "<td>user23@example.org</td>", "<strong>2</strong>"},
+			wantNoRow: []string{"alice@example.com", "user18@example.org"},
+		},
+		{
+			name:      "Page past the end",
+			urlPath:   "/admin/users?page=99",
+			wantBody:  []string{"<td>user19@example.org</td>", "<strong>2</strong>"},
+			wantNoRow: []string{"user18@example.org"},
+		},
+		{
+			name:      "Page before the start",
+			urlPath:   "/admin/users?page=-3",
+			wantBody:  []string{"<td>alice@example.com</td>", "<strong>1</strong>"},
+			wantNoRow: []string{"user19@example.org"},
+		},
+		{
+			name:      "Search paginates",
+			urlPath:   "/admin/users?q=example.org&page=2",
+			wantBody:  []string{"<p>24 users</p>", "<td>user20@example.org</td>", "<a href='/admin/users?q=example.org&amp;sort=id&amp;page_size=20&amp;page=1'>1</a>"},
+			wantNoRow: []string{"user19@example.org"},
+		},
+		{
+			name:      "Page size",
+			urlPath:   "/admin/users?page_size=5&page=5",
+			wantBody:  []string{"<td>user19@example.org</td>", "<td>user23@example.org</td>", "<strong>5</strong>"},
+			wantNoRow: []string{"user18@example.org"},
+		},
+		{
+			name:      "Descending",
+			urlPath:   "/admin/users?sort=-id&page_size=2",
+			wantBody:  []string{"<td>user23@example.org</td>", "<td>user22@example.org</td>"},
+			wantNoRow: []string{"alice@example.com", "user21@example.org"},
+		},
+		{
+			name:     "No match",
+			urlPath:  "/admin/users?q=nobody",
+			wantBody: []string{"No users match."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, http.StatusOK)
+			for _, want := range tt.wantBody {
+				assert.StringContains(t, body, want)
+			}
+			for _, unwanted := range tt.wantNoRow {
+				assert.Equal(t, strings.Contains(body, unwanted), false)
+			}
+			assert.Equal(t, strings.Contains(body, "$2a$"), false)
+		})
+	}
+
+	code, _, body := ts.get(t, "/admin/users?sort=hashed_password")
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This field must be one of id, -id")
+
+	code, _, body = ts.get(t, "/admin/users?page_size=1000")
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This field must be between 1 and 50")
+
+	app.users = &nonAdminUserModel{}
+
+	code, _, _ = ts.get(t, "/admin/users")
+	assert.Equal(t, code, http.StatusForbidden)
+}
@@ -0,0 +1,589 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"time"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/models"
+	"github.com/Vadim-Makhnev/snippetbox/internal/validator"
+)
+
+type inboundEmailPayload struct {
+	From    string `json:"from"`
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+}
+
+// inboundEmail godoc
+// @Summary      Create snippet from inbound email
+// @Description  Accept a parsed inbound email from the mail provider webhook and create a snippet from its subject and body, attributed to the registered user matching the sender address.
+// @Tags         api
+// @Accept       json
+// @Produce      json
+// @Param        X-Inbound-Secret header string true "Shared webhook secret"
+// @Param        payload body inboundEmailPayload true "Parsed inbound email"
+// @Success      201 {object} map[string]int "ID of the created snippet"
+// @Failure      400 {object} problemDetails "Bad request - malformed JSON"
+// @Failure      401 {object} problemDetails "Unauthorized - missing or invalid webhook secret"
+// @Failure      403 {object} problemDetails "Forbidden - unknown sender"
+// @Failure      404 {object} problemDetails "Inbound email is not configured"
+// @Failure      422 {object} problemDetails "Unprocessable entity - validation failed"
+// @Failure      500 {object} problemDetails "Internal server error"
+// @Router       /api/v1/inbound [post]
+func (app *application) inboundEmail(w http.ResponseWriter, r *http.Request) {
+	if app.config.inboundSecret == "" {
+		app.problem(w, r, http.StatusNotFound, "the requested resource could not be found")
+		return
+	}
+
+	secret := r.Header.Get("X-Inbound-Secret")
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(app.config.inboundSecret)) != 1 {
+		app.problem(w, r, http.StatusUnauthorized, "invalid or missing webhook secret")
+		return
+	}
+
+	var payload inboundEmailPayload
+
+	err := app.readJSON(w, r, &payload)
+	if err != nil {
+		app.problem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	payload.Subject = app.normalizeTitle(payload.Subject)
+
+	var v validator.Validator
+
+	sender, err := mail.ParseAddress(payload.From)
+	v.CheckField(err == nil, "from", "This field must be a valid email address")
+	v.CheckField(validator.NotBlank(payload.Subject), "subject", "This field cannot be blank")
+	v.CheckField(validator.MaxChars(payload.Subject, 100), "subject", "This field cannot be more than 100 characters long")
+	v.CheckField(validator.NotBlank(payload.Text), "text", "This field cannot be blank")
+	v.CheckField(validator.NoControlChars(payload.Text), "text", "This field cannot contain control characters")
+
+	if !v.Valid() {
+		app.validationProblem(w, r, v.FieldErrors)
+		return
+	}
+
+	user, err := app.users.GetByEmail(sender.Address)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.problem(w, r, http.StatusForbidden, "unknown sender")
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	id, err := app.snippets.Insert(user.ID, payload.Subject, payload.Text, app.snippetLanguage("", payload.Text), false, app.defaultExpiry())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.webhooks.dispatch(webhookEvent{Event: eventSnippetCreated, SnippetID: id, UserID: user.ID, Title: payload.Subject})
+
+	headers := make(http.Header)
+	headers.Set("Location", "/snippet/view/"+snippetIDs.encode(id))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"id": id}, headers)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// apiSnippetList godoc
+// @Summary      List snippets
+// @Description  Retrieve live snippets newest first using cursor pagination. Pass the returned next_cursor as the after parameter to fetch the next page; next_cursor is null on the last page.
+// @Tags         api
+// @Produce      json
+// @Param        after query int false "Return snippets with an ID lower than this cursor"
+// @Param        limit query int false "Maximum number of snippets to return, within -page-size-api (1-100 by default)" default(20)
+// @Success      200 {object} map[string]any "Snippets and the next cursor"
+// @Failure      422 {object} problemDetails "Unprocessable entity - invalid cursor or limit"
+// @Failure      429 {object} problemDetails "Too many requests - rate limit exceeded"
+// @Failure      503 {object} problemDetails "Service unavailable - global rate limit exceeded, see Retry-After"
+// @Failure      500 {object} problemDetails "Internal server error"
+// @Router       /api/v1/snippets [get]
+func (app *application) apiSnippetList(w http.ResponseWriter, r *http.Request) {
+	var v validator.Validator
+
+	qs := r.URL.Query()
+
+	limits := app.config.pageSizes.api
+
+	after := app.readInt(qs, "after", 0, &v)
+	limit := app.readInt(qs, "limit", limits.Default, &v)
+
+	v.CheckField(after >= 0, "after", "This field must not be negative")
+	v.CheckField(limits.Allows(limit), "limit", limits.Message())
+
+	if !v.Valid() {
+		app.validationProblem(w, r, v.FieldErrors)
+		return
+	}
+
+	snippets, err := app.snippets.LatestAfter(after, limit+1)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	var nextCursor *int
+
+	if len(snippets) > limit {
+		snippets = snippets[:limit]
+		nextCursor = &snippets[limit-1].ID
+	}
+
+	if snippets == nil {
+		snippets = []models.Snippet{}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"snippets": snippets, "next_cursor": nextCursor}, nil)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+type snippetAuthor struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type snippetResponse struct {
+	models.Snippet
+	Slug   string         `json:"slug"`
+	Author *snippetAuthor `json:"author"`
+}
+
+// apiSnippetGet godoc
+// @Summary      Get snippet by id
+// @Description  Retrieve a live snippet with a slug of its title, of at most -slug-max-length characters, and a minimal block about its author, which is null for anonymous snippets. Private snippets are only returned to their owner; anyone else gets 404, as for missing and expired snippets.
+// @Tags         api
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Snippet ID, or its opaque code when -obfuscate-ids is set"
+// @Success      200 {object} map[string]any "The snippet and its author"
+// @Failure      404 {object} problemDetails "Snippet not found"
+// @Failure      429 {object} problemDetails "Too many requests - rate limit exceeded"
+// @Failure      503 {object} problemDetails "Service unavailable - global rate limit exceeded, see Retry-After"
+// @Failure      500 {object} problemDetails "Internal server error"
+// @Router       /api/v1/snippets/{id} [get]
+func (app *application) apiSnippetGet(w http.ResponseWriter, r *http.Request) {
+	id, ok := snippetIDs.decode(r.PathValue("id"))
+	if !ok {
+		app.problem(w, r, http.StatusNotFound, "snippet not found")
+		return
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.problem(w, r, http.StatusNotFound, "snippet not found")
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if snippet.Private && snippet.UserID != app.authenticatedUserID(r) {
+		app.problem(w, r, http.StatusNotFound, "snippet not found")
+		return
+	}
+
+	resp := snippetResponse{
+		Snippet: snippet,
+		Slug:    slugify(snippet.Title, "snippet-"+snippetIDs.encode(snippet.ID), app.config.slugMaxLength),
+	}
+
+	if snippet.UserID != 0 {
+		user, err := app.users.Get(snippet.UserID)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
+			return
+		}
+
+		if err == nil {
+			resp.Author = &snippetAuthor{ID: user.ID, Name: user.Name}
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"snippet": resp}, nil)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+type snippetCreateRequest struct {
+	Title    string   `json:"title"`
+	Content  string   `json:"content"`
+	Language string   `json:"language"`
+	Private  bool     `json:"private"`
+	Tags     []string `json:"tags"`
+	Expires  int      `json:"expires"`
+}
+
+// apiSnippetCreate godoc
+// @Summary      Create snippet
+// @Description  Create a new snippet for the authenticated user. Sending an Idempotency-Key header makes retries safe: a replayed key returns the originally created snippet ID instead of creating a duplicate.
+// @Tags         api
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        Idempotency-Key header string false "Unique key identifying this create request"
+// @Param        payload body snippetCreateRequest true "Snippet to create"
+// @Success      201 {object} map[string]int "ID of the created snippet"
+// @Failure      400 {object} problemDetails "Bad request - malformed JSON"
+// @Failure      401 {object} problemDetails "Unauthorized - missing or invalid token"
+// @Failure      422 {object} problemDetails "Unprocessable entity - validation failed"
+// @Failure      429 {object} problemDetails "Too many requests - rate limit exceeded"
+// @Failure      503 {object} problemDetails "Service unavailable - global rate limit exceeded, see Retry-After"
+// @Failure      500 {object} problemDetails "Internal server error"
+// @Router       /api/v1/snippets [post]
+func (app *application) apiSnippetCreate(w http.ResponseWriter, r *http.Request) {
+	var input snippetCreateRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.problem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	input.Title = app.normalizeTitle(input.Title)
+
+	var v validator.Validator
+
+	v.CheckField(validator.NotBlank(input.Title), "title", "This field cannot be blank")
+	v.CheckField(validator.MaxChars(input.Title, 100), "title", "This field cannot be more than 100 characters long")
+	v.CheckField(validator.NotBlank(input.Content), "content", "This field cannot be blank")
+	v.CheckField(validator.NoControlChars(input.Content), "content", "This field cannot contain control characters")
+	v.CheckField(app.permittedLanguage(input.Language), "language", "This field must be one of the configured languages")
+	v.CheckField(validator.PermittedValue(input.Expires, app.expiryDays()...), "expires", app.expiryMessage())
+
+	tags := normalizeTags(input.Tags)
+	app.checkTags(&v, tags)
+
+	if !v.Valid() {
+		app.validationProblem(w, r, v.FieldErrors)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	input.Language = app.snippetLanguage(input.Language, input.Content)
+
+	// The snippet and its tags are saved together, so a failure can't leave
+	// an untagged snippet behind for an idempotent retry to duplicate.
+	insert := func() (int, error) {
+		tx, err := app.db.BeginTx(r.Context(), nil)
+		if err != nil {
+			return 0, err
+		}
+
+		defer tx.Rollback()
+
+		snippets := app.snippets.WithTx(tx)
+
+		id, err := snippets.Insert(userID, input.Title, input.Content, input.Language, input.Private, input.Expires)
+		if err != nil {
+			return 0, err
+		}
+
+		if len(tags) > 0 {
+			err = snippets.SetTags(id, tags)
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return 0, err
+		}
+
+		return id, nil
+	}
+
+	var id int
+	var replayed bool
+
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		id, replayed, err = app.idempotency.do(fmt.Sprintf("%d:%s", userID, key), insert)
+	} else {
+		id, err = insert()
+	}
+
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", "/snippet/view/"+snippetIDs.encode(id))
+	if replayed {
+		headers.Set("Idempotent-Replayed", "true")
+	} else {
+		app.webhooks.dispatch(webhookEvent{Event: eventSnippetCreated, SnippetID: id, UserID: userID, Title: input.Title})
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"id": id}, headers)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+type userSignupRequest struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Invite   string `json:"invite,omitempty"`
+	Terms    bool   `json:"terms,omitempty"`
+}
+
+// apiUserSignup godoc
+// @Summary      Register new user
+// @Description  Create a new user account, with the same rules as the signup form. Validation failures, including an email that is already in use, are reported per field in the errors member.
+// @Tags         api
+// @Accept       json
+// @Produce      json
+// @Param        payload body userSignupRequest true "New user"
+// @Success      201 {object} map[string]any "The created user"
+// @Failure      400 {object} problemDetails "Bad request - malformed JSON"
+// @Failure      403 {object} problemDetails "Forbidden - signup is disabled with -signup-enabled=false"
+// @Failure      422 {object} problemDetails "Unprocessable entity - validation failed, duplicate email or invalid invite code"
+// @Failure      429 {object} problemDetails "Too many requests - rate limit exceeded"
+// @Failure      503 {object} problemDetails "Service unavailable - global rate limit exceeded, see Retry-After"
+// @Failure      500 {object} problemDetails "Internal server error"
+// @Router       /api/v1/users [post]
+func (app *application) apiUserSignup(w http.ResponseWriter, r *http.Request) {
+	if !app.config.signupEnabled {
+		app.problem(w, r, http.StatusForbidden, "registration is closed")
+		return
+	}
+
+	var input userSignupRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.problem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var v validator.Validator
+
+	app.checkSignup(&v, input.Name, input.Email, input.Password)
+	app.checkTerms(&v, input.Terms)
+
+	err = app.checkInvite(&v, input.Invite)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if !v.Valid() {
+		app.validationProblem(w, r, v.FieldErrors)
+		return
+	}
+
+	err = app.signup(r.Context(), input.Name, input.Email, input.Password, input.Invite, input.Terms)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			v.AddFieldError("invite", "This invite code is invalid or has already been used")
+		case errors.Is(err, models.ErrDuplicateEmail):
+			v.AddFieldError("email", "Email address is already in use")
+		default:
+			app.serverError(w, r, err)
+			return
+		}
+
+		app.validationProblem(w, r, v.FieldErrors)
+		return
+	}
+
+	user, err := app.users.GetByEmail(input.Email)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"user": user}, nil)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+type authenticationTokenRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// apiCreateAuthenticationToken godoc
+// @Summary      Create authentication token
+// @Description  Exchange user credentials for a bearer token used to authenticate API requests.
+// @Tags         api
+// @Accept       json
+// @Produce      json
+// @Param        payload body authenticationTokenRequest true "User credentials"
+// @Success      201 {object} map[string]any "Authentication token and its expiry"
+// @Failure      400 {object} problemDetails "Bad request - malformed JSON"
+// @Failure      401 {object} problemDetails "Unauthorized - invalid credentials"
+// @Failure      422 {object} problemDetails "Unprocessable entity - validation failed"
+// @Failure      429 {object} problemDetails "Too many requests - rate limit exceeded"
+// @Failure      503 {object} problemDetails "Service unavailable - global rate limit exceeded, see Retry-After"
+// @Failure      500 {object} problemDetails "Internal server error"
+// @Router       /api/v1/tokens/authentication [post]
+func (app *application) apiCreateAuthenticationToken(w http.ResponseWriter, r *http.Request) {
+	var input authenticationTokenRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.problem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var v validator.Validator
+
+	v.CheckField(validator.NotBlank(input.Email), "email", "This field cannot be blank")
+	v.CheckField(validator.Matches(input.Email, validator.EmailRX), "email", "This field must be a valid email address")
+	v.CheckField(validator.NotBlank(input.Password), "password", "This field cannot be blank")
+
+	if !v.Valid() {
+		app.validationProblem(w, r, v.FieldErrors)
+		return
+	}
+
+	id, err := app.users.Authenticate(input.Email, input.Password)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			app.problem(w, r, http.StatusUnauthorized, "invalid authentication credentials")
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	token, err := app.tokens.New(id, 24*time.Hour, models.ScopeAuthentication)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"authentication_token": token}, nil)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+type draftSaveRequest struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	Expires int    `json:"expires"`
+}
+
+// apiDraftGet godoc
+// @Summary      Get draft
+// @Description  Retrieve the authenticated user's saved snippet draft. Requires a session; the create form uses it to restore unsaved work.
+// @Tags         api
+// @Produce      json
+// @Success      200 {object} map[string]any "Saved draft"
+// @Failure      401 {object} problemDetails "Unauthorized - not logged in"
+// @Failure      404 {object} problemDetails "No draft saved"
+// @Failure      429 {object} problemDetails "Too many requests - rate limit exceeded"
+// @Failure      503 {object} problemDetails "Service unavailable - global rate limit exceeded, see Retry-After"
+// @Failure      500 {object} problemDetails "Internal server error"
+// @Router       /api/v1/drafts [get]
+func (app *application) apiDraftGet(w http.ResponseWriter, r *http.Request) {
+	draft, err := app.drafts.Get(app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.problem(w, r, http.StatusNotFound, "no draft saved")
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"draft": draft}, nil)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// apiDraftSave godoc
+// @Summary      Save draft
+// @Description  Save the authenticated user's in-progress snippet, replacing any earlier draft. Drafts may be incomplete, so only lengths and the expiry value are checked. The draft is cleared once a snippet is created through the form.
+// @Tags         api
+// @Accept       json
+// @Produce      json
+// @Param        X-CSRF-Token header string true "CSRF token from the create form"
+// @Param        payload body draftSaveRequest true "Draft to save"
+// @Success      200 {object} map[string]any "Saved draft"
+// @Failure      400 {object} problemDetails "Bad request - malformed JSON or CSRF failure"
+// @Failure      401 {object} problemDetails "Unauthorized - not logged in"
+// @Failure      422 {object} problemDetails "Unprocessable entity - validation failed"
+// @Failure      429 {object} problemDetails "Too many requests - rate limit exceeded"
+// @Failure      503 {object} problemDetails "Service unavailable - global rate limit exceeded, see Retry-After"
+// @Failure      500 {object} problemDetails "Internal server error"
+// @Router       /api/v1/drafts [post]
+func (app *application) apiDraftSave(w http.ResponseWriter, r *http.Request) {
+	var input draftSaveRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.problem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var v validator.Validator
+
+	v.CheckField(validator.MaxChars(input.Title, 100), "title", "This field cannot be more than 100 characters long")
+	v.CheckField(input.Expires == 0 || validator.PermittedValue(input.Expires, app.expiryDays()...), "expires", app.expiryMessage())
+
+	if !v.Valid() {
+		app.validationProblem(w, r, v.FieldErrors)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	err = app.drafts.Upsert(userID, input.Title, input.Content, input.Expires)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	draft, err := app.drafts.Get(userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"draft": draft}, nil)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// apiExpiryOptions godoc
+// @Summary      List expiry options
+// @Description  List the snippet lifetimes the server accepts, so clients can build their expiry choices without hardcoding them.
+// @Tags         api
+// @Produce      json
+// @Success      200 {array} expiryOption "Configured expiry options"
+// @Failure      429 {object} problemDetails "Too many requests - rate limit exceeded"
+// @Failure      503 {object} problemDetails "Service unavailable - global rate limit exceeded, see Retry-After"
+// @Failure      500 {object} problemDetails "Internal server error"
+// @Router       /api/v1/meta/expiry-options [get]
+func (app *application) apiExpiryOptions(w http.ResponseWriter, r *http.Request) {
+	err := app.writeJSON(w, http.StatusOK, app.config.expiryOptions, nil)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
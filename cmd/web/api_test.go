@@ -0,0 +1,526 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/assert"
+	"github.com/Vadim-Makhnev/snippetbox/internal/models"
+	"github.com/Vadim-Makhnev/snippetbox/internal/models/mocks"
+)
+
+func TestInboundEmail(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.inboundSecret = "s3cret"
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		secret   string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Known sender",
+			secret:   "s3cret",
+			body:     `{"from": "Alice <alice@example.com>", "subject": "Hello", "text": "An old silent pond..."}`,
+			wantCode: http.StatusCreated,
+			wantBody: `"id":2`,
+		},
+		{
+			name:     "Unknown sender",
+			secret:   "s3cret",
+			body:     `{"from": "mallory@example.com", "subject": "Hello", "text": "An old silent pond..."}`,
+			wantCode: http.StatusForbidden,
+			wantBody: "unknown sender",
+		},
+		{
+			name:     "Invalid secret",
+			secret:   "wrong",
+			body:     `{"from": "alice@example.com", "subject": "Hello", "text": "An old silent pond..."}`,
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "Missing subject",
+			secret:   "s3cret",
+			body:     `{"from": "alice@example.com", "subject": "", "text": "An old silent pond..."}`,
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "subject",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			header.Set("X-Inbound-Secret", tt.secret)
+
+			code, _, body := ts.postJSON(t, "/api/v1/inbound", header, tt.body)
+
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+// manySnippetModel lists as many large snippets as are asked for.
+type manySnippetModel struct {
+	mocks.SnippetModel
+}
+
+func (m *manySnippetModel) LatestAfter(after, limit int) ([]models.Snippet, error) {
+	snippets := make([]models.Snippet, limit)
+	for i := range snippets {
+		snippets[i] = models.Snippet{ID: i + 1, Title: "Big", Content: strings.Repeat("x", 10_000)}
+	}
+
+	return snippets, nil
+}
+
+func TestMaxResponseBody(t *testing.T) {
+	app := newTestApplication(t)
+	app.snippets = &manySnippetModel{}
+	app.config.maxResponseBody = 100_000
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/api/v1/snippets?limit=5")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(body) <= 100_000, true)
+
+	code, header, body := ts.get(t, "/api/v1/snippets?limit=100")
+	assert.Equal(t, code, http.StatusInternalServerError)
+	assert.Equal(t, header.Get("Content-Type"), "application/problem+json")
+	assert.StringContains(t, body, `"detail":"response too large"`)
+	assert.Equal(t, len(body) < 1000, true)
+
+	app.config.maxResponseBody = 0
+
+	code, _, body = ts.get(t, "/api/v1/snippets?limit=100")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(body) > 1_000_000, true)
+}
+
+func TestAPISnippetListCursor(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	seen := map[int]int{}
+	path := "/api/v1/snippets?limit=1"
+
+	for range 10 {
+		code, _, body := ts.get(t, path)
+		assert.Equal(t, code, http.StatusOK)
+
+		var page struct {
+			Snippets   []models.Snippet `json:"snippets"`
+			NextCursor *int             `json:"next_cursor"`
+		}
+
+		err := json.Unmarshal([]byte(body), &page)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, s := range page.Snippets {
+			seen[s.ID]++
+		}
+
+		if page.NextCursor == nil {
+			break
+		}
+
+		path = fmt.Sprintf("/api/v1/snippets?limit=1&after=%d", *page.NextCursor)
+	}
+
+	assert.Equal(t, len(seen), 2)
+	assert.Equal(t, seen[1], 1)
+	assert.Equal(t, seen[3], 1)
+
+	code, _, _ := ts.get(t, "/api/v1/snippets?limit=0")
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	code, _, _ = ts.get(t, "/api/v1/snippets?after=abc")
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+}
+
+type insertCountingSnippetModel struct {
+	mocks.SnippetModel
+	mu      sync.Mutex
+	inserts int
+}
+
+func (m *insertCountingSnippetModel) WithTx(tx *sql.Tx) models.SnippetModelInterface {
+	return m
+}
+
+func (m *insertCountingSnippetModel) Insert(userID int, title, content, language string, private bool, expires int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.inserts++
+	return 100 + m.inserts, nil
+}
+
+func TestAPISnippetCreateIdempotency(t *testing.T) {
+	app := newTestApplication(t)
+
+	snippets := &insertCountingSnippetModel{}
+	app.snippets = snippets
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	const body = `{"title": "O snail", "content": "Climb Mount Fuji,", "expires": 7}`
+
+	code, _, _ := ts.postJSON(t, "/api/v1/snippets", nil, body)
+	assert.Equal(t, code, http.StatusUnauthorized)
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+mocks.AliceToken)
+	header.Set("Idempotency-Key", "7c4a8d09")
+
+	code, _, first := ts.postJSON(t, "/api/v1/snippets", header, body)
+	assert.Equal(t, code, http.StatusCreated)
+
+	code, replayHeader, second := ts.postJSON(t, "/api/v1/snippets", header, body)
+	assert.Equal(t, code, http.StatusCreated)
+	assert.Equal(t, replayHeader.Get("Idempotent-Replayed"), "true")
+
+	assert.Equal(t, second, first)
+	assert.Equal(t, snippets.inserts, 1)
+
+	header.Set("Idempotency-Key", "9f86d081")
+
+	code, _, third := ts.postJSON(t, "/api/v1/snippets", header, body)
+	assert.Equal(t, code, http.StatusCreated)
+	assert.Equal(t, third == first, false)
+	assert.Equal(t, snippets.inserts, 2)
+}
+
+func TestAPICreateAuthenticationToken(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.postJSON(t, "/api/v1/tokens/authentication", nil, `{"email": "alice@example.com", "password": "pa$$word"}`)
+	assert.Equal(t, code, http.StatusCreated)
+	assert.StringContains(t, body, mocks.AliceToken)
+
+	code, _, _ = ts.postJSON(t, "/api/v1/tokens/authentication", nil, `{"email": "alice@example.com", "password": "wrong"}`)
+	assert.Equal(t, code, http.StatusUnauthorized)
+}
+
+func TestAPIDrafts(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, _ := ts.get(t, "/api/v1/drafts")
+	assert.Equal(t, code, http.StatusUnauthorized)
+
+	ts.login(t)
+
+	code, _, _ = ts.get(t, "/api/v1/drafts")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	_, _, body := ts.get(t, "/snippet/create")
+
+	header := http.Header{}
+	header.Set("X-CSRF-Token", extractCSRFToken(t, body))
+
+	code, _, _ = ts.postJSON(t, "/api/v1/drafts", header, `{"title": "First draft", "content": "", "expires": 0}`)
+	assert.Equal(t, code, http.StatusOK)
+
+	code, _, _ = ts.postJSON(t, "/api/v1/drafts", header, `{"title": "O snail", "content": "Climb Mount Fuji,", "expires": 7}`)
+	assert.Equal(t, code, http.StatusOK)
+
+	code, _, body = ts.get(t, "/api/v1/drafts")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `"title":"O snail"`)
+	assert.StringContains(t, body, `"expires":7`)
+
+	code, _, _ = ts.postJSON(t, "/api/v1/drafts", header, `{"title": "O snail", "content": "", "expires": 3}`)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	_, _, body = ts.get(t, "/snippet/create")
+	assert.StringContains(t, body, "<input type='text' name='title' value='O snail'>")
+	assert.StringContains(t, body, "<textarea name='content'>Climb Mount Fuji,</textarea>")
+	assert.StringContains(t, body, "<input type='radio' name='expires' value='7' checked>")
+
+	matches := formTokenRX.FindStringSubmatch(body)
+	if len(matches) < 2 {
+		t.Fatal("no form token found in body")
+	}
+
+	form := url.Values{}
+	form.Add("title", "O snail")
+	form.Add("content", "Climb Mount Fuji,")
+	form.Add("expires", "7")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	form.Add("form_token", matches[1])
+
+	code, _, _ = ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	code, _, _ = ts.get(t, "/api/v1/drafts")
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestAPIExpiryOptions(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.expiryOptions = []expiryOption{
+		{Days: 3, Label: "Three days"},
+		{Days: 30, Label: "One month"},
+	}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/api/v1/meta/expiry-options")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body, `[{"days":3,"label":"Three days"},{"days":30,"label":"One month"}]`+"\n")
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+mocks.AliceToken)
+
+	code, _, _ = ts.postJSON(t, "/api/v1/snippets", header, `{"title": "O snail", "content": "Climb Mount Fuji,", "expires": 30}`)
+	assert.Equal(t, code, http.StatusCreated)
+
+	code, _, body = ts.postJSON(t, "/api/v1/snippets", header, `{"title": "O snail", "content": "Climb Mount Fuji,", "expires": 7}`)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This field must equal 3 or 30")
+}
+
+func TestParseExpiryOptions(t *testing.T) {
+	options, err := parseExpiryOptions("1:One day, 30:One month")
+	assert.NilError(t, err)
+	assert.Equal(t, len(options), 2)
+	assert.Equal(t, options[1], expiryOption{Days: 30, Label: "One month"})
+
+	for _, s := range []string{"", "7", "x:Week", "0:Never"} {
+		_, err := parseExpiryOptions(s)
+		assert.Equal(t, err != nil, true)
+	}
+}
+
+func TestAPIProblemDetails(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantErrors []string
+	}{
+		{
+			name:       "Not found",
+			path:       "/api/v1/drafts",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Validation failed",
+			path:       "/api/v1/snippets?limit=0&after=-1",
+			wantStatus: http.StatusUnprocessableEntity,
+			wantErrors: []string{"after", "limit"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.get(t, tt.path)
+
+			assert.Equal(t, code, tt.wantStatus)
+			assert.Equal(t, header.Get("Content-Type"), "application/problem+json")
+
+			var problem problemDetails
+
+			err := json.Unmarshal([]byte(body), &problem)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, problem.Type, "about:blank")
+			assert.Equal(t, problem.Title, http.StatusText(tt.wantStatus))
+			assert.Equal(t, problem.Status, tt.wantStatus)
+			assert.Equal(t, problem.Detail != "", true)
+			assert.Equal(t, problem.Instance, strings.Split(tt.path, "?")[0])
+			assert.Equal(t, len(problem.Errors), len(tt.wantErrors))
+
+			for _, field := range tt.wantErrors {
+				assert.Equal(t, problem.Errors[field] != "", true)
+			}
+		})
+	}
+}
+
+func TestAPIUserSignup(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Duplicate email", func(t *testing.T) {
+		code, _, body := ts.postJSON(t, "/api/v1/users", nil, `{"name": "Alice", "email": "Alice@Example.com", "password": "validPa$$word"}`)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+		var problem problemDetails
+
+		err := json.Unmarshal([]byte(body), &problem)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, problem.Errors["email"], "Email address is already in use")
+		assert.Equal(t, len(problem.Errors), 1)
+	})
+
+	t.Run("Invalid fields", func(t *testing.T) {
+		code, _, body := ts.postJSON(t, "/api/v1/users", nil, `{"name": "Bob", "email": "bob@example.", "password": "pa$$"}`)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+		var problem problemDetails
+
+		err := json.Unmarshal([]byte(body), &problem)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, problem.Errors["email"] != "", true)
+		assert.Equal(t, problem.Errors["password"] != "", true)
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		code, _, body := ts.postJSON(t, "/api/v1/users", nil, `{"name": "Bob", "email": "Bob@Example.com", "password": "validPa$$word"}`)
+		assert.Equal(t, code, http.StatusCreated)
+
+		var resp map[string]map[string]any
+
+		err := json.Unmarshal([]byte(body), &resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		user := resp["user"]
+		assert.Equal(t, user["name"], "Bob")
+		assert.Equal(t, user["email"], "bob@example.com")
+
+		for _, field := range []string{"HashedPassword", "hashed_password", "password"} {
+			_, ok := user[field]
+			assert.Equal(t, ok, false)
+		}
+		assert.Equal(t, strings.Contains(strings.ToLower(body), "password"), false)
+	})
+}
+
+func TestParseLanguages(t *testing.T) {
+	languages, err := parseLanguages("Go, zig,,go, c++")
+	assert.NilError(t, err)
+	assert.Equal(t, strings.Join(languages, ","), "go,zig,c++")
+
+	for _, s := range []string{"", " , ", "visual basic"} {
+		_, err := parseLanguages(s)
+		assert.Equal(t, err != nil, true)
+	}
+}
+
+func TestAPISnippetGet(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	get := func(t *testing.T, urlPath, token string) (int, string) {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+urlPath, nil)
+		assert.NilError(t, err)
+
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		rs, err := ts.Client().Do(req)
+		assert.NilError(t, err)
+		defer rs.Body.Close()
+
+		body, err := io.ReadAll(rs.Body)
+		assert.NilError(t, err)
+
+		return rs.StatusCode, string(body)
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		code, body := get(t, "/api/v1/snippets/1", "")
+		assert.Equal(t, code, http.StatusOK)
+
+		var resp struct {
+			Snippet struct {
+				ID      int    `json:"id"`
+				Title   string `json:"title"`
+				Content string `json:"content"`
+				Slug    string `json:"slug"`
+				Author  *struct {
+					ID   int    `json:"id"`
+					Name string `json:"name"`
+				} `json:"author"`
+			} `json:"snippet"`
+		}
+
+		err := json.Unmarshal([]byte(body), &resp)
+		assert.NilError(t, err)
+
+		assert.Equal(t, resp.Snippet.ID, 1)
+		assert.Equal(t, resp.Snippet.Title, "An old silent pond")
+		assert.Equal(t, resp.Snippet.Content, "An old silent pond...")
+		assert.Equal(t, resp.Snippet.Slug, "an-old-silent-pond")
+		assert.Equal(t, resp.Snippet.Author != nil, true)
+		assert.Equal(t, resp.Snippet.Author.ID, 1)
+		assert.Equal(t, resp.Snippet.Author.Name, "Alice")
+
+		// The author block is minimal.
+		assert.Equal(t, strings.Contains(body, "alice@example.com"), false)
+	})
+
+	t.Run("Private to its owner", func(t *testing.T) {
+		code, body := get(t, "/api/v1/snippets/5", mocks.AliceToken)
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, `"content":"A private haiku..."`)
+	})
+
+	tests := []struct {
+		name    string
+		urlPath string
+	}{
+		{name: "Missing or expired", urlPath: "/api/v1/snippets/2"},
+		{name: "Private to others", urlPath: "/api/v1/snippets/5"},
+		{name: "Invalid ID", urlPath: "/api/v1/snippets/abc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := get(t, tt.urlPath, "")
+			assert.Equal(t, code, http.StatusNotFound)
+			assert.StringContains(t, body, "snippet not found")
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/Vadim-Makhnev/snippetbox/ui"
+)
+
+// fingerprintedExts lists the asset types that get a content hash in their
+// URL. Only these are safe to cache forever, since any change to the file
+// changes its URL.
+var fingerprintedExts = []string{".css", ".js"}
+
+// assetManifest maps logical asset names, such as "main.css", to URLs with a
+// content hash in the filename, such as "/static/css/main.1a2b3c4d.css". It
+// is also an fs.FS that opens the fingerprinted names as the original files.
+type assetManifest struct {
+	fsys  fs.FS
+	urls  map[string]string
+	paths map[string]string
+}
+
+// assets serves the bundled static files. It is replaced at startup when
+// -static-dir is set.
+var assets = mustStaticAssets()
+
+func mustStaticAssets() *assetManifest {
+	m, err := newStaticAssets(nil)
+	if err != nil {
+		panic(err)
+	}
+
+	return m
+}
+
+// newStaticAssets returns the manifest for the bundled static files, with
+// the given directories layered on top in priority order. The first
+// directory containing a file wins, so a deployment can override bundled
+// assets without rebuilding.
+func newStaticAssets(dirs []string) (*assetManifest, error) {
+	var layers layeredFS
+
+	for _, dir := range dirs {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("assets: %s is not a directory", dir)
+		}
+
+		layers = append(layers, os.DirFS(dir))
+	}
+
+	static, err := fs.Sub(ui.Files, "static")
+	if err != nil {
+		return nil, err
+	}
+
+	return newAssetManifest(append(layers, static))
+}
+
+// layeredFS is a read-only union of file systems. A name resolves to the
+// first layer that has it, so earlier layers shadow later ones.
+type layeredFS []fs.FS
+
+func (l layeredFS) Open(name string) (fs.File, error) {
+	for _, fsys := range l {
+		f, err := fsys.Open(name)
+		if err == nil {
+			return f, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadDir merges the directory across all layers. An entry in an earlier
+// layer hides any entry with the same name in later ones.
+func (l layeredFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	var entries []fs.DirEntry
+	seen := make(map[string]bool)
+	found := false
+
+	for _, fsys := range l {
+		des, err := fs.ReadDir(fsys, name)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+
+		found = true
+
+		for _, de := range des {
+			if !seen[de.Name()] {
+				seen[de.Name()] = true
+				entries = append(entries, de)
+			}
+		}
+	}
+
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+
+	return entries, nil
+}
+
+// newAssetManifest hashes every fingerprinted asset in fsys, which must be
+// rooted at the static directory.
+func newAssetManifest(fsys fs.FS) (*assetManifest, error) {
+	m := &assetManifest{
+		fsys:  fsys,
+		urls:  make(map[string]string),
+		paths: make(map[string]string),
+	}
+
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		ext := path.Ext(p)
+		if d.IsDir() || !slices.Contains(fingerprintedExts, ext) {
+			return nil
+		}
+
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(data)
+		hashed := strings.TrimSuffix(p, ext) + "." + hex.EncodeToString(sum[:4]) + ext
+
+		name := path.Base(p)
+		if _, ok := m.urls[name]; ok {
+			return fmt.Errorf("assets: duplicate asset name %q", name)
+		}
+
+		m.urls[name] = "/static/" + hashed
+		m.paths[hashed] = p
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// url returns the fingerprinted URL for a logical asset name.
+func (m *assetManifest) url(name string) (string, error) {
+	u, ok := m.urls[name]
+	if !ok {
+		return "", fmt.Errorf("assets: unknown asset %q", name)
+	}
+
+	return u, nil
+}
+
+// assetURL is the asset template function. It looks assets up at call time,
+// so it follows the manifest set up at startup.
+func assetURL(name string) (string, error) {
+	return assets.url(name)
+}
+
+func (m *assetManifest) Open(name string) (fs.File, error) {
+	if p, ok := m.paths[name]; ok {
+		name = p
+	}
+
+	return m.fsys.Open(name)
+}
+
+// fileServer serves the static directory, marking fingerprinted URLs as
+// immutable. It expects the /static prefix to have been stripped.
+func (m *assetManifest) fileServer() http.Handler {
+	fileServer := http.FileServerFS(m)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := m.paths[strings.TrimPrefix(r.URL.Path, "/")]; ok {
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		}
+
+		fileServer.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/assert"
+	"github.com/Vadim-Makhnev/snippetbox/ui"
+)
+
+func TestAsset(t *testing.T) {
+	url, err := assets.url("main.css")
+	assert.NilError(t, err)
+
+	if !regexp.MustCompile(`^/static/css/main\.[0-9a-f]{8}\.css$`).MatchString(url) {
+		t.Fatalf("got %q; want a fingerprinted main.css URL", url)
+	}
+
+	got, err := fs.ReadFile(assets, strings.TrimPrefix(url, "/static/"))
+	assert.NilError(t, err)
+
+	want, err := fs.ReadFile(ui.Files, "static/css/main.css")
+	assert.NilError(t, err)
+
+	assert.Equal(t, string(got), string(want))
+
+	_, err = assets.url("missing.css")
+	assert.Equal(t, err != nil, true)
+}
+
+func TestStaticFiles(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	url, err := assets.url("main.js")
+	assert.NilError(t, err)
+
+	code, header, _ := ts.get(t, url)
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Cache-Control"), "public, max-age=31536000, immutable")
+
+	code, header, _ = ts.get(t, "/static/img/logo.png")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Cache-Control"), "")
+
+	_, _, body := ts.get(t, "/")
+	assert.StringContains(t, body, "<script src='"+url+"'")
+}
+
+func TestStaticDirs(t *testing.T) {
+	override := t.TempDir()
+	theme := t.TempDir()
+
+	writeFile := func(dir, name, content string) {
+		t.Helper()
+
+		err := os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0o755)
+		assert.NilError(t, err)
+
+		err = os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644)
+		assert.NilError(t, err)
+	}
+
+	writeFile(override, "css/main.css", "body { color: red; }")
+	writeFile(theme, "css/main.css", "body { color: blue; }")
+	writeFile(theme, "img/theme.svg", "<svg></svg>")
+
+	m, err := newStaticAssets([]string{override, theme})
+	assert.NilError(t, err)
+
+	srv := httptest.NewServer(http.StripPrefix("/static", m.fileServer()))
+	defer srv.Close()
+
+	get := func(urlPath string) (int, string) {
+		t.Helper()
+
+		rs, err := srv.Client().Get(srv.URL + urlPath)
+		assert.NilError(t, err)
+		defer rs.Body.Close()
+
+		body, err := io.ReadAll(rs.Body)
+		assert.NilError(t, err)
+
+		return rs.StatusCode, string(body)
+	}
+
+	url, err := m.url("main.css")
+	assert.NilError(t, err)
+
+	code, body := get(url)
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body, "body { color: red; }")
+
+	code, body = get("/static/img/theme.svg")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body, "<svg></svg>")
+
+	// Files not overridden still come from the bundle.
+	url, err = m.url("main.js")
+	assert.NilError(t, err)
+
+	code, _ = get(url)
+	assert.Equal(t, code, http.StatusOK)
+
+	code, _ = get("/static/img/missing.png")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	_, err = newStaticAssets([]string{filepath.Join(override, "missing")})
+	assert.Equal(t, err != nil, true)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"expvar"
+	"sync/atomic"
+)
+
+// cacheMetrics holds the counters of every cache, published through expvar
+// under "caches" with one entry per cache name.
+var cacheMetrics = expvar.NewMap("caches")
+
+// cacheStats counts the hits and misses of one in-memory cache, to tell
+// whether the cache is effective and to tune its TTL.
+type cacheStats struct {
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// newCacheStats returns the counters for the named cache, published as
+// caches.<name> in expvar. Calling it again with the same name replaces the
+// published counters.
+func newCacheStats(name string) *cacheStats {
+	s := &cacheStats{}
+
+	cacheMetrics.Set(name, expvar.Func(func() any {
+		return s.snapshot()
+	}))
+
+	return s
+}
+
+func (s *cacheStats) hit() {
+	s.hits.Add(1)
+}
+
+func (s *cacheStats) miss() {
+	s.misses.Add(1)
+}
+
+func (s *cacheStats) snapshot() map[string]int64 {
+	return map[string]int64{
+		"hits":   s.hits.Load(),
+		"misses": s.misses.Load(),
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/assert"
+)
+
+func TestCacheStats(t *testing.T) {
+	s := newCacheStats("test")
+
+	s.hit()
+	assert.Equal(t, s.hits.Load(), int64(1))
+	assert.Equal(t, s.misses.Load(), int64(0))
+
+	s.miss()
+	s.miss()
+	assert.Equal(t, s.hits.Load(), int64(1))
+	assert.Equal(t, s.misses.Load(), int64(2))
+
+	var published map[string]map[string]int64
+	err := json.Unmarshal([]byte(cacheMetrics.String()), &published)
+	assert.NilError(t, err)
+	assert.Equal(t, published["test"]["hits"], int64(1))
+	assert.Equal(t, published["test"]["misses"], int64(2))
+}
+
+func TestAdminDebugVars(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	newCacheStats("debug-vars").hit()
+
+	code, header, _ := ts.get(t, "/admin/debug/vars")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/user/login")
+
+	ts.login(t)
+
+	code, header, body := ts.get(t, "/admin/debug/vars")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, header.Get("Content-Type"), "application/json")
+
+	var vars struct {
+		Caches map[string]map[string]int64 `json:"caches"`
+	}
+	err := json.Unmarshal([]byte(body), &vars)
+	assert.NilError(t, err)
+	assert.Equal(t, vars.Caches["debug-vars"]["hits"], int64(1))
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Vadim-Makhnev/snippetbox/migrations"
+	"github.com/go-sql-driver/mysql"
+)
+
+// smtpCheckTimeout bounds the smtp check's connection and greeting.
+const smtpCheckTimeout = 5 * time.Second
+
+// preflightCheck is one step of the -check startup mode.
+type preflightCheck struct {
+	name string
+	run  func() error
+}
+
+// preflightChecks returns the checks run by -check, in order. The database
+// checks share one connection, which the last check closes.
+func preflightChecks(cfg config) []preflightCheck {
+	var db *sql.DB
+
+	return []preflightCheck{
+		{
+			name: "dsn",
+			run: func() error {
+				_, err := mysql.ParseDSN(cfg.dsn)
+				return err
+			},
+		},
+		{
+			name: "templates",
+			run: func() error {
+				_, err := newTemplateCache(cfg.dateLayout)
+				return err
+			},
+		},
+		{
+			name: "disposable-domains",
+			run: func() error {
+				if cfg.disposableDomains == "" {
+					return nil
+				}
+				_, err := loadDomainList(cfg.disposableDomains)
+				return err
+			},
+		},
+		{
+			name: "smtp",
+			run: func() error {
+				if cfg.smtp.addr == "" {
+					return nil
+				}
+				return checkSMTP(cfg.smtp.addr)
+			},
+		},
+		{
+			name: "database",
+			run: func() error {
+				var err error
+				db, err = OpenDB(cfg.dsn, cfg.dbStatementTimeout, nil)
+				return err
+			},
+		},
+		{
+			name: "migrations",
+			run: func() error {
+				if db == nil {
+					return errors.New("no database connection")
+				}
+				defer db.Close()
+
+				return checkMigrations(db)
+			},
+		},
+	}
+}
+
+// runPreflight runs every check, logging each result, and returns the process
+// exit code: 0 if all checks passed and 1 otherwise.
+func runPreflight(logger *slog.Logger, checks []preflightCheck) int {
+	code := 0
+
+	for _, c := range checks {
+		err := c.run()
+		if err != nil {
+			logger.Error("check failed", "check", c.name, "error", err.Error())
+			code = 1
+			continue
+		}
+
+		logger.Info("check passed", "check", c.name)
+	}
+
+	return code
+}
+
+// checkSMTP connects to the relay at addr and waits for its greeting, so a
+// wrong -smtp-addr is found before the first confirmation email fails.
+func checkSMTP(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.DialTimeout("tcp", addr, smtpCheckTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	err = conn.SetDeadline(time.Now().Add(smtpCheckTimeout))
+	if err != nil {
+		return err
+	}
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return fmt.Errorf("reading greeting from %s: %w", addr, err)
+	}
+
+	return c.Close()
+}
+
+// checkMigrations compares the version recorded by golang-migrate with the
+// newest embedded migration.
+func checkMigrations(db *sql.DB) error {
+	latest, err := latestMigration(migrations.Files)
+	if err != nil {
+		return err
+	}
+
+	var version int
+	var dirty bool
+
+	err = db.QueryRow("SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if err != nil {
+		return fmt.Errorf("reading schema_migrations: %w", err)
+	}
+
+	switch {
+	case dirty:
+		return fmt.Errorf("database is dirty at version %d", version)
+	case version < latest:
+		return fmt.Errorf("database is at version %d, want %d", version, latest)
+	}
+
+	return nil
+}
+
+func latestMigration(fsys fs.FS) (int, error) {
+	names, err := fs.Glob(fsys, "*.up.sql")
+	if err != nil {
+		return 0, err
+	}
+
+	latest := 0
+
+	for _, name := range names {
+		prefix, _, _ := strings.Cut(name, "_")
+
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return 0, fmt.Errorf("invalid migration name %q", name)
+		}
+
+		latest = max(latest, version)
+	}
+
+	return latest, nil
+}
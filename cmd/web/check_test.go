@@ -0,0 +1,89 @@
+package main
+
+import (
+	"io/fs"
+	"log/slog"
+	"net"
+	"testing"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/assert"
+	"github.com/Vadim-Makhnev/snippetbox/migrations"
+)
+
+func TestPreflight(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+
+	code := runPreflight(logger, preflightChecks(config{dsn: "web:pass@tcp(localhost:3306"}))
+	assert.Equal(t, code, 1)
+
+	// The database checks need a running MySQL, so only the checks that work
+	// offline are run against a good config.
+	var offline []preflightCheck
+	for _, c := range preflightChecks(config{dsn: "web:pass@/snippetbox?parseTime=true"}) {
+		if c.name == "dsn" || c.name == "templates" || c.name == "disposable-domains" || c.name == "smtp" {
+			offline = append(offline, c)
+		}
+	}
+
+	assert.Equal(t, len(offline), 4)
+	assert.Equal(t, runPreflight(logger, offline), 0)
+}
+
+// serveSMTP writes greeting to each connection to ln and hangs up.
+func serveSMTP(ln net.Listener, greeting string) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+
+		conn.Write([]byte(greeting))
+		conn.Close()
+	}
+}
+
+func TestCheckSMTP(t *testing.T) {
+	relay, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	defer relay.Close()
+	go serveSMTP(relay, "220 relay.example.com ESMTP\r\n")
+
+	assert.NilError(t, checkSMTP(relay.Addr().String()))
+
+	notSMTP, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	defer notSMTP.Close()
+	go serveSMTP(notSMTP, "HTTP/1.1 400 Bad Request\r\n")
+
+	if err := checkSMTP(notSMTP.Addr().String()); err == nil {
+		t.Error("checkSMTP succeeded against a server that is not SMTP")
+	}
+
+	// Nothing listens on a closed listener's address.
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	closed.Close()
+
+	for _, addr := range []string{closed.Addr().String(), "localhost"} {
+		if err := checkSMTP(addr); err == nil {
+			t.Errorf("checkSMTP(%q) succeeded; want an error", addr)
+		}
+	}
+
+	// The smtp preflight check is skipped without a relay.
+	for _, c := range preflightChecks(config{}) {
+		if c.name == "smtp" {
+			assert.NilError(t, c.run())
+		}
+	}
+}
+
+func TestLatestMigration(t *testing.T) {
+	latest, err := latestMigration(migrations.Files)
+	assert.NilError(t, err)
+
+	// Migrations are numbered without gaps.
+	ups, err := fs.Glob(migrations.Files, "*.up.sql")
+	assert.NilError(t, err)
+	assert.Equal(t, latest, len(ups))
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"log/slog"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// redacted replaces secrets in the logged configuration.
+const redacted = "[redacted]"
+
+// redactSecret returns redacted for a set secret, so the log still shows
+// whether one is configured, and an empty string otherwise.
+func redactSecret(s string) string {
+	if s == "" {
+		return ""
+	}
+
+	return redacted
+}
+
+// redactDSN returns dsn with its password replaced by redacted, leaving the
+// user, address and database readable. A DSN that doesn't parse is redacted
+// entirely, since where its password is can't be told.
+func redactDSN(dsn string) string {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return redacted
+	}
+
+	if cfg.Passwd != "" {
+		cfg.Passwd = redacted
+	}
+
+	return cfg.FormatDSN()
+}
+
+// LogValue implements slog.LogValuer, so the effective configuration can be
+// logged at startup with every secret redacted.
+func (cfg config) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Group("server",
+			"addr", cfg.addr,
+			"base_url", cfg.baseURL,
+			"canonical_host", cfg.canonicalHost,
+			"trusted_origins", cfg.trustedOrigins,
+			"static_dirs", cfg.staticDirs,
+			"debug", cfg.debug,
+			"strict_templates", cfg.strictTemplates,
+			"max_in_flight", cfg.maxInFlight,
+			"max_response_body", cfg.maxResponseBody,
+			"shutdown_drain", cfg.shutdownDrain,
+			"metrics_log_interval", cfg.metricsLogInterval,
+		),
+		slog.Group("db",
+			"driver", "mysql",
+			"dsn", redactDSN(cfg.dsn),
+			"statement_timeout", cfg.dbStatementTimeout,
+			"log_queries", cfg.dbLog.queries,
+			"log_args", cfg.dbLog.args,
+			"ready_migrations", cfg.readyMigrations,
+		),
+		slog.Group("features",
+			"signup_enabled", cfg.signupEnabled,
+			"require_invite", cfg.requireInvite,
+			"require_terms", cfg.requireTerms,
+			"snippet_of_day", cfg.snippetOfDay,
+			"personalized_home", cfg.personalizedHome,
+			"date_layout", cfg.dateLayout,
+			"detect_language", cfg.detectLanguage,
+			"obfuscate_ids", cfg.obfuscateIDs,
+			"normalize_titles", cfg.titles.normalize,
+			"title_case", cfg.titles.titleCase,
+			"auto_extend_popular", cfg.autoExtend.enabled,
+			"auto_extend_window", cfg.autoExtend.window,
+			"default_sort", cfg.defaultSort,
+			"languages", cfg.languages,
+		),
+		slog.Group("limits",
+			"page_sizes_home", cfg.pageSizes.home,
+			"page_sizes_search", cfg.pageSizes.search,
+			"page_sizes_api", cfg.pageSizes.api,
+			"featured_limit", cfg.featuredLimit,
+			"max_pins", cfg.maxPins,
+			"min_password_length", cfg.minPasswordLength,
+			"max_tags", cfg.maxTags,
+			"slug_max_length", cfg.slugMaxLength,
+			"create_cooldown", cfg.createCooldown,
+			"comment_cooldown", cfg.commentCooldown,
+			"import_max_bytes", cfg.importMaxBytes,
+			"upload_allowed_types", cfg.uploadAllowedTypes,
+			"import_timeout", cfg.importTimeout,
+			"session_idle_timeout", cfg.sessionIdleTimeout,
+			"session_max_age", cfg.sessionMaxAge,
+			"feed_ttl", cfg.feedTTL,
+			"home_cache_max_age", cfg.homeCacheMaxAge,
+			"idempotency_ttl", cfg.idempotencyTTL,
+		),
+		slog.Group("rate_limits",
+			"enabled", cfg.limiter.enabled,
+			"user_rps", cfg.limiter.userRPS,
+			"user_burst", cfg.limiter.userBurst,
+			"ip_rps", cfg.limiter.ipRPS,
+			"ip_burst", cfg.limiter.ipBurst,
+			"global_rps", cfg.limiter.globalRPS,
+		),
+		slog.Group("smtp",
+			"addr", cfg.smtp.addr,
+			"username", cfg.smtp.username,
+			"password", redactSecret(cfg.smtp.password),
+			"sender", cfg.smtp.sender,
+		),
+		slog.Group("secrets",
+			"inbound_secret", redactSecret(cfg.inboundSecret),
+			"id_salt", redactSecret(cfg.idSalt),
+			"content_encryption_key", redactSecret(cfg.contentKey),
+			"webhook_secret", redactSecret(cfg.webhookSecret),
+		),
+		slog.Int("webhooks", len(cfg.webhookURLs)),
+	)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/assert"
+)
+
+func TestConfigLogValue(t *testing.T) {
+	var cfg config
+	cfg.addr = ":4000"
+	cfg.dsn = "web:hunter2@tcp(db.example.com:3306)/snippetbox?parseTime=true"
+	cfg.smtp.addr = "smtp.example.com:587"
+	cfg.smtp.password = "smtp-s3cret"
+	cfg.webhookSecret = "hook-s3cret"
+	cfg.signupEnabled = true
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+
+	logger.Info("effective config", "config", cfg)
+
+	out := buf.String()
+
+	assert.Equal(t, strings.Count(out, "\n"), 1)
+	assert.StringContains(t, out, "config.db.dsn=\"web:[redacted]@tcp(db.example.com:3306)/snippetbox?parseTime=true\"")
+	assert.StringContains(t, out, "config.smtp.addr=smtp.example.com:587")
+	assert.StringContains(t, out, "config.smtp.password=[redacted]")
+	assert.StringContains(t, out, "config.secrets.webhook_secret=[redacted]")
+	assert.StringContains(t, out, "config.secrets.inbound_secret=\"\"")
+	assert.StringContains(t, out, "config.features.signup_enabled=true")
+
+	for _, secret := range []string{"hunter2", "smtp-s3cret", "hook-s3cret"} {
+		assert.Equal(t, strings.Contains(out, secret), false)
+	}
+}
+
+func TestRedactDSN(t *testing.T) {
+	tests := []struct {
+		dsn  string
+		want string
+	}{
+		{"web:pass@/snippetbox?parseTime=true", "web:[redacted]@tcp(127.0.0.1:3306)/snippetbox?parseTime=true"},
+		{"web@tcp(localhost:3306)/snippetbox", "web@tcp(localhost:3306)/snippetbox"},
+		{"web:pa:ss@tcp(localhost)/snippetbox", "web:[redacted]@tcp(localhost:3306)/snippetbox"},
+		{"not a dsn", "[redacted]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dsn, func(t *testing.T) {
+			assert.Equal(t, redactDSN(tt.dsn), tt.want)
+		})
+	}
+}
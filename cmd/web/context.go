@@ -2,4 +2,10 @@ package main
 
 type contextKey string
 
-const isAuthenticatedContextKey = contextKey("isAuthenticated")
+const (
+	isAuthenticatedContextKey     = contextKey("isAuthenticated")
+	authenticatedUserIDContextKey = contextKey("authenticatedUserID")
+	csrfHandlerContextKey         = contextKey("csrfHandler")
+	txContextKey                  = contextKey("tx")
+	txHooksContextKey             = contextKey("txHooks")
+)
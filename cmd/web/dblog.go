@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// queryLogger logs every SQL statement run through the connector it wraps,
+// with its duration, for -db-log-queries. Arguments are only logged with
+// -db-log-args, and never for statements that touch a password column.
+type queryLogger struct {
+	logger *slog.Logger
+	args   bool
+}
+
+func (l *queryLogger) wrap(c driver.Connector) driver.Connector {
+	return &loggingConnector{Connector: c, log: l}
+}
+
+func (l *queryLogger) log(ctx context.Context, query string, args []driver.NamedValue, start time.Time, err error) {
+	attrs := []slog.Attr{
+		slog.String("query", strings.Join(strings.Fields(query), " ")),
+		slog.Duration("duration", time.Since(start)),
+	}
+
+	switch {
+	case !l.args:
+	case strings.Contains(strings.ToLower(query), "password"):
+		attrs = append(attrs, slog.String("args", "[redacted]"))
+	default:
+		values := make([]any, len(args))
+		for i, arg := range args {
+			values[i] = arg.Value
+		}
+		attrs = append(attrs, slog.Any("args", values))
+	}
+
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+
+	l.logger.LogAttrs(ctx, slog.LevelInfo, "sql query", attrs...)
+}
+
+type loggingConnector struct {
+	driver.Connector
+	log *queryLogger
+}
+
+func (c *loggingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &loggingConn{Conn: conn, log: c.log}, nil
+}
+
+// loggingConn passes everything through to the driver's connection, logging
+// statements on the way. Where the driver lacks an optional interface it
+// returns driver.ErrSkip, so database/sql falls back as it would have.
+type loggingConn struct {
+	driver.Conn
+	log *queryLogger
+}
+
+func (c *loggingConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *loggingConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &loggingStmt{Stmt: stmt, query: query, log: c.log}, nil
+}
+
+func (c *loggingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+
+	return c.Conn.Begin()
+}
+
+func (c *loggingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	start := time.Now()
+	result, err := e.ExecContext(ctx, query, args)
+	if err != driver.ErrSkip {
+		c.log.log(ctx, query, args, start, err)
+	}
+
+	return result, err
+}
+
+func (c *loggingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	start := time.Now()
+	rows, err := q.QueryContext(ctx, query, args)
+	if err != driver.ErrSkip {
+		c.log.log(ctx, query, args, start, err)
+	}
+
+	return rows, err
+}
+
+func (c *loggingConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+
+	return nil
+}
+
+func (c *loggingConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+
+	return nil
+}
+
+func (c *loggingConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+
+	return true
+}
+
+func (c *loggingConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+
+	return driver.ErrSkip
+}
+
+type loggingStmt struct {
+	driver.Stmt
+	query string
+	log   *queryLogger
+}
+
+func (s *loggingStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+
+	var (
+		result driver.Result
+		err    error
+	)
+
+	if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+		result, err = e.ExecContext(ctx, args)
+	} else {
+		result, err = s.Stmt.Exec(namedValues(args))
+	}
+
+	s.log.log(ctx, s.query, args, start, err)
+
+	return result, err
+}
+
+func (s *loggingStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+
+	var (
+		rows driver.Rows
+		err  error
+	)
+
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = q.QueryContext(ctx, args)
+	} else {
+		rows, err = s.Stmt.Query(namedValues(args))
+	}
+
+	s.log.log(ctx, s.query, args, start, err)
+
+	return rows, err
+}
+
+func namedValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+
+	return values
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/assert"
+	"github.com/Vadim-Makhnev/snippetbox/internal/models"
+)
+
+func TestQueryLogger(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		args    bool
+	}{
+		{name: "Off"},
+		{name: "On", enabled: true},
+		{name: "On with arguments", enabled: true, args: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			var connector driver.Connector = txConnector{store: &txStore{}}
+			if tt.enabled {
+				ql := &queryLogger{logger: slog.New(slog.NewTextHandler(&buf, nil)), args: tt.args}
+				connector = ql.wrap(connector)
+			}
+
+			db := sql.OpenDB(connector)
+			defer db.Close()
+
+			tokens := models.TokenModel{DB: db}
+
+			err := tokens.DeleteAllForUser(models.ScopeEmailChange, 42)
+			assert.NilError(t, err)
+
+			logs := buf.String()
+
+			if !tt.enabled {
+				assert.Equal(t, logs, "")
+				return
+			}
+
+			assert.StringContains(t, logs, `msg="sql query" query="DELETE FROM tokens WHERE scope = ? AND user_id = ?" duration=`)
+			assert.Equal(t, strings.Contains(logs, "args="), tt.args)
+			if tt.args {
+				assert.StringContains(t, logs, `args="[email-change 42]"`)
+			}
+
+			buf.Reset()
+
+			users := models.UserModel{DB: db}
+
+			err = users.Insert("Bob", "bob@example.com", "pa$$word123", false)
+			assert.NilError(t, err)
+
+			logs = buf.String()
+
+			assert.StringContains(t, logs, "INSERT INTO users (name, email, hashed_password, created, terms_accepted_at)")
+			assert.Equal(t, strings.Contains(logs, "$2a$"), false)
+			if tt.args {
+				assert.StringContains(t, logs, "args=[redacted]")
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// domainList is a set of lowercased email domains, such as the disposable
+// email providers blocked at signup.
+type domainList map[string]struct{}
+
+// loadDomainList reads one domain per line from path. Blank lines and lines
+// starting with # are ignored.
+func loadDomainList(path string) (domainList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	list := domainList{}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		list[strings.ToLower(line)] = struct{}{}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// containsEmail reports whether the domain part of email is in the list,
+// ignoring case. A nil list contains nothing.
+func (l domainList) containsEmail(email string) bool {
+	i := strings.LastIndex(email, "@")
+	if i == -1 {
+		return false
+	}
+
+	_, ok := l[strings.ToLower(email[i+1:])]
+
+	return ok
+}
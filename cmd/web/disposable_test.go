@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/assert"
+)
+
+func TestLoadDomainList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "domains.txt")
+
+	err := os.WriteFile(path, []byte("# Disposable providers\nmailinator.com\n\n  Guerrillamail.com  \n"), 0o600)
+	assert.NilError(t, err)
+
+	list, err := loadDomainList(path)
+	assert.NilError(t, err)
+
+	assert.Equal(t, len(list), 2)
+	assert.Equal(t, list.containsEmail("bob@mailinator.com"), true)
+	assert.Equal(t, list.containsEmail("bob@GUERRILLAMAIL.com"), true)
+	assert.Equal(t, list.containsEmail("bob@example.com"), false)
+	assert.Equal(t, list.containsEmail("bob@sub.mailinator.com"), false)
+	assert.Equal(t, list.containsEmail("mailinator.com"), false)
+
+	var none domainList
+	assert.Equal(t, none.containsEmail("bob@mailinator.com"), false)
+
+	_, err = loadDomainList(filepath.Join(t.TempDir(), "missing.txt"))
+	assert.Equal(t, err != nil, true)
+}
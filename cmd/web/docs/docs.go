@@ -17,7 +17,7 @@ const docTemplate = `{
     "paths": {
         "/": {
             "get": {
-                "description": "Retrieve the latest snippets and render the home page",
+                "description": "Retrieve the latest snippets and render the home page, with up to -featured-limit snippets featured by admins above them. With -personalized-home, logged-in users see their own latest snippets, private ones included, instead of everyone's. The latest snippets are listed newest first unless -default-sort or the sort parameter says otherwise. Anonymous pages may be cached for -home-cache-max-age, only by the browser when the response sets a cookie; pages for logged-in users, or showing a flash message, are marked no-store.",
                 "produces": [
                     "text/html"
                 ],
@@ -25,37 +25,1873 @@ const docTemplate = `{
                     "pages"
                 ],
                 "summary": "Get home page with latest snippets",
+                "parameters": [
+                    {
+                        "enum": [
+                            "created",
+                            "-created"
+                        ],
+                        "type": "string",
+                        "default": "-created",
+                        "description": "Order of the latest snippets by creation time; the default is -default-sort if set",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Number of latest snippets, capped to -page-size-home (1-50 by default)",
+                        "name": "page_size",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "HTML page",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid sort",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/account/email/confirm": {
+            "get": {
+                "description": "Switch the user's email to the pending address using the token from the confirmation email",
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Confirm email change",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Confirmation token",
+                        "name": "token",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "303": {
+                        "description": "Redirect to home page with a message",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/account/email/update": {
+            "get": {
+                "description": "Display the form for changing the current user's email address",
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Show email change form",
+                "responses": {
+                    "200": {
+                        "description": "Email change form",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Change the current user's email address after verifying their password. When -smtp-addr is set, a confirmation link is sent to the new address and the old one stays in use until it is followed.",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Change email",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "format": "email",
+                        "description": "New email address",
+                        "name": "newEmail",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Current password",
+                        "name": "currentPassword",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "303": {
+                        "description": "Redirect to home page with a message",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid form data",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable entity - validation failed, wrong current password or duplicate email",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/account/password/update": {
+            "get": {
+                "description": "Display the form for changing the current user's password",
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Show password change form",
+                "responses": {
+                    "200": {
+                        "description": "Password change form",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Change the current user's password after verifying the current one",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Change password",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Current password",
+                        "name": "currentPassword",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "minLength": 8,
+                        "type": "string",
+                        "description": "New password, at least -min-password-length characters",
+                        "name": "newPassword",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "New password again",
+                        "name": "newPasswordConfirmation",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "303": {
+                        "description": "Redirect to home page with success message",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid form data",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable entity - validation failed or wrong current password",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin": {
+            "get": {
+                "description": "Show the numbers from /admin/stats.json as a page. It is where admins land after logging in, unless -admin-login-redirect says otherwise. Admins only.",
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Admin dashboard",
+                "responses": {
+                    "200": {
+                        "description": "Admin dashboard",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - not an admin",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/debug/vars": {
+            "get": {
+                "description": "The variables published through expvar: memory statistics, the command line and the hit and miss counters of the in-memory caches under caches. Admins only.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Runtime variables",
+                "responses": {
+                    "200": {
+                        "description": "Published variables",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - not an admin",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/invites": {
+            "post": {
+                "description": "Generate a single-use invite code for signing up when -require-invite is set. The code is only shown in this response. Admins only; the session's CSRF token goes in the X-CSRF-Token header.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Create an invite code",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "CSRF token",
+                        "name": "X-CSRF-Token",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "The invite code",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - not an admin",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/snippets/bulk": {
+            "post": {
+                "description": "Delete, hide or unhide a batch of snippets in one transaction, for moderation. The result lists the outcome for each ID; IDs with no snippet are reported as not_found without affecting the rest. Admins only; the session's CSRF token goes in the X-CSRF-Token header.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Apply an action to many snippets",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "CSRF token",
+                        "name": "X-CSRF-Token",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Action (delete, hide or unhide) and up to 100 snippet IDs",
+                        "name": "payload",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.adminBulkRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Outcome per snippet ID",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - malformed JSON",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - not an admin",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable entity - invalid action or IDs",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/snippets/{id}/featured": {
+            "post": {
+                "description": "Mark a snippet as featured, so it is listed in the Featured section of the home page, or remove the mark. Only live public snippets can be featured; a featured snippet drops out of the section once it expires. Admins only; the session's CSRF token goes in the X-CSRF-Token header.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Feature or un-feature a snippet",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "CSRF token",
+                        "name": "X-CSRF-Token",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Snippet ID, or its opaque code when -obfuscate-ids is set",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Whether the snippet is featured",
+                        "name": "payload",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.adminFeaturedRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Snippet ID and featured state",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - malformed JSON",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - not an admin",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Snippet not found",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable entity - snippet is private or expired",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/stats.json": {
+            "get": {
+                "description": "Aggregate numbers of users and snippets for an admin dashboard: users in total and activated, snippets in total, live and expired, and snippets created in the last 24 hours and 7 days. Hidden and private snippets are counted. Admins only.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Site statistics",
+                "responses": {
+                    "200": {
+                        "description": "User and snippet counts",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - not an admin",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users": {
+            "get": {
+                "description": "List user accounts, paginated and in order of signing up unless sorted otherwise, optionally only those whose name or email contains the search term. A page number out of range shows the first or last page. Password hashes are never shown. Admins only.",
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List users",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Search term, matched against names and emails ignoring case",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Users per page, within -page-size-search (1-50 by default)",
+                        "name": "page_size",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "id",
+                            "-id",
+                            "name",
+                            "-name",
+                            "email",
+                            "-email",
+                            "created",
+                            "-created"
+                        ],
+                        "type": "string",
+                        "default": "id",
+                        "description": "Order, descending with a leading -",
+                        "name": "sort",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "User list",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - not an admin",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable entity - invalid page size or sort",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/drafts": {
+            "get": {
+                "description": "Retrieve the authenticated user's saved snippet draft. Requires a session; the create form uses it to restore unsaved work.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "api"
+                ],
+                "summary": "Get draft",
+                "responses": {
+                    "200": {
+                        "description": "Saved draft",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - not logged in",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "404": {
+                        "description": "No draft saved",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests - rate limit exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "503": {
+                        "description": "Service unavailable - global rate limit exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Save the authenticated user's in-progress snippet, replacing any earlier draft. Drafts may be incomplete, so only lengths and the expiry value are checked. The draft is cleared once a snippet is created through the form.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "api"
+                ],
+                "summary": "Save draft",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "CSRF token from the create form",
+                        "name": "X-CSRF-Token",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Draft to save",
+                        "name": "payload",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.draftSaveRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Saved draft",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - malformed JSON or CSRF failure",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - not logged in",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable entity - validation failed",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests - rate limit exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "503": {
+                        "description": "Service unavailable - global rate limit exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/inbound": {
+            "post": {
+                "description": "Accept a parsed inbound email from the mail provider webhook and create a snippet from its subject and body, attributed to the registered user matching the sender address.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "api"
+                ],
+                "summary": "Create snippet from inbound email",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Shared webhook secret",
+                        "name": "X-Inbound-Secret",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Parsed inbound email",
+                        "name": "payload",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.inboundEmailPayload"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "ID of the created snippet",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "integer"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - malformed JSON",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - missing or invalid webhook secret",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - unknown sender",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "404": {
+                        "description": "Inbound email is not configured",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable entity - validation failed",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/meta/expiry-options": {
+            "get": {
+                "description": "List the snippet lifetimes the server accepts, so clients can build their expiry choices without hardcoding them.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "api"
+                ],
+                "summary": "List expiry options",
+                "responses": {
+                    "200": {
+                        "description": "Configured expiry options",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.expiryOption"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests - rate limit exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "503": {
+                        "description": "Service unavailable - global rate limit exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/snippets": {
+            "get": {
+                "description": "Retrieve live snippets newest first using cursor pagination. Pass the returned next_cursor as the after parameter to fetch the next page; next_cursor is null on the last page.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "api"
+                ],
+                "summary": "List snippets",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Return snippets with an ID lower than this cursor",
+                        "name": "after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Maximum number of snippets to return, within -page-size-api (1-100 by default)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Snippets and the next cursor",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable entity - invalid cursor or limit",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests - rate limit exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "503": {
+                        "description": "Service unavailable - global rate limit exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create a new snippet for the authenticated user. Sending an Idempotency-Key header makes retries safe: a replayed key returns the originally created snippet ID instead of creating a duplicate.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "api"
+                ],
+                "summary": "Create snippet",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Unique key identifying this create request",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Snippet to create",
+                        "name": "payload",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.snippetCreateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "ID of the created snippet",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "integer"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - malformed JSON",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - missing or invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable entity - validation failed",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests - rate limit exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "503": {
+                        "description": "Service unavailable - global rate limit exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/snippets/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Retrieve a live snippet with a slug of its title, of at most -slug-max-length characters, and a minimal block about its author, which is null for anonymous snippets. Private snippets are only returned to their owner; anyone else gets 404, as for missing and expired snippets.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "api"
+                ],
+                "summary": "Get snippet by id",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Snippet ID, or its opaque code when -obfuscate-ids is set",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The snippet and its author",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Snippet not found",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests - rate limit exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "503": {
+                        "description": "Service unavailable - global rate limit exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/tokens/authentication": {
+            "post": {
+                "description": "Exchange user credentials for a bearer token used to authenticate API requests.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "api"
+                ],
+                "summary": "Create authentication token",
+                "parameters": [
+                    {
+                        "description": "User credentials",
+                        "name": "payload",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.authenticationTokenRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Authentication token and its expiry",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - malformed JSON",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable entity - validation failed",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests - rate limit exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "503": {
+                        "description": "Service unavailable - global rate limit exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users": {
+            "post": {
+                "description": "Create a new user account, with the same rules as the signup form. Validation failures, including an email that is already in use, are reported per field in the errors member.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "api"
+                ],
+                "summary": "Register new user",
+                "parameters": [
+                    {
+                        "description": "New user",
+                        "name": "payload",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.userSignupRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "The created user",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - malformed JSON",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - signup is disabled with -signup-enabled=false",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable entity - validation failed, duplicate email or invalid invite code",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests - rate limit exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "503": {
+                        "description": "Service unavailable - global rate limit exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/archive": {
+            "get": {
+                "description": "List the months that have live snippets, newest first, with the number created in each month (UTC). Each month links to the date range archive.",
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "snippets"
+                ],
+                "summary": "Show monthly archive",
+                "responses": {
+                    "200": {
+                        "description": "Archive index page",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/feed.xml": {
+            "get": {
+                "description": "RSS 2.0 feed of the latest snippets. The feed is cached for -feed-ttl and refreshed in the background once stale, so reads never wait on the database after the first.",
+                "produces": [
+                    "text/xml"
+                ],
+                "tags": [
+                    "snippets"
+                ],
+                "summary": "RSS feed",
+                "responses": {
+                    "200": {
+                        "description": "RSS document",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/graphql": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Run a GraphQL query against snippets and users. The schema has snippet(id: ID!), snippets(page: Int, sort: String) listing live public snippets with the default page size of -page-size-api, and me, the user authenticated with a bearer token or null. A private snippet is null to anyone but its owner, as are missing and expired snippets. Only queries are supported. Errors are reported in the errors member of a 200 response.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "api"
+                ],
+                "summary": "GraphQL queries",
+                "parameters": [
+                    {
+                        "description": "Query, with optional variables",
+                        "name": "payload",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.graphqlRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The data and any errors",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - malformed JSON",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests - rate limit exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/main.problemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/healthz": {
+            "get": {
+                "description": "Returns 200 as long as the process is up and serving, whatever the state of its dependencies",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Liveness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Returns 200 when the server can handle traffic: the database is reachable and, with -ready-require-migrations, fully migrated. Returns 503 otherwise, and from the moment a graceful shutdown starts, so load balancers drain traffic before the process exits.",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Readiness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "Not ready",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/snippet/archive": {
+            "get": {
+                "description": "List live snippets created between two dates, inclusive, paginated and oldest first unless -default-sort or the sort parameter says otherwise. Without dates only the search form is shown.",
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "snippets"
+                ],
+                "summary": "Browse snippets by date",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "First day of the range (YYYY-MM-DD)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last day of the range (YYYY-MM-DD)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Snippets per page, within -page-size-search (1-50 by default)",
+                        "name": "page_size",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "created",
+                            "-created"
+                        ],
+                        "type": "string",
+                        "default": "created",
+                        "description": "Order by creation time, oldest first or newest first; the default is -default-sort if set",
+                        "name": "sort",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Archive page",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable entity - malformed dates, inverted range or invalid paging",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/snippet/create": {
+            "get": {
+                "description": "Display the form for creating a new code snippet. It is pre-filled from the named preset if one is given and exists, otherwise from the user's saved draft if there is one, otherwise with the -default-snippet-content scaffold.",
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "snippets"
+                ],
+                "summary": "Show snippet creation form",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Name of a preset from ui/presets.json to start from",
+                        "name": "preset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Snippet creation form",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Create a new code snippet with validation. A title matching one of the user's live snippets is allowed but noted in the flash message. With -create-cooldown, a user must wait that long after their last snippet before creating another.",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "snippets"
+                ],
+                "summary": "Create new snippet",
+                "parameters": [
+                    {
+                        "maxLength": 100,
+                        "minLength": 1,
+                        "type": "string",
+                        "description": "Snippet title",
+                        "name": "title",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "minLength": 1,
+                        "type": "string",
+                        "description": "Snippet content",
+                        "name": "content",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Snippet language, one of -languages, or empty for plain text, or for a guess from the content with -detect-language",
+                        "name": "language",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated tags, case-insensitive, up to -max-tags-per-snippet different ones",
+                        "name": "tags",
+                        "in": "formData"
+                    },
+                    {
+                        "enum": [
+                            1,
+                            7,
+                            365
+                        ],
+                        "type": "integer",
+                        "description": "Expiration in days, unless expires_at is given",
+                        "name": "expires",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Exact expiry time, in RFC 3339 or as a local date and time in the user's time zone, instead of expires",
+                        "name": "expires_at",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "303": {
+                        "description": "Redirect to created snippet",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid form data",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable entity - validation failed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/snippet/import-url": {
+            "post": {
+                "description": "Fetch a text resource from a public http or https URL and create a snippet of it, titled after the last segment of the URL's path, with the longest expiry option. Addresses on private, loopback and link-local networks are refused, including after redirects, as are responses larger than -import-max-bytes or slower than -import-timeout. The -create-cooldown applies.",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "snippets"
+                ],
+                "summary": "Create a snippet from a URL",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "URL to import from",
+                        "name": "url",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "303": {
+                        "description": "Redirect to created snippet",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable entity - URL not allowed, not fetchable, too large or not text",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/snippet/preview": {
+            "post": {
+                "description": "Render snippet content as Markdown and return the sanitized HTML fragment, using the same renderer as the final page",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "snippets"
+                ],
+                "summary": "Preview snippet content",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Snippet content in Markdown",
+                        "name": "content",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Rendered HTML fragment",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid form data or CSRF token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/snippet/raw/{id}": {
+            "get": {
+                "description": "Retrieve the snippet content as plain text, streamed from the database in chunks. Byte ranges can be requested with a Range header. The response is always served as text/plain with nosniff so stored content is never interpreted as HTML or script.",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "snippets"
+                ],
+                "summary": "Get raw snippet content",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Snippet ID, or its opaque code when -obfuscate-ids is set",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Byte range to fetch, such as bytes=0-9",
+                        "name": "Range",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Snippet content",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "206": {
+                        "description": "The requested range of the snippet content",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Snippet not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "416": {
+                        "description": "Range not satisfiable",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/snippet/stats/{id}": {
+            "get": {
+                "description": "Show the owner of a snippet how many times it was viewed, per day over the last 30 days, and which sites referred the views",
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "snippets"
+                ],
+                "summary": "Show snippet view stats",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Snippet ID, or its opaque code when -obfuscate-ids is set",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "HTML page",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Not the snippet's owner",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Snippet not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/snippet/upload": {
+            "post": {
+                "description": "Create a snippet of an uploaded text file, titled after the file's name, with the longest expiry option. The file's type is sniffed from its content, and must be one of -upload-allowed-types; when extensions are listed there, its name must also have one of them. Files larger than -import-max-bytes are refused. The -create-cooldown applies.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "snippets"
+                ],
+                "summary": "Create a snippet from an uploaded file",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "Text file to create the snippet from",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "303": {
+                        "description": "Redirect to created snippet",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - not a multipart form, or far too large",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable entity - no file, or a file that is too large, empty or not an allowed type",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/snippet/view/{id}": {
+            "get": {
+                "description": "Retrieve snippet by snippet id, with a page of its comments, oldest first. The view is counted in the snippet's stats, along with the referring site. With -auto-extend-popular, a view close to expiry extends the snippet by a day.",
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "snippets"
+                ],
+                "summary": "Get snippet by id",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Snippet ID, or its opaque code when -obfuscate-ids is set",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Page of comments; pages out of range show the nearest one",
+                        "name": "page",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "HTML page",
                         "schema": {
                             "type": "string"
                         }
+                    },
+                    "404": {
+                        "description": "Snippet not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
                 }
             }
         },
-        "/snippet/create": {
+        "/snippet/view/{id}/qr.png": {
             "get": {
-                "description": "Display the form for creating a new code snippet",
+                "description": "Render a PNG QR code encoding the snippet's absolute URL under -base-url, for opening it on a phone. Like the snippet itself, expired snippets and other users' private snippets are not found. The code may be cached for a day, or until the snippet expires if that is sooner; only by the browser for private snippets and responses that set a cookie.",
                 "produces": [
-                    "text/html"
+                    "image/png"
                 ],
                 "tags": [
                     "snippets"
                 ],
-                "summary": "Show snippet creation form",
+                "summary": "Get a QR code of a snippet's link",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Snippet ID, or its opaque code when -obfuscate-ids is set",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 256,
+                        "description": "Width and height of the image in pixels, from 64 to 1024",
+                        "name": "size",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
-                        "description": "Snippet creation form",
+                        "description": "PNG image",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid size",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Snippet not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
                         "schema": {
                             "type": "string"
                         }
                     }
                 }
-            },
+            }
+        },
+        "/snippet/{id}/comments": {
             "post": {
-                "description": "Create a new code snippet with validation",
+                "description": "Add a comment to a snippet the logged-in user can see. Comments on private snippets are only possible, and only shown, to the snippet's owner. With -comment-cooldown, a user has to wait that long after their last comment before posting another.",
                 "consumes": [
                     "application/x-www-form-urlencoded"
                 ],
@@ -65,53 +1901,98 @@ const docTemplate = `{
                 "tags": [
                     "snippets"
                 ],
-                "summary": "Create new snippet",
+                "summary": "Comment on a snippet",
                 "parameters": [
                     {
-                        "maxLength": 100,
-                        "minLength": 1,
                         "type": "string",
-                        "description": "Snippet title",
-                        "name": "title",
-                        "in": "formData",
+                        "description": "Snippet ID, or its opaque code when -obfuscate-ids is set",
+                        "name": "id",
+                        "in": "path",
                         "required": true
                     },
                     {
-                        "minLength": 1,
                         "type": "string",
-                        "description": "Snippet content",
-                        "name": "content",
+                        "description": "Comment text, at most 2000 characters",
+                        "name": "body",
                         "in": "formData",
                         "required": true
+                    }
+                ],
+                "responses": {
+                    "303": {
+                        "description": "Redirect to the snippet's comments",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid form",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Snippet not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation errors, shown on the snippet page",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/snippet/{id}/comments/{cid}/delete": {
+            "post": {
+                "description": "Remove a comment from a snippet's thread. It stays in place shown as [removed], so replies around it keep their context. Only the snippet's owner and admins can delete comments; on another user's private snippet, other users get 404 as if it didn't exist.",
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "snippets"
+                ],
+                "summary": "Delete a comment",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Snippet ID, or its opaque code when -obfuscate-ids is set",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     },
                     {
-                        "enum": [
-                            1,
-                            7,
-                            365
-                        ],
                         "type": "integer",
-                        "description": "Expiration in days",
-                        "name": "expires",
-                        "in": "formData",
+                        "description": "Comment ID",
+                        "name": "cid",
+                        "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
                     "303": {
-                        "description": "Redirect to created snippet",
+                        "description": "Redirect to the snippet's comments",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "400": {
-                        "description": "Bad request - invalid form data",
+                    "403": {
+                        "description": "Not the snippet's owner or an admin",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "422": {
-                        "description": "Unprocessable entity - validation failed",
+                    "404": {
+                        "description": "Snippet or comment not found, or the comment was already deleted",
                         "schema": {
                             "type": "string"
                         }
@@ -125,20 +2006,114 @@ const docTemplate = `{
                 }
             }
         },
-        "/snippet/view/{id}": {
-            "get": {
-                "description": "Retrieve snippet by snippet id",
+        "/snippet/{id}/pin": {
+            "post": {
+                "description": "Show one of the logged-in user's public snippets at the top of their profile, after the ones pinned before it. A user can pin at most -max-pins snippets; past that, and for private snippets, the pin is refused with a flash message. Pinning a pinned snippet does nothing.",
                 "produces": [
                     "text/html"
                 ],
                 "tags": [
                     "snippets"
                 ],
-                "summary": "Get snippet by id",
+                "summary": "Pin a snippet to the owner's profile",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Snippet ID, or its opaque code when -obfuscate-ids is set",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "303": {
+                        "description": "Redirect to the user's profile, or to the snippet if it is private",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Not the snippet's owner",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Snippet not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/snippet/{id}/unpin": {
+            "post": {
+                "description": "Stop showing one of the logged-in user's snippets at the top of their profile. Unpinning a snippet that isn't pinned does nothing.",
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "snippets"
+                ],
+                "summary": "Unpin a snippet from the owner's profile",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Snippet ID, or its opaque code when -obfuscate-ids is set",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "303": {
+                        "description": "Redirect to the user's profile",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Not the snippet's owner",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Snippet not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/u/{id}": {
+            "get": {
+                "description": "Show a user's public snippets: the ones they pinned first, in the order they pinned them, then their other latest snippets, newest first. Private snippets and the user's email are never shown.",
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "User profile",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Snippet ID",
+                        "description": "User ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -152,7 +2127,7 @@ const docTemplate = `{
                         }
                     },
                     "404": {
-                        "description": "Snippet not found",
+                        "description": "User not found",
                         "schema": {
                             "type": "string"
                         }
@@ -176,6 +2151,14 @@ const docTemplate = `{
                     "auth"
                 ],
                 "summary": "Show login form",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Local path to go to after logging in, instead of the default for the user's role",
+                        "name": "next",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "User login form",
@@ -186,7 +2169,7 @@ const docTemplate = `{
                 }
             },
             "post": {
-                "description": "Verify user credentials and create session. On success, redirects to snippet creation page.",
+                "description": "Verify user credentials and create session. On success, redirects to next if it is a local path, otherwise to -admin-login-redirect for admins and -login-redirect for everyone else.",
                 "consumes": [
                     "application/x-www-form-urlencoded"
                 ],
@@ -212,11 +2195,17 @@ const docTemplate = `{
                         "name": "password",
                         "in": "formData",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Local path to go to after logging in",
+                        "name": "next",
+                        "in": "formData"
                     }
                 ],
                 "responses": {
                     "303": {
-                        "description": "Redirect to /snippet/create with active session",
+                        "description": "Redirect with active session",
                         "schema": {
                             "type": "string"
                         }
@@ -286,7 +2275,7 @@ const docTemplate = `{
                 "summary": "Show user registration form",
                 "responses": {
                     "200": {
-                        "description": "User registration form",
+                        "description": "User registration form, or a registration closed page when -signup-enabled=false",
                         "schema": {
                             "type": "string"
                         }
@@ -294,7 +2283,7 @@ const docTemplate = `{
                 }
             },
             "post": {
-                "description": "Create a new user account with email and password validation. Checks for duplicate emails.",
+                "description": "Create a new user account with email and password validation. Checks for duplicate emails and, when -disposable-domains is set, rejects disposable email domains.",
                 "consumes": [
                     "application/x-www-form-urlencoded"
                 ],
@@ -326,10 +2315,22 @@ const docTemplate = `{
                     {
                         "minLength": 8,
                         "type": "string",
-                        "description": "User's password",
+                        "description": "User's password, at least -min-password-length characters",
                         "name": "password",
                         "in": "formData",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Unused invite code, required when -require-invite is set",
+                        "name": "invite",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Acceptance of the terms of service, required when -require-terms is set",
+                        "name": "terms",
+                        "in": "formData"
                     }
                 ],
                 "responses": {
@@ -345,8 +2346,14 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
+                    "403": {
+                        "description": "Forbidden - signup is disabled with -signup-enabled=false",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
                     "422": {
-                        "description": "Unprocessable entity - validation failed or duplicate email",
+                        "description": "Unprocessable entity - validation failed, disposable or duplicate email, or invalid invite code",
                         "schema": {
                             "type": "string"
                         }
@@ -360,6 +2367,174 @@ const docTemplate = `{
                 }
             }
         }
+    },
+    "definitions": {
+        "main.adminBulkRequest": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "main.adminFeaturedRequest": {
+            "type": "object",
+            "properties": {
+                "featured": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "main.authenticationTokenRequest": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.draftSaveRequest": {
+            "type": "object",
+            "properties": {
+                "content": {
+                    "type": "string"
+                },
+                "expires": {
+                    "type": "integer"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.expiryOption": {
+            "type": "object",
+            "properties": {
+                "days": {
+                    "type": "integer"
+                },
+                "label": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.graphqlRequest": {
+            "type": "object",
+            "properties": {
+                "operationName": {
+                    "type": "string"
+                },
+                "query": {
+                    "type": "string"
+                },
+                "variables": {
+                    "type": "object",
+                    "additionalProperties": {}
+                }
+            }
+        },
+        "main.inboundEmailPayload": {
+            "type": "object",
+            "properties": {
+                "from": {
+                    "type": "string"
+                },
+                "subject": {
+                    "type": "string"
+                },
+                "text": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.problemDetails": {
+            "type": "object",
+            "properties": {
+                "detail": {
+                    "type": "string"
+                },
+                "errors": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "instance": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "integer"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.snippetCreateRequest": {
+            "type": "object",
+            "properties": {
+                "content": {
+                    "type": "string"
+                },
+                "expires": {
+                    "type": "integer"
+                },
+                "language": {
+                    "type": "string"
+                },
+                "private": {
+                    "type": "boolean"
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.userSignupRequest": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string"
+                },
+                "invite": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                },
+                "terms": {
+                    "type": "boolean"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "BearerAuth": {
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
+        }
     }
 }`
 
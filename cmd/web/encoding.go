@@ -0,0 +1,31 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// toUTF8 returns s as valid UTF-8. Text that isn't valid UTF-8 is taken to
+// be Latin-1 (ISO 8859-1), the usual encoding of text pasted from older
+// systems, and transcoded. Latin-1 text has no use for the C1 control codes
+// 0x80-0x9F, so if s contains any of those bytes it is in some other
+// encoding, or is binary: ok is then false, and the invalid bytes are
+// replaced with U+FFFD so the text can still be shown back to the user.
+func toUTF8(s string) (string, bool) {
+	if utf8.ValidString(s) {
+		return s, true
+	}
+
+	var b strings.Builder
+	b.Grow(len(s) * 2)
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 0x80 && c <= 0x9f {
+			return strings.ToValidUTF8(s, "\uFFFD"), false
+		}
+		b.WriteRune(rune(c))
+	}
+
+	return b.String(), true
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// expiryOption is one of the lifetimes a snippet can be created with.
+type expiryOption struct {
+	Days  int    `json:"days"`
+	Label string `json:"label"`
+}
+
+var defaultExpiryOptions = []expiryOption{
+	{Days: 1, Label: "One day"},
+	{Days: 7, Label: "One week"},
+	{Days: 365, Label: "One year"},
+}
+
+// parseExpiryOptions parses a comma-separated list of days:label pairs, such
+// as "1:One day,7:One week".
+func parseExpiryOptions(s string) ([]expiryOption, error) {
+	var options []expiryOption
+
+	for pair := range strings.SplitSeq(s, ",") {
+		days, label, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid expiry option %q: want days:label", pair)
+		}
+
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid expiry option %q: days must be a positive integer", pair)
+		}
+
+		options = append(options, expiryOption{Days: n, Label: strings.TrimSpace(label)})
+	}
+
+	if len(options) == 0 {
+		return nil, errors.New("at least one expiry option is required")
+	}
+
+	return options, nil
+}
+
+func (app *application) expiryDays() []int {
+	days := make([]int, len(app.config.expiryOptions))
+	for i, o := range app.config.expiryOptions {
+		days[i] = o.Days
+	}
+
+	return days
+}
+
+// defaultExpiry is the longest configured lifetime, used to preselect the
+// create form and for snippets created without a choice.
+func (app *application) defaultExpiry() int {
+	var longest int
+	for _, days := range app.expiryDays() {
+		longest = max(longest, days)
+	}
+
+	return longest
+}
+
+// expiryMessage is the validation error shown for an expiry that is not one
+// of the configured options, e.g. "This field must equal 1, 7 or 365".
+func (app *application) expiryMessage() string {
+	days := app.expiryDays()
+
+	choices := make([]string, len(days))
+	for i, d := range days {
+		choices[i] = strconv.Itoa(d)
+	}
+
+	if len(choices) == 1 {
+		return "This field must equal " + choices[0]
+	}
+
+	return "This field must equal " + strings.Join(choices[:len(choices)-1], ", ") + " or " + choices[len(choices)-1]
+}
+
+// parseExpiresAt parses an exact expiry time. It takes RFC 3339, or the
+// "2006-01-02T15:04" form a datetime-local input submits, read in loc.
+func parseExpiresAt(s string, loc *time.Location) (time.Time, error) {
+	s = strings.TrimSpace(s)
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err == nil {
+		return t, nil
+	}
+
+	return time.ParseInLocation("2006-01-02T15:04", s, loc)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// feedLimit is the number of snippets in the feed.
+const feedLimit = 20
+
+// feedCache holds the rendered feed and serves it stale-while-revalidate:
+// once the feed is older than the TTL, readers still get the cached copy
+// straight away while a single background refresh rebuilds it. Only the very
+// first read waits for a build.
+type feedCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	build      func() ([]byte, error)
+	background func(fn func())
+	logger     *slog.Logger
+	stats      *cacheStats
+
+	body       []byte
+	built      time.Time
+	refreshing bool
+}
+
+func newFeedCache(ttl time.Duration, build func() ([]byte, error), background func(fn func()), logger *slog.Logger) *feedCache {
+	return &feedCache{
+		ttl:        ttl,
+		build:      build,
+		background: background,
+		logger:     logger,
+		stats:      newCacheStats("feed"),
+	}
+}
+
+// get returns the cached feed, starting a background refresh if it is stale
+// and none is running yet.
+func (c *feedCache) get() ([]byte, error) {
+	c.mu.Lock()
+
+	if c.body == nil {
+		defer c.mu.Unlock()
+
+		c.stats.miss()
+
+		body, err := c.build()
+		if err != nil {
+			return nil, err
+		}
+
+		c.body, c.built = body, time.Now()
+		return c.body, nil
+	}
+
+	c.stats.hit()
+
+	body := c.body
+
+	refresh := time.Since(c.built) > c.ttl && !c.refreshing
+	if refresh {
+		c.refreshing = true
+	}
+
+	c.mu.Unlock()
+
+	if refresh {
+		c.background(c.refresh)
+	}
+
+	return body, nil
+}
+
+// refresh rebuilds the feed. On failure the stale feed is kept, and the next
+// read tries again.
+func (c *feedCache) refresh() {
+	body, err := c.build()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.refreshing = false
+
+	if err != nil {
+		c.logger.Error("refreshing feed", "error", err.Error())
+		return
+	}
+
+	c.body, c.built = body, time.Now()
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	GUID        string `xml:"guid"`
+	PubDate     string `xml:"pubDate"`
+	Description string `xml:"description"`
+}
+
+// buildFeed renders the latest snippets as an RSS 2.0 document.
+func (app *application) buildFeed() ([]byte, error) {
+	snippets, err := app.snippets.Latest(feedLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	feed := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:       app.config.appName,
+			Link:        app.config.baseURL + "/",
+			Description: "The latest snippets on " + app.config.appName,
+		},
+	}
+
+	for _, s := range snippets {
+		link := app.config.baseURL + "/snippet/view/" + snippetIDs.encode(s.ID)
+
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       s.Title,
+			Link:        link,
+			GUID:        link,
+			PubDate:     s.Created.UTC().Format(time.RFC1123Z),
+			Description: s.Content,
+		})
+	}
+
+	buf := bytes.NewBufferString(xml.Header)
+
+	err = xml.NewEncoder(buf).Encode(feed)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// feed godoc
+// @Summary      RSS feed
+// @Description  RSS 2.0 feed of the latest snippets. The feed is cached for -feed-ttl and refreshed in the background once stale, so reads never wait on the database after the first.
+// @Tags         snippets
+// @Produce      xml
+// @Success      200 {string} string "RSS document"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /feed.xml [get]
+func (app *application) feed(w http.ResponseWriter, r *http.Request) {
+	body, err := app.feedCache.get()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	w.Write(body)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/assert"
+)
+
+func TestFeedCacheStaleWhileRevalidate(t *testing.T) {
+	var (
+		builds  atomic.Int32
+		release = make(chan struct{})
+		wg      sync.WaitGroup
+	)
+
+	build := func() ([]byte, error) {
+		if builds.Add(1) == 1 {
+			return []byte("v1"), nil
+		}
+
+		<-release
+		return []byte("v2"), nil
+	}
+
+	background := func(fn func()) {
+		wg.Go(fn)
+	}
+
+	c := newFeedCache(time.Minute, build, background, slog.New(slog.DiscardHandler))
+
+	body, err := c.get()
+	assert.NilError(t, err)
+	assert.Equal(t, string(body), "v1")
+	assert.Equal(t, builds.Load(), int32(1))
+
+	// A fresh feed is served without rebuilding.
+	body, err = c.get()
+	assert.NilError(t, err)
+	assert.Equal(t, string(body), "v1")
+	assert.Equal(t, builds.Load(), int32(1))
+
+	c.mu.Lock()
+	c.built = time.Now().Add(-2 * time.Minute)
+	c.mu.Unlock()
+
+	// Concurrent readers of the stale feed all get it straight away, while
+	// the refresh they started is still blocked.
+	var readers sync.WaitGroup
+
+	for range 10 {
+		readers.Go(func() {
+			body, err := c.get()
+			assert.NilError(t, err)
+			assert.Equal(t, string(body), "v1")
+		})
+	}
+
+	readers.Wait()
+
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, builds.Load(), int32(2))
+
+	body, err = c.get()
+	assert.NilError(t, err)
+	assert.Equal(t, string(body), "v2")
+	assert.Equal(t, builds.Load(), int32(2))
+}
+
+func TestFeedCacheRefreshError(t *testing.T) {
+	var builds atomic.Int32
+
+	build := func() ([]byte, error) {
+		if builds.Add(1) == 1 {
+			return []byte("v1"), nil
+		}
+
+		return nil, errors.New("database unavailable")
+	}
+
+	c := newFeedCache(time.Minute, build, func(fn func()) { fn() }, slog.New(slog.DiscardHandler))
+
+	_, err := c.get()
+	assert.NilError(t, err)
+
+	c.built = time.Now().Add(-2 * time.Minute)
+
+	// The failed refresh keeps the stale feed, and the next read retries.
+	body, err := c.get()
+	assert.NilError(t, err)
+	assert.Equal(t, string(body), "v1")
+
+	body, err = c.get()
+	assert.NilError(t, err)
+	assert.Equal(t, string(body), "v1")
+	assert.Equal(t, builds.Load(), int32(3))
+}
+
+func TestFeed(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, header, body := ts.get(t, "/feed.xml")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Type"), "application/rss+xml; charset=utf-8")
+	assert.StringContains(t, body, "<title>An old silent pond</title>")
+	assert.StringContains(t, body, "<link>https://snippetbox.example.com/snippet/view/1</link>")
+}
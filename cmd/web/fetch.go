@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	// errBlockedAddress is returned when a URL resolves to an address the
+	// fetcher must not connect to, such as one on the server's own network.
+	errBlockedAddress = errors.New("fetch: address not allowed")
+	// errResponseTooLarge is returned when a response is larger than the
+	// fetcher's limit.
+	errResponseTooLarge = errors.New("fetch: response too large")
+	// errUnsupportedScheme is returned for URLs other than http and https.
+	errUnsupportedScheme = errors.New("fetch: unsupported scheme")
+)
+
+// sharedAddressSpace is the carrier-grade NAT range, which netip doesn't
+// count as private but is no more reachable from outside.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// publicAddr reports whether addr is a public unicast address: not
+// loopback, private, link-local, multicast or unspecified.
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddressSpace.Contains(addr)
+}
+
+// urlFetcher fetches user-supplied URLs without letting them reach the
+// server's own network. The address check runs on every connection after
+// DNS resolution, so it also covers redirects and hostnames that resolve to
+// internal addresses.
+type urlFetcher struct {
+	client   *http.Client
+	maxBytes int64
+	// allowed decides which addresses may be connected to. It is publicAddr
+	// except in tests, which serve from loopback.
+	allowed func(netip.Addr) bool
+}
+
+func newURLFetcher(timeout time.Duration, maxBytes int64) *urlFetcher {
+	f := &urlFetcher{maxBytes: maxBytes, allowed: publicAddr}
+
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, c syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !f.allowed(addrPort.Addr()) {
+				return errBlockedAddress
+			}
+			return nil
+		},
+	}
+
+	f.client = &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// A proxy would make the connection, bypassing the address check.
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   timeout,
+			ResponseHeaderTimeout: timeout,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("fetch: too many redirects")
+			}
+			return checkFetchScheme(req.URL)
+		},
+	}
+
+	return f
+}
+
+func checkFetchScheme(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errUnsupportedScheme
+	}
+
+	return nil
+}
+
+// fetch returns the body of a successful GET of rawURL, failing with
+// errResponseTooLarge rather than reading more than maxBytes of it.
+func (f *urlFetcher) fetch(ctx context.Context, rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkFetchScheme(u)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch: unexpected status %s", resp.Status)
+	}
+
+	if resp.ContentLength > f.maxBytes {
+		return nil, errResponseTooLarge
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(body)) > f.maxBytes {
+		return nil, errResponseTooLarge
+	}
+
+	return body, nil
+}
+
+// titleFromURL suggests a snippet title for content fetched from u: the
+// last segment of its path, or its host if the path has none.
+func titleFromURL(u *url.URL) string {
+	name := path.Base(u.Path)
+	if name == "." || name == "/" {
+		return u.Hostname()
+	}
+
+	return strings.TrimSpace(name)
+}
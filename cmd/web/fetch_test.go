@@ -0,0 +1,168 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/assert"
+)
+
+func TestPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.215.14", true},
+		{"2606:2800:21f:cb07:6820:80da:af6b:8b2c", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"224.0.0.1", false},
+		{"::ffff:127.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			assert.Equal(t, publicAddr(netip.MustParseAddr(tt.addr)), tt.want)
+		})
+	}
+}
+
+func TestSnippetImportURL(t *testing.T) {
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/files/hello.go":
+			w.Write([]byte("package main\n"))
+		case "/big":
+			w.Write([]byte(strings.Repeat("a", 2048)))
+		case "/big-streamed":
+			// Flushing first sends the body chunked, without a length.
+			w.(http.Flusher).Flush()
+			w.Write([]byte(strings.Repeat("a", 2048)))
+		case "/redirect":
+			http.Redirect(w, r, "http://10.0.0.1/secret", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer remote.Close()
+
+	tests := []struct {
+		name      string
+		url       string
+		loopback  bool
+		wantCode  int
+		wantError string
+		wantTitle string
+	}{
+		{
+			name:      "Success",
+			url:       remote.URL + "/files/hello.go",
+			loopback:  true,
+			wantCode:  http.StatusSeeOther,
+			wantTitle: "hello.go",
+		},
+		{
+			name:      "Loopback",
+			url:       remote.URL + "/files/hello.go",
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "This address is not allowed",
+		},
+		{
+			name:      "Private",
+			url:       "http://10.0.0.1/",
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "This address is not allowed",
+		},
+		{
+			name:      "Cloud metadata",
+			url:       "http://169.254.169.254/latest/meta-data/",
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "This address is not allowed",
+		},
+		{
+			name:      "Redirect to private",
+			url:       remote.URL + "/redirect",
+			loopback:  true,
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "This address is not allowed",
+		},
+		{
+			name:      "Oversized",
+			url:       remote.URL + "/big",
+			loopback:  true,
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "This resource is larger than 1024 bytes",
+		},
+		{
+			name:      "Oversized without length",
+			url:       remote.URL + "/big-streamed",
+			loopback:  true,
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "This resource is larger than 1024 bytes",
+		},
+		{
+			name:      "Not found",
+			url:       remote.URL + "/missing",
+			loopback:  true,
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "This resource could not be fetched",
+		},
+		{
+			name:      "Other scheme",
+			url:       "file:///etc/passwd",
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "This field must be an http or https URL",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+
+			if tt.loopback {
+				// The remote server listens on loopback; other addresses
+				// stay blocked.
+				app.fetcher.allowed = func(addr netip.Addr) bool {
+					return addr.IsLoopback() || publicAddr(addr)
+				}
+			}
+
+			snippets := &contentRecordingSnippetModel{}
+			app.snippets = snippets
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t)
+
+			_, _, body := ts.get(t, "/snippet/create")
+
+			form := url.Values{}
+			form.Add("url", tt.url)
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, _, body := ts.postForm(t, "/snippet/import-url", form)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, snippets.title, tt.wantTitle)
+
+			if tt.wantError != "" {
+				assert.StringContains(t, body, tt.wantError)
+				assert.Equal(t, snippets.content, "")
+			} else {
+				assert.Equal(t, snippets.content, "package main\n")
+			}
+		})
+	}
+}
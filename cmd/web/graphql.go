@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/models"
+	"github.com/Vadim-Makhnev/snippetbox/internal/validator"
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+// graphqlSchema is the GraphQL schema served at /graphql. Only queries are
+// supported.
+const graphqlSchema = `
+	schema {
+		query: Query
+	}
+
+	type Query {
+		snippet(id: ID!): Snippet
+		snippets(page: Int = 1, sort: String): [Snippet!]
+		me: Viewer
+	}
+
+	type Snippet {
+		id: ID!
+		title: String!
+		content: String!
+		language: String!
+		private: Boolean!
+		tags: [String!]!
+		created: String!
+		expires: String!
+		author: User
+	}
+
+	type User {
+		id: ID!
+		name: String!
+	}
+
+	type Viewer {
+		id: ID!
+		name: String!
+		email: String!
+		created: String!
+	}
+`
+
+type graphqlRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// graphqlArgumentError is a resolver error caused by the query's arguments,
+// which is reported to the client. Any other resolver error is a server
+// error.
+type graphqlArgumentError string
+
+func (e graphqlArgumentError) Error() string {
+	return string(e)
+}
+
+// graphql godoc
+// @Summary      GraphQL queries
+// @Description  Run a GraphQL query against snippets and users. The schema has snippet(id: ID!), snippets(page: Int, sort: String) listing live public snippets with the default page size of -page-size-api, and me, the user authenticated with a bearer token or null. A private snippet is null to anyone but its owner, as are missing and expired snippets. Only queries are supported. Errors are reported in the errors member of a 200 response.
+// @Tags         api
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        payload body graphqlRequest true "Query, with optional variables"
+// @Success      200 {object} map[string]any "The data and any errors"
+// @Failure      400 {object} problemDetails "Bad request - malformed JSON"
+// @Failure      429 {object} problemDetails "Too many requests - rate limit exceeded"
+// @Failure      500 {object} problemDetails "Internal server error"
+// @Router       /graphql [post]
+func (app *application) graphql() http.HandlerFunc {
+	schema := graphql.MustParseSchema(graphqlSchema, &graphqlResolver{app: app})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req graphqlRequest
+
+		err := app.readJSON(w, r, &req)
+		if err != nil {
+			app.problem(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		result := schema.Exec(r.Context(), req.Query, req.OperationName, graphqlVariables(req.Variables))
+
+		for _, qerr := range result.Errors {
+			var argErr graphqlArgumentError
+			if qerr.ResolverError != nil && !errors.As(qerr.ResolverError, &argErr) {
+				app.serverError(w, r, qerr.ResolverError)
+				return
+			}
+		}
+
+		resp := envelope{}
+		if result.Data != nil {
+			resp["data"] = result.Data
+		}
+		if len(result.Errors) > 0 {
+			resp["errors"] = result.Errors
+		}
+
+		err = app.writeJSON(w, http.StatusOK, resp, nil)
+		if err != nil {
+			app.serverError(w, r, err)
+		}
+	}
+}
+
+// graphqlVariables converts the integral numbers among the decoded JSON
+// variables to the int32 that the GraphQL Int and ID types take.
+func graphqlVariables(vars map[string]any) map[string]any {
+	for name, value := range vars {
+		if n, ok := value.(float64); ok && n == float64(int32(n)) {
+			vars[name] = int32(n)
+		}
+	}
+
+	return vars
+}
+
+// graphqlViewerID returns the ID of the user authenticated for the query, or
+// 0 for anonymous queries.
+func graphqlViewerID(ctx context.Context) int {
+	id, _ := ctx.Value(authenticatedUserIDContextKey).(int)
+	return id
+}
+
+// graphqlResolver resolves the fields of the Query type.
+type graphqlResolver struct {
+	app *application
+}
+
+func (q *graphqlResolver) Snippet(ctx context.Context, args struct{ ID graphql.ID }) (*snippetResolver, error) {
+	id, ok := snippetIDs.decode(string(args.ID))
+	if !ok {
+		return nil, nil
+	}
+
+	s, err := q.app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if s.Private && s.UserID != graphqlViewerID(ctx) {
+		return nil, nil
+	}
+
+	return &snippetResolver{app: q.app, s: s}, nil
+}
+
+func (q *graphqlResolver) Snippets(args struct {
+	Page int32
+	Sort *string
+}) (*[]*snippetResolver, error) {
+	filters := models.Filters{
+		Page:         int(args.Page),
+		PageSize:     q.app.config.pageSizes.api.Default,
+		Sort:         q.app.listSort("-created"),
+		SortSafelist: snippetSortSafelist,
+	}
+
+	if args.Sort != nil {
+		filters.Sort = *args.Sort
+	}
+
+	var v validator.Validator
+	filters.Validate(&v)
+
+	if !v.Valid() {
+		var msgs []string
+		for _, key := range slices.Sorted(maps.Keys(v.FieldErrors)) {
+			msgs = append(msgs, fmt.Sprintf("Argument %q: %s", key, v.FieldErrors[key]))
+		}
+
+		return nil, graphqlArgumentError(strings.Join(msgs, "; "))
+	}
+
+	snippets, _, err := q.app.snippets.List(filters)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, len(snippets))
+	for i, s := range snippets {
+		ids[i] = s.ID
+	}
+
+	tags, err := q.app.snippets.TagsFor(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	authors, err := q.app.snippetAuthors(snippets)
+	if err != nil {
+		return nil, err
+	}
+
+	list := []*snippetResolver{}
+
+	for _, s := range snippets {
+		s.Tags = tags[s.ID]
+		list = append(list, &snippetResolver{app: q.app, s: s, authors: authors})
+	}
+
+	return &list, nil
+}
+
+func (q *graphqlResolver) Me(ctx context.Context) (*viewerResolver, error) {
+	id := graphqlViewerID(ctx)
+	if id == 0 {
+		return nil, nil
+	}
+
+	user, err := q.app.users.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &viewerResolver{u: user}, nil
+}
+
+type snippetResolver struct {
+	app *application
+	s   models.Snippet
+
+	// authors, when set, holds the authors of a whole page of snippets,
+	// loaded together so Author doesn't look each one up.
+	authors map[int]models.User
+}
+
+func (r *snippetResolver) ID() graphql.ID {
+	return graphql.ID(snippetIDs.encode(r.s.ID))
+}
+
+func (r *snippetResolver) Title() string {
+	return r.s.Title
+}
+
+func (r *snippetResolver) Content() string {
+	return r.s.Content
+}
+
+func (r *snippetResolver) Language() string {
+	return r.s.Language
+}
+
+func (r *snippetResolver) Private() bool {
+	return r.s.Private
+}
+
+func (r *snippetResolver) Tags() []string {
+	return append([]string{}, r.s.Tags...)
+}
+
+func (r *snippetResolver) Created() string {
+	return r.s.Created.UTC().Format(time.RFC3339)
+}
+
+func (r *snippetResolver) Expires() string {
+	return r.s.Expires.UTC().Format(time.RFC3339)
+}
+
+// Author resolves the public fields of the snippet's author, which is null
+// for anonymous snippets.
+func (r *snippetResolver) Author() (*userResolver, error) {
+	if r.s.UserID == 0 {
+		return nil, nil
+	}
+
+	if r.authors != nil {
+		user, ok := r.authors[r.s.UserID]
+		if !ok {
+			return nil, nil
+		}
+
+		return &userResolver{u: &user}, nil
+	}
+
+	user, err := r.app.users.Get(r.s.UserID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &userResolver{u: user}, nil
+}
+
+// userResolver resolves the public fields of a user.
+type userResolver struct {
+	u *models.User
+}
+
+func (r *userResolver) ID() graphql.ID {
+	return graphql.ID(strconv.Itoa(r.u.ID))
+}
+
+func (r *userResolver) Name() string {
+	return r.u.Name
+}
+
+// viewerResolver resolves the authenticated user, whose own email address
+// is visible to them.
+type viewerResolver struct {
+	u *models.User
+}
+
+func (r *viewerResolver) ID() graphql.ID {
+	return graphql.ID(strconv.Itoa(r.u.ID))
+}
+
+func (r *viewerResolver) Name() string {
+	return r.u.Name
+}
+
+func (r *viewerResolver) Email() string {
+	return r.u.Email
+}
+
+func (r *viewerResolver) Created() string {
+	return r.u.Created.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/assert"
+	"github.com/Vadim-Makhnev/snippetbox/internal/models"
+	"github.com/Vadim-Makhnev/snippetbox/internal/models/mocks"
+)
+
+func TestGraphQL(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.limiter.enabled = false
+	app.snippets = &strangerSnippetModel{}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	query := func(t *testing.T, token, query string, variables map[string]any) string {
+		header := make(http.Header)
+		if token != "" {
+			header.Set("Authorization", "Bearer "+token)
+		}
+
+		body, err := json.Marshal(map[string]any{"query": query, "variables": variables})
+		assert.NilError(t, err)
+
+		code, _, resp := ts.postJSON(t, "/graphql", header, string(body))
+		assert.Equal(t, code, http.StatusOK)
+
+		return strings.TrimSpace(resp)
+	}
+
+	tests := []struct {
+		name      string
+		token     string
+		query     string
+		variables map[string]any
+		want      string
+	}{
+		{
+			name:  "Snippet",
+			query: `{ snippet(id: "1") { id title author { name } } }`,
+			want:  `{"data":{"snippet":{"id":"1","title":"An old silent pond","author":{"name":"Alice"}}}}`,
+		},
+		{
+			name:      "Variables and aliases",
+			query:     `query Get($id: ID!) { first: snippet(id: $id) { name: title } }`,
+			variables: map[string]any{"id": 1},
+			want:      `{"data":{"first":{"name":"An old silent pond"}}}`,
+		},
+		{
+			name:  "Missing snippet",
+			query: `{ snippet(id: 2) { title } }`,
+			want:  `{"data":{"snippet":null}}`,
+		},
+		{
+			name:  "Private snippet for anonymous users",
+			query: `{ snippet(id: 5) { title } }`,
+			want:  `{"data":{"snippet":null}}`,
+		},
+		{
+			name:  "Private snippet for its owner",
+			token: mocks.AliceToken,
+			query: `{ snippet(id: 5) { title private } }`,
+			want:  `{"data":{"snippet":{"title":"Private","private":true}}}`,
+		},
+		{
+			name:  "Private snippet for another user",
+			token: mocks.AliceToken,
+			query: `{ snippet(id: 6) { title } }`,
+			want:  `{"data":{"snippet":null}}`,
+		},
+		{
+			name:  "Snippets",
+			query: `{ snippets(sort: "created") { id } }`,
+			want:  `{"data":{"snippets":[{"id":"1"},{"id":"3"}]}}`,
+		},
+		{
+			name:  "Snippets page past the end",
+			query: `{ snippets(page: 2) { id } }`,
+			want:  `{"data":{"snippets":[]}}`,
+		},
+		{
+			name:  "Invalid sort",
+			query: `{ snippets(sort: "title") { id } }`,
+			want:  `{"data":{"snippets":null},"errors":[{"message":"Argument \"sort\": This field must be one of created, -created","path":["snippets"]}]}`,
+		},
+		{
+			name:  "Fragments",
+			query: `{ snippet(id: 1) { ...Fields } } fragment Fields on Snippet { id tags }`,
+			want:  `{"data":{"snippet":{"id":"1","tags":[]}}}`,
+		},
+		{
+			name:  "Anonymous me",
+			query: `{ me { name } }`,
+			want:  `{"data":{"me":null}}`,
+		},
+		{
+			name:  "Me",
+			token: mocks.AliceToken,
+			query: `{ me { id email } __typename }`,
+			want:  `{"data":{"me":{"id":"1","email":"alice@example.com"},"__typename":"Query"}}`,
+		},
+		{
+			name:  "Unknown field",
+			query: `{ snippet(id: 1) { password } }`,
+			want:  `{"errors":[{"message":"Cannot query field \"password\" on type \"Snippet\".","locations":[{"line":1,"column":20}]}]}`,
+		},
+		{
+			name:  "Author email",
+			query: `{ snippet(id: 1) { author { email } } }`,
+			want:  `{"errors":[{"message":"Cannot query field \"email\" on type \"User\".","locations":[{"line":1,"column":29}]}]}`,
+		},
+		{
+			name:  "Missing selection",
+			query: `{ snippet(id: 1) }`,
+			want:  `{"errors":[{"message":"Field \"snippet\" of type \"Snippet\" must have a selection of subfields. Did you mean \"snippet { ... }\"?","locations":[{"line":1,"column":3}]}]}`,
+		},
+		{
+			name:  "Mutation",
+			query: `mutation { snippet(id: 1) { id } }`,
+			want:  `{"errors":[{"message":"no mutations are offered by the schema"}]}`,
+		},
+		{
+			name:  "Syntax error",
+			query: `{ snippet(id: 1) { id }`,
+			want:  `{"errors":[{"message":"syntax error: unexpected \"\", expecting Ident","locations":[{"line":1,"column":24}]}]}`,
+		},
+		{
+			name:  "Undefined variable",
+			query: `{ snippet(id: $id) { id } }`,
+			want:  `{"errors":[{"message":"Variable \"$id\" is not defined.","locations":[{"line":1,"column":15},{"line":1,"column":1}]}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, query(t, tt.token, tt.query, tt.variables), tt.want)
+		})
+	}
+}
+
+type failingListSnippetModel struct {
+	mocks.SnippetModel
+}
+
+func (m *failingListSnippetModel) List(f models.Filters) ([]models.Snippet, int, error) {
+	return nil, 0, errors.New("connection refused")
+}
+
+func TestGraphQLServerError(t *testing.T) {
+	app := newTestApplication(t)
+	app.snippets = &failingListSnippetModel{}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.postJSON(t, "/graphql", nil, `{"query": "{ snippets { id } }"}`)
+	assert.Equal(t, code, http.StatusInternalServerError)
+	assert.Equal(t, strings.Contains(body, "connection refused"), false)
+}
+
+type taggedSnippetModel struct {
+	mocks.SnippetModel
+	snippets []models.Snippet
+	tags     map[int][]string
+	queries  *int
+}
+
+func (m *taggedSnippetModel) List(f models.Filters) ([]models.Snippet, int, error) {
+	*m.queries++
+	return m.snippets, len(m.snippets), nil
+}
+
+func (m *taggedSnippetModel) TagsFor(ids []int) (map[int][]string, error) {
+	*m.queries++
+	return m.tags, nil
+}
+
+func TestGraphQLSnippetsBatched(t *testing.T) {
+	app := newTestApplication(t)
+
+	var queries int
+
+	var snippets []models.Snippet
+	for i := 1; i <= 10; i++ {
+		snippets = append(snippets, models.Snippet{ID: i, UserID: i%2 + 1, Title: fmt.Sprintf("Snippet %d", i)})
+	}
+
+	app.snippets = &taggedSnippetModel{
+		snippets: snippets,
+		tags:     map[int][]string{1: {"go", "sql"}},
+		queries:  &queries,
+	}
+	app.users = &countingUserModel{
+		users: map[int]models.User{
+			1: {ID: 1, Name: "Alice"},
+			2: {ID: 2, Name: "Bob"},
+		},
+		queries: &queries,
+	}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.postJSON(t, "/graphql", nil, `{"query": "{ snippets { id tags author { name } } }"}`)
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, queries, 3)
+
+	var resp struct {
+		Data struct {
+			Snippets []struct {
+				ID     string
+				Tags   []string
+				Author struct{ Name string }
+			}
+		}
+	}
+
+	assert.NilError(t, json.Unmarshal([]byte(body), &resp))
+	assert.Equal(t, len(resp.Data.Snippets), 10)
+	assert.Equal(t, strings.Join(resp.Data.Snippets[0].Tags, ","), "go,sql")
+	assert.Equal(t, resp.Data.Snippets[0].Author.Name, "Bob")
+	assert.Equal(t, len(resp.Data.Snippets[1].Tags), 0)
+	assert.Equal(t, resp.Data.Snippets[1].Author.Name, "Alice")
+}
@@ -1,151 +1,1238 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"net/url"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/Vadim-Makhnev/snippetbox/internal/models"
 	"github.com/Vadim-Makhnev/snippetbox/internal/validator"
+	"github.com/Vadim-Makhnev/snippetbox/ui"
 )
 
 type snippetCreateForm struct {
 	Title               string `form:"title"`
 	Content             string `form:"content"`
+	Language            string `form:"language"`
+	Private             bool   `form:"private"`
+	Tags                string `form:"tags"`
 	Expires             int    `form:"expires"`
+	ExpiresAt           string `form:"expires_at"`
+	FormToken           string `form:"form_token"`
+	ImportURL           string `form:"-"`
 	validator.Validator `form:"-"`
 }
 
+type snippetImportForm struct {
+	URL string `form:"url"`
+}
+
+type snippetArchiveForm struct {
+	From                string `form:"from"`
+	To                  string `form:"to"`
+	Sort                string `form:"sort"`
+	PageSize            int    `form:"page_size"`
+	validator.Validator `form:"-"`
+}
+
+type commentCreateForm struct {
+	Body                string `form:"body"`
+	validator.Validator `form:"-"`
+}
+
+type snippetPreviewForm struct {
+	Content string `form:"content"`
+}
+
 type userSignupForm struct {
 	Name                string `form:"name"`
 	Email               string `form:"email"`
 	Password            string `form:"password"`
+	Invite              string `form:"invite"`
+	Terms               bool   `form:"terms"`
 	validator.Validator `form:"-"`
 }
 
 type userLoginForm struct {
 	Email               string `form:"email"`
 	Password            string `form:"password"`
+	Next                string `form:"next"`
+	validator.Validator `form:"-"`
+}
+
+type accountPasswordUpdateForm struct {
+	CurrentPassword         string `form:"currentPassword"`
+	NewPassword             string `form:"newPassword"`
+	NewPasswordConfirmation string `form:"newPasswordConfirmation"`
+	validator.Validator     `form:"-"`
+}
+
+type accountEmailUpdateForm struct {
+	NewEmail            string `form:"newEmail"`
+	CurrentPassword     string `form:"currentPassword"`
 	validator.Validator `form:"-"`
 }
 
-// Home godoc
-// @Summary      Get home page with latest snippets
-// @Description  Retrieve the latest snippets and render the home page
-// @Tags         pages
-// @Produce      html
-// @Success      200 {string} string "HTML page"
-// @Router       / [get]
-func (app *application) home(w http.ResponseWriter, r *http.Request) {
-	w.Header().Add("Server", "Go")
+// Home godoc
+// @Summary      Get home page with latest snippets
+// @Description  Retrieve the latest snippets and render the home page, with up to -featured-limit snippets featured by admins above them. With -personalized-home, logged-in users see their own latest snippets, private ones included, instead of everyone's. The latest snippets are listed newest first unless -default-sort or the sort parameter says otherwise. Anonymous pages may be cached for -home-cache-max-age, only by the browser when the response sets a cookie; pages for logged-in users, or showing a flash message, are marked no-store.
+// @Tags         pages
+// @Produce      html
+// @Param        sort query string false "Order of the latest snippets by creation time; the default is -default-sort if set" Enums(created, -created) default(-created)
+// @Param        page_size query int false "Number of latest snippets, capped to -page-size-home (1-50 by default)" default(10)
+// @Success      200 {string} string "HTML page"
+// @Failure      400 {string} string "Bad request - invalid sort"
+// @Router       / [get]
+func (app *application) home(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Server", "Go")
+
+	qs := r.URL.Query()
+
+	sort := app.readString(qs, "sort", app.listSort("-created"))
+	if !validator.PermittedValue(sort, snippetSortSafelist...) {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	// The page size is capped rather than refused, and a malformed one is
+	// ignored, as the home page has no form to report errors on.
+	var v validator.Validator
+	limits := app.config.pageSizes.home
+	limit := limits.Clamp(app.readInt(qs, "page_size", limits.Default, &v))
+
+	personalized := app.config.personalizedHome && app.isAuthenticated(r)
+
+	var snippets []models.Snippet
+	var err error
+
+	if personalized {
+		snippets, err = app.snippets.LatestByUser(app.authenticatedUserID(r), limit, true)
+	} else {
+		snippets, err = app.snippets.Latest(limit)
+	}
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Both lists come newest first, so only the other order needs work.
+	if sort == "created" {
+		slices.Reverse(snippets)
+	}
+
+	authors, err := app.snippetAuthors(snippets)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippets = snippets
+	data.Authors = authors
+	data.Personalized = personalized
+
+	if app.config.featuredLimit > 0 {
+		data.Featured, err = app.snippets.Featured(app.config.featuredLimit)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	if app.config.snippetOfDay {
+		snippet, err := app.snippets.OfTheDay(time.Now())
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
+			return
+		}
+
+		if err == nil {
+			data.SnippetOfDay = &snippet
+		}
+	}
+
+	app.setHomeCacheHeaders(w, r, data)
+
+	app.render(w, r, http.StatusOK, "home.tmpl", data)
+}
+
+// setHomeCacheHeaders lets browsers and CDNs cache the home page briefly,
+// for -home-cache-max-age. Only anonymous pages without a flash message are
+// cacheable, and the response varies on the cookie so a logged-in page is
+// never served from an anonymous one's cache entry. A response that sets a
+// cookie, such as the CSRF cookie on a first visit, is only cached by the
+// browser, so a shared cache can't hand one visitor's cookie to everyone.
+func (app *application) setHomeCacheHeaders(w http.ResponseWriter, r *http.Request, data templateData) {
+	// The session and CSRF middleware already vary on the cookie.
+	for _, field := range []string{"Accept", "Cookie"} {
+		if !slices.Contains(w.Header().Values("Vary"), field) {
+			w.Header().Add("Vary", field)
+		}
+	}
+
+	switch {
+	case app.isAuthenticated(r) || data.Flash != nil:
+		w.Header().Set("Cache-Control", "no-store")
+	case app.config.homeCacheMaxAge >= time.Second:
+		visibility := "public"
+		if len(w.Header().Values("Set-Cookie")) > 0 {
+			visibility = "private"
+		}
+
+		w.Header().Set("Cache-Control", fmt.Sprintf("%s, max-age=%d", visibility, int(app.config.homeCacheMaxAge.Seconds())))
+	default:
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+}
+
+// snippetView godoc
+// @Summary      Get snippet by id
+// @Description  Retrieve snippet by snippet id, with a page of its comments, oldest first. The view is counted in the snippet's stats, along with the referring site. With -auto-extend-popular, a view close to expiry extends the snippet by a day.
+// @Tags         snippets
+// @Produce      html
+// @Param        id path string true "Snippet ID, or its opaque code when -obfuscate-ids is set"
+// @Param        page query int false "Page of comments; pages out of range show the nearest one" default(1)
+// @Success      200 {string} string "HTML page"
+// @Failure      404 {string} string "Snippet not found"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /snippet/view/{id} [get]
+func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
+	id, ok := snippetIDs.decode(r.PathValue("id"))
+	if !ok {
+		app.notFound(w, r)
+		return
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if snippet.Private && snippet.UserID != app.authenticatedUserID(r) {
+		app.notFound(w, r)
+		return
+	}
+
+	referrer := referrerHost(r)
+
+	app.background(func() {
+		err := app.snippets.RecordView(snippet.ID, referrer)
+		if err != nil {
+			app.logger.Error(err.Error(), "snippet", snippet.ID)
+		}
+	})
+
+	if app.config.autoExtend.enabled && nearExpiry(snippet, time.Now(), app.config.autoExtend.window) {
+		app.background(func() {
+			err := app.snippets.ExtendExpiry(snippet.ID, snippet.Expires)
+			if err != nil {
+				app.logger.Error(err.Error(), "snippet", snippet.ID)
+			}
+		})
+	}
+
+	// A bad page number shows the nearest page, as in the admin user list.
+	var v validator.Validator
+	page := max(1, min(app.readInt(r.URL.Query(), "page", 1, &v), 10_000_000))
+
+	data, err := app.snippetViewData(r, snippet, page)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.render(w, r, http.StatusOK, "view.tmpl", data)
+}
+
+// snippetViewData returns the template data of view.tmpl for snippet, with
+// the given page of its comments and an empty comment form.
+func (app *application) snippetViewData(r *http.Request, snippet models.Snippet, page int) (templateData, error) {
+	comments, err := app.comments.ForSnippet(snippet.ID, models.Filters{Page: page, PageSize: defaultPageSize})
+	if err != nil {
+		return templateData{}, err
+	}
+
+	canModerate, err := app.canModerateComments(r, snippet)
+	if err != nil {
+		return templateData{}, err
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Comments = comments.Comments
+	data.CanModerate = canModerate
+	data.Page = comments.Page
+	data.LastPage = comments.LastPage
+	data.Form = commentCreateForm{}
+
+	if !snippet.Private && app.isAuthenticated(r) && snippet.UserID == app.authenticatedUserID(r) {
+		pinned, err := app.snippets.Pinned(snippet.UserID)
+		if err != nil {
+			return templateData{}, err
+		}
+
+		data.SnippetPinned = slices.ContainsFunc(pinned, func(s models.Snippet) bool {
+			return s.ID == snippet.ID
+		})
+	}
+
+	if len(snippet.Content) > maxViewContentBytes {
+		data.Snippet.Content = truncateUTF8(snippet.Content, maxViewContentBytes)
+		data.Truncated = true
+	}
+
+	return data, nil
+}
+
+// maxCommentChars caps the length of a comment.
+const maxCommentChars = 2000
+
+// snippetCommentCreate godoc
+// @Summary      Comment on a snippet
+// @Description  Add a comment to a snippet the logged-in user can see. Comments on private snippets are only possible, and only shown, to the snippet's owner. With -comment-cooldown, a user has to wait that long after their last comment before posting another.
+// @Tags         snippets
+// @Accept       x-www-form-urlencoded
+// @Produce      html
+// @Param        id path string true "Snippet ID, or its opaque code when -obfuscate-ids is set"
+// @Param        body formData string true "Comment text, at most 2000 characters"
+// @Success      303 {string} string "Redirect to the snippet's comments"
+// @Failure      400 {string} string "Bad request - invalid form"
+// @Failure      404 {string} string "Snippet not found"
+// @Failure      422 {string} string "Validation errors, shown on the snippet page"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /snippet/{id}/comments [post]
+func (app *application) snippetCommentCreate(w http.ResponseWriter, r *http.Request) {
+	id, ok := snippetIDs.decode(r.PathValue("id"))
+	if !ok {
+		app.notFound(w, r)
+		return
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	if snippet.Private && snippet.UserID != userID {
+		app.notFound(w, r)
+		return
+	}
+
+	var form commentCreateForm
+
+	err = app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	var bodyOK bool
+	form.Body, bodyOK = toUTF8(form.Body)
+
+	form.CheckField(bodyOK, "body", "This field must be UTF-8 or Latin-1 text")
+	form.CheckField(validator.NotBlank(form.Body), "body", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Body, maxCommentChars), "body", fmt.Sprintf("This field cannot be more than %d characters long", maxCommentChars))
+	form.CheckField(validator.NoControlChars(form.Body), "body", "This field cannot contain control characters")
+
+	if app.config.commentCooldown > 0 {
+		wait, err := app.commentCooldownLeft(userID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		if wait > 0 {
+			form.AddNonFieldError(fmt.Sprintf("Please wait %d seconds before posting another comment", int(math.Ceil(wait.Seconds()))))
+		}
+	}
+
+	if !form.Valid() {
+		data, err := app.snippetViewData(r, snippet, 1)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "view.tmpl", data)
+		return
+	}
+
+	_, err = app.comments.Insert(snippet.ID, userID, form.Body)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.redirectWithFlash(w, r, "/snippet/view/"+snippetIDs.encode(snippet.ID)+"#comments", flashSuccess, "Comment posted!")
+}
+
+// snippetCommentDelete godoc
+// @Summary      Delete a comment
+// @Description  Remove a comment from a snippet's thread. It stays in place shown as [removed], so replies around it keep their context. Only the snippet's owner and admins can delete comments; on another user's private snippet, other users get 404 as if it didn't exist.
+// @Tags         snippets
+// @Produce      html
+// @Param        id path string true "Snippet ID, or its opaque code when -obfuscate-ids is set"
+// @Param        cid path int true "Comment ID"
+// @Success      303 {string} string "Redirect to the snippet's comments"
+// @Failure      403 {string} string "Not the snippet's owner or an admin"
+// @Failure      404 {string} string "Snippet or comment not found, or the comment was already deleted"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /snippet/{id}/comments/{cid}/delete [post]
+func (app *application) snippetCommentDelete(w http.ResponseWriter, r *http.Request) {
+	id, ok := snippetIDs.decode(r.PathValue("id"))
+	if !ok {
+		app.notFound(w, r)
+		return
+	}
+
+	commentID, err := strconv.Atoi(r.PathValue("cid"))
+	if err != nil || commentID < 1 {
+		app.notFound(w, r)
+		return
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	canModerate, err := app.canModerateComments(r, snippet)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if !canModerate {
+		if snippet.Private {
+			app.notFound(w, r)
+		} else {
+			app.clientError(w, r, http.StatusForbidden)
+		}
+		return
+	}
+
+	err = app.comments.Delete(snippet.ID, commentID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.redirectWithFlash(w, r, "/snippet/view/"+snippetIDs.encode(snippet.ID)+"#comments", flashSuccess, "Comment removed.")
+}
+
+// ownSnippet returns the snippet named by the id path value if it belongs
+// to the logged-in user. Otherwise it sends 404, or 403 for another user's
+// public snippet, and returns false.
+func (app *application) ownSnippet(w http.ResponseWriter, r *http.Request) (models.Snippet, bool) {
+	id, ok := snippetIDs.decode(r.PathValue("id"))
+	if !ok {
+		app.notFound(w, r)
+		return models.Snippet{}, false
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return models.Snippet{}, false
+	}
+
+	if snippet.UserID != app.authenticatedUserID(r) {
+		if snippet.Private {
+			app.notFound(w, r)
+		} else {
+			app.clientError(w, r, http.StatusForbidden)
+		}
+		return models.Snippet{}, false
+	}
+
+	return snippet, true
+}
+
+// snippetPin godoc
+// @Summary      Pin a snippet to the owner's profile
+// @Description  Show one of the logged-in user's public snippets at the top of their profile, after the ones pinned before it. A user can pin at most -max-pins snippets; past that, and for private snippets, the pin is refused with a flash message. Pinning a pinned snippet does nothing.
+// @Tags         snippets
+// @Produce      html
+// @Param        id path string true "Snippet ID, or its opaque code when -obfuscate-ids is set"
+// @Success      303 {string} string "Redirect to the user's profile, or to the snippet if it is private"
+// @Failure      403 {string} string "Not the snippet's owner"
+// @Failure      404 {string} string "Snippet not found"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /snippet/{id}/pin [post]
+func (app *application) snippetPin(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	if snippet.Private {
+		app.redirectWithFlash(w, r, "/snippet/view/"+snippetIDs.encode(snippet.ID), flashError, "Private snippets can't be pinned to your profile.")
+		return
+	}
+
+	profile := fmt.Sprintf("/u/%d", snippet.UserID)
+
+	err := app.snippets.Pin(snippet.UserID, snippet.ID, app.config.maxPins)
+	if err != nil {
+		if errors.Is(err, models.ErrTooManyPins) {
+			app.redirectWithFlash(w, r, profile, flashError, fmt.Sprintf("You can pin at most %d snippets. Unpin one first.", app.config.maxPins))
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.redirectWithFlash(w, r, profile, flashSuccess, "Snippet pinned to your profile.")
+}
+
+// snippetUnpin godoc
+// @Summary      Unpin a snippet from the owner's profile
+// @Description  Stop showing one of the logged-in user's snippets at the top of their profile. Unpinning a snippet that isn't pinned does nothing.
+// @Tags         snippets
+// @Produce      html
+// @Param        id path string true "Snippet ID, or its opaque code when -obfuscate-ids is set"
+// @Success      303 {string} string "Redirect to the user's profile"
+// @Failure      403 {string} string "Not the snippet's owner"
+// @Failure      404 {string} string "Snippet not found"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /snippet/{id}/unpin [post]
+func (app *application) snippetUnpin(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.snippets.Unpin(snippet.UserID, snippet.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.redirectWithFlash(w, r, fmt.Sprintf("/u/%d", snippet.UserID), flashSuccess, "Snippet unpinned.")
+}
+
+// userProfile godoc
+// @Summary      User profile
+// @Description  Show a user's public snippets: the ones they pinned first, in the order they pinned them, then their other latest snippets, newest first. Private snippets and the user's email are never shown.
+// @Tags         users
+// @Produce      html
+// @Param        id path int true "User ID"
+// @Success      200 {string} string "HTML page"
+// @Failure      404 {string} string "User not found"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /u/{id} [get]
+func (app *application) userProfile(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		app.notFound(w, r)
+		return
+	}
+
+	user, err := app.users.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	pinned, err := app.snippets.Pinned(user.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Pinned snippets are left out of the latest ones, so enough are
+	// fetched to fill the list after dropping them.
+	limit := app.config.pageSizes.home.Default
+
+	latest, err := app.snippets.LatestByUser(user.ID, limit+len(pinned), false)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	latest = slices.DeleteFunc(latest, func(s models.Snippet) bool {
+		return slices.ContainsFunc(pinned, func(p models.Snippet) bool { return p.ID == s.ID })
+	})
+	latest = latest[:min(limit, len(latest))]
+
+	data := app.newTemplateData(r)
+	data.Profile = models.User{ID: user.ID, Name: user.Name, Created: user.Created}
+	data.Pinned = pinned
+	data.Snippets = latest
+
+	app.render(w, r, http.StatusOK, "profile.tmpl", data)
+}
+
+// nearExpiry reports whether s is in the last window percent of its
+// lifetime at now.
+func nearExpiry(s models.Snippet, now time.Time, window int) bool {
+	lifetime := s.Expires.Sub(s.Created)
+	remaining := s.Expires.Sub(now)
+
+	return lifetime > 0 && remaining*100 <= lifetime*time.Duration(window)
+}
+
+// maxViewContentBytes caps how much content the HTML view renders. The page
+// is buffered before it is sent, so larger snippets link to the streamed raw
+// view for the rest.
+const maxViewContentBytes = 32 * 1024
+
+// truncateUTF8 cuts s to at most n bytes without splitting a character.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return s[:n]
+}
+
+// snippetStats godoc
+// @Summary      Show snippet view stats
+// @Description  Show the owner of a snippet how many times it was viewed, per day over the last 30 days, and which sites referred the views
+// @Tags         snippets
+// @Produce      html
+// @Param        id path string true "Snippet ID, or its opaque code when -obfuscate-ids is set"
+// @Success      200 {string} string "HTML page"
+// @Failure      403 {string} string "Not the snippet's owner"
+// @Failure      404 {string} string "Snippet not found"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /snippet/stats/{id} [get]
+func (app *application) snippetStats(w http.ResponseWriter, r *http.Request) {
+	id, ok := snippetIDs.decode(r.PathValue("id"))
+	if !ok {
+		app.notFound(w, r)
+		return
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if snippet.UserID != app.authenticatedUserID(r) {
+		app.clientError(w, r, http.StatusForbidden)
+		return
+	}
+
+	stats, err := app.snippets.ViewStats(id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.ViewStats = stats
+
+	app.render(w, r, http.StatusOK, "stats.tmpl", data)
+}
+
+// snippetRaw godoc
+// @Summary      Get raw snippet content
+// @Description  Retrieve the snippet content as plain text, streamed from the database in chunks. Byte ranges can be requested with a Range header. The response is always served as text/plain with nosniff so stored content is never interpreted as HTML or script.
+// @Tags         snippets
+// @Produce      plain
+// @Param        id path string true "Snippet ID, or its opaque code when -obfuscate-ids is set"
+// @Param        Range header string false "Byte range to fetch, such as bytes=0-9"
+// @Success      200 {string} string "Snippet content"
+// @Success      206 {string} string "The requested range of the snippet content"
+// @Failure      404 {string} string "Snippet not found"
+// @Failure      416 {string} string "Range not satisfiable"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /snippet/raw/{id} [get]
+func (app *application) snippetRaw(w http.ResponseWriter, r *http.Request) {
+	id, ok := snippetIDs.decode(r.PathValue("id"))
+	if !ok {
+		app.notFound(w, r)
+		return
+	}
+
+	content, err := app.snippets.ContentReader(id, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	// ServeContent answers Range requests by seeking, and the reader only
+	// fetches the chunks of content the requested range covers. Snippets have
+	// no modification time to offer for conditional requests.
+	http.ServeContent(w, r, "", time.Time{}, content)
+}
+
+// snippetCreate godoc
+// @Summary      Show snippet creation form
+// @Description  Display the form for creating a new code snippet. It is pre-filled from the named preset if one is given and exists, otherwise from the user's saved draft if there is one, otherwise with the -default-snippet-content scaffold.
+// @Tags         snippets
+// @Produce      html
+// @Param        preset query string false "Name of a preset from ui/presets.json to start from"
+// @Success      200 {string} string "Snippet creation form"
+// @Router       /snippet/create [get]
+func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+
+	form := snippetCreateForm{
+		Content:   app.config.defaultContent,
+		Expires:   app.defaultExpiry(),
+		FormToken: app.newFormToken(r),
+	}
+
+	draft, err := app.drafts.Get(app.authenticatedUserID(r))
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if err == nil {
+		form.Title = draft.Title
+		form.Content = draft.Content
+		if draft.Expires != 0 {
+			form.Expires = draft.Expires
+		}
+	}
+
+	// Asking for a preset is more deliberate than leaving a draft, so it
+	// wins. Unknown presets are ignored.
+	if p, ok := app.presets[r.URL.Query().Get("preset")]; ok {
+		form.Title = p.Title
+		form.Content = p.Content
+	}
+
+	data.Form = form
+	data.Presets = app.presets.names()
+
+	app.render(w, r, http.StatusOK, "create.tmpl", data)
+}
+
+// snippetCreatePost godoc
+// @Summary      Create new snippet
+// @Description  Create a new code snippet with validation. A title matching one of the user's live snippets is allowed but noted in the flash message. With -create-cooldown, a user must wait that long after their last snippet before creating another.
+// @Tags         snippets
+// @Accept       x-www-form-urlencoded
+// @Produce      html
+// @Param        title formData string true "Snippet title" minlength(1) maxlength(100)
+// @Param        content formData string true "Snippet content" minlength(1)
+// @Param        language formData string false "Snippet language, one of -languages, or empty for plain text, or for a guess from the content with -detect-language"
+// @Param        tags formData string false "Comma-separated tags, case-insensitive, up to -max-tags-per-snippet different ones"
+// @Param        expires formData int false "Expiration in days, unless expires_at is given" Enums(1, 7, 365)
+// @Param        expires_at formData string false "Exact expiry time, in RFC 3339 or as a local date and time in the user's time zone, instead of expires"
+// @Success      303 {string} string "Redirect to created snippet"
+// @Failure      400 {string} string "Bad request - invalid form data"
+// @Failure      422 {string} string "Unprocessable entity - validation failed"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /snippet/create [post]
+func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request) error {
+	var form snippetCreateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return nil
+	}
+
+	if !app.consumeFormToken(r, form.FormToken) {
+		form.AddNonFieldError("This form has already been submitted")
+	}
+
+	// Form values are raw bytes, unlike JSON strings, which the decoder
+	// already makes valid UTF-8.
+	var titleOK, contentOK bool
+	form.Title, titleOK = toUTF8(form.Title)
+	form.Content, contentOK = toUTF8(form.Content)
+
+	form.CheckField(titleOK, "title", "This field must be UTF-8 or Latin-1 text")
+	form.CheckField(contentOK, "content", "This field must be UTF-8 or Latin-1 text")
+
+	form.Title = app.normalizeTitle(form.Title)
+
+	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
+	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+	form.CheckField(validator.NoControlChars(form.Content), "content", "This field cannot contain control characters")
+	form.CheckField(app.permittedLanguage(form.Language), "language", "This field must be one of the listed languages")
+
+	tags := normalizeTags(splitTags(form.Tags))
+	app.checkTags(&form.Validator, tags)
+
+	var expiresAt time.Time
+
+	switch {
+	case form.ExpiresAt == "":
+		form.CheckField(validator.PermittedValue(form.Expires, app.expiryDays()...), "expires", app.expiryMessage())
+	case form.Expires != 0:
+		form.AddFieldError("expires_at", "Choose either a preset or an exact time, not both")
+	default:
+		expiresAt, err = parseExpiresAt(form.ExpiresAt, userLocation(r))
+		if err != nil {
+			form.AddFieldError("expires_at", "This field must be a date and time such as 2026-01-02T15:04:05Z")
+		} else {
+			form.CheckField(expiresAt.After(time.Now()), "expires_at", "This field must be in the future")
+		}
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	if app.config.createCooldown > 0 {
+		wait, err := app.createCooldownLeft(userID)
+		if err != nil {
+			return err
+		}
+
+		if wait > 0 {
+			form.AddNonFieldError(fmt.Sprintf("Please wait %d seconds before creating another snippet", int(math.Ceil(wait.Seconds()))))
+		}
+	}
+
+	if !form.Valid() {
+		form.FormToken = app.newFormToken(r)
+
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "create.tmpl", data)
+		return nil
+	}
+
+	// The route runs under txHandler, so the snippet and its tags are saved
+	// together: a failure to save the tags leaves no untagged snippet behind.
+	snippets := app.snippets.WithTx(app.tx(r))
+
+	// A duplicate title is allowed, but the user is told about it in case it
+	// was an accident. It has to be checked before the insert, which would
+	// otherwise match itself.
+	duplicate, err := snippets.TitleExistsForUser(userID, form.Title)
+	if err != nil {
+		return err
+	}
+
+	form.Language = app.snippetLanguage(form.Language, form.Content)
+
+	var id int
+
+	if form.ExpiresAt != "" {
+		id, err = snippets.InsertUntil(userID, form.Title, form.Content, form.Language, form.Private, expiresAt)
+	} else {
+		id, err = snippets.Insert(userID, form.Title, form.Content, form.Language, form.Private, form.Expires)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(tags) > 0 {
+		err = snippets.SetTags(id, tags)
+		if err != nil {
+			return err
+		}
+	}
+
+	app.afterCommit(r, func() {
+		app.webhooks.dispatch(webhookEvent{Event: eventSnippetCreated, SnippetID: id, UserID: userID, Title: form.Title})
+
+		// The snippet exists now, so a stale draft is only logged rather
+		// than failing the request.
+		err := app.drafts.Delete(userID)
+		if err != nil {
+			app.logger.Error("could not clear draft", "user_id", userID, "error", err.Error())
+		}
+	})
+
+	level, msg := flashSuccess, "Snippet successfully created!"
+	if duplicate {
+		level, msg = flashWarning, fmt.Sprintf("Snippet successfully created! You already have a snippet named %q.", form.Title)
+	}
+
+	app.redirectWithFlash(w, r, "/snippet/view/"+snippetIDs.encode(id), level, msg)
+	return nil
+}
+
+// snippetImportURL godoc
+// @Summary      Create a snippet from a URL
+// @Description  Fetch a text resource from a public http or https URL and create a snippet of it, titled after the last segment of the URL's path, with the longest expiry option. Addresses on private, loopback and link-local networks are refused, including after redirects, as are responses larger than -import-max-bytes or slower than -import-timeout. The -create-cooldown applies.
+// @Tags         snippets
+// @Accept       x-www-form-urlencoded
+// @Produce      html
+// @Param        url formData string true "URL to import from"
+// @Success      303 {string} string "Redirect to created snippet"
+// @Failure      422 {string} string "Unprocessable entity - URL not allowed, not fetchable, too large or not text"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /snippet/import-url [post]
+func (app *application) snippetImportURL(w http.ResponseWriter, r *http.Request) {
+	var input snippetImportForm
+
+	err := app.decodePostForm(r, &input)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form := snippetCreateForm{
+		Content:   app.config.defaultContent,
+		Expires:   app.defaultExpiry(),
+		ImportURL: input.URL,
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	if app.config.createCooldown > 0 {
+		wait, err := app.createCooldownLeft(userID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		if wait > 0 {
+			form.AddNonFieldError(fmt.Sprintf("Please wait %d seconds before creating another snippet", int(math.Ceil(wait.Seconds()))))
+		}
+	}
+
+	u, err := url.Parse(strings.TrimSpace(input.URL))
+	if err != nil || u.Host == "" || checkFetchScheme(u) != nil {
+		form.AddFieldError("url", "This field must be an http or https URL")
+	}
+
+	var title, content string
+
+	if form.Valid() {
+		body, err := app.fetcher.fetch(r.Context(), u.String())
+		switch {
+		case errors.Is(err, errBlockedAddress):
+			form.AddFieldError("url", "This address is not allowed")
+		case errors.Is(err, errResponseTooLarge):
+			form.AddFieldError("url", fmt.Sprintf("This resource is larger than %d bytes", app.config.importMaxBytes))
+		case err != nil:
+			app.logger.Info("snippet import failed", "url", u.String(), "error", err.Error())
+			form.AddFieldError("url", "This resource could not be fetched")
+		default:
+			var ok bool
+			content, ok = toUTF8(string(body))
+			form.CheckField(ok && validator.NoControlChars(content), "url", "This resource must be text")
+			form.CheckField(validator.NotBlank(content), "url", "This resource is empty")
+
+			title = app.importedTitle(titleFromURL(u), "Imported snippet")
+		}
+	}
+
+	if !form.Valid() {
+		form.FormToken = app.newFormToken(r)
+
+		data := app.newTemplateData(r)
+		data.Form = form
+		data.Presets = app.presets.names()
+		app.render(w, r, http.StatusUnprocessableEntity, "create.tmpl", data)
+		return
+	}
+
+	language := app.snippetLanguage("", content)
+
+	id, err := app.snippets.Insert(userID, title, content, language, false, form.Expires)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.webhooks.dispatch(webhookEvent{Event: eventSnippetCreated, SnippetID: id, UserID: userID, Title: title})
+
+	app.redirectWithFlash(w, r, "/snippet/view/"+snippetIDs.encode(id), flashSuccess, "Snippet successfully imported!")
+}
+
+// importedTitle returns the title of a snippet imported from a file or URL
+// called name: name normalized and cut to 100 characters, or fallback if
+// nothing is left of it.
+func (app *application) importedTitle(name, fallback string) string {
+	title := app.normalizeTitle(name)
+	if utf8.RuneCountInString(title) > 100 {
+		title = string([]rune(title)[:100])
+	}
+
+	if !validator.NotBlank(title) {
+		return fallback
+	}
+
+	return title
+}
+
+// snippetUpload godoc
+// @Summary      Create a snippet from an uploaded file
+// @Description  Create a snippet of an uploaded text file, titled after the file's name, with the longest expiry option. The file's type is sniffed from its content, and must be one of -upload-allowed-types; when extensions are listed there, its name must also have one of them. Files larger than -import-max-bytes are refused. The -create-cooldown applies.
+// @Tags         snippets
+// @Accept       multipart/form-data
+// @Produce      html
+// @Param        file formData file true "Text file to create the snippet from"
+// @Success      303 {string} string "Redirect to created snippet"
+// @Failure      400 {string} string "Bad request - not a multipart form, or far too large"
+// @Failure      422 {string} string "Unprocessable entity - no file, or a file that is too large, empty or not an allowed type"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /snippet/upload [post]
+func (app *application) snippetUpload(w http.ResponseWriter, r *http.Request) {
+	form := snippetCreateForm{
+		Content: app.config.defaultContent,
+		Expires: app.defaultExpiry(),
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	if app.config.createCooldown > 0 {
+		wait, err := app.createCooldownLeft(userID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		if wait > 0 {
+			form.AddNonFieldError(fmt.Sprintf("Please wait %d seconds before creating another snippet", int(math.Ceil(wait.Seconds()))))
+		}
+	}
+
+	var title, content string
+
+	file, header, err := r.FormFile("file")
+	switch {
+	case errors.Is(err, http.ErrMissingFile):
+		form.AddFieldError("file", "Choose a file to upload")
+	case err != nil:
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	default:
+		defer file.Close()
+
+		body, err := io.ReadAll(io.LimitReader(file, app.config.importMaxBytes+1))
+		switch {
+		case err != nil:
+			app.serverError(w, r, err)
+			return
+		case int64(len(body)) > app.config.importMaxBytes:
+			form.AddFieldError("file", fmt.Sprintf("This file is larger than %d bytes", app.config.importMaxBytes))
+		case !uploadAllowed(app.config.uploadAllowedTypes, header.Filename, body):
+			form.AddFieldError("file", "This type of file is not allowed")
+		default:
+			var ok bool
+			content, ok = toUTF8(string(body))
+			form.CheckField(ok && validator.NoControlChars(content), "file", "This file must be text")
+			form.CheckField(validator.NotBlank(content), "file", "This file is empty")
+
+			title = app.importedTitle(header.Filename, "Uploaded snippet")
+		}
+	}
+
+	if !form.Valid() {
+		form.FormToken = app.newFormToken(r)
+
+		data := app.newTemplateData(r)
+		data.Form = form
+		data.Presets = app.presets.names()
+		app.render(w, r, http.StatusUnprocessableEntity, "create.tmpl", data)
+		return
+	}
+
+	language := app.snippetLanguage("", content)
 
-	snippets, err := app.snippets.Latest()
+	id, err := app.snippets.Insert(userID, title, content, language, false, form.Expires)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
-	data := app.newTemplateData(r)
-	data.Snippets = snippets
+	app.webhooks.dispatch(webhookEvent{Event: eventSnippetCreated, SnippetID: id, UserID: userID, Title: title})
 
-	app.render(w, r, http.StatusOK, "home.tmpl", data)
+	app.redirectWithFlash(w, r, "/snippet/view/"+snippetIDs.encode(id), flashSuccess, "Snippet successfully uploaded!")
 }
 
-// snippetView godoc
-// @Summary      Get snippet by id
-// @Description  Retrieve snippet by snippet id
+// snippetSortSafelist are the orders snippet listings can be sorted in.
+var snippetSortSafelist = []string{"created", "-created"}
+
+// checkDefaultSort returns an error unless sort, the -default-sort flag, is
+// empty or one of snippetSortSafelist.
+func checkDefaultSort(sort string) error {
+	if sort != "" && !slices.Contains(snippetSortSafelist, sort) {
+		return fmt.Errorf("-default-sort must be one of %s", strings.Join(snippetSortSafelist, ", "))
+	}
+
+	return nil
+}
+
+// listSort returns the order a snippet listing is sorted in when the request
+// doesn't say: -default-sort if it is set, otherwise the listing's own.
+func (app *application) listSort(natural string) string {
+	if app.config.defaultSort != "" {
+		return app.config.defaultSort
+	}
+
+	return natural
+}
+
+// snippetArchive godoc
+// @Summary      Browse snippets by date
+// @Description  List live snippets created between two dates, inclusive, paginated and oldest first unless -default-sort or the sort parameter says otherwise. Without dates only the search form is shown.
 // @Tags         snippets
 // @Produce      html
-// @Param        id path int true "Snippet ID"
-// @Success      200 {string} string "HTML page"
-// @Failure      404 {string} string "Snippet not found"
+// @Param        from query string false "First day of the range (YYYY-MM-DD)"
+// @Param        to query string false "Last day of the range (YYYY-MM-DD)"
+// @Param        page query int false "Page number" default(1)
+// @Param        page_size query int false "Snippets per page, within -page-size-search (1-50 by default)" default(20)
+// @Param        sort query string false "Order by creation time, oldest first or newest first; the default is -default-sort if set" Enums(created, -created) default(created)
+// @Success      200 {string} string "Archive page"
+// @Failure      422 {string} string "Unprocessable entity - malformed dates, inverted range or invalid paging"
 // @Failure      500 {string} string "Internal server error"
-// @Router       /snippet/view/{id} [get]
-func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.Atoi(r.PathValue("id"))
-	if err != nil || id < 1 {
-		http.NotFound(w, r)
+// @Router       /snippet/archive [get]
+func (app *application) snippetArchive(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	form := snippetArchiveForm{
+		From: qs.Get("from"),
+		To:   qs.Get("to"),
+		Sort: app.listSort("created"),
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+
+	if form.From == "" && form.To == "" {
+		app.render(w, r, http.StatusOK, "archive.tmpl", data)
 		return
 	}
 
-	snippet, err := app.snippets.Get(id)
+	filters := app.readFilters(qs, app.listSort("created"), snippetSortSafelist, app.config.pageSizes.search, &form.Validator)
+	form.Sort = filters.Sort
+	form.PageSize = filters.PageSize
+
+	from, fromErr := time.Parse(time.DateOnly, form.From)
+	to, toErr := time.Parse(time.DateOnly, form.To)
+
+	form.CheckField(fromErr == nil, "from", "This field must be a date in YYYY-MM-DD format")
+	form.CheckField(toErr == nil, "to", "This field must be a date in YYYY-MM-DD format")
+	if fromErr == nil && toErr == nil {
+		form.CheckField(!to.Before(from), "to", "This date cannot be before the from date")
+	}
+
+	data.Form = form
+
+	if !form.Valid() {
+		app.render(w, r, http.StatusUnprocessableEntity, "archive.tmpl", data)
+		return
+	}
+
+	snippets, total, err := app.snippets.Between(from, to.AddDate(0, 0, 1), filters)
 	if err != nil {
-		if errors.Is(err, models.ErrNoRecord) {
-			http.NotFound(w, r)
-		} else {
-			app.serverError(w, r, err)
-		}
+		app.serverError(w, r, err)
 		return
 	}
 
-	data := app.newTemplateData(r)
-	data.Snippet = snippet
+	authors, err := app.snippetAuthors(snippets)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 
-	app.render(w, r, http.StatusOK, "view.tmpl", data)
+	data.Snippets = snippets
+	data.Authors = authors
+	data.Page = filters.Page
+	data.LastPage = filters.LastPage(total)
+
+	app.render(w, r, http.StatusOK, "archive.tmpl", data)
 }
 
-// snippetCreate godoc
-// @Summary      Show snippet creation form
-// @Description  Display the form for creating a new code snippet
+// archiveIndex godoc
+// @Summary      Show monthly archive
+// @Description  List the months that have live snippets, newest first, with the number created in each month (UTC). Each month links to the date range archive.
 // @Tags         snippets
 // @Produce      html
-// @Success      200 {string} string "Snippet creation form"
-// @Router       /snippet/create [get]
-func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
-	data := app.newTemplateData(r)
-
-	data.Form = snippetCreateForm{
-		Expires: 365,
+// @Success      200 {string} string "Archive index page"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /archive [get]
+func (app *application) archiveIndex(w http.ResponseWriter, r *http.Request) {
+	counts, err := app.snippets.MonthlyCounts()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
 	}
 
-	app.render(w, r, http.StatusOK, "create.tmpl", data)
+	data := app.newTemplateData(r)
+	data.MonthlyCounts = counts
+
+	app.render(w, r, http.StatusOK, "months.tmpl", data)
 }
 
-// snippetCreatePost godoc
-// @Summary      Create new snippet
-// @Description  Create a new code snippet with validation
+// snippetPreview godoc
+// @Summary      Preview snippet content
+// @Description  Render snippet content as Markdown and return the sanitized HTML fragment, using the same renderer as the final page
 // @Tags         snippets
 // @Accept       x-www-form-urlencoded
 // @Produce      html
-// @Param        title formData string true "Snippet title" minlength(1) maxlength(100)
-// @Param        content formData string true "Snippet content" minlength(1)
-// @Param        expires formData int true "Expiration in days" Enums(1, 7, 365)
-// @Success      303 {string} string "Redirect to created snippet"
-// @Failure      400 {string} string "Bad request - invalid form data"
-// @Failure      422 {string} string "Unprocessable entity - validation failed"
+// @Param        content formData string false "Snippet content in Markdown"
+// @Success      200 {string} string "Rendered HTML fragment"
+// @Failure      400 {string} string "Bad request - invalid form data or CSRF token"
 // @Failure      500 {string} string "Internal server error"
-// @Router       /snippet/create [post]
-func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request) {
-	var form snippetCreateForm
+// @Router       /snippet/preview [post]
+func (app *application) snippetPreview(w http.ResponseWriter, r *http.Request) {
+	var form snippetPreviewForm
 
 	err := app.decodePostForm(r, &form)
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
-		return
-	}
-
-	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
-	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
-	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
-	form.CheckField(validator.PermittedValue(form.Expires, 1, 7, 365), "expires", "This field must equal 1, 7 or 365")
-
-	if !form.Valid() {
-		data := app.newTemplateData(r)
-		data.Form = form
-		app.render(w, r, http.StatusUnprocessableEntity, "create.tmpl", data)
+		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
 
-	id, err := app.snippets.Insert(form.Title, form.Content, form.Expires)
+	html, err := renderMarkdown(form.Content)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
-	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully created!")
-
-	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(html))
 }
 
 // userSignup godoc
@@ -153,42 +1240,59 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 // @Description  Display the form for new user registration
 // @Tags         auth
 // @Produce      html
-// @Success      200 {string} string "User registration form"
+// @Success      200 {string} string "User registration form, or a registration closed page when -signup-enabled=false"
 // @Router       /user/signup [get]
 func (app *application) userSignup(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
+
+	if !app.config.signupEnabled {
+		app.render(w, r, http.StatusOK, "signup_closed.tmpl", data)
+		return
+	}
+
 	data.Form = userSignupForm{}
 	app.render(w, r, http.StatusOK, "signup.tmpl", data)
 }
 
 // userSignupPost godoc
 // @Summary      Register new user
-// @Description  Create a new user account with email and password validation. Checks for duplicate emails.
+// @Description  Create a new user account with email and password validation. Checks for duplicate emails and, when -disposable-domains is set, rejects disposable email domains.
 // @Tags         auth
 // @Accept       x-www-form-urlencoded
 // @Produce      html
 // @Param        name formData string true "User's full name" minlength(1) maxlength(255)
 // @Param        email formData string true "User's email address" format(email)
-// @Param        password formData string true "User's password" minlength(8)
+// @Param        password formData string true "User's password, at least -min-password-length characters" minlength(8)
+// @Param        invite formData string false "Unused invite code, required when -require-invite is set"
+// @Param        terms formData bool false "Acceptance of the terms of service, required when -require-terms is set"
 // @Success      303 {string} string "Redirect to login page with success message"
 // @Failure      400 {string} string "Bad request - invalid form data"
-// @Failure      422 {string} string "Unprocessable entity - validation failed or duplicate email"
+// @Failure      403 {string} string "Forbidden - signup is disabled with -signup-enabled=false"
+// @Failure      422 {string} string "Unprocessable entity - validation failed, disposable or duplicate email, or invalid invite code"
 // @Failure      500 {string} string "Internal server error"
 // @Router       /user/signup [post]
 func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
+	if !app.config.signupEnabled {
+		app.clientError(w, r, http.StatusForbidden)
+		return
+	}
+
 	var form userSignupForm
 
 	err := app.decodePostForm(r, &form)
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
 
-	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
-	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
-	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
-	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
-	form.CheckField(validator.MinChars(form.Password, 8), "password", "This field must be at least 8 characters long")
+	app.checkSignup(&form.Validator, form.Name, form.Email, form.Password)
+	app.checkTerms(&form.Validator, form.Terms)
+
+	err = app.checkInvite(&form.Validator, form.Invite)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 
 	if !form.Valid() {
 		data := app.newTemplateData(r)
@@ -197,24 +1301,25 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = app.users.Insert(form.Name, form.Email, form.Password)
+	err = app.signup(r.Context(), form.Name, form.Email, form.Password, form.Invite, form.Terms)
 	if err != nil {
-		if errors.Is(err, models.ErrDuplicateEmail) {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			form.AddFieldError("invite", "This invite code is invalid or has already been used")
+		case errors.Is(err, models.ErrDuplicateEmail):
 			form.AddFieldError("email", "Email address is already in use")
-
-			data := app.newTemplateData(r)
-			data.Form = form
-			app.render(w, r, http.StatusUnprocessableEntity, "signup.tmpl", data)
-		} else {
+		default:
 			app.serverError(w, r, err)
+			return
 		}
 
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "signup.tmpl", data)
 		return
 	}
 
-	app.sessionManager.Put(r.Context(), "flash", "Your signup was successful. Please log in.")
-
-	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+	app.redirectWithFlash(w, r, "/user/login", flashSuccess, "Your signup was successful. Please log in.")
 }
 
 // userLogin godoc
@@ -222,23 +1327,25 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 // @Description  Display the form for user authentication
 // @Tags         auth
 // @Produce      html
+// @Param        next query string false "Local path to go to after logging in, instead of the default for the user's role"
 // @Success      200 {string} string "User login form"
 // @Router       /user/login [get]
 func (app *application) userLogin(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
-	data.Form = userLoginForm{}
+	data.Form = userLoginForm{Next: r.URL.Query().Get("next")}
 	app.render(w, r, http.StatusOK, "login.tmpl", data)
 }
 
 // userLoginPost godoc
 // @Summary      Authenticate user
-// @Description  Verify user credentials and create session. On success, redirects to snippet creation page.
+// @Description  Verify user credentials and create session. On success, redirects to next if it is a local path, otherwise to -admin-login-redirect for admins and -login-redirect for everyone else.
 // @Tags         auth
 // @Accept       x-www-form-urlencoded
 // @Produce      html
 // @Param        email formData string true "User's email address" format(email)
 // @Param        password formData string true "User's password"
-// @Success      303 {string} string "Redirect to /snippet/create with active session"
+// @Param        next formData string false "Local path to go to after logging in"
+// @Success      303 {string} string "Redirect with active session"
 // @Failure      400 {string} string "Bad request - invalid form data"
 // @Failure      401 {string} string "Unauthorized - invalid credentials"
 // @Failure      422 {string} string "Unprocessable entity - validation failed"
@@ -250,7 +1357,7 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 
 	err := app.decodePostForm(r, &form)
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
 
@@ -285,9 +1392,18 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	renewCSRFToken(w, r)
+
 	app.sessionManager.Put(r.Context(), "authenticatedUserID", id)
+	app.sessionManager.Put(r.Context(), "loginTime", time.Now())
+
+	redirect, err := app.postLoginRedirect(id, form.Next)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 
-	http.Redirect(w, r, "/snippet/create", http.StatusSeeOther)
+	http.Redirect(w, r, redirect, http.StatusSeeOther)
 }
 
 // userLogoutPost godoc
@@ -305,9 +1421,223 @@ func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	renewCSRFToken(w, r)
+
 	app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+	app.sessionManager.Remove(r.Context(), "loginTime")
 
-	app.sessionManager.Put(r.Context(), "flash", "You've been logged out successfully!")
+	app.redirectWithFlash(w, r, "/", flashSuccess, "You've been logged out successfully!")
+}
+
+// accountPasswordUpdate godoc
+// @Summary      Show password change form
+// @Description  Display the form for changing the current user's password
+// @Tags         auth
+// @Produce      html
+// @Success      200 {string} string "Password change form"
+// @Router       /account/password/update [get]
+func (app *application) accountPasswordUpdate(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = accountPasswordUpdateForm{}
+
+	app.render(w, r, http.StatusOK, "password.tmpl", data)
+}
+
+// accountPasswordUpdatePost godoc
+// @Summary      Change password
+// @Description  Change the current user's password after verifying the current one
+// @Tags         auth
+// @Accept       x-www-form-urlencoded
+// @Produce      html
+// @Param        currentPassword formData string true "Current password"
+// @Param        newPassword formData string true "New password, at least -min-password-length characters" minlength(8)
+// @Param        newPasswordConfirmation formData string true "New password again"
+// @Success      303 {string} string "Redirect to home page with success message"
+// @Failure      400 {string} string "Bad request - invalid form data"
+// @Failure      422 {string} string "Unprocessable entity - validation failed or wrong current password"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /account/password/update [post]
+func (app *application) accountPasswordUpdatePost(w http.ResponseWriter, r *http.Request) {
+	var form accountPasswordUpdateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.CurrentPassword), "currentPassword", "This field cannot be blank")
+	form.CheckField(validator.NotBlank(form.NewPassword), "newPassword", "This field cannot be blank")
+	app.checkPassword(&form.Validator, "newPassword", form.NewPassword)
+	form.CheckField(validator.NotBlank(form.NewPasswordConfirmation), "newPasswordConfirmation", "This field cannot be blank")
+	form.CheckField(form.NewPassword == form.NewPasswordConfirmation, "newPasswordConfirmation", "Passwords do not match")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "password.tmpl", data)
+		return
+	}
+
+	err = app.users.PasswordUpdate(app.authenticatedUserID(r), form.CurrentPassword, form.NewPassword)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.AddFieldError("currentPassword", "Current password is incorrect")
+
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, r, http.StatusUnprocessableEntity, "password.tmpl", data)
+		} else {
+			app.serverError(w, r, err)
+		}
+
+		return
+	}
+
+	app.redirectWithFlash(w, r, "/", flashSuccess, "Your password has been updated!")
+}
+
+// emailChangeTTL is how long an email change confirmation link stays valid.
+const emailChangeTTL = 24 * time.Hour
+
+// accountEmailUpdate godoc
+// @Summary      Show email change form
+// @Description  Display the form for changing the current user's email address
+// @Tags         auth
+// @Produce      html
+// @Success      200 {string} string "Email change form"
+// @Router       /account/email/update [get]
+func (app *application) accountEmailUpdate(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = accountEmailUpdateForm{}
+
+	app.render(w, r, http.StatusOK, "email.tmpl", data)
+}
+
+// accountEmailUpdatePost godoc
+// @Summary      Change email
+// @Description  Change the current user's email address after verifying their password. When -smtp-addr is set, a confirmation link is sent to the new address and the old one stays in use until it is followed.
+// @Tags         auth
+// @Accept       x-www-form-urlencoded
+// @Produce      html
+// @Param        newEmail formData string true "New email address" format(email)
+// @Param        currentPassword formData string true "Current password"
+// @Success      303 {string} string "Redirect to home page with a message"
+// @Failure      400 {string} string "Bad request - invalid form data"
+// @Failure      422 {string} string "Unprocessable entity - validation failed, wrong current password or duplicate email"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /account/email/update [post]
+func (app *application) accountEmailUpdatePost(w http.ResponseWriter, r *http.Request) {
+	var form accountEmailUpdateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.NewEmail), "newEmail", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.NewEmail, validator.EmailRX), "newEmail", "This field must be a valid email address")
+	form.CheckField(validator.NotBlank(form.CurrentPassword), "currentPassword", "This field cannot be blank")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "email.tmpl", data)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	err = app.users.SetPendingEmail(userID, form.CurrentPassword, form.NewEmail)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidCredentials):
+			form.AddFieldError("currentPassword", "Current password is incorrect")
+		case errors.Is(err, models.ErrDuplicateEmail):
+			form.AddFieldError("newEmail", "Email address is already in use")
+		default:
+			app.serverError(w, r, err)
+			return
+		}
+
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "email.tmpl", data)
+		return
+	}
+
+	if app.mailer == nil {
+		err = app.users.ConfirmEmail(userID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		app.redirectWithFlash(w, r, "/", flashSuccess, "Your email address has been updated!")
+		return
+	}
+
+	// Only the latest link works, so an older request can't overwrite a
+	// newer one.
+	err = app.tokens.DeleteAllForUser(models.ScopeEmailChange, userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	token, err := app.tokens.New(userID, emailChangeTTL, models.ScopeEmailChange)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	link := app.config.baseURL + "/account/email/confirm?token=" + url.QueryEscape(token.Plaintext)
+
+	err = app.mailer.send(models.NormalizeEmail(form.NewEmail), "Confirm your new email address",
+		fmt.Sprintf("Follow this link within 24 hours to start using this address for Snippetbox:\n\n%s\n", link))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.redirectWithFlash(w, r, "/", flashInfo, "We've sent a confirmation link to your new email address. Your email changes once you follow it.")
+}
+
+// accountEmailConfirm godoc
+// @Summary      Confirm email change
+// @Description  Switch the user's email to the pending address using the token from the confirmation email
+// @Tags         auth
+// @Produce      html
+// @Param        token query string true "Confirmation token"
+// @Success      303 {string} string "Redirect to home page with a message"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /account/email/confirm [get]
+func (app *application) accountEmailConfirm(w http.ResponseWriter, r *http.Request) {
+	userID, err := app.tokens.UserID(models.ScopeEmailChange, r.URL.Query().Get("token"))
+	if err == nil {
+		err = app.users.ConfirmEmail(userID)
+	}
+
+	switch {
+	case err == nil:
+		app.flash(r, flashMessage{Level: flashSuccess, Text: "Your email address has been updated!"})
+	case errors.Is(err, models.ErrNoRecord):
+		app.flash(r, flashMessage{Level: flashError, Text: "That confirmation link is invalid or has expired."})
+	case errors.Is(err, models.ErrDuplicateEmail):
+		app.flash(r, flashMessage{Level: flashError, Text: "That email address is already in use."})
+	default:
+		app.serverError(w, r, err)
+		return
+	}
+
+	if userID != 0 {
+		err = app.tokens.DeleteAllForUser(models.ScopeEmailChange, userID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
 
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
@@ -315,3 +1645,49 @@ func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
 func ping(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("OK"))
 }
+
+func favicon(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=604800")
+	w.Header().Set("Content-Type", "image/x-icon")
+
+	http.ServeFileFS(w, r, ui.Files, "static/img/favicon.ico")
+}
+
+type webManifestIcon struct {
+	Src   string `json:"src"`
+	Type  string `json:"type"`
+	Sizes string `json:"sizes"`
+}
+
+type webManifest struct {
+	Name       string            `json:"name"`
+	ShortName  string            `json:"short_name"`
+	StartURL   string            `json:"start_url"`
+	Display    string            `json:"display"`
+	ThemeColor string            `json:"theme_color"`
+	Icons      []webManifestIcon `json:"icons"`
+}
+
+func (app *application) webManifest(w http.ResponseWriter, r *http.Request) {
+	manifest := webManifest{
+		Name:       app.config.appName,
+		ShortName:  app.config.appName,
+		StartURL:   "/",
+		Display:    "standalone",
+		ThemeColor: app.config.themeColor,
+		Icons: []webManifestIcon{
+			{Src: "/favicon.ico", Type: "image/x-icon", Sizes: "16x16"},
+		},
+	}
+
+	js, err := json.Marshal(manifest)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Header().Set("Content-Type", "application/manifest+json")
+
+	w.Write(js)
+}
@@ -1,11 +1,25 @@
 package main
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"io"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+	"unicode/utf8"
 
 	"github.com/Vadim-Makhnev/snippetbox/internal/assert"
+	"github.com/Vadim-Makhnev/snippetbox/internal/models"
+	"github.com/Vadim-Makhnev/snippetbox/internal/models/mocks"
 )
 
 func TestPing(t *testing.T) {
@@ -21,6 +35,206 @@ func TestPing(t *testing.T) {
 	assert.Equal(t, body, "OK")
 }
 
+func TestHome(t *testing.T) {
+
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Snippet of the Day")
+	assert.StringContains(t, body, "An old silent pond...")
+
+	app.config.snippetOfDay = false
+
+	code, _, body = ts.get(t, "/")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, strings.Contains(body, "Snippet of the Day"), false)
+}
+
+type htmlOfTheDaySnippetModel struct {
+	mocks.SnippetModel
+}
+
+func (m *htmlOfTheDaySnippetModel) OfTheDay(day time.Time) (models.Snippet, error) {
+	return models.Snippet{ID: 3, Title: "<i>Markup</i>", Content: "<script>alert('pwned')</script>"}, nil
+}
+
+func TestHomeEscapesSnippetOfDay(t *testing.T) {
+	app := newTestApplication(t)
+	app.snippets = &htmlOfTheDaySnippetModel{}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "&lt;i&gt;Markup&lt;/i&gt;")
+	assert.StringContains(t, body, "<pre><code>&lt;script&gt;alert(&#39;pwned&#39;)&lt;/script&gt;</code></pre>")
+	assert.Equal(t, strings.Contains(body, "<script>alert"), false)
+}
+
+type countingSnippetModel struct {
+	mocks.SnippetModel
+	snippets []models.Snippet
+	queries  *int
+}
+
+func (m *countingSnippetModel) Latest(limit int) ([]models.Snippet, error) {
+	*m.queries++
+	return m.snippets[:min(limit, len(m.snippets))], nil
+}
+
+type countingUserModel struct {
+	mocks.UserModel
+	users   map[int]models.User
+	queries *int
+}
+
+func (m *countingUserModel) GetMany(ids []int) (map[int]models.User, error) {
+	*m.queries++
+
+	users := make(map[int]models.User)
+	for _, id := range ids {
+		if u, ok := m.users[id]; ok {
+			users[id] = u
+		}
+	}
+
+	return users, nil
+}
+
+func TestHomeAuthorsSingleQuery(t *testing.T) {
+
+	app := newTestApplication(t)
+	app.config.snippetOfDay = false
+
+	var queries int
+
+	var snippets []models.Snippet
+	for i := 1; i <= 10; i++ {
+		snippets = append(snippets, models.Snippet{ID: i, UserID: i%3 + 1, Title: fmt.Sprintf("Snippet %d", i)})
+	}
+
+	app.snippets = &countingSnippetModel{snippets: snippets, queries: &queries}
+	app.users = &countingUserModel{
+		users: map[int]models.User{
+			1: {ID: 1, Name: "Alice"},
+			2: {ID: 2, Name: "Bob"},
+			3: {ID: 3, Name: "Carol"},
+		},
+		queries: &queries,
+	}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, queries, 2)
+	assert.StringContains(t, body, "Alice")
+	assert.StringContains(t, body, "Bob")
+	assert.StringContains(t, body, "Carol")
+}
+
+func TestHomeEscapesAuthorName(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.snippetOfDay = false
+
+	var queries int
+	app.snippets = &countingSnippetModel{snippets: []models.Snippet{{ID: 1, UserID: 1, Title: "Haiku"}}, queries: &queries}
+	app.users = &countingUserModel{
+		users:   map[int]models.User{1: {ID: 1, Name: "<b>Alice</b>"}},
+		queries: &queries,
+	}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<td>&lt;b&gt;Alice&lt;/b&gt;</td>")
+}
+
+func TestHomeLimit(t *testing.T) {
+
+	app := newTestApplication(t)
+	app.config.snippetOfDay = false
+
+	var queries int
+
+	var snippets []models.Snippet
+	for i := 1; i <= 20; i++ {
+		snippets = append(snippets, models.Snippet{ID: i, UserID: 1, Title: fmt.Sprintf("Snippet %d", i)})
+	}
+
+	app.snippets = &countingSnippetModel{snippets: snippets, queries: &queries}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name  string
+		limit int
+		want  int
+	}{
+		{name: "Default", limit: 10, want: 10},
+		{name: "Smaller", limit: 3, want: 3},
+		{name: "Larger than available", limit: 50, want: 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app.config.pageSizes.home.Default = tt.limit
+
+			code, _, body := ts.get(t, "/")
+
+			assert.Equal(t, code, http.StatusOK)
+			assert.Equal(t, strings.Count(body, "<a href='/snippet/view/"), tt.want)
+		})
+	}
+}
+
+func TestFavicon(t *testing.T) {
+
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, header, body := ts.get(t, "/favicon.ico")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Type"), "image/x-icon")
+	assert.StringContains(t, header.Get("Cache-Control"), "max-age=")
+	assert.Equal(t, len(body) > 0, true)
+}
+
+func TestWebManifest(t *testing.T) {
+
+	app := newTestApplication(t)
+	app.config.appName = "Pastes"
+	app.config.themeColor = "#112233"
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, header, body := ts.get(t, "/site.webmanifest")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Type"), "application/manifest+json")
+	assert.StringContains(t, header.Get("Cache-Control"), "max-age=")
+	assert.StringContains(t, body, `"name":"Pastes"`)
+	assert.StringContains(t, body, `"theme_color":"#112233"`)
+}
+
 func TestSnippetView(t *testing.T) {
 
 	app := newTestApplication(t)
@@ -78,99 +292,505 @@ func TestSnippetView(t *testing.T) {
 	}
 }
 
-func TestUserSignup(t *testing.T) {
+func TestSnippetViewLineNumbers(t *testing.T) {
 	app := newTestApplication(t)
+
 	ts := newTestServer(t, app.routes())
 	defer ts.Close()
 
-	_, _, body := ts.get(t, "/user/signup")
-	validCSRFToken := extractCSRFToken(t, body)
+	_, _, body := ts.get(t, "/snippet/view/3")
 
-	const (
-		validName     = "Bob"
-		validPassword = "validPa$$word"
-		validEmail    = "bob@example.com"
-		formTag       = "<form action='/user/signup' method='POST' novalidate>"
-	)
+	assert.StringContains(t, body, "<span class='line-number'>1</span>&lt;script&gt;")
+	assert.Equal(t, strings.Contains(body, "<script>alert"), false)
+}
+
+func TestSnippetRaw(t *testing.T) {
+
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, header, body := ts.get(t, "/snippet/raw/3")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Type"), "text/plain; charset=utf-8")
+	assert.Equal(t, header.Get("X-Content-Type-Options"), "nosniff")
+	assert.Equal(t, body, "<script>alert('pwned')</script><b>bold</b>")
+
+	code, _, _ = ts.get(t, "/snippet/raw/2")
+
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestSnippetRawRange(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	get := func(rangeHeader string) (int, http.Header, string) {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+"/snippet/raw/3", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Range", rangeHeader)
+
+		rs, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rs.Body.Close()
+
+		body, err := io.ReadAll(rs.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return rs.StatusCode, rs.Header, string(body)
+	}
+
+	code, header, body := get("bytes=0-9")
+	assert.Equal(t, code, http.StatusPartialContent)
+	assert.Equal(t, header.Get("Accept-Ranges"), "bytes")
+	assert.Equal(t, header.Get("Content-Range"), "bytes 0-9/42")
+	assert.Equal(t, header.Get("Content-Type"), "text/plain; charset=utf-8")
+	assert.Equal(t, body, "<script>al")
+
+	code, _, body = get("bytes=-6")
+	assert.Equal(t, code, http.StatusPartialContent)
+	assert.Equal(t, body, "ld</b>")
+
+	code, _, _ = get("bytes=100-")
+	assert.Equal(t, code, http.StatusRequestedRangeNotSatisfiable)
+}
+
+func TestUserSignupDisposableDomains(t *testing.T) {
+	app := newTestApplication(t)
+	app.disposableDomains = domainList{"mailinator.com": {}}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
 
 	tests := []struct {
-		name         string
-		userName     string
-		userEmail    string
-		userPassword string
-		csrfToken    string
-		wantCode     int
-		wantFormTag  string
+		name     string
+		email    string
+		wantCode int
 	}{
 		{
-			name:         "Valid submission",
-			userName:     validName,
-			userEmail:    validEmail,
-			userPassword: validPassword,
-			csrfToken:    validCSRFToken,
-			wantCode:     http.StatusSeeOther,
+			name:     "Blocked domain",
+			email:    "bob@mailinator.com",
+			wantCode: http.StatusUnprocessableEntity,
 		},
 		{
-			name:         "Invalid CSRF Token",
-			userName:     validName,
-			userEmail:    validEmail,
-			userPassword: validPassword,
-			csrfToken:    "wrongToken",
-			wantCode:     http.StatusBadRequest,
+			name:     "Blocked domain in another case",
+			email:    "bob@Mailinator.COM",
+			wantCode: http.StatusUnprocessableEntity,
 		},
 		{
-			name:         "Empty name",
-			userName:     "",
-			userEmail:    validEmail,
-			userPassword: validPassword,
-			csrfToken:    validCSRFToken,
-			wantCode:     http.StatusUnprocessableEntity,
-			wantFormTag:  formTag,
+			name:     "Allowed domain",
+			email:    "bob@example.com",
+			wantCode: http.StatusSeeOther,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, body := ts.get(t, "/user/signup")
+
+			form := url.Values{}
+			form.Add("name", "Bob")
+			form.Add("email", tt.email)
+			form.Add("password", "validPa$$word")
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, _, body := ts.postForm(t, "/user/signup", form)
+
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantCode == http.StatusUnprocessableEntity {
+				assert.StringContains(t, body, "Disposable email addresses are not allowed")
+			}
+		})
+	}
+}
+
+func TestSignupEnabled(t *testing.T) {
+	tests := []struct {
+		name        string
+		enabled     bool
+		wantGetBody string
+		wantCode    int
+	}{
+		{
+			name:        "Enabled",
+			enabled:     true,
+			wantGetBody: "<form action='/user/signup' method='POST' novalidate>",
+			wantCode:    http.StatusSeeOther,
 		},
 		{
-			name:         "Empty email",
-			userName:     validName,
-			userEmail:    "",
-			userPassword: validPassword,
-			csrfToken:    validCSRFToken,
-			wantCode:     http.StatusUnprocessableEntity,
-			wantFormTag:  formTag,
+			name:        "Disabled",
+			enabled:     false,
+			wantGetBody: "Registration closed",
+			wantCode:    http.StatusForbidden,
 		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.signupEnabled = tt.enabled
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			code, _, body := ts.get(t, "/user/signup")
+			assert.Equal(t, code, http.StatusOK)
+			assert.StringContains(t, body, tt.wantGetBody)
+
+			// The login page carries a CSRF token whether or not signup is open.
+			_, _, body = ts.get(t, "/user/login")
+
+			form := url.Values{}
+			form.Add("name", "Bob")
+			form.Add("email", "bob@example.com")
+			form.Add("password", "validPa$$word")
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, _, _ = ts.postForm(t, "/user/signup", form)
+			assert.Equal(t, code, tt.wantCode)
+
+			code, _, _ = ts.postJSON(t, "/api/v1/users", nil, `{"name": "Carol", "email": "carol@example.com", "password": "validPa$$word"}`)
+			if tt.enabled {
+				assert.Equal(t, code, http.StatusCreated)
+			} else {
+				assert.Equal(t, code, http.StatusForbidden)
+			}
+		})
+	}
+}
+
+func TestSignupTerms(t *testing.T) {
+	tests := []struct {
+		name         string
+		requireTerms bool
+		terms        bool
+		wantCode     int
+		wantAccepted bool
+	}{
 		{
-			name:         "Empty password",
-			userName:     validName,
-			userEmail:    validEmail,
-			userPassword: "",
-			csrfToken:    validCSRFToken,
-			wantCode:     http.StatusUnprocessableEntity,
-			wantFormTag:  formTag,
+			name:         "Required and accepted",
+			requireTerms: true,
+			terms:        true,
+			wantCode:     http.StatusSeeOther,
+			wantAccepted: true,
 		},
 		{
-			name:         "Invalid email",
-			userName:     validName,
-			userEmail:    "bob@example.",
-			userPassword: validPassword,
-			csrfToken:    validCSRFToken,
+			name:         "Required and unchecked",
+			requireTerms: true,
 			wantCode:     http.StatusUnprocessableEntity,
-			wantFormTag:  formTag,
 		},
 		{
-			name:         "Short password",
-			userName:     validName,
-			userEmail:    validEmail,
-			userPassword: "pa$$",
-			csrfToken:    validCSRFToken,
-			wantCode:     http.StatusUnprocessableEntity,
-			wantFormTag:  formTag,
+			name:     "Not required",
+			wantCode: http.StatusSeeOther,
 		},
 		{
-			name:         "Duplicate email",
-			userName:     validName,
-			userEmail:    "dupe@example.com",
-			userPassword: validPassword,
-			csrfToken:    validCSRFToken,
-			wantCode:     http.StatusUnprocessableEntity,
-			wantFormTag:  formTag,
+			name:         "Accepted anyway",
+			terms:        true,
+			wantCode:     http.StatusSeeOther,
+			wantAccepted: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.requireTerms = tt.requireTerms
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			_, _, body := ts.get(t, "/user/signup")
+			assert.Equal(t, strings.Contains(body, "name='terms'"), tt.requireTerms)
+
+			form := url.Values{}
+			form.Add("name", "Bob")
+			form.Add("email", "bob@example.com")
+			form.Add("password", "validPa$$word")
+			form.Add("csrf_token", extractCSRFToken(t, body))
+			if tt.terms {
+				form.Add("terms", "true")
+			}
+
+			code, _, body := ts.postForm(t, "/user/signup", form)
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantCode == http.StatusUnprocessableEntity {
+				assert.StringContains(t, body, "You must accept the terms of service")
+
+				_, err := app.users.GetByEmail("bob@example.com")
+				assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+				return
+			}
+
+			user, err := app.users.GetByEmail("bob@example.com")
+			assert.NilError(t, err)
+			assert.Equal(t, !user.TermsAcceptedAt.IsZero(), tt.wantAccepted)
+		})
+	}
+
+	app := newTestApplication(t)
+	app.config.requireTerms = true
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.postJSON(t, "/api/v1/users", nil, `{"name": "Carol", "email": "carol@example.com", "password": "validPa$$word"}`)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "You must accept the terms of service")
+
+	code, _, _ = ts.postJSON(t, "/api/v1/users", nil, `{"name": "Carol", "email": "carol@example.com", "password": "validPa$$word", "terms": true}`)
+	assert.Equal(t, code, http.StatusCreated)
+
+	user, err := app.users.GetByEmail("carol@example.com")
+	assert.NilError(t, err)
+	assert.Equal(t, user.TermsAcceptedAt.IsZero(), false)
+}
+
+type extendRecordingSnippetModel struct {
+	mocks.SnippetModel
+	snippet  models.Snippet
+	mu       sync.Mutex
+	extended []time.Time
+}
+
+func (m *extendRecordingSnippetModel) Get(id int) (models.Snippet, error) {
+	return m.snippet, nil
+}
+
+func (m *extendRecordingSnippetModel) ExtendExpiry(id int, expires time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.extended = append(m.extended, expires)
+	return nil
+}
+
+func TestSnippetViewAutoExtend(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		created    time.Time
+		expires    time.Time
+		enabled    bool
+		wantExtend bool
+	}{
+		{
+			name:       "Near expiry",
+			created:    now.Add(-9 * 24 * time.Hour),
+			expires:    now.Add(12 * time.Hour),
+			enabled:    true,
+			wantExtend: true,
+		},
+		{
+			name:       "Early view",
+			created:    now.Add(-24 * time.Hour),
+			expires:    now.Add(6 * 24 * time.Hour),
+			enabled:    true,
+			wantExtend: false,
+		},
+		{
+			name:       "Disabled",
+			created:    now.Add(-9 * 24 * time.Hour),
+			expires:    now.Add(12 * time.Hour),
+			enabled:    false,
+			wantExtend: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.autoExtend.enabled = tt.enabled
+			app.config.autoExtend.window = 10
+
+			snippets := &extendRecordingSnippetModel{
+				snippet: models.Snippet{ID: 1, Title: "Old", Content: "An old silent pond...", Created: tt.created, Expires: tt.expires},
+			}
+			app.snippets = snippets
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			code, _, _ := ts.get(t, "/snippet/view/1")
+			assert.Equal(t, code, http.StatusOK)
+
+			app.wg.Wait()
+
+			if tt.wantExtend {
+				assert.Equal(t, len(snippets.extended), 1)
+				assert.Equal(t, snippets.extended[0].Equal(tt.expires), true)
+			} else {
+				assert.Equal(t, len(snippets.extended), 0)
+			}
+		})
+	}
+}
+
+func TestSnippetRawStreamsLargeContent(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	rs, err := ts.Client().Get(ts.URL + "/snippet/raw/4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := app.snippets.Get(4)
+	assert.NilError(t, err)
+
+	// The reader knows the size up front, so the response has a length
+	// while still being copied through chunk by chunk.
+	assert.Equal(t, rs.StatusCode, http.StatusOK)
+	assert.Equal(t, rs.ContentLength, int64(len(want.Content)))
+	assert.Equal(t, len(body), len(want.Content))
+	assert.Equal(t, string(body) == want.Content, true)
+}
+
+func TestSnippetViewTruncatesLargeContent(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/snippet/view/4")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "This snippet is too large to show in full.")
+	assert.StringContains(t, body, "<a href='/snippet/raw/4'>")
+	assert.Equal(t, strings.Count(body, "0123456789abcdef") <= maxViewContentBytes/len("0123456789abcdef\n")+1, true)
+
+	_, _, body = ts.get(t, "/snippet/view/1")
+	assert.Equal(t, strings.Contains(body, "too large to show in full"), false)
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	assert.Equal(t, truncateUTF8("hello", 10), "hello")
+	assert.Equal(t, truncateUTF8("hello", 3), "hel")
+	assert.Equal(t, truncateUTF8("héllo", 2), "h")
+	assert.Equal(t, truncateUTF8("héllo", 3), "hé")
+}
+
+func TestUserSignup(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/signup")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	const (
+		validName     = "Bob"
+		validPassword = "validPa$$word"
+		validEmail    = "bob@example.com"
+		formTag       = "<form action='/user/signup' method='POST' novalidate>"
+	)
+
+	tests := []struct {
+		name         string
+		userName     string
+		userEmail    string
+		userPassword string
+		csrfToken    string
+		wantCode     int
+		wantFormTag  string
+	}{
+		{
+			name:         "Valid submission",
+			userName:     validName,
+			userEmail:    validEmail,
+			userPassword: validPassword,
+			csrfToken:    validCSRFToken,
+			wantCode:     http.StatusSeeOther,
+		},
+		{
+			name:         "Invalid CSRF Token",
+			userName:     validName,
+			userEmail:    validEmail,
+			userPassword: validPassword,
+			csrfToken:    "wrongToken",
+			wantCode:     http.StatusBadRequest,
+		},
+		{
+			name:         "Empty name",
+			userName:     "",
+			userEmail:    validEmail,
+			userPassword: validPassword,
+			csrfToken:    validCSRFToken,
+			wantCode:     http.StatusUnprocessableEntity,
+			wantFormTag:  formTag,
+		},
+		{
+			name:         "Empty email",
+			userName:     validName,
+			userEmail:    "",
+			userPassword: validPassword,
+			csrfToken:    validCSRFToken,
+			wantCode:     http.StatusUnprocessableEntity,
+			wantFormTag:  formTag,
+		},
+		{
+			name:         "Empty password",
+			userName:     validName,
+			userEmail:    validEmail,
+			userPassword: "",
+			csrfToken:    validCSRFToken,
+			wantCode:     http.StatusUnprocessableEntity,
+			wantFormTag:  formTag,
+		},
+		{
+			name:         "Invalid email",
+			userName:     validName,
+			userEmail:    "bob@example.",
+			userPassword: validPassword,
+			csrfToken:    validCSRFToken,
+			wantCode:     http.StatusUnprocessableEntity,
+			wantFormTag:  formTag,
+		},
+		{
+			name:         "Short password",
+			userName:     validName,
+			userEmail:    validEmail,
+			userPassword: "pa$$",
+			csrfToken:    validCSRFToken,
+			wantCode:     http.StatusUnprocessableEntity,
+			wantFormTag:  formTag,
+		},
+		{
+			name:         "Duplicate email",
+			userName:     validName,
+			userEmail:    "dupe@example.com",
+			userPassword: validPassword,
+			csrfToken:    validCSRFToken,
+			wantCode:     http.StatusUnprocessableEntity,
+			wantFormTag:  formTag,
+		},
+		{
+			name:         "Duplicate email in another case",
+			userName:     validName,
+			userEmail:    "Dupe@Example.COM",
+			userPassword: validPassword,
+			csrfToken:    validCSRFToken,
+			wantCode:     http.StatusUnprocessableEntity,
+			wantFormTag:  formTag,
 		},
 	}
 
@@ -192,3 +812,2231 @@ func TestUserSignup(t *testing.T) {
 		})
 	}
 }
+
+var formTokenRX = regexp.MustCompile(`<input type='hidden' name='form_token' value='(.+)'>`)
+
+func TestSnippetCreateDefaultContent(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.defaultContent = "// Author:\n// License: MIT\n"
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	code, _, body := ts.get(t, "/snippet/create")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<textarea name='content'>// Author:\n// License: MIT\n</textarea>")
+
+	app.config.defaultContent = ""
+
+	_, _, body = ts.get(t, "/snippet/create")
+	assert.StringContains(t, body, "<textarea name='content'></textarea>")
+}
+
+func TestSnippetCreatePreset(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.defaultContent = "// Author:\n"
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	license, ok := app.presets["license"]
+	assert.Equal(t, ok, true)
+
+	code, _, body := ts.get(t, "/snippet/create?preset=license")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<input type='text' name='title' value='MIT License'>")
+	assert.StringContains(t, body, "<textarea name='content'>"+html.EscapeString(license.Content)+"</textarea>")
+	assert.StringContains(t, body, "<a href='/snippet/create?preset=license'>license</a>")
+
+	code, _, body = ts.get(t, "/snippet/create?preset=nonexistent")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<input type='text' name='title' value=''>")
+	assert.StringContains(t, body, "<textarea name='content'>// Author:\n</textarea>")
+}
+
+func TestSnippetCreateDraftEscaped(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	err := app.drafts.Upsert(1, "x' autofocus onfocus='alert(1)", "</textarea><script>alert(1)</script>", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, body := ts.get(t, "/snippet/create")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<input type='text' name='title' value='x&#39; autofocus onfocus=&#39;alert(1)'>")
+	assert.StringContains(t, body, "<textarea name='content'>&lt;/textarea&gt;&lt;script&gt;alert(1)&lt;/script&gt;</textarea>")
+
+	if strings.Contains(body, "<script>alert(1)") {
+		t.Errorf("got unescaped draft content in %q", body)
+	}
+}
+
+func TestSnippetCreatePostReplay(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippet/create")
+
+	matches := formTokenRX.FindStringSubmatch(body)
+	if len(matches) < 2 {
+		t.Fatal("no form token found in body")
+	}
+
+	form := url.Values{}
+	form.Add("title", "O snail")
+	form.Add("content", "Climb Mount Fuji,")
+	form.Add("expires", "7")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	form.Add("form_token", matches[1])
+
+	code, header, _ := ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/snippet/view/2")
+
+	code, _, body = ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This form has already been submitted")
+
+	form.Set("form_token", "forged")
+
+	code, _, _ = ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+}
+
+func TestSnippetCreateDuplicateTitle(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	tests := []struct {
+		name      string
+		title     string
+		wantFlash string
+	}{
+		{
+			name:      "Duplicate title",
+			title:     "An old silent pond",
+			wantFlash: "Snippet successfully created! You already have a snippet named &#34;An old silent pond&#34;.",
+		},
+		{
+			name:      "Unique title",
+			title:     "O snail",
+			wantFlash: "Snippet successfully created!</div>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, body := ts.get(t, "/snippet/create")
+
+			matches := formTokenRX.FindStringSubmatch(body)
+			if len(matches) < 2 {
+				t.Fatal("no form token found in body")
+			}
+
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("content", "Climb Mount Fuji,")
+			form.Add("expires", "7")
+			form.Add("csrf_token", extractCSRFToken(t, body))
+			form.Add("form_token", matches[1])
+
+			code, _, _ := ts.postForm(t, "/snippet/create", form)
+			assert.Equal(t, code, http.StatusSeeOther)
+
+			_, _, body = ts.get(t, "/")
+			assert.StringContains(t, body, tt.wantFlash)
+		})
+	}
+}
+
+func TestTemplateDataOnEveryPage(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	pages := []string{"/", "/snippet/view/1", "/user/signup", "/user/login"}
+
+	for _, page := range pages {
+		_, _, body := ts.get(t, page)
+
+		assert.StringContains(t, body, "&middot; "+version)
+		assert.StringContains(t, body, "<a href='/user/login'>Login</a>")
+	}
+
+	ts.login(t)
+
+	for _, page := range append(pages, "/snippet/create") {
+		_, _, body := ts.get(t, page)
+
+		assert.StringContains(t, body, "&middot; "+version)
+		assert.StringContains(t, body, "<button>Logout</button>")
+	}
+}
+
+func TestFlashShownOnce(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/")
+
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, header, _ := ts.postForm(t, "/user/logout", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/")
+
+	const flash = "<div class='flash' data-level='success'>You&#39;ve been logged out successfully!</div>"
+
+	_, _, body = ts.get(t, "/")
+	assert.StringContains(t, body, flash)
+
+	_, _, body = ts.get(t, "/")
+	assert.Equal(t, strings.Contains(body, flash), false)
+}
+
+func TestSnippetPreview(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	form := url.Values{}
+	form.Add("content", "# Haiku\n\n<script>alert('pwned')</script>\n\nAn **old** silent pond")
+
+	code, header, _ := ts.postForm(t, "/snippet/preview", form)
+	assert.Equal(t, code, http.StatusBadRequest)
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippet/create")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, header, body = ts.postForm(t, "/snippet/preview", form)
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Type"), "text/html; charset=utf-8")
+	assert.StringContains(t, body, "<h1>Haiku</h1>")
+	assert.StringContains(t, body, "<strong>old</strong>")
+	assert.Equal(t, strings.Contains(body, "<script"), false)
+	assert.Equal(t, strings.Contains(body, "alert("), false)
+
+	form.Set("csrf_token", "wrongToken")
+
+	code, _, _ = ts.postForm(t, "/snippet/preview", form)
+	assert.Equal(t, code, http.StatusBadRequest)
+}
+
+func TestMinPasswordLength(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.minPasswordLength = 12
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	const (
+		shortPassword = "elevenchars"
+		longPassword  = "twelve chars"
+		wantMessage   = "This field must be at least 12 characters long"
+	)
+
+	_, _, body := ts.get(t, "/user/signup")
+
+	form := url.Values{}
+	form.Add("name", "Bob")
+	form.Add("email", "bob@example.com")
+	form.Add("password", shortPassword)
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, body := ts.postForm(t, "/user/signup", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, wantMessage)
+
+	form.Set("password", longPassword)
+
+	code, _, _ = ts.postForm(t, "/user/signup", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	ts.login(t)
+
+	_, _, body = ts.get(t, "/account/password/update")
+
+	form = url.Values{}
+	form.Add("currentPassword", "pa$$word")
+	form.Add("newPassword", shortPassword)
+	form.Add("newPasswordConfirmation", shortPassword)
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, body = ts.postForm(t, "/account/password/update", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, wantMessage)
+
+	form.Set("newPassword", longPassword)
+	form.Set("newPasswordConfirmation", longPassword)
+
+	code, header, _ := ts.postForm(t, "/account/password/update", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/")
+}
+
+func TestSessionIdleTimeout(t *testing.T) {
+	app := newTestApplication(t)
+	app.sessionManager.IdleTimeout = 300 * time.Millisecond
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	// Activity within the idle window keeps the session alive past it.
+	for range 4 {
+		time.Sleep(100 * time.Millisecond)
+
+		code, _, _ := ts.get(t, "/snippet/create")
+		assert.Equal(t, code, http.StatusOK)
+	}
+
+	time.Sleep(600 * time.Millisecond)
+
+	code, header, _ := ts.get(t, "/snippet/create")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/user/login")
+}
+
+func TestSessionMaxAge(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.sessionMaxAge = 300 * time.Millisecond
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	code, _, _ := ts.get(t, "/snippet/create")
+	assert.Equal(t, code, http.StatusOK)
+
+	// Unlike the idle timeout, activity does not extend the maximum age.
+	for range 4 {
+		time.Sleep(100 * time.Millisecond)
+
+		ts.get(t, "/snippet/create")
+	}
+
+	code, header, _ := ts.get(t, "/snippet/create")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/user/login")
+
+	// Logging in again starts a fresh session.
+	ts.login(t)
+
+	code, _, _ = ts.get(t, "/snippet/create")
+	assert.Equal(t, code, http.StatusOK)
+}
+
+func TestSnippetArchive(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	today := time.Now().Format(time.DateOnly)
+	yesterday := time.Now().AddDate(0, 0, -1).Format(time.DateOnly)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Form only",
+			urlPath:  "/snippet/archive",
+			wantCode: http.StatusOK,
+			wantBody: "<input type='date' name='from' value=''>",
+		},
+		{
+			name:     "Valid range",
+			urlPath:  "/snippet/archive?from=" + yesterday + "&to=" + today,
+			wantCode: http.StatusOK,
+			wantBody: "An old silent pond",
+		},
+		{
+			name:     "Empty range",
+			urlPath:  "/snippet/archive?from=2020-01-01&to=2020-12-31",
+			wantCode: http.StatusOK,
+			wantBody: "No snippets were created in this period.",
+		},
+		{
+			name:     "Inverted range",
+			urlPath:  "/snippet/archive?from=" + today + "&to=" + yesterday,
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This date cannot be before the from date",
+		},
+		{
+			name:     "Malformed date",
+			urlPath:  "/snippet/archive?from=01/02/2024&to=" + today,
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field must be a date in YYYY-MM-DD format",
+		},
+		{
+			name:     "Page zero",
+			urlPath:  "/snippet/archive?from=" + yesterday + "&to=" + today + "&page=0",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field must be a positive number",
+		},
+		{
+			name:     "Page size too large",
+			urlPath:  "/snippet/archive?from=" + yesterday + "&to=" + today + "&page_size=51",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field must be between 1 and 50",
+		},
+		{
+			name:     "Sort not safelisted",
+			urlPath:  "/snippet/archive?from=" + yesterday + "&to=" + today + "&sort=title",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field must be one of created, -created",
+		},
+		{
+			name:     "Newest first",
+			urlPath:  "/snippet/archive?from=" + yesterday + "&to=" + today + "&sort=-created",
+			wantCode: http.StatusOK,
+			wantBody: "<option value='-created' selected>Newest first</option>",
+		},
+		{
+			name:     "Reflected input is escaped",
+			urlPath:  "/snippet/archive?from=%27%3E%3Cscript%3E&to=" + today,
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "value='&#39;&gt;&lt;script&gt;'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
+
+func TestSnippetArchiveEscapesAuthorName(t *testing.T) {
+	app := newTestApplication(t)
+
+	var queries int
+	app.users = &countingUserModel{
+		users:   map[int]models.User{1: {ID: 1, Name: "<b>Alice</b>"}},
+		queries: &queries,
+	}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	today := time.Now().Format(time.DateOnly)
+
+	code, _, body := ts.get(t, "/snippet/archive?from="+today+"&to="+today)
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<td>&lt;b&gt;Alice&lt;/b&gt;</td>")
+}
+
+func TestArchiveIndex(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	now := time.Now().UTC()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, -1)
+
+	code, _, body := ts.get(t, "/archive")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, fmt.Sprintf("<a href='/snippet/archive?from=%s&amp;to=%s'>%s %d</a>",
+		start.Format(time.DateOnly), end.Format(time.DateOnly), now.Month(), now.Year()))
+	assert.StringContains(t, body, "<td>2</td>")
+}
+
+type recordingMailer struct {
+	to   []string
+	body []string
+}
+
+func (m *recordingMailer) send(to, subject, body string) error {
+	m.to = append(m.to, to)
+	m.body = append(m.body, body)
+	return nil
+}
+
+type pendingEmailUserModel struct {
+	mocks.UserModel
+	email   string
+	pending string
+}
+
+func (m *pendingEmailUserModel) SetPendingEmail(id int, currentPassword, newEmail string) error {
+	err := m.UserModel.SetPendingEmail(id, currentPassword, newEmail)
+	if err != nil {
+		return err
+	}
+
+	m.pending = models.NormalizeEmail(newEmail)
+	return nil
+}
+
+func (m *pendingEmailUserModel) ConfirmEmail(id int) error {
+	if m.pending == "" {
+		return models.ErrNoRecord
+	}
+
+	m.email, m.pending = m.pending, ""
+	return nil
+}
+
+func TestAccountEmailUpdate(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	tests := []struct {
+		name            string
+		newEmail        string
+		currentPassword string
+		wantCode        int
+		wantBody        string
+	}{
+		{
+			name:            "Wrong password",
+			newEmail:        "alice@new.example.com",
+			currentPassword: "wrong",
+			wantCode:        http.StatusUnprocessableEntity,
+			wantBody:        "Current password is incorrect",
+		},
+		{
+			name:            "Duplicate email",
+			newEmail:        "Dupe@Example.com",
+			currentPassword: "pa$$word",
+			wantCode:        http.StatusUnprocessableEntity,
+			wantBody:        "Email address is already in use",
+		},
+		{
+			name:            "Invalid email",
+			newEmail:        "alice@",
+			currentPassword: "pa$$word",
+			wantCode:        http.StatusUnprocessableEntity,
+			wantBody:        "This field must be a valid email address",
+		},
+		{
+			name:            "Valid without verification",
+			newEmail:        "alice@new.example.com",
+			currentPassword: "pa$$word",
+			wantCode:        http.StatusSeeOther,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, body := ts.get(t, "/account/email/update")
+
+			form := url.Values{}
+			form.Add("newEmail", tt.newEmail)
+			form.Add("currentPassword", tt.currentPassword)
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, _, body := ts.postForm(t, "/account/email/update", form)
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+
+	_, _, body := ts.get(t, "/")
+	assert.StringContains(t, body, "Your email address has been updated!")
+}
+
+func TestAccountEmailUpdateConfirmation(t *testing.T) {
+	app := newTestApplication(t)
+
+	mailer := &recordingMailer{}
+	app.mailer = mailer
+
+	users := &pendingEmailUserModel{email: "alice@example.com"}
+	app.users = users
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/account/email/update")
+
+	form := url.Values{}
+	form.Add("newEmail", "Alice@New.example.com")
+	form.Add("currentPassword", "pa$$word")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, _ := ts.postForm(t, "/account/email/update", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	// The old address stays in use until the link is followed.
+	assert.Equal(t, users.email, "alice@example.com")
+	assert.Equal(t, users.pending, "alice@new.example.com")
+
+	assert.Equal(t, len(mailer.to), 1)
+	assert.Equal(t, mailer.to[0], "alice@new.example.com")
+
+	link := app.config.baseURL + "/account/email/confirm?token=" + mocks.AliceToken
+	assert.StringContains(t, mailer.body[0], link)
+
+	code, _, _ = ts.get(t, "/account/email/confirm?token=wrong")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, users.email, "alice@example.com")
+
+	_, _, body = ts.get(t, "/")
+	assert.StringContains(t, body, "That confirmation link is invalid or has expired.")
+
+	code, _, _ = ts.get(t, strings.TrimPrefix(link, app.config.baseURL))
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, users.email, "alice@new.example.com")
+	assert.Equal(t, users.pending, "")
+
+	_, _, body = ts.get(t, "/")
+	assert.StringContains(t, body, "Your email address has been updated!")
+}
+
+type otherOwnerSnippetModel struct {
+	mocks.SnippetModel
+}
+
+func (m *otherOwnerSnippetModel) Get(id int) (models.Snippet, error) {
+	s, err := m.SnippetModel.Get(id)
+	s.UserID = 2
+	return s, err
+}
+
+func TestSnippetStats(t *testing.T) {
+	t.Run("Owner", func(t *testing.T) {
+		app := newTestApplication(t)
+
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		ts.login(t)
+
+		code, _, body := ts.get(t, "/snippet/stats/1")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "Viewed 5 times in total.")
+		assert.StringContains(t, body, "<td>3</td>")
+		assert.StringContains(t, body, "news.example.com")
+	})
+
+	t.Run("Not the owner", func(t *testing.T) {
+		app := newTestApplication(t)
+		app.snippets = &otherOwnerSnippetModel{}
+
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		ts.login(t)
+
+		code, _, body := ts.get(t, "/snippet/stats/1")
+
+		assert.Equal(t, code, http.StatusForbidden)
+		assert.Equal(t, strings.Contains(body, "news.example.com"), false)
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		app := newTestApplication(t)
+
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		code, header, _ := ts.get(t, "/snippet/stats/1")
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, header.Get("Location"), "/user/login")
+	})
+}
+
+func TestErrorPage(t *testing.T) {
+	t.Run("Missing snippet", func(t *testing.T) {
+		app := newTestApplication(t)
+
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		code, header, body := ts.get(t, "/snippet/view/99")
+
+		assert.Equal(t, code, http.StatusNotFound)
+		assert.Equal(t, header.Get("Content-Type"), "text/html; charset=utf-8")
+		assert.StringContains(t, body, "<title>Not Found - Snippetbox</title>")
+		assert.StringContains(t, body, "The page you were looking for doesn't exist")
+	})
+
+	t.Run("Template missing", func(t *testing.T) {
+		app := newTestApplication(t)
+		delete(app.templateCache, "error.tmpl")
+
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		code, _, body := ts.get(t, "/snippet/view/99")
+
+		assert.Equal(t, code, http.StatusNotFound)
+		assert.Equal(t, body, "Not Found\n")
+	})
+
+	t.Run("Server error detail", func(t *testing.T) {
+		for _, debug := range []bool{false, true} {
+			app := newTestApplication(t)
+			app.config.debug = debug
+
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+			app.serverError(rr, r, errors.New("table snippets is on fire"))
+
+			assert.Equal(t, rr.Code, http.StatusInternalServerError)
+			assert.Equal(t, strings.Contains(rr.Body.String(), "table snippets is on fire"), debug)
+		}
+	})
+}
+
+func TestSnippetCreateLanguage(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.languages = []string{"go", "zig"}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	tests := []struct {
+		name      string
+		language  string
+		wantCode  int
+		wantError bool
+	}{
+		{
+			name:     "Configured language",
+			language: "zig",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Plain text",
+			language: "",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:      "Unconfigured language",
+			language:  "python",
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, body := ts.get(t, "/snippet/create")
+			assert.StringContains(t, body, "<option value='zig' >zig</option>")
+
+			matches := formTokenRX.FindStringSubmatch(body)
+			if len(matches) < 2 {
+				t.Fatal("no form token found in body")
+			}
+
+			form := url.Values{}
+			form.Add("title", "O snail")
+			form.Add("content", "Climb Mount Fuji,")
+			form.Add("language", tt.language)
+			form.Add("expires", "7")
+			form.Add("csrf_token", extractCSRFToken(t, body))
+			form.Add("form_token", matches[1])
+
+			code, _, body := ts.postForm(t, "/snippet/create", form)
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantError {
+				assert.StringContains(t, body, "This field must be one of the listed languages")
+			}
+		})
+	}
+}
+
+type insertUntilRecordingSnippetModel struct {
+	mocks.SnippetModel
+	expires []time.Time
+}
+
+func (m *insertUntilRecordingSnippetModel) WithTx(tx *sql.Tx) models.SnippetModelInterface {
+	return m
+}
+
+func (m *insertUntilRecordingSnippetModel) InsertUntil(userID int, title, content, language string, private bool, expires time.Time) (int, error) {
+	m.expires = append(m.expires, expires)
+	return 2, nil
+}
+
+func TestSnippetCreateExpiresAt(t *testing.T) {
+	future := time.Now().Add(72 * time.Hour).UTC().Truncate(time.Second)
+
+	tests := []struct {
+		name        string
+		expires     string
+		expiresAt   string
+		wantCode    int
+		wantError   string
+		wantExpires time.Time
+	}{
+		{
+			name:        "Future datetime",
+			expiresAt:   future.Format(time.RFC3339),
+			wantCode:    http.StatusSeeOther,
+			wantExpires: future,
+		},
+		{
+			name:        "Local datetime",
+			expiresAt:   future.Format("2006-01-02T15:04"),
+			wantCode:    http.StatusSeeOther,
+			wantExpires: future.Truncate(time.Minute),
+		},
+		{
+			name:      "Past datetime",
+			expiresAt: time.Now().Add(-time.Hour).UTC().Format(time.RFC3339),
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "This field must be in the future",
+		},
+		{
+			name:      "Invalid datetime",
+			expiresAt: "next tuesday",
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "This field must be a date and time",
+		},
+		{
+			name:      "Preset and datetime",
+			expires:   "7",
+			expiresAt: future.Format(time.RFC3339),
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "Choose either a preset or an exact time, not both",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			snippets := &insertUntilRecordingSnippetModel{}
+			app.snippets = snippets
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t)
+
+			_, _, body := ts.get(t, "/snippet/create")
+
+			matches := formTokenRX.FindStringSubmatch(body)
+			if len(matches) < 2 {
+				t.Fatal("no form token found in body")
+			}
+
+			form := url.Values{}
+			form.Add("title", "O snail")
+			form.Add("content", "Climb Mount Fuji,")
+			form.Add("expires", tt.expires)
+			form.Add("expires_at", tt.expiresAt)
+			form.Add("csrf_token", extractCSRFToken(t, body))
+			form.Add("form_token", matches[1])
+
+			code, _, body := ts.postForm(t, "/snippet/create", form)
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantError != "" {
+				assert.StringContains(t, body, tt.wantError)
+				assert.Equal(t, len(snippets.expires), 0)
+			} else {
+				assert.Equal(t, len(snippets.expires), 1)
+				assert.Equal(t, snippets.expires[0].Equal(tt.wantExpires), true)
+			}
+		})
+	}
+}
+
+func TestCSRFTokenRenewedOnLogin(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/login")
+	preLoginToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("email", "alice@example.com")
+	form.Add("password", "pa$$word")
+	form.Add("csrf_token", preLoginToken)
+
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	form = url.Values{}
+	form.Add("csrf_token", preLoginToken)
+
+	code, _, _ = ts.postForm(t, "/user/logout", form)
+	assert.Equal(t, code, http.StatusBadRequest)
+
+	_, _, body = ts.get(t, "/snippet/create")
+
+	form = url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, header, _ := ts.postForm(t, "/user/logout", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/")
+}
+
+type lastCreatedSnippetModel struct {
+	mocks.SnippetModel
+	lastCreated time.Time
+}
+
+func (m *lastCreatedSnippetModel) LastCreatedAt(userID int) (time.Time, error) {
+	return m.lastCreated, nil
+}
+
+func TestSnippetCreateCooldown(t *testing.T) {
+	tests := []struct {
+		name        string
+		lastCreated time.Time
+		wantCode    int
+		wantError   string
+	}{
+		{
+			name:        "Within cooldown",
+			lastCreated: time.Now().Add(-20 * time.Second),
+			wantCode:    http.StatusUnprocessableEntity,
+			wantError:   "Please wait 40 seconds before creating another snippet",
+		},
+		{
+			name:        "After cooldown",
+			lastCreated: time.Now().Add(-2 * time.Minute),
+			wantCode:    http.StatusSeeOther,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.createCooldown = time.Minute
+			app.snippets = &lastCreatedSnippetModel{lastCreated: tt.lastCreated}
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t)
+
+			_, _, body := ts.get(t, "/snippet/create")
+
+			matches := formTokenRX.FindStringSubmatch(body)
+			if len(matches) < 2 {
+				t.Fatal("no form token found in body")
+			}
+
+			form := url.Values{}
+			form.Add("title", "O snail")
+			form.Add("content", "Climb Mount Fuji,")
+			form.Add("expires", "7")
+			form.Add("csrf_token", extractCSRFToken(t, body))
+			form.Add("form_token", matches[1])
+
+			code, _, body := ts.postForm(t, "/snippet/create", form)
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantError != "" {
+				assert.StringContains(t, body, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestPrivateSnippet(t *testing.T) {
+	t.Run("Owner", func(t *testing.T) {
+		app := newTestApplication(t)
+
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		ts.login(t)
+
+		code, _, body := ts.get(t, "/snippet/view/5")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "A private haiku...")
+
+		code, _, body = ts.get(t, "/snippet/raw/5")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, body, "A private haiku...")
+	})
+
+	t.Run("Anonymous", func(t *testing.T) {
+		app := newTestApplication(t)
+
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		code, _, body := ts.get(t, "/snippet/view/5")
+
+		assert.Equal(t, code, http.StatusNotFound)
+		assert.Equal(t, strings.Contains(body, "A private haiku..."), false)
+
+		code, _, _ = ts.get(t, "/snippet/raw/5")
+
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+}
+
+func TestNormalizeTitle(t *testing.T) {
+	tests := []struct {
+		name      string
+		title     string
+		titleCase bool
+		want      string
+	}{
+		{name: "Trimmed", title: "  An old silent pond \n", want: "An old silent pond"},
+		{name: "Collapsed", title: "An \t old\n\nsilent   pond", want: "An old silent pond"},
+		{name: "Title case", title: " an old  SQL pond", titleCase: true, want: "An Old SQL Pond"},
+		{name: "Non-ASCII", title: "élan  vital", titleCase: true, want: "Élan Vital"},
+		{name: "Blank", title: " \t ", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, normalizeTitle(tt.title, tt.titleCase), tt.want)
+		})
+	}
+}
+
+func TestSnippetCreateTitleNormalization(t *testing.T) {
+	tests := []struct {
+		name      string
+		normalize bool
+		title     string
+		wantCode  int
+		wantBody  string
+	}{
+		{
+			name:      "Normalized before length check",
+			normalize: true,
+			title:     "  " + strings.Repeat("a", 100) + "  ",
+			wantCode:  http.StatusSeeOther,
+		},
+		{
+			name:     "Not normalized",
+			title:    "  " + strings.Repeat("a", 100) + "  ",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field cannot be more than 100 characters long",
+		},
+		{
+			name:      "Normalized before duplicate check",
+			normalize: true,
+			title:     " An  old\tsilent pond ",
+			wantCode:  http.StatusSeeOther,
+			wantBody:  "You already have a snippet named &#34;An old silent pond&#34;.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.titles.normalize = tt.normalize
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t)
+
+			_, _, body := ts.get(t, "/snippet/create")
+
+			matches := formTokenRX.FindStringSubmatch(body)
+			if len(matches) < 2 {
+				t.Fatal("no form token found in body")
+			}
+
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("content", "Climb Mount Fuji,")
+			form.Add("expires", "7")
+			form.Add("csrf_token", extractCSRFToken(t, body))
+			form.Add("form_token", matches[1])
+
+			code, _, body := ts.postForm(t, "/snippet/create", form)
+			assert.Equal(t, code, tt.wantCode)
+
+			if code == http.StatusSeeOther {
+				_, _, body = ts.get(t, "/")
+			}
+
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
+
+func TestFlashMetadata(t *testing.T) {
+	app := newTestApplication(t)
+
+	var data []templateData
+
+	handler := app.sessionManager.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/put" {
+			app.flash(r, flashMessage{Level: flashWarning, Text: "Saved as a draft", AutoDismissMs: 5000})
+		}
+
+		data = append(data, app.newTemplateData(r))
+	}))
+
+	ts := newTestServer(t, handler)
+	defer ts.Close()
+
+	ts.get(t, "/put")
+	ts.get(t, "/next")
+
+	assert.Equal(t, len(data), 2)
+	assert.Equal(t, *data[0].Flash, flashMessage{Level: flashWarning, Text: "Saved as a draft", AutoDismissMs: 5000})
+	assert.Equal(t, data[1].Flash == nil, true)
+
+	tmpl := app.templateCache["home.tmpl"]
+	if tmpl == nil {
+		t.Fatal("home.tmpl not in template cache")
+	}
+
+	var buf strings.Builder
+
+	err := tmpl.ExecuteTemplate(&buf, "base", data[0])
+	assert.NilError(t, err)
+	assert.StringContains(t, buf.String(), "<div class='flash' data-level='warning' data-auto-dismiss-ms='5000'>Saved as a draft</div>")
+}
+
+func TestSnippetCreateControlChars(t *testing.T) {
+	tests := []struct {
+		name     string
+		title    string
+		content  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Null byte in content",
+			title:    "O snail",
+			content:  "Climb Mount\x00 Fuji,",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field cannot contain control characters",
+		},
+		{
+			name:     "Tabs and newlines in content",
+			title:    "O snail",
+			content:  "Climb Mount Fuji,\r\n\tBut slowly, slowly!",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			// The model strips control characters from titles rather than
+			// rejecting them.
+			name:     "Null byte in title",
+			title:    "O\x00 snail",
+			content:  "Climb Mount Fuji,",
+			wantCode: http.StatusSeeOther,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t)
+
+			_, _, body := ts.get(t, "/snippet/create")
+
+			matches := formTokenRX.FindStringSubmatch(body)
+			if len(matches) < 2 {
+				t.Fatal("no form token found in body")
+			}
+
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("content", tt.content)
+			form.Add("expires", "7")
+			form.Add("csrf_token", extractCSRFToken(t, body))
+			form.Add("form_token", matches[1])
+
+			code, _, body := ts.postForm(t, "/snippet/create", form)
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	assert.Equal(t, strings.Join(normalizeTags(splitTags("go, Go, GO")), ","), "go")
+	assert.Equal(t, strings.Join(normalizeTags(splitTags(" SQL,,go , sql ")), ","), "sql,go")
+	assert.Equal(t, len(normalizeTags(splitTags(""))), 0)
+}
+
+type tagsRecordingSnippetModel struct {
+	mocks.SnippetModel
+	tags []string
+}
+
+func (m *tagsRecordingSnippetModel) WithTx(tx *sql.Tx) models.SnippetModelInterface {
+	return m
+}
+
+func (m *tagsRecordingSnippetModel) SetTags(id int, tags []string) error {
+	m.tags = tags
+	return nil
+}
+
+func TestSnippetCreateTags(t *testing.T) {
+	tests := []struct {
+		name      string
+		tags      string
+		wantCode  int
+		wantTags  string
+		wantError bool
+	}{
+		{
+			name:     "Under the cap",
+			tags:     "go, sql",
+			wantCode: http.StatusSeeOther,
+			wantTags: "go,sql",
+		},
+		{
+			name:      "Over the cap",
+			tags:      "go, sql, rust, zig, c, bash",
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: true,
+		},
+		{
+			name:     "Duplicates reduce below the cap",
+			tags:     "go, Go, GO, sql, SQL, rust, zig",
+			wantCode: http.StatusSeeOther,
+			wantTags: "go,sql,rust,zig",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.maxTags = 4
+
+			snippets := &tagsRecordingSnippetModel{}
+			app.snippets = snippets
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t)
+
+			_, _, body := ts.get(t, "/snippet/create")
+
+			matches := formTokenRX.FindStringSubmatch(body)
+			if len(matches) < 2 {
+				t.Fatal("no form token found in body")
+			}
+
+			form := url.Values{}
+			form.Add("title", "O snail")
+			form.Add("content", "Climb Mount Fuji,")
+			form.Add("tags", tt.tags)
+			form.Add("expires", "7")
+			form.Add("csrf_token", extractCSRFToken(t, body))
+			form.Add("form_token", matches[1])
+
+			code, _, body := ts.postForm(t, "/snippet/create", form)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, strings.Join(snippets.tags, ","), tt.wantTags)
+
+			if tt.wantError {
+				assert.StringContains(t, body, "This field cannot have more than 4 different tags")
+			}
+		})
+	}
+}
+
+func TestRedirectWithFlash(t *testing.T) {
+	app := newTestApplication(t)
+
+	var flash *flashMessage
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /done", func(w http.ResponseWriter, r *http.Request) {
+		app.redirectWithFlash(w, r, "/next?page=2", flashWarning, "Saved, with warnings")
+	})
+	mux.HandleFunc("GET /next", func(w http.ResponseWriter, r *http.Request) {
+		flash = app.popFlash(r)
+	})
+
+	ts := newTestServer(t, app.sessionManager.LoadAndSave(mux))
+	defer ts.Close()
+
+	code, header, _ := ts.postForm(t, "/done", url.Values{})
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/next?page=2")
+
+	ts.get(t, "/next?page=2")
+
+	assert.Equal(t, flash != nil, true)
+	assert.Equal(t, *flash, flashMessage{Level: flashWarning, Text: "Saved, with warnings"})
+}
+
+func TestToUTF8(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		want   string
+		wantOK bool
+	}{
+		{
+			name:   "Valid UTF-8",
+			input:  "Café, 日本語 and emoji 🎉",
+			want:   "Café, 日本語 and emoji 🎉",
+			wantOK: true,
+		},
+		{
+			name:   "Latin-1",
+			input:  "Caf\xe9 cr\xe8me br\xfbl\xe9e \xa9",
+			want:   "Café crème brûlée ©",
+			wantOK: true,
+		},
+		{
+			name:   "Invalid byte sequence",
+			input:  "Bad \x80\x9f bytes \xe9",
+			want:   "Bad � bytes �",
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := toUTF8(tt.input)
+			assert.Equal(t, got, tt.want)
+			assert.Equal(t, ok, tt.wantOK)
+		})
+	}
+}
+
+type contentRecordingSnippetModel struct {
+	mocks.SnippetModel
+	title    string
+	content  string
+	language string
+}
+
+func (m *contentRecordingSnippetModel) WithTx(tx *sql.Tx) models.SnippetModelInterface {
+	return m
+}
+
+func (m *contentRecordingSnippetModel) Insert(userID int, title, content, language string, private bool, expires int) (int, error) {
+	m.title, m.content, m.language = title, content, language
+	return 2, nil
+}
+
+func TestSnippetCreateEncoding(t *testing.T) {
+	tests := []struct {
+		name        string
+		title       string
+		content     string
+		wantCode    int
+		wantTitle   string
+		wantContent string
+	}{
+		{
+			name:        "Valid UTF-8",
+			title:       "Café",
+			content:     "Climb Mount Fuji, ふじ",
+			wantCode:    http.StatusSeeOther,
+			wantTitle:   "Café",
+			wantContent: "Climb Mount Fuji, ふじ",
+		},
+		{
+			name:        "Latin-1",
+			title:       "Caf\xe9",
+			content:     "Cr\xe8me br\xfbl\xe9e",
+			wantCode:    http.StatusSeeOther,
+			wantTitle:   "Café",
+			wantContent: "Crème brûlée",
+		},
+		{
+			name:     "Invalid byte sequence",
+			title:    "O snail",
+			content:  "Climb \x81\x8d Fuji",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+
+			snippets := &contentRecordingSnippetModel{}
+			app.snippets = snippets
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t)
+
+			_, _, body := ts.get(t, "/snippet/create")
+
+			matches := formTokenRX.FindStringSubmatch(body)
+			if len(matches) < 2 {
+				t.Fatal("no form token found in body")
+			}
+
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("content", tt.content)
+			form.Add("expires", "7")
+			form.Add("csrf_token", extractCSRFToken(t, body))
+			form.Add("form_token", matches[1])
+
+			code, _, body := ts.postForm(t, "/snippet/create", form)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, snippets.title, tt.wantTitle)
+			assert.Equal(t, snippets.content, tt.wantContent)
+
+			if tt.wantCode == http.StatusUnprocessableEntity {
+				assert.StringContains(t, body, "This field must be UTF-8 or Latin-1 text")
+				assert.Equal(t, utf8.ValidString(body), true)
+			}
+		})
+	}
+}
+
+func TestPostLoginRedirect(t *testing.T) {
+	tests := []struct {
+		name         string
+		users        models.UserModelInterface
+		next         string
+		wantLocation string
+	}{
+		{
+			name:         "Admin",
+			users:        &mocks.UserModel{},
+			wantLocation: "/admin",
+		},
+		{
+			name:         "Regular user",
+			users:        &nonAdminUserModel{},
+			wantLocation: "/snippet/create",
+		},
+		{
+			name:         "Local next",
+			users:        &mocks.UserModel{},
+			next:         "/snippet/view/1",
+			wantLocation: "/snippet/view/1",
+		},
+		{
+			name:         "Next on another host",
+			users:        &nonAdminUserModel{},
+			next:         "//evil.example.com/",
+			wantLocation: "/snippet/create",
+		},
+		{
+			name:         "Absolute next",
+			users:        &mocks.UserModel{},
+			next:         "https://evil.example.com/",
+			wantLocation: "/admin",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.users = tt.users
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			_, _, body := ts.get(t, "/user/login?next="+url.QueryEscape(tt.next))
+
+			form := url.Values{}
+			form.Add("email", "alice@example.com")
+			form.Add("password", "pa$$word")
+			form.Add("csrf_token", extractCSRFToken(t, body))
+			if tt.next != "" {
+				assert.StringContains(t, body, "<input type='hidden' name='next'")
+				form.Add("next", tt.next)
+			}
+
+			code, header, _ := ts.postForm(t, "/user/login", form)
+			assert.Equal(t, code, http.StatusSeeOther)
+			assert.Equal(t, header.Get("Location"), tt.wantLocation)
+		})
+	}
+}
+
+const goSnippet = `package main
+
+import "fmt"
+
+func main() {
+	msg := "hello"
+	fmt.Println(msg)
+}`
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "Go",
+			content: goSnippet,
+			want:    "go",
+		},
+		{
+			name:    "Python",
+			content: "import sys\n\ndef greet(name):\n    print(name)\n\nif __name__ == '__main__':\n    greet(sys.argv[1])",
+			want:    "python",
+		},
+		{
+			name:    "SQL",
+			content: "SELECT id, title FROM snippets WHERE expires > UTC_TIMESTAMP()",
+			want:    "sql",
+		},
+		{
+			name:    "Bash",
+			content: "#!/bin/bash\nfor f in *.go; do\n    echo $f\ndone",
+			want:    "bash",
+		},
+		{
+			name:    "Prose",
+			content: "An old silent pond...\nA frog jumps into the pond,\nsplash! Silence again.",
+			want:    "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, detectLanguage(tt.content, defaultLanguages), tt.want)
+		})
+	}
+
+	// Only configured languages are detected.
+	assert.Equal(t, detectLanguage(goSnippet, []string{"python", "sql"}), "")
+}
+
+func TestSnippetCreateDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name         string
+		detect       bool
+		language     string
+		wantLanguage string
+	}{
+		{
+			name:         "Detected when blank",
+			detect:       true,
+			wantLanguage: "go",
+		},
+		{
+			name:         "Explicit choice wins",
+			detect:       true,
+			language:     "python",
+			wantLanguage: "python",
+		},
+		{
+			name:         "Detection off",
+			wantLanguage: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.detectLanguage = tt.detect
+
+			snippets := &contentRecordingSnippetModel{}
+			app.snippets = snippets
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t)
+
+			_, _, body := ts.get(t, "/snippet/create")
+
+			matches := formTokenRX.FindStringSubmatch(body)
+			if len(matches) < 2 {
+				t.Fatal("no form token found in body")
+			}
+
+			form := url.Values{}
+			form.Add("title", "Hello")
+			form.Add("content", goSnippet)
+			form.Add("language", tt.language)
+			form.Add("expires", "7")
+			form.Add("csrf_token", extractCSRFToken(t, body))
+			form.Add("form_token", matches[1])
+
+			code, _, _ := ts.postForm(t, "/snippet/create", form)
+			assert.Equal(t, code, http.StatusSeeOther)
+			assert.Equal(t, snippets.language, tt.wantLanguage)
+		})
+	}
+}
+
+type twoLatestSnippetModel struct {
+	mocks.SnippetModel
+}
+
+func (m *twoLatestSnippetModel) Latest(limit int) ([]models.Snippet, error) {
+	return []models.Snippet{
+		{ID: 2, Title: "Newer", Created: time.Now()},
+		{ID: 1, Title: "Older", Created: time.Now().Add(-time.Hour)},
+	}, nil
+}
+
+func TestDefaultSort(t *testing.T) {
+	assert.NilError(t, checkDefaultSort(""))
+	assert.NilError(t, checkDefaultSort("created"))
+	assert.NilError(t, checkDefaultSort("-created"))
+	assert.Equal(t, checkDefaultSort("title").Error(), "-default-sort must be one of created, -created")
+
+	app := newTestApplication(t)
+	app.snippets = &twoLatestSnippetModel{}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	newerFirst := func(body string) bool {
+		return strings.Index(body, "Newer") < strings.Index(body, "Older")
+	}
+
+	today := time.Now().Format(time.DateOnly)
+	archive := "/snippet/archive?from=" + today + "&to=" + today
+
+	_, _, body := ts.get(t, "/")
+	assert.Equal(t, newerFirst(body), true)
+
+	_, _, body = ts.get(t, archive)
+	assert.StringContains(t, body, "<option value='-created' >Newest first</option>")
+
+	app.config.defaultSort = "created"
+
+	_, _, body = ts.get(t, "/")
+	assert.Equal(t, newerFirst(body), false)
+
+	_, _, body = ts.get(t, "/?sort=-created")
+	assert.Equal(t, newerFirst(body), true)
+
+	code, _, _ := ts.get(t, "/?sort=title")
+	assert.Equal(t, code, http.StatusBadRequest)
+
+	app.config.defaultSort = "-created"
+
+	_, _, body = ts.get(t, archive)
+	assert.StringContains(t, body, "<option value='-created' selected>Newest first</option>")
+
+	_, _, body = ts.get(t, archive+"&sort=created")
+	assert.StringContains(t, body, "<option value='-created' >Newest first</option>")
+}
+
+type ownLatestSnippetModel struct {
+	twoLatestSnippetModel
+}
+
+func (m *ownLatestSnippetModel) LatestByUser(userID, limit int, includePrivate bool) ([]models.Snippet, error) {
+	if userID != 1 {
+		return nil, nil
+	}
+
+	return []models.Snippet{
+		{ID: 3, UserID: 1, Title: "Mine", Created: time.Now(), Private: true},
+	}, nil
+}
+
+func TestPersonalizedHome(t *testing.T) {
+	app := newTestApplication(t)
+	app.snippets = &ownLatestSnippetModel{}
+	app.config.personalizedHome = true
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/")
+	assert.StringContains(t, body, "<h2>Latest Snippets</h2>")
+	assert.StringContains(t, body, "Newer")
+
+	ts.login(t)
+
+	_, _, body = ts.get(t, "/")
+	assert.StringContains(t, body, "<h2>Your Latest Snippets</h2>")
+	assert.StringContains(t, body, "Mine")
+	assert.Equal(t, strings.Contains(body, "Newer"), false)
+
+	app.config.personalizedHome = false
+
+	_, _, body = ts.get(t, "/")
+	assert.StringContains(t, body, "<h2>Latest Snippets</h2>")
+	assert.StringContains(t, body, "Newer")
+}
+
+func TestHomeCacheHeaders(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// The first visit sets the CSRF cookie, which no shared cache may keep.
+	_, header, _ := ts.get(t, "/")
+	assert.Equal(t, len(header.Values("Set-Cookie")) > 0, true)
+	assert.Equal(t, header.Get("Cache-Control"), "private, max-age=30")
+
+	_, header, _ = ts.get(t, "/")
+	assert.Equal(t, len(header.Values("Set-Cookie")), 0)
+	assert.Equal(t, header.Get("Cache-Control"), "public, max-age=30")
+	assert.Equal(t, slices.Contains(header.Values("Vary"), "Accept"), true)
+	assert.Equal(t, slices.Contains(header.Values("Vary"), "Cookie"), true)
+
+	app.config.homeCacheMaxAge = 0
+
+	_, header, _ = ts.get(t, "/")
+	assert.Equal(t, header.Get("Cache-Control"), "no-cache")
+
+	app.config.homeCacheMaxAge = 30 * time.Second
+
+	ts.login(t)
+
+	_, header, body := ts.get(t, "/")
+	assert.Equal(t, header.Get("Cache-Control"), "no-store")
+	assert.Equal(t, slices.Contains(header.Values("Vary"), "Accept"), true)
+	assert.Equal(t, slices.Contains(header.Values("Vary"), "Cookie"), true)
+
+	// The page after logging out is anonymous, but shows the logout flash.
+	form := url.Values{"csrf_token": {extractCSRFToken(t, body)}}
+	code, _, _ := ts.postForm(t, "/user/logout", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, header, body = ts.get(t, "/")
+	assert.StringContains(t, body, "You&#39;ve been logged out successfully!")
+	assert.Equal(t, header.Get("Cache-Control"), "no-store")
+
+	_, header, _ = ts.get(t, "/")
+	assert.Equal(t, header.Get("Cache-Control"), "public, max-age=30")
+}
+
+func TestSnippetCommentCreate(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "No comments yet.")
+	assert.Equal(t, strings.Contains(body, "Post comment"), false)
+
+	_, _, login := ts.get(t, "/user/login")
+
+	code, header, _ := ts.postForm(t, "/snippet/1/comments", url.Values{"body": {"Nice"}, "csrf_token": {extractCSRFToken(t, login)}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/user/login")
+
+	ts.login(t)
+
+	_, _, body = ts.get(t, "/snippet/view/1")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Empty body",
+			body:     "  ",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field cannot be blank",
+		},
+		{
+			name:     "Oversized body",
+			body:     strings.Repeat("a", maxCommentChars+1),
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field cannot be more than 2000 characters long",
+		},
+		{
+			name:     "Valid body",
+			body:     "<b>Lovely</b> haiku",
+			wantCode: http.StatusSeeOther,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("body", tt.body)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/snippet/1/comments", form)
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+
+	_, _, body = ts.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "&lt;b&gt;Lovely&lt;/b&gt; haiku")
+	assert.Equal(t, strings.Contains(body, "No comments yet."), false)
+
+	code, _, _ = ts.postForm(t, "/snippet/2/comments", url.Values{"body": {"Nice"}, "csrf_token": {csrfToken}})
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestSnippetCommentPages(t *testing.T) {
+	app := newTestApplication(t)
+
+	for i := range defaultPageSize + 1 {
+		_, err := app.comments.Insert(1, 1, fmt.Sprintf("Comment %d.", i+1))
+		assert.NilError(t, err)
+	}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "Comment 1.")
+	assert.StringContains(t, body, "<a href='/snippet/view/1?page=2#comments'>2</a>")
+	assert.Equal(t, strings.Contains(body, "Comment 21."), false)
+
+	_, _, body = ts.get(t, "/snippet/view/1?page=9")
+	assert.StringContains(t, body, "Comment 21.")
+	assert.Equal(t, strings.Contains(body, "Comment 1."), false)
+}
+
+// strangerSnippetModel adds snippets of user 2: 6 is private and 7 public.
+type strangerSnippetModel struct {
+	mocks.SnippetModel
+}
+
+func (m *strangerSnippetModel) Get(id int) (models.Snippet, error) {
+	switch id {
+	case 6:
+		return models.Snippet{ID: 6, UserID: 2, Title: "Someone else's", Private: true}, nil
+	case 7:
+		return models.Snippet{ID: 7, UserID: 2, Title: "Someone else's public"}, nil
+	default:
+		return m.SnippetModel.Get(id)
+	}
+}
+
+func TestSnippetCommentPrivate(t *testing.T) {
+	app := newTestApplication(t)
+	app.snippets = &strangerSnippetModel{}
+
+	_, err := app.comments.Insert(5, 1, "A note to self")
+	assert.NilError(t, err)
+
+	_, err = app.comments.Insert(6, 2, "A secret")
+	assert.NilError(t, err)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/snippet/view/5")
+	assert.Equal(t, code, http.StatusNotFound)
+	assert.Equal(t, strings.Contains(body, "A note to self"), false)
+
+	ts.login(t)
+
+	code, _, body = ts.get(t, "/snippet/view/5")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "A note to self")
+	csrfToken := extractCSRFToken(t, body)
+
+	code, _, body = ts.get(t, "/snippet/view/6")
+	assert.Equal(t, code, http.StatusNotFound)
+	assert.Equal(t, strings.Contains(body, "A secret"), false)
+
+	code, _, _ = ts.postForm(t, "/snippet/6/comments", url.Values{"body": {"Let me in"}, "csrf_token": {csrfToken}})
+	assert.Equal(t, code, http.StatusNotFound)
+
+	comments, err := app.comments.ForSnippet(6, models.Filters{Page: 1, PageSize: defaultPageSize})
+	assert.NilError(t, err)
+	assert.Equal(t, comments.Total, 1)
+
+	code, _, _ = ts.postForm(t, "/snippet/5/comments", url.Values{"body": {"Another note"}, "csrf_token": {csrfToken}})
+	assert.Equal(t, code, http.StatusSeeOther)
+}
+
+func TestSnippetCommentDelete(t *testing.T) {
+	tests := []struct {
+		name      string
+		admin     bool
+		snippetID int
+		wantCode  int
+	}{
+		{
+			name:      "Owner",
+			snippetID: 1,
+			wantCode:  http.StatusSeeOther,
+		},
+		{
+			name:      "Other user",
+			snippetID: 7,
+			wantCode:  http.StatusForbidden,
+		},
+		{
+			name:      "Other user on a private snippet",
+			snippetID: 6,
+			wantCode:  http.StatusNotFound,
+		},
+		{
+			name:      "Admin",
+			admin:     true,
+			snippetID: 7,
+			wantCode:  http.StatusSeeOther,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.snippets = &strangerSnippetModel{}
+			if !tt.admin {
+				app.users = &nonAdminUserModel{}
+			}
+
+			id, err := app.comments.Insert(tt.snippetID, 2, "Spam, spam, spam")
+			assert.NilError(t, err)
+
+			_, err = app.comments.Insert(tt.snippetID, 2, "A reply")
+			assert.NilError(t, err)
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t)
+
+			_, _, body := ts.get(t, "/snippet/view/1")
+			form := url.Values{"csrf_token": {extractCSRFToken(t, body)}}
+
+			urlPath := fmt.Sprintf("/snippet/%d/comments/%d/delete", tt.snippetID, id)
+
+			code, _, _ := ts.postForm(t, urlPath, form)
+			assert.Equal(t, code, tt.wantCode)
+
+			comments, err := app.comments.ForSnippet(tt.snippetID, models.Filters{Page: 1, PageSize: defaultPageSize})
+			assert.NilError(t, err)
+			assert.Equal(t, len(comments.Comments), 2)
+			assert.Equal(t, comments.Comments[0].Deleted, tt.wantCode == http.StatusSeeOther)
+			assert.Equal(t, comments.Comments[1].Deleted, false)
+
+			if tt.wantCode != http.StatusSeeOther {
+				return
+			}
+
+			_, _, body = ts.get(t, fmt.Sprintf("/snippet/view/%d", tt.snippetID))
+			assert.StringContains(t, body, "[removed]")
+			assert.StringContains(t, body, "A reply")
+			assert.Equal(t, strings.Contains(body, "Spam"), false)
+			assert.StringContains(t, body, "value='Delete'")
+
+			code, _, _ = ts.postForm(t, urlPath, form)
+			assert.Equal(t, code, http.StatusNotFound)
+		})
+	}
+}
+
+type lastCommentedCommentModel struct {
+	mocks.CommentModel
+	lastCreated time.Time
+}
+
+func (m *lastCommentedCommentModel) LastCreatedAt(userID int) (time.Time, error) {
+	return m.lastCreated, nil
+}
+
+func TestSnippetCommentCooldown(t *testing.T) {
+	tests := []struct {
+		name        string
+		lastCreated time.Time
+		wantCode    int
+		wantError   string
+	}{
+		{
+			name:        "Within cooldown",
+			lastCreated: time.Now().Add(-20 * time.Second),
+			wantCode:    http.StatusUnprocessableEntity,
+			wantError:   "Please wait 40 seconds before posting another comment",
+		},
+		{
+			name:        "After cooldown",
+			lastCreated: time.Now().Add(-2 * time.Minute),
+			wantCode:    http.StatusSeeOther,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.commentCooldown = time.Minute
+			app.comments = &lastCommentedCommentModel{lastCreated: tt.lastCreated}
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t)
+
+			_, _, body := ts.get(t, "/snippet/view/1")
+
+			form := url.Values{}
+			form.Add("body", "First!")
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, _, body := ts.postForm(t, "/snippet/1/comments", form)
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantError != "" {
+				assert.StringContains(t, body, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestListingPageSizes(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.snippetOfDay = false
+	app.config.limiter.enabled = false
+	app.config.pageSizes.home = models.PageSizeLimits{Min: 1, Default: 3, Max: 5}
+
+	var queries int
+
+	var snippets []models.Snippet
+	for i := 1; i <= 20; i++ {
+		snippets = append(snippets, models.Snippet{ID: i, UserID: 1, Title: fmt.Sprintf("Snippet %d", i)})
+	}
+
+	app.snippets = &countingSnippetModel{snippets: snippets, queries: &queries}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantRows int
+		wantBody string
+	}{
+		{name: "Home default", urlPath: "/", wantCode: http.StatusOK, wantRows: 3},
+		{name: "Home smaller", urlPath: "/?page_size=2", wantCode: http.StatusOK, wantRows: 2},
+		{name: "Home capped", urlPath: "/?page_size=100", wantCode: http.StatusOK, wantRows: 5},
+		{name: "Home malformed", urlPath: "/?page_size=lots", wantCode: http.StatusOK, wantRows: 3},
+		{name: "API largest", urlPath: "/api/v1/snippets?limit=100", wantCode: http.StatusOK},
+		{name: "API too large", urlPath: "/api/v1/snippets?limit=101", wantCode: http.StatusUnprocessableEntity, wantBody: "must be between 1 and 100"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantRows > 0 {
+				assert.Equal(t, strings.Count(body, "<a href='/snippet/view/"), tt.wantRows)
+			}
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestParsePageSizeLimits(t *testing.T) {
+	limits, err := parsePageSizeLimits("5:10:50")
+	assert.NilError(t, err)
+	assert.Equal(t, limits, models.PageSizeLimits{Min: 5, Default: 10, Max: 50})
+
+	for _, s := range []string{"", "10", "1:10", "a:10:50", "1:60:50", "0:10:50", "1:10:101"} {
+		_, err := parsePageSizeLimits(s)
+		if err == nil {
+			t.Errorf("parsePageSizeLimits(%q) succeeded; want an error", s)
+		}
+	}
+}
+
+func TestSnippetPin(t *testing.T) {
+	app := newTestApplication(t)
+	app.snippets = &strangerSnippetModel{}
+	app.config.maxPins = 2
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "<form action='/snippet/1/pin' method='POST'>")
+	form := url.Values{"csrf_token": {extractCSRFToken(t, body)}}
+
+	tests := []struct {
+		name      string
+		urlPath   string
+		wantCode  int
+		wantFlash string
+	}{
+		{
+			name:      "Pin",
+			urlPath:   "/snippet/3/pin",
+			wantCode:  http.StatusSeeOther,
+			wantFlash: "Snippet pinned to your profile.",
+		},
+		{
+			name:      "Pin another",
+			urlPath:   "/snippet/1/pin",
+			wantCode:  http.StatusSeeOther,
+			wantFlash: "Snippet pinned to your profile.",
+		},
+		{
+			name:      "Pin again",
+			urlPath:   "/snippet/1/pin",
+			wantCode:  http.StatusSeeOther,
+			wantFlash: "Snippet pinned to your profile.",
+		},
+		{
+			name:      "Over the cap",
+			urlPath:   "/snippet/4/pin",
+			wantCode:  http.StatusSeeOther,
+			wantFlash: "You can pin at most 2 snippets. Unpin one first.",
+		},
+		{
+			name:      "Private snippet",
+			urlPath:   "/snippet/5/pin",
+			wantCode:  http.StatusSeeOther,
+			wantFlash: "Private snippets can&#39;t be pinned to your profile.",
+		},
+		{
+			name:     "Other user's snippet",
+			urlPath:  "/snippet/7/pin",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Other user's private snippet",
+			urlPath:  "/snippet/6/pin",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Other user's unpin",
+			urlPath:  "/snippet/7/unpin",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Missing snippet",
+			urlPath:  "/snippet/2/pin",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, _ := ts.postForm(t, tt.urlPath, form)
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantFlash != "" {
+				_, _, body := ts.get(t, header.Get("Location"))
+				assert.StringContains(t, body, tt.wantFlash)
+			}
+		})
+	}
+
+	pinned, err := app.snippets.Pinned(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(pinned), 2)
+	assert.Equal(t, pinned[0].ID, 3)
+	assert.Equal(t, pinned[1].ID, 1)
+
+	_, _, body = ts.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "<form action='/snippet/1/unpin' method='POST'>")
+
+	code, header, _ := ts.postForm(t, "/snippet/3/unpin", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/u/1")
+
+	pinned, err = app.snippets.Pinned(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(pinned), 1)
+	assert.Equal(t, pinned[0].ID, 1)
+
+	code, _, _ = ts.postForm(t, "/snippet/4/pin", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	pinned, err = app.snippets.Pinned(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(pinned), 2)
+}
+
+func TestUserProfile(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/u/1")
+	assert.StringContains(t, body, "<h2>Alice</h2>")
+	assert.Equal(t, strings.Contains(body, "<h2>Pinned</h2>"), false)
+	assert.StringContains(t, body, "An old silent pond")
+	assert.Equal(t, strings.Contains(body, "alice@example.com"), false)
+
+	assert.NilError(t, app.snippets.Pin(1, 3, app.config.maxPins))
+	assert.NilError(t, app.snippets.Pin(1, 1, app.config.maxPins))
+
+	_, _, body = ts.get(t, "/u/1")
+
+	// The pinned snippets come first, in the order they were pinned, and
+	// aren't repeated among the latest ones.
+	pinned := strings.Index(body, "<h2>Pinned</h2>")
+	markup := strings.Index(body, "Markup")
+	pond := strings.Index(body, "An old silent pond")
+	latest := strings.Index(body, "<h2>Latest Snippets</h2>")
+
+	assert.Equal(t, pinned != -1 && pinned < markup && markup < pond && pond < latest, true)
+	assert.Equal(t, strings.Count(body, "An old silent pond"), 1)
+	assert.StringContains(t, body, "<p>There's nothing to see here... yet!</p>")
+	assert.Equal(t, strings.Contains(body, "value='Unpin'"), false)
+
+	ts.login(t)
+
+	_, _, body = ts.get(t, "/u/1")
+	assert.Equal(t, strings.Count(body, "value='Unpin'"), 2)
+
+	for _, urlPath := range []string{"/u/2", "/u/0", "/u/alice"} {
+		code, _, _ := ts.get(t, urlPath)
+		assert.Equal(t, code, http.StatusNotFound)
+	}
+}
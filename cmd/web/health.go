@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// readyTimeout bounds the dependency checks of a single readiness probe.
+const readyTimeout = 2 * time.Second
+
+// healthz godoc
+// @Summary      Liveness probe
+// @Description  Returns 200 as long as the process is up and serving, whatever the state of its dependencies
+// @Tags         health
+// @Produce      plain
+// @Success      200 {string} string "OK"
+// @Router       /healthz [get]
+func healthz(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("OK"))
+}
+
+// readyz godoc
+// @Summary      Readiness probe
+// @Description  Returns 200 when the server can handle traffic: the database is reachable and, with -ready-require-migrations, fully migrated. Returns 503 otherwise, and from the moment a graceful shutdown starts, so load balancers drain traffic before the process exits.
+// @Tags         health
+// @Produce      plain
+// @Success      200 {string} string "OK"
+// @Failure      503 {string} string "Not ready"
+// @Router       /readyz [get]
+func (app *application) readyz(w http.ResponseWriter, r *http.Request) {
+	if app.draining.Load() {
+		http.Error(w, "Draining", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	err := app.readyCheck(ctx)
+	if err != nil {
+		app.logger.Warn("not ready", "error", err.Error())
+		http.Error(w, "Not ready", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Write([]byte("OK"))
+}
+
+// dbReadyCheck returns the readiness check for db: it must answer a ping and,
+// if requireMigrations is set, have every embedded migration applied.
+func dbReadyCheck(db *sql.DB, requireMigrations bool) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		err := db.PingContext(ctx)
+		if err != nil {
+			return err
+		}
+
+		if requireMigrations {
+			return checkMigrations(db)
+		}
+
+		return nil
+	}
+}
+
+// serve runs srv until it fails or a SIGINT or SIGTERM shuts it down
+// gracefully.
+func (app *application) serve(srv *http.Server, certFile, keyFile string) error {
+	shutdownErr := make(chan error)
+
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
+		s := <-quit
+
+		app.logger.Info("shutting down server", "signal", s.String())
+
+		shutdownErr <- app.shutdown(srv)
+	}()
+
+	err := srv.ListenAndServeTLS(certFile, keyFile)
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	err = <-shutdownErr
+	if err != nil {
+		return err
+	}
+
+	app.logger.Info("stopped server", "addr", srv.Addr)
+
+	return nil
+}
+
+// shutdown fails readiness at once, keeps serving for -shutdown-drain so load
+// balancers notice and stop sending traffic, then stops the server and waits
+// for in-flight requests and background work to finish.
+func (app *application) shutdown(srv *http.Server) error {
+	app.draining.Store(true)
+
+	time.Sleep(app.config.shutdownDrain)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	err := srv.Shutdown(ctx)
+	if err != nil {
+		return err
+	}
+
+	app.wg.Wait()
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/assert"
+)
+
+func TestHealthz(t *testing.T) {
+	app := newTestApplication(t)
+	app.readyCheck = func(ctx context.Context) error {
+		return errors.New("database unreachable")
+	}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Liveness doesn't depend on the database.
+	code, _, body := ts.get(t, "/healthz")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body, "OK")
+}
+
+func TestReadyz(t *testing.T) {
+	tests := []struct {
+		name     string
+		check    error
+		draining bool
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Ready",
+			wantCode: http.StatusOK,
+			wantBody: "OK",
+		},
+		{
+			name:     "Database unreachable",
+			check:    errors.New("dial tcp: connection refused"),
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: "Not ready\n",
+		},
+		{
+			name:     "Draining",
+			draining: true,
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: "Draining\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.readyCheck = func(ctx context.Context) error {
+				return tt.check
+			}
+			app.draining.Store(tt.draining)
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			code, _, body := ts.get(t, "/readyz")
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, body, tt.wantBody)
+		})
+	}
+}
+
+func TestShutdownDrains(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.shutdownDrain = 300 * time.Millisecond
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, _ := ts.get(t, "/readyz")
+	assert.Equal(t, code, http.StatusOK)
+
+	done := make(chan error)
+
+	go func() {
+		done <- app.shutdown(ts.Config)
+	}()
+
+	deadline := time.Now().Add(app.config.shutdownDrain / 2)
+	for !app.draining.Load() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	// While draining, the server keeps serving but reports itself not ready.
+	code, _, _ = ts.get(t, "/readyz")
+	assert.Equal(t, code, http.StatusServiceUnavailable)
+
+	code, _, _ = ts.get(t, "/healthz")
+	assert.Equal(t, code, http.StatusOK)
+
+	err := <-done
+	assert.NilError(t, err)
+
+	_, err = ts.Client().Get(ts.URL + "/healthz")
+	assert.Equal(t, err != nil, true)
+}
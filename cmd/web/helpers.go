@@ -2,12 +2,23 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"maps"
 	"net/http"
+	"net/url"
 	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/Vadim-Makhnev/snippetbox/internal/models"
+	"github.com/Vadim-Makhnev/snippetbox/internal/validator"
 	"github.com/go-playground/form/v4"
 	"github.com/justinas/nosurf"
 )
@@ -19,12 +30,80 @@ func (app *application) serverError(w http.ResponseWriter, r *http.Request, err
 		trace  = string(debug.Stack())
 	)
 
-	app.logger.Error(err.Error(), "method", method, "uri", url, "trace", trace)
-	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	// A dropped database connection is a transient blip rather than a bug, so
+	// it gets its own log message to alert on and asks the client to retry.
+	status := http.StatusInternalServerError
+
+	if errors.Is(err, models.ErrConnLost) {
+		app.logger.Error("database connection lost", "error", err.Error(), "method", method, "uri", url)
+		w.Header().Set("Retry-After", "1")
+		status = http.StatusServiceUnavailable
+	} else {
+		app.logger.Error(err.Error(), "method", method, "uri", url, "trace", trace)
+	}
+
+	if isAPIRequest(r) {
+		if errors.Is(err, errResponseBodyTooLarge) {
+			app.problem(w, r, status, "response too large")
+			return
+		}
+
+		app.problem(w, r, status, "the server encountered a problem and could not process your request")
+		return
+	}
+
+	app.errorPage(w, r, status, err)
 }
 
-func (app *application) clientError(w http.ResponseWriter, status int) {
-	http.Error(w, http.StatusText(status), status)
+func (app *application) clientError(w http.ResponseWriter, r *http.Request, status int) {
+	app.errorPage(w, r, status, nil)
+}
+
+func (app *application) notFound(w http.ResponseWriter, r *http.Request) {
+	app.clientError(w, r, http.StatusNotFound)
+}
+
+// errorPage renders error.tmpl for the status, including the error itself
+// with -debug and any suggested links. It can't report a failure of its own
+// through serverError without risking a loop, so if the template is missing
+// or fails it falls back to a plain text response.
+func (app *application) errorPage(w http.ResponseWriter, r *http.Request, status int, err error, suggestions ...suggestion) {
+	ts, ok := app.templateCache["error.tmpl"]
+	if !ok {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	// Error pages can be served outside the session middleware, so the data
+	// skips the flash, and there's no flash to lose on an error either.
+	var data templateData
+
+	app.injectSiteData(r, &data)
+	app.injectAuthData(r, &data)
+	app.injectCSRFToken(r, &data)
+	app.injectLocation(r, &data)
+
+	data.Status = status
+	data.StatusText = http.StatusText(status)
+	data.Suggestions = suggestions
+
+	if app.config.debug && err != nil {
+		data.ErrorDetail = err.Error()
+	}
+
+	buf := new(bytes.Buffer)
+
+	execErr := ts.ExecuteTemplate(buf, "base", data)
+	if execErr != nil {
+		app.logger.Error(execErr.Error(), "method", r.Method, "uri", r.URL)
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+
+	buf.WriteTo(w)
 }
 
 func (app *application) render(w http.ResponseWriter, r *http.Request, status int, page string, data templateData) {
@@ -48,13 +127,207 @@ func (app *application) render(w http.ResponseWriter, r *http.Request, status in
 	buf.WriteTo(w)
 }
 
+// newTemplateData returns the data shared by every page. Each injector fills
+// in one group of global fields, so all renders get them regardless of the
+// handler; a new global field belongs in one of these injectors.
 func (app *application) newTemplateData(r *http.Request) templateData {
-	return templateData{
-		CurrentYear:     time.Now().Year(),
-		Flash:           app.sessionManager.PopString(r.Context(), "flash"),
-		IsAuthenticated: app.isAuthenticated(r),
-		CSRFToken:       nosurf.Token(r),
+	var data templateData
+
+	injectors := []func(r *http.Request, data *templateData){
+		app.injectSiteData,
+		app.injectFlash,
+		app.injectAuthData,
+		app.injectCSRFToken,
+		app.injectLocation,
+	}
+
+	for _, inject := range injectors {
+		inject(r, &data)
+	}
+
+	return data
+}
+
+func (app *application) injectSiteData(r *http.Request, data *templateData) {
+	data.CurrentYear = time.Now().Year()
+	data.Version = version
+	data.BaseURL = app.config.baseURL
+	data.ExpiryOptions = app.config.expiryOptions
+	data.Languages = app.config.languages
+	data.SignupEnabled = app.config.signupEnabled
+	data.RequireInvite = app.config.requireInvite
+	data.RequireTerms = app.config.requireTerms
+}
+
+func (app *application) injectFlash(r *http.Request, data *templateData) {
+	data.Flash = app.popFlash(r)
+}
+
+// Flash levels.
+const (
+	flashInfo    = "info"
+	flashSuccess = "success"
+	flashWarning = "warning"
+	flashError   = "error"
+)
+
+// flashMessage is a one-off message shown on the next page. Level selects
+// how the frontend styles it, and AutoDismissMs, when set, how long it is
+// shown before being hidden; by default it stays.
+type flashMessage struct {
+	Level         string
+	Text          string
+	AutoDismissMs int
+}
+
+// flash stores a flash message for the next page, with the info level if
+// none is given.
+func (app *application) flash(r *http.Request, f flashMessage) {
+	if f.Level == "" {
+		f.Level = flashInfo
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", f.Text)
+	app.sessionManager.Put(r.Context(), "flash_level", f.Level)
+	app.sessionManager.Put(r.Context(), "flash_auto_dismiss_ms", f.AutoDismissMs)
+}
+
+// redirectWithFlash ends a successful POST the post-redirect-get way: it
+// stores a flash for the next page and redirects there with 303 See Other.
+func (app *application) redirectWithFlash(w http.ResponseWriter, r *http.Request, url, level, msg string) {
+	app.flash(r, flashMessage{Level: level, Text: msg})
+
+	http.Redirect(w, r, url, http.StatusSeeOther)
+}
+
+// popFlash reads and removes the flash message in a single step, so a flash
+// put before a redirect is shown on the redirect target and never again. It
+// returns nil when there is none.
+func (app *application) popFlash(r *http.Request) *flashMessage {
+	text := app.sessionManager.PopString(r.Context(), "flash")
+	level := app.sessionManager.PopString(r.Context(), "flash_level")
+	autoDismissMs := app.sessionManager.PopInt(r.Context(), "flash_auto_dismiss_ms")
+
+	if text == "" {
+		return nil
+	}
+
+	return &flashMessage{Level: level, Text: text, AutoDismissMs: autoDismissMs}
+}
+
+func (app *application) injectAuthData(r *http.Request, data *templateData) {
+	data.IsAuthenticated = app.isAuthenticated(r)
+	data.AuthenticatedUserID = app.authenticatedUserID(r)
+}
+
+func (app *application) injectCSRFToken(r *http.Request, data *templateData) {
+	data.CSRFToken = nosurf.Token(r)
+}
+
+// renewCSRFToken replaces the CSRF token along with the session token, so a
+// token issued before a login or logout can't be used after it. Pages
+// rendered later in the same request get the new token.
+func renewCSRFToken(w http.ResponseWriter, r *http.Request) {
+	if h, ok := r.Context().Value(csrfHandlerContextKey).(*nosurf.CSRFHandler); ok {
+		h.RegenerateToken(w, r)
+	}
+}
+
+func (app *application) injectLocation(r *http.Request, data *templateData) {
+	data.Location = userLocation(r)
+}
+
+// userLocation returns the time zone named by the tz cookie, which the
+// browser sets from its own settings. Timestamps are stored in UTC and only
+// converted for display.
+func userLocation(r *http.Request) *time.Location {
+	cookie, err := r.Cookie("tz")
+	if err != nil {
+		return time.UTC
+	}
+
+	return loadLocation(cookie.Value)
+}
+
+// loadLocation looks up an IANA time zone name such as "Europe/Berlin",
+// falling back to UTC for unknown names. "Local" is refused too, since the
+// server's zone means nothing to the reader.
+func loadLocation(name string) *time.Location {
+	if name == "" || name == "Local" {
+		return time.UTC
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
 	}
+
+	return loc
+}
+
+// referrerHost returns the host name of the site that referred the request,
+// the only part of the Referer kept in view stats, or "" if there isn't one.
+func referrerHost(r *http.Request) string {
+	u, err := url.Parse(r.Header.Get("Referer"))
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(u.Hostname())
+}
+
+// background runs fn in its own goroutine so the response isn't held up,
+// recovering and logging any panic.
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.Error(fmt.Sprintf("%v", err))
+			}
+		}()
+
+		fn()
+	}()
+}
+
+// maxFormTokens caps how many unused one-time form tokens are kept in a
+// session, so several open tabs each keep a valid token.
+const maxFormTokens = 10
+
+// newFormToken issues a one-time token for a form render and records it in
+// the session. Unlike the CSRF token it can be used only once, which stops a
+// browser back-button re-POST from submitting the same form twice.
+func (app *application) newFormToken(r *http.Request) string {
+	token := rand.Text()
+
+	tokens, _ := app.sessionManager.Get(r.Context(), "formTokens").([]string)
+	tokens = append(tokens, token)
+	if len(tokens) > maxFormTokens {
+		tokens = tokens[len(tokens)-maxFormTokens:]
+	}
+
+	app.sessionManager.Put(r.Context(), "formTokens", tokens)
+
+	return token
+}
+
+// consumeFormToken reports whether token was issued by newFormToken and not
+// used yet, invalidating it in the process.
+func (app *application) consumeFormToken(r *http.Request, token string) bool {
+	tokens, _ := app.sessionManager.Get(r.Context(), "formTokens").([]string)
+
+	i := slices.Index(tokens, token)
+	if token == "" || i == -1 {
+		return false
+	}
+
+	app.sessionManager.Put(r.Context(), "formTokens", slices.Delete(tokens, i, i+1))
+
+	return true
 }
 
 func (app *application) decodePostForm(r *http.Request, dst any) error {
@@ -78,6 +351,173 @@ func (app *application) decodePostForm(r *http.Request, dst any) error {
 	return nil
 }
 
+// checkPassword applies the password rules shared by every flow that sets a
+// password, so they all enforce the configured minimum length.
+func (app *application) checkPassword(v *validator.Validator, key, password string) {
+	n := app.config.minPasswordLength
+	v.CheckField(validator.MinChars(password, n), key, fmt.Sprintf("This field must be at least %d characters long", n))
+}
+
+// checkSignup applies the rules for a new account, shared by the signup form
+// and the signup API.
+func (app *application) checkSignup(v *validator.Validator, name, email, password string) {
+	v.CheckField(validator.NotBlank(name), "name", "This field cannot be blank")
+	v.CheckField(validator.NotBlank(email), "email", "This field cannot be blank")
+	v.CheckField(validator.Matches(email, validator.EmailRX), "email", "This field must be a valid email address")
+	v.CheckField(!app.disposableDomains.containsEmail(email), "email", "Disposable email addresses are not allowed")
+	v.CheckField(validator.NotBlank(password), "password", "This field cannot be blank")
+	app.checkPassword(v, "password", password)
+}
+
+// checkTerms adds a field error unless the terms of service were accepted,
+// when -require-terms is set.
+func (app *application) checkTerms(v *validator.Validator, accepted bool) {
+	v.CheckField(accepted || !app.config.requireTerms, "terms", "You must accept the terms of service")
+}
+
+// checkInvite adds a field error unless code is an unused invite, when
+// -require-invite is set.
+func (app *application) checkInvite(v *validator.Validator, code string) error {
+	if !app.config.requireInvite {
+		return nil
+	}
+
+	valid, err := app.invites.Valid(code)
+	if err != nil {
+		return err
+	}
+
+	v.CheckField(valid, "invite", "This invite code is invalid or has already been used")
+
+	return nil
+}
+
+// signup creates an account. With -require-invite it first uses up the
+// invite, in the same transaction, so each code creates at most one account
+// even when signups race on it. It returns models.ErrNoRecord if the invite
+// is invalid or already used, and models.ErrDuplicateEmail if the email is
+// taken; nothing is saved in either case.
+func (app *application) signup(ctx context.Context, name, email, password, invite string, termsAccepted bool) error {
+	tx, err := app.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if app.config.requireInvite {
+		err = app.invites.WithTx(tx).Use(invite)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = app.users.WithTx(tx).Insert(name, email, password, termsAccepted)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// localPath reports whether path is an absolute path on this site, and so
+// safe to redirect to. Paths starting with // or /\ are refused, as
+// browsers treat them as links to another host.
+func localPath(path string) bool {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.HasPrefix(path, "/\\") {
+		return false
+	}
+
+	u, err := url.Parse(path)
+	return err == nil && u.Scheme == "" && u.Host == ""
+}
+
+// postLoginRedirect returns where to send a user who just logged in: next,
+// when the login page was given a local path, otherwise the configured
+// default for the user's role.
+func (app *application) postLoginRedirect(userID int, next string) (string, error) {
+	if next != "" && localPath(next) {
+		return next, nil
+	}
+
+	admin, err := app.users.IsAdmin(userID)
+	if err != nil {
+		return "", err
+	}
+
+	if admin {
+		return app.config.loginRedirect.admin, nil
+	}
+
+	return app.config.loginRedirect.user, nil
+}
+
+// canModerateComments reports whether the user making the request may
+// delete comments on snippet: its owner and admins can.
+func (app *application) canModerateComments(r *http.Request, snippet models.Snippet) (bool, error) {
+	if !app.isAuthenticated(r) {
+		return false, nil
+	}
+
+	userID := app.authenticatedUserID(r)
+	if snippet.UserID == userID {
+		return true, nil
+	}
+
+	return app.users.IsAdmin(userID)
+}
+
+// createCooldownLeft returns how much longer the user must wait before
+// creating another snippet under -create-cooldown, or zero.
+func (app *application) createCooldownLeft(userID int) (time.Duration, error) {
+	last, err := app.snippets.LastCreatedAt(userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	return max(time.Until(last.Add(app.config.createCooldown)), 0), nil
+}
+
+// commentCooldownLeft returns how much longer the user must wait before
+// posting another comment under -comment-cooldown, or zero.
+func (app *application) commentCooldownLeft(userID int) (time.Duration, error) {
+	last, err := app.comments.LastCreatedAt(userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	return max(time.Until(last.Add(app.config.commentCooldown)), 0), nil
+}
+
+// snippetAuthors loads the authors of all the given snippets with a single
+// query, so listing pages don't issue one user lookup per snippet.
+func (app *application) snippetAuthors(snippets []models.Snippet) (map[int]models.User, error) {
+	var ids []int
+
+	for _, s := range snippets {
+		if s.UserID != 0 && !slices.Contains(ids, s.UserID) {
+			ids = append(ids, s.UserID)
+		}
+	}
+
+	return app.users.GetMany(ids)
+}
+
+func (app *application) authenticatedUserID(r *http.Request) int {
+	id, ok := r.Context().Value(authenticatedUserIDContextKey).(int)
+	if !ok {
+		return 0
+	}
+
+	return id
+}
+
 func (app *application) isAuthenticated(r *http.Request) bool {
 	isAuthenticated, ok := r.Context().Value(isAuthenticatedContextKey).(bool)
 	if !ok {
@@ -86,3 +526,193 @@ func (app *application) isAuthenticated(r *http.Request) bool {
 
 	return isAuthenticated
 }
+
+type envelope map[string]any
+
+// errResponseBodyTooLarge is returned by writeJSON, before anything is
+// written, for a response larger than -max-response-body.
+var errResponseBodyTooLarge = errors.New("response too large")
+
+func (app *application) writeJSON(w http.ResponseWriter, status int, data any, headers http.Header) error {
+	js, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	js = append(js, '\n')
+
+	if app.config.maxResponseBody > 0 && len(js) > app.config.maxResponseBody {
+		return fmt.Errorf("%w: %d bytes exceeds the limit of %d", errResponseBodyTooLarge, len(js), app.config.maxResponseBody)
+	}
+
+	maps.Copy(w.Header(), headers)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(js)
+
+	return nil
+}
+
+func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, 1_048_576)
+
+	dec := json.NewDecoder(r.Body)
+
+	err := dec.Decode(dst)
+	if err != nil {
+		var syntaxError *json.SyntaxError
+		var unmarshalTypeError *json.UnmarshalTypeError
+		var invalidUnmarshalError *json.InvalidUnmarshalError
+		var maxBytesError *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &syntaxError):
+			return fmt.Errorf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return errors.New("body contains badly-formed JSON")
+		case errors.As(err, &unmarshalTypeError):
+			if unmarshalTypeError.Field != "" {
+				return fmt.Errorf("body contains incorrect JSON type for field %q", unmarshalTypeError.Field)
+			}
+			return fmt.Errorf("body contains incorrect JSON type (at character %d)", unmarshalTypeError.Offset)
+		case errors.Is(err, io.EOF):
+			return errors.New("body must not be empty")
+		case errors.As(err, &maxBytesError):
+			return fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+		case errors.As(err, &invalidUnmarshalError):
+			panic(err)
+		default:
+			return err
+		}
+	}
+
+	err = dec.Decode(&struct{}{})
+	if !errors.Is(err, io.EOF) {
+		return errors.New("body must only contain a single JSON value")
+	}
+
+	return nil
+}
+
+func (app *application) invalidAuthenticationToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	app.problem(w, r, http.StatusUnauthorized, "invalid or missing authentication token")
+}
+
+// problemDetails is an RFC 7807 problem document. Errors is an extension
+// member holding per-field validation messages.
+type problemDetails struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance"`
+	Errors   map[string]string `json:"errors,omitempty"`
+}
+
+func isAPIRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/")
+}
+
+// problem writes an application/problem+json error response. Every error
+// from the /api routes goes through here.
+func (app *application) problem(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	app.writeProblem(w, r, problemDetails{Status: status, Detail: detail})
+}
+
+func (app *application) validationProblem(w http.ResponseWriter, r *http.Request, fieldErrors map[string]string) {
+	app.writeProblem(w, r, problemDetails{
+		Status: http.StatusUnprocessableEntity,
+		Detail: "the request failed validation",
+		Errors: fieldErrors,
+	})
+}
+
+func (app *application) writeProblem(w http.ResponseWriter, r *http.Request, p problemDetails) {
+	p.Type = "about:blank"
+	p.Title = http.StatusText(p.Status)
+	p.Instance = r.URL.Path
+
+	js, err := json.Marshal(p)
+	if err != nil {
+		app.logger.Error(err.Error(), "method", r.Method, "uri", r.URL)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	w.Write(append(js, '\n'))
+}
+
+func (app *application) readInt(qs url.Values, key string, defaultValue int, v *validator.Validator) int {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		v.AddFieldError(key, "This field must be an integer value")
+		return defaultValue
+	}
+
+	return i
+}
+
+func (app *application) readString(qs url.Values, key string, defaultValue string) string {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+
+	return s
+}
+
+// defaultPageSize is the number of comments on each page of a snippet.
+const defaultPageSize = 20
+
+// readFilters reads the page, page_size and sort query parameters of a list
+// into models.Filters and validates them against the list's page size
+// limits, recording any errors in v.
+func (app *application) readFilters(qs url.Values, defaultSort string, sortSafelist []string, limits models.PageSizeLimits, v *validator.Validator) models.Filters {
+	f := models.Filters{
+		Page:           app.readInt(qs, "page", 1, v),
+		PageSize:       app.readInt(qs, "page_size", limits.Default, v),
+		PageSizeLimits: limits,
+		Sort:           app.readString(qs, "sort", defaultSort),
+		SortSafelist:   sortSafelist,
+	}
+
+	f.Validate(v)
+
+	return f
+}
+
+// parsePageSizeLimits parses page size limits written as min:default:max,
+// such as "1:20:100".
+func parsePageSizeLimits(s string) (models.PageSizeLimits, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return models.PageSizeLimits{}, fmt.Errorf("invalid page sizes %q: want min:default:max", s)
+	}
+
+	var n [3]int
+	for i, part := range parts {
+		var err error
+		n[i], err = strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return models.PageSizeLimits{}, fmt.Errorf("invalid page sizes %q: want min:default:max", s)
+		}
+	}
+
+	limits := models.PageSizeLimits{Min: n[0], Default: n[1], Max: n[2]}
+
+	err := limits.Check()
+	if err != nil {
+		return models.PageSizeLimits{}, fmt.Errorf("invalid page sizes %q: %w", s, err)
+	}
+
+	return limits, nil
+}
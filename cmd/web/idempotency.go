@@ -0,0 +1,80 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// idempotencyStore remembers the snippet ID created for each Idempotency-Key
+// so that a replayed request returns the original result instead of creating
+// a duplicate. Concurrent requests with the same key wait for the first one
+// to finish. Failed attempts are forgotten so the client can retry.
+type idempotencyStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*idempotencyEntry
+}
+
+type idempotencyEntry struct {
+	done    chan struct{}
+	id      int
+	err     error
+	expires time.Time
+}
+
+func newIdempotencyStore(ttl time.Duration) *idempotencyStore {
+	return &idempotencyStore{
+		ttl:     ttl,
+		entries: make(map[string]*idempotencyEntry),
+	}
+}
+
+// do runs fn once per key within the TTL. It returns the resulting ID and
+// whether it was replayed from an earlier request.
+func (s *idempotencyStore) do(key string, fn func() (int, error)) (int, bool, error) {
+	for {
+		s.mu.Lock()
+
+		now := time.Now()
+		s.evictExpired(now)
+
+		e, ok := s.entries[key]
+		if !ok {
+			e = &idempotencyEntry{done: make(chan struct{})}
+			s.entries[key] = e
+			s.mu.Unlock()
+
+			id, err := fn()
+
+			s.mu.Lock()
+			e.id, e.err = id, err
+			e.expires = time.Now().Add(s.ttl)
+			if err != nil {
+				delete(s.entries, key)
+			}
+			close(e.done)
+			s.mu.Unlock()
+
+			return id, false, err
+		}
+
+		s.mu.Unlock()
+
+		<-e.done
+		if e.err == nil {
+			return e.id, true, nil
+		}
+	}
+}
+
+func (s *idempotencyStore) evictExpired(now time.Time) {
+	for key, e := range s.entries {
+		select {
+		case <-e.done:
+			if now.After(e.expires) {
+				delete(s.entries, key)
+			}
+		default:
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/speps/go-hashids/v2"
+)
+
+// idCodec converts snippet IDs to and from the form used in URLs. Without
+// hashids it uses plain integers; with them, opaque codes that don't reveal
+// how many snippets exist or allow walking through them.
+type idCodec struct {
+	hashids *hashids.HashID
+}
+
+// snippetIDs is the codec for snippet URLs. It is replaced at startup when
+// -obfuscate-ids is set.
+var snippetIDs = &idCodec{}
+
+// idCodeMinLength pads short codes so that low IDs don't stand out.
+const idCodeMinLength = 6
+
+func newIDCodec(salt string) (*idCodec, error) {
+	data := hashids.NewData()
+	data.Salt = salt
+	data.MinLength = idCodeMinLength
+
+	h, err := hashids.NewWithData(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &idCodec{hashids: h}, nil
+}
+
+func (c *idCodec) encode(id int) string {
+	if c.hashids == nil {
+		return strconv.Itoa(id)
+	}
+
+	code, err := c.hashids.Encode([]int{id})
+	if err != nil {
+		// Encode only fails for negative numbers, which are never valid IDs.
+		return ""
+	}
+
+	return code
+}
+
+// decode returns the ID for a URL value. It reports false for anything that
+// isn't exactly what encode produces for a positive ID.
+func (c *idCodec) decode(s string) (int, bool) {
+	if c.hashids == nil {
+		id, err := strconv.Atoi(s)
+		return id, err == nil && id >= 1
+	}
+
+	if s == "" {
+		return 0, false
+	}
+
+	ids, err := c.hashids.DecodeWithError(s)
+	if err != nil || len(ids) != 1 || ids[0] < 1 {
+		return 0, false
+	}
+
+	return ids[0], true
+}
+
+// snippetID is the snippetID template function, for building snippet URLs.
+func snippetID(id int) string {
+	return snippetIDs.encode(id)
+}
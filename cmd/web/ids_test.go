@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/assert"
+)
+
+func TestIDCodec(t *testing.T) {
+	hashed, err := newIDCodec("pepper")
+	assert.NilError(t, err)
+
+	for _, id := range []int{1, 2, 42, 1_000_000} {
+		code := hashed.encode(id)
+		assert.Equal(t, len(code) >= idCodeMinLength, true)
+
+		got, ok := hashed.decode(code)
+		assert.Equal(t, ok, true)
+		assert.Equal(t, got, id)
+
+		got, ok = snippetIDs.decode(snippetIDs.encode(id))
+		assert.Equal(t, ok, true)
+		assert.Equal(t, got, id)
+	}
+
+	other, err := newIDCodec("salt")
+	assert.NilError(t, err)
+	assert.Equal(t, other.encode(1) == hashed.encode(1), false)
+
+	for _, code := range []string{"", "1", "!!!!!!", hashed.encode(7) + "x", other.encode(7)} {
+		_, ok := hashed.decode(code)
+		assert.Equal(t, ok, false)
+	}
+
+	for _, code := range []string{"", "0", "-1", "1.5", "abc"} {
+		_, ok := snippetIDs.decode(code)
+		assert.Equal(t, ok, false)
+	}
+}
+
+func TestSnippetViewObfuscatedIDs(t *testing.T) {
+	codec, err := newIDCodec("pepper")
+	assert.NilError(t, err)
+
+	plain := snippetIDs
+	snippetIDs = codec
+	t.Cleanup(func() { snippetIDs = plain })
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/snippet/view/"+codec.encode(1))
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "An old silent pond...")
+	assert.StringContains(t, body, "#"+codec.encode(1))
+
+	_, _, body = ts.get(t, "/")
+	assert.StringContains(t, body, "<a href='/snippet/view/"+codec.encode(1)+"'>")
+
+	code, _, _ = ts.get(t, "/snippet/view/1")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _, _ = ts.get(t, "/snippet/view/not-a-code")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _, _ = ts.get(t, "/snippet/raw/"+codec.encode(3))
+	assert.Equal(t, code, http.StatusOK)
+}
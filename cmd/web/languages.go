@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// defaultLanguages are the -languages defaults.
+var defaultLanguages = []string{"bash", "c", "css", "go", "html", "javascript", "python", "rust", "sql"}
+
+var languageRX = regexp.MustCompile(`^[a-z0-9+#._-]{1,32}$`)
+
+// parseLanguages parses a comma-separated list of language names, such as
+// "go,python,sql". Names are lowercased and must fit the snippets.language
+// column.
+func parseLanguages(s string) ([]string, error) {
+	var languages []string
+
+	for name := range strings.SplitSeq(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		if !languageRX.MatchString(name) {
+			return nil, fmt.Errorf("invalid language %q: use up to 32 letters, digits or +#._-", name)
+		}
+
+		if !slices.Contains(languages, name) {
+			languages = append(languages, name)
+		}
+	}
+
+	if len(languages) == 0 {
+		return nil, errors.New("at least one language is required")
+	}
+
+	return languages, nil
+}
+
+// permittedLanguage reports whether a snippet may be created with the
+// language: one of the configured languages, or none for plain text.
+func (app *application) permittedLanguage(language string) bool {
+	return language == "" || slices.Contains(app.config.languages, language)
+}
+
+// languageSignal is a pattern typical of a language, and how strongly it
+// points to it.
+type languageSignal struct {
+	rx     *regexp.Regexp
+	weight int
+}
+
+// languageSignals are the patterns detectLanguage looks for. Each counts once
+// however often it matches, so one repeated construct can't outweigh a mix
+// of them.
+var languageSignals = map[string][]languageSignal{
+	"go": {
+		{regexp.MustCompile(`(?m)^package \w+\s*$`), 3},
+		{regexp.MustCompile(`(?m)^func (\([^)]*\) )?\w+\(`), 3},
+		{regexp.MustCompile(`(?m)^import (\(|")`), 2},
+		{regexp.MustCompile(`\w+ :=`), 1},
+		{regexp.MustCompile(`\bfmt\.\w+\(`), 2},
+		{regexp.MustCompile(`\b(defer|go func|chan)\b`), 1},
+		{regexp.MustCompile(`\bif err != nil\b`), 2},
+	},
+	"python": {
+		{regexp.MustCompile(`(?m)^\s*def \w+\(.*\):\s*$`), 3},
+		{regexp.MustCompile(`(?m)^(import \w+|from [\w.]+ import )`), 2},
+		{regexp.MustCompile(`\bself\.`), 1},
+		{regexp.MustCompile(`(?m)^\s*(elif|except)\b.*:\s*$`), 2},
+		{regexp.MustCompile(`(?m)^if __name__ == `), 3},
+	},
+	"javascript": {
+		{regexp.MustCompile(`\bconsole\.log\(`), 3},
+		{regexp.MustCompile(`(?m)^\s*(const|let|var) \w+ = `), 1},
+		{regexp.MustCompile(`\bfunction\s*\w*\(`), 2},
+		{regexp.MustCompile(`\) => `), 1},
+		{regexp.MustCompile(`\b(document|window)\.\w+`), 2},
+		{regexp.MustCompile(`\brequire\(['"]`), 2},
+	},
+	"rust": {
+		{regexp.MustCompile(`(?m)^\s*(pub )?fn \w+`), 2},
+		{regexp.MustCompile(`\blet mut\b`), 3},
+		{regexp.MustCompile(`\b(println|vec|format)!`), 3},
+		{regexp.MustCompile(`(?m)^\s*(impl|use|mod) `), 2},
+	},
+	"c": {
+		{regexp.MustCompile(`(?m)^#include\s*[<"]`), 3},
+		{regexp.MustCompile(`\bint main\s*\(`), 3},
+		{regexp.MustCompile(`\b(printf|malloc|free)\(`), 1},
+	},
+	"bash": {
+		{regexp.MustCompile(`^#!.*\b(ba)?sh\b`), 5},
+		{regexp.MustCompile(`(?m)^\s*echo\b`), 1},
+		{regexp.MustCompile(`(?m)^\s*(fi|done|esac)\s*$`), 2},
+		{regexp.MustCompile(`\$\{\w+\}`), 1},
+	},
+	"sql": {
+		{regexp.MustCompile(`(?is)\bSELECT\b.+\bFROM\b`), 3},
+		{regexp.MustCompile(`(?i)\b(INSERT INTO|CREATE TABLE|DELETE FROM)\b`), 3},
+		{regexp.MustCompile(`(?i)\bUPDATE \w+ SET\b`), 3},
+		{regexp.MustCompile(`(?i)\bWHERE\b`), 1},
+	},
+	"html": {
+		{regexp.MustCompile(`(?i)<!DOCTYPE html`), 5},
+		{regexp.MustCompile(`(?i)</(html|head|body|div|span|p|a|ul|li)>`), 3},
+	},
+	"css": {
+		{regexp.MustCompile(`(?m)^\s*[.#]?[\w-]+(\s*[>,.#:]?\s*[\w-]+)*\s*\{\s*$`), 1},
+		{regexp.MustCompile(`(?m)^\s*[a-z-]+\s*:\s*[^;{}]+;\s*$`), 2},
+		{regexp.MustCompile(`@media\b`), 3},
+	},
+}
+
+// minLanguageScore is the score a language must reach before detectLanguage
+// trusts it, so that prose with the odd keyword stays plain text.
+const minLanguageScore = 4
+
+// detectLanguage guesses the language of content among languages, by adding
+// up the weights of the language signals it contains. It returns "" when no
+// language scores well enough, or when two tie for the best score.
+func detectLanguage(content string, languages []string) string {
+	best, bestScore, tie := "", 0, false
+
+	for _, language := range languages {
+		score := 0
+		for _, signal := range languageSignals[language] {
+			if signal.rx.MatchString(content) {
+				score += signal.weight
+			}
+		}
+
+		switch {
+		case score > bestScore:
+			best, bestScore, tie = language, score, false
+		case score == bestScore:
+			tie = true
+		}
+	}
+
+	if bestScore < minLanguageScore || tie {
+		return ""
+	}
+
+	return best
+}
+
+// snippetLanguage returns the language to store a new snippet with: the
+// one chosen, or with -detect-language and no choice, a detected one.
+func (app *application) snippetLanguage(chosen, content string) string {
+	if chosen != "" || !app.config.detectLanguage {
+		return chosen
+	}
+
+	return detectLanguage(content, app.config.languages)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+// mailer sends transactional email, such as confirmation links.
+type mailer interface {
+	send(to, subject, body string) error
+}
+
+// smtpMailer sends plain-text email through an SMTP relay.
+type smtpMailer struct {
+	addr   string
+	auth   smtp.Auth
+	sender string
+}
+
+// newSMTPMailer returns a mailer for the relay at addr. Authentication is
+// only used when a username is given.
+func newSMTPMailer(addr, username, password, sender string) (*smtpMailer, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("mailer: %w", err)
+	}
+
+	m := &smtpMailer{addr: addr, sender: sender}
+
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return m, nil
+}
+
+func (m *smtpMailer) send(to, subject, body string) error {
+	if strings.ContainsAny(to+subject, "\r\n") {
+		return fmt.Errorf("mailer: header values must not contain line breaks")
+	}
+
+	var msg strings.Builder
+
+	fmt.Fprintf(&msg, "From: %s\r\n", m.sender)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	return smtp.SendMail(m.addr, m.auth, m.sender, []string{to}, []byte(msg.String()))
+}
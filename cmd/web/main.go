@@ -1,29 +1,167 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
 	"flag"
 	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"text/template"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/Vadim-Makhnev/snippetbox/internal/models"
 	"github.com/alexedwards/scs/mysqlstore"
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-playground/form/v4"
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
+	"golang.org/x/time/rate"
 )
 
+// version is the build version, set with -ldflags "-X main.version=...".
+var version = "dev"
+
+type config struct {
+	addr               string
+	baseURL            string
+	dsn                string
+	dbStatementTimeout time.Duration
+	appName            string
+	themeColor         string
+	inboundSecret      string
+	disposableDomains  string
+	staticDirs         []string
+	logExcludePaths    []string
+	obfuscateIDs       bool
+	idSalt             string
+	contentKey         string
+	canonicalHost      string
+	trustedOrigins     []string
+	defaultContent     string
+	snippetOfDay       bool
+	personalizedHome   bool
+	dateLayout         string
+	idempotencyTTL     time.Duration
+	feedTTL            time.Duration
+	homeCacheMaxAge    time.Duration
+	createCooldown     time.Duration
+	commentCooldown    time.Duration
+	importMaxBytes     int64
+	maxResponseBody    int
+	importTimeout      time.Duration
+	shutdownDrain      time.Duration
+	metricsLogInterval time.Duration
+	readyMigrations    bool
+	webhookURLs        []string
+	webhookSecret      string
+	defaultSort        string
+	featuredLimit      int
+	maxPins            int
+	minPasswordLength  int
+	signupEnabled      bool
+	requireInvite      bool
+	requireTerms       bool
+	sessionIdleTimeout time.Duration
+	sessionMaxAge      time.Duration
+	check              bool
+	strictTemplates    bool
+	debug              bool
+	expiryOptions      []expiryOption
+	languages          []string
+	uploadAllowedTypes []string
+	detectLanguage     bool
+	maxTags            int
+	maxInFlight        int
+	slugMaxLength      int
+	limiter            struct {
+		enabled   bool
+		userRPS   float64
+		userBurst int
+		ipRPS     float64
+		ipBurst   int
+		globalRPS float64
+	}
+	pageSizes struct {
+		home   models.PageSizeLimits
+		search models.PageSizeLimits
+		api    models.PageSizeLimits
+	}
+	titles struct {
+		normalize bool
+		titleCase bool
+	}
+	dbLog struct {
+		queries bool
+		args    bool
+	}
+	loginRedirect struct {
+		user  string
+		admin string
+	}
+	autoExtend struct {
+		enabled bool
+		window  int
+	}
+	smtp struct {
+		addr     string
+		username string
+		password string
+		sender   string
+	}
+}
+
 type application struct {
+	config         config
 	logger         *slog.Logger
 	snippets       models.SnippetModelInterface
 	users          models.UserModelInterface
+	tokens         models.TokenModelInterface
+	drafts         models.DraftModelInterface
+	invites        models.InviteModelInterface
+	comments       models.CommentModelInterface
 	templateCache  map[string]*template.Template
+	presets        presets
 	formDecoder    *form.Decoder
 	sessionManager *scs.SessionManager
+	idempotency    *idempotencyStore
+	webhooks       *webhookDispatcher
+	fetcher        *urlFetcher
+	userLimiter    *rateLimiter
+	ipLimiter      *rateLimiter
+	// globalLimiter caps API requests across all clients. It is nil when no
+	// -global-rps is set.
+	globalLimiter *rate.Limiter
+	feedCache     *feedCache
+	// inFlight holds a token for each request being served, to cap them at
+	// -max-in-flight. It is nil when there is no cap.
+	inFlight chan struct{}
+	// disposableDomains is nil when no -disposable-domains file is set, which
+	// skips the check.
+	disposableDomains domainList
+	// mailer is nil when no -smtp-addr is set, in which case email changes
+	// apply without confirmation.
+	mailer mailer
+	// db is only used directly to open the transactions of txHandler; other
+	// queries go through the models.
+	db *sql.DB
+	// wg tracks work started with background.
+	wg sync.WaitGroup
+	// readyCheck reports whether the server's dependencies are available,
+	// for /readyz.
+	readyCheck func(ctx context.Context) error
+	// draining is set once a graceful shutdown starts.
+	draining atomic.Bool
+	// requestsServed counts the requests served since startup, for the
+	// -metrics-log-interval snapshots.
+	requestsServed atomic.Int64
 }
 
 // @title       My API
@@ -31,40 +169,362 @@ type application struct {
 // @description This is a sample API
 // @host        localhost:4000
 // @BasePath
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
 func main() {
-	addr := flag.String("addr", ":4000", "HTTP network address")
-	dsn := flag.String("dsn", "web:pass@/snippetbox?parseTime=true", "MySQL data source name")
+	cfg := config{
+		expiryOptions:   defaultExpiryOptions,
+		languages:       defaultLanguages,
+		logExcludePaths: defaultLogExcludePaths,
+
+		uploadAllowedTypes: defaultUploadAllowedTypes,
+	}
+
+	cfg.pageSizes.home = models.PageSizeLimits{Min: 1, Default: 10, Max: 50}
+	cfg.pageSizes.search = models.PageSizeLimits{Min: 1, Default: 20, Max: 50}
+	cfg.pageSizes.api = models.PageSizeLimits{Min: 1, Default: 20, Max: models.MaxPageSize}
+
+	flag.StringVar(&cfg.addr, "addr", ":4000", "HTTP network address")
+	flag.StringVar(&cfg.baseURL, "base-url", "https://localhost:4000", "Public base URL of the application")
+	flag.StringVar(&cfg.dsn, "dsn", "web:pass@/snippetbox?parseTime=true", "MySQL data source name")
+	flag.StringVar(&cfg.appName, "app-name", "Snippetbox", "Application name used in the web app manifest")
+	flag.StringVar(&cfg.themeColor, "theme-color", "#34495E", "Theme color used in the web app manifest")
+	flag.StringVar(&cfg.canonicalHost, "canonical-host", "", "Host, with port if not the default, that requests for other hosts are redirected to (disabled when empty)")
+	flag.StringVar(&cfg.inboundSecret, "inbound-secret", "", "Shared secret for the inbound email webhook (disabled when empty)")
+	flag.StringVar(&cfg.defaultContent, "default-snippet-content", "", "Content pre-filled in the create form, such as a comment header")
+	flag.Func("page-size-home", `Page sizes of the latest snippets on the home page, as min:default:max (default "1:10:50")`, func(s string) (err error) {
+		cfg.pageSizes.home, err = parsePageSizeLimits(s)
+		return err
+	})
+	flag.Func("page-size-search", `Page sizes of the snippet archive and the admin user search, as min:default:max (default "1:20:50")`, func(s string) (err error) {
+		cfg.pageSizes.search, err = parsePageSizeLimits(s)
+		return err
+	})
+	flag.Func("page-size-api", `Page sizes of the JSON and GraphQL snippet lists, as min:default:max (default "1:20:100")`, func(s string) (err error) {
+		cfg.pageSizes.api, err = parsePageSizeLimits(s)
+		return err
+	})
+	flag.Func("home-limit", "Number of latest snippets shown on the home page by default, the default of -page-size-home (default 10)", func(s string) (err error) {
+		cfg.pageSizes.home.Default, err = strconv.Atoi(s)
+		return err
+	})
+	flag.StringVar(&cfg.defaultSort, "default-sort", "", "Order of snippet listings without a sort parameter: created or -created (default newest first on the home page, oldest first in the archive)")
+	flag.IntVar(&cfg.maxTags, "max-tags-per-snippet", 5, "Maximum number of different tags a snippet can have")
+	flag.IntVar(&cfg.slugMaxLength, "slug-max-length", 60, "Maximum length of the slugs generated from snippet titles (at least 1)")
+	flag.IntVar(&cfg.featuredLimit, "featured-limit", 5, "Number of featured snippets shown on the home page (0 hides the section)")
+	flag.IntVar(&cfg.maxPins, "max-pins", 3, "Maximum number of snippets a user can pin to their profile (0 disables pinning)")
+	flag.BoolVar(&cfg.snippetOfDay, "snippet-of-the-day", true, "Show a snippet of the day on the home page")
+	flag.BoolVar(&cfg.personalizedHome, "personalized-home", false, "List a logged-in user's own latest snippets on the home page instead of everyone's")
+	flag.StringVar(&cfg.dateLayout, "date-layout", defaultDateLayout, "Default Go time layout used by the formatDate template function")
+	flag.DurationVar(&cfg.idempotencyTTL, "idempotency-ttl", 24*time.Hour, "How long Idempotency-Key results are remembered")
+	flag.DurationVar(&cfg.createCooldown, "create-cooldown", 0, "Minimum time between two snippets created by the same user through the form (0 disables)")
+	flag.DurationVar(&cfg.commentCooldown, "comment-cooldown", 0, "Minimum time between two comments posted by the same user (0 disables)")
+	flag.IntVar(&cfg.maxResponseBody, "max-response-body", 10_485_760, "Largest JSON response body in bytes; larger responses fail with a 500 problem document (0 disables)")
+	flag.Int64Var(&cfg.importMaxBytes, "import-max-bytes", 1_048_576, "Largest response or file accepted when importing a snippet from a URL or an upload")
+	flag.DurationVar(&cfg.importTimeout, "import-timeout", 10*time.Second, "Time allowed for fetching a URL to import a snippet from")
+	flag.DurationVar(&cfg.metricsLogInterval, "metrics-log-interval", 0, "How often to log a snapshot of requests served, goroutines, database pool and memory use (0 disables)")
+	flag.DurationVar(&cfg.homeCacheMaxAge, "home-cache-max-age", 30*time.Second, "How long browsers and CDNs may cache the home page for anonymous users, in whole seconds (0 disables)")
+	flag.DurationVar(&cfg.feedTTL, "feed-ttl", 5*time.Minute, "How long the RSS feed is served before it is refreshed in the background")
+	flag.Func("webhook-url", "Endpoint that receives snippet lifecycle webhooks (repeatable)", func(s string) error {
+		cfg.webhookURLs = append(cfg.webhookURLs, s)
+		return nil
+	})
+	flag.Func("expiry-options", `Snippet lifetimes offered to users as days:label pairs (default "1:One day,7:One week,365:One year")`, func(s string) error {
+		options, err := parseExpiryOptions(s)
+		if err != nil {
+			return err
+		}
+		cfg.expiryOptions = options
+		return nil
+	})
+	flag.Func("languages", `Comma-separated languages snippets can be marked as (default "`+strings.Join(defaultLanguages, ",")+`")`, func(s string) error {
+		languages, err := parseLanguages(s)
+		if err != nil {
+			return err
+		}
+		cfg.languages = languages
+		return nil
+	})
+	flag.Func("upload-allowed-types", `Comma-separated MIME types, sniffed from the content, and file extensions of the files snippets can be uploaded from (default "`+strings.Join(defaultUploadAllowedTypes, ",")+`")`, func(s string) error {
+		types, err := parseUploadTypes(s)
+		if err != nil {
+			return err
+		}
+		cfg.uploadAllowedTypes = types
+		return nil
+	})
+	flag.BoolVar(&cfg.detectLanguage, "detect-language", false, "Guess the language of snippets created without one from their content")
+	flag.Func("static-dir", "Directory of static files overriding the bundled ones (repeatable, earlier wins)", func(s string) error {
+		cfg.staticDirs = append(cfg.staticDirs, s)
+		return nil
+	})
+	flag.Func("log-exclude-paths", `Comma-separated URL path prefixes left out of the request log (default "/healthz,/readyz,/metrics,/favicon.ico")`, func(s string) error {
+		cfg.logExcludePaths = nil
+		for prefix := range strings.SplitSeq(s, ",") {
+			if prefix = strings.TrimSpace(prefix); prefix != "" {
+				cfg.logExcludePaths = append(cfg.logExcludePaths, prefix)
+			}
+		}
+		return nil
+	})
+	flag.Func("trusted-origins", "Comma-separated origins, besides -base-url, allowed to submit forms (such as https://admin.example.com)", func(s string) error {
+		cfg.trustedOrigins = nil
+		for origin := range strings.SplitSeq(s, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				cfg.trustedOrigins = append(cfg.trustedOrigins, origin)
+			}
+		}
+		return nil
+	})
+	flag.StringVar(&cfg.webhookSecret, "webhook-secret", "", "Shared secret used to sign webhook payloads")
+	flag.IntVar(&cfg.minPasswordLength, "min-password-length", 8, "Minimum length of user passwords (at least 8)")
+	flag.StringVar(&cfg.loginRedirect.user, "login-redirect", "/snippet/create", "Path users are sent to after logging in, unless the login page was given another with ?next=")
+	flag.StringVar(&cfg.loginRedirect.admin, "admin-login-redirect", "/admin", "Path admins are sent to after logging in, unless the login page was given another with ?next=")
+	flag.BoolVar(&cfg.signupEnabled, "signup-enabled", true, "Let visitors register accounts through the signup form and API")
+	flag.BoolVar(&cfg.requireInvite, "require-invite", false, "Require a single-use invite code, created by an admin, to sign up")
+	flag.BoolVar(&cfg.requireTerms, "require-terms", false, "Require accepting the terms of service to sign up")
+	flag.DurationVar(&cfg.sessionIdleTimeout, "session-idle-timeout", 0, "Expire sessions after this long without activity (0 disables)")
+	flag.DurationVar(&cfg.sessionMaxAge, "session-max-age", 12*time.Hour, "Require logging in again this long after the last login, whatever the session cookie says (0 disables)")
+	flag.BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enable API rate limiting")
+	flag.Float64Var(&cfg.limiter.userRPS, "limiter-user-rps", 10, "API requests per second allowed per authenticated user")
+	flag.IntVar(&cfg.limiter.userBurst, "limiter-user-burst", 20, "API request burst allowed per authenticated user")
+	flag.Float64Var(&cfg.limiter.ipRPS, "limiter-ip-rps", 2, "API requests per second allowed per IP for anonymous calls")
+	flag.IntVar(&cfg.limiter.ipBurst, "limiter-ip-burst", 4, "API request burst allowed per IP for anonymous calls")
+	flag.Float64Var(&cfg.limiter.globalRPS, "global-rps", 0, "API requests per second allowed across all clients, on top of the per-client limits (0 disables)")
+	flag.StringVar(&cfg.disposableDomains, "disposable-domains", "", "File listing disposable email domains to reject at signup, one per line")
+	flag.BoolVar(&cfg.autoExtend.enabled, "auto-extend-popular", false, "Extend a snippet's expiry by a day when it is viewed close to expiring")
+	flag.IntVar(&cfg.autoExtend.window, "auto-extend-window", 10, "How close to expiry a view must be to extend it, as a percentage of the snippet's lifetime")
+	flag.BoolVar(&cfg.titles.normalize, "normalize-titles", false, "Trim snippet titles and collapse internal whitespace before validating them")
+	flag.BoolVar(&cfg.titles.titleCase, "title-case", false, "Also capitalise the first letter of each word in snippet titles (implies -normalize-titles)")
+	flag.BoolVar(&cfg.obfuscateIDs, "obfuscate-ids", false, "Use opaque hashids codes instead of integers in snippet URLs")
+	flag.StringVar(&cfg.idSalt, "id-salt", "", "Secret salt for -obfuscate-ids codes (required with it)")
+	flag.StringVar(&cfg.contentKey, "content-encryption-key", "", "Hex-encoded 32-byte AES key to encrypt private snippet content at rest (empty stores it in plaintext)")
+	flag.StringVar(&cfg.smtp.addr, "smtp-addr", "", "SMTP relay host:port for confirmation emails (email changes skip confirmation when empty)")
+	flag.StringVar(&cfg.smtp.username, "smtp-username", "", "SMTP username")
+	flag.StringVar(&cfg.smtp.password, "smtp-password", "", "SMTP password")
+	flag.StringVar(&cfg.smtp.sender, "smtp-sender", "Snippetbox <no-reply@snippetbox.example.com>", "Sender address for outgoing email")
+	flag.DurationVar(&cfg.shutdownDrain, "shutdown-drain", 5*time.Second, "How long to keep serving with /readyz failing before a graceful shutdown stops the server")
+	flag.BoolVar(&cfg.readyMigrations, "ready-require-migrations", false, "Report not ready from /readyz until all migrations are applied")
+	flag.IntVar(&cfg.maxInFlight, "max-in-flight", 0, "Maximum number of requests served at once, beyond which requests get 503 (0 means no limit)")
+	flag.DurationVar(&cfg.dbStatementTimeout, "db-statement-timeout", 0, "Longest a SELECT may run before MySQL aborts it, set as max_execution_time on every connection (0 leaves the server's setting)")
+	flag.BoolVar(&cfg.dbLog.queries, "db-log-queries", false, "Log every SQL statement and its duration (for development only)")
+	flag.BoolVar(&cfg.dbLog.args, "db-log-args", false, "Include statement arguments in -db-log-queries logs, except for statements involving passwords")
+	flag.BoolVar(&cfg.debug, "debug", false, "Show error details on error pages (for development only)")
+	flag.BoolVar(&cfg.strictTemplates, "strict-templates", false, "Refuse to start if any page template fails to render with empty data")
+	flag.BoolVar(&cfg.check, "check", false, "Validate configuration and connectivity, then exit without serving")
 	flag.Parse()
 
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 
-	db, err := OpenDB(*dsn)
+	if cfg.minPasswordLength < 8 {
+		logger.Error("-min-password-length must be at least 8", "value", cfg.minPasswordLength)
+		os.Exit(1)
+	}
+
+	if !localPath(cfg.loginRedirect.user) {
+		logger.Error("-login-redirect must be a path on this site", "value", cfg.loginRedirect.user)
+		os.Exit(1)
+	}
+
+	if !localPath(cfg.loginRedirect.admin) {
+		logger.Error("-admin-login-redirect must be a path on this site", "value", cfg.loginRedirect.admin)
+		os.Exit(1)
+	}
+
+	err := checkDefaultSort(cfg.defaultSort)
+	if err != nil {
+		logger.Error(err.Error(), "value", cfg.defaultSort)
+		os.Exit(1)
+	}
+
+	// -home-limit can move the home default outside -page-size-home.
+	err = cfg.pageSizes.home.Check()
+	if err != nil {
+		logger.Error("-home-limit must be within -page-size-home", "value", cfg.pageSizes.home.Default)
+		os.Exit(1)
+	}
+
+	if cfg.importMaxBytes < 1 {
+		logger.Error("-import-max-bytes must be at least 1", "value", cfg.importMaxBytes)
+		os.Exit(1)
+	}
+
+	if cfg.maxResponseBody < 0 {
+		logger.Error("-max-response-body must not be negative", "value", cfg.maxResponseBody)
+		os.Exit(1)
+	}
+
+	if cfg.dbStatementTimeout < 0 || (cfg.dbStatementTimeout > 0 && cfg.dbStatementTimeout < time.Millisecond) {
+		logger.Error("-db-statement-timeout must be 0 or at least 1ms", "value", cfg.dbStatementTimeout)
+		os.Exit(1)
+	}
+
+	if cfg.homeCacheMaxAge < 0 {
+		logger.Error("-home-cache-max-age must not be negative", "value", cfg.homeCacheMaxAge)
+		os.Exit(1)
+	}
+
+	if cfg.metricsLogInterval < 0 {
+		logger.Error("-metrics-log-interval must not be negative", "value", cfg.metricsLogInterval)
+		os.Exit(1)
+	}
+
+	if cfg.maxInFlight < 0 {
+		logger.Error("-max-in-flight must not be negative", "value", cfg.maxInFlight)
+		os.Exit(1)
+	}
+
+	if cfg.maxPins < 0 {
+		logger.Error("-max-pins must not be negative", "value", cfg.maxPins)
+		os.Exit(1)
+	}
+
+	if cfg.slugMaxLength < 1 {
+		logger.Error("-slug-max-length must be at least 1", "value", cfg.slugMaxLength)
+		os.Exit(1)
+	}
+
+	if cfg.autoExtend.window < 1 || cfg.autoExtend.window > 100 {
+		logger.Error("-auto-extend-window must be between 1 and 100", "value", cfg.autoExtend.window)
+		os.Exit(1)
+	}
+
+	if cfg.obfuscateIDs {
+		if cfg.idSalt == "" {
+			logger.Error("-id-salt is required with -obfuscate-ids")
+			os.Exit(1)
+		}
+
+		codec, err := newIDCodec(cfg.idSalt)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+
+		snippetIDs = codec
+	}
+
+	var contentCipher *models.ContentCipher
+	if cfg.contentKey != "" {
+		key, err := hex.DecodeString(cfg.contentKey)
+		if err != nil {
+			logger.Error("-content-encryption-key must be hex encoded", "error", err.Error())
+			os.Exit(1)
+		}
+
+		contentCipher, err = models.NewContentCipher(key)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if cfg.check {
+		os.Exit(runPreflight(logger, preflightChecks(cfg)))
+	}
+
+	var queryLog *queryLogger
+	if cfg.dbLog.queries {
+		logger.Warn("logging every SQL statement; -db-log-queries is meant for development")
+		queryLog = &queryLogger{logger: logger, args: cfg.dbLog.args}
+	}
+
+	db, err := OpenDB(cfg.dsn, cfg.dbStatementTimeout, queryLog)
 	if err != nil {
 		logger.Error(err.Error())
 		os.Exit(1)
 	}
 
+	var disposableDomains domainList
+	if cfg.disposableDomains != "" {
+		disposableDomains, err = loadDomainList(cfg.disposableDomains)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+	}
+
 	defer db.Close()
 
-	templateCache, err := newTemplateCache()
+	if len(cfg.staticDirs) > 0 {
+		assets, err = newStaticAssets(cfg.staticDirs)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+	}
+
+	templateCache, err := newTemplateCache(cfg.dateLayout)
 	if err != nil {
 		logger.Error(err.Error())
 		os.Exit(1)
 	}
 
+	err = selfTestTemplates(templateCache, cfg.strictTemplates, logger)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	presets, err := newPresets()
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	var mailer mailer
+	if cfg.smtp.addr != "" {
+		mailer, err = newSMTPMailer(cfg.smtp.addr, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+	}
+
 	formDecoder := form.NewDecoder()
 
 	sessionManager := scs.New()
 	sessionManager.Store = mysqlstore.New(db)
 	sessionManager.Lifetime = 12 * time.Hour
+	sessionManager.IdleTimeout = cfg.sessionIdleTimeout
 
 	app := &application{
+		config:         cfg,
 		logger:         logger,
-		snippets:       &models.SnippetModel{DB: db},
+		db:             db,
+		snippets:       &models.SnippetModel{DB: db, Cipher: contentCipher},
 		users:          &models.UserModel{DB: db},
+		tokens:         &models.TokenModel{DB: db},
+		drafts:         &models.DraftModel{DB: db},
+		invites:        &models.InviteModel{DB: db},
+		comments:       &models.CommentModel{DB: db},
 		templateCache:  templateCache,
+		presets:        presets,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
+		idempotency:    newIdempotencyStore(cfg.idempotencyTTL),
+		webhooks:       newWebhookDispatcher(cfg.webhookURLs, cfg.webhookSecret, logger),
+		fetcher:        newURLFetcher(cfg.importTimeout, cfg.importMaxBytes),
+		userLimiter:    newRateLimiter(cfg.limiter.userRPS, cfg.limiter.userBurst),
+		ipLimiter:      newRateLimiter(cfg.limiter.ipRPS, cfg.limiter.ipBurst),
+		globalLimiter:  newGlobalLimiter(cfg.limiter.globalRPS),
+
+		disposableDomains: disposableDomains,
+		mailer:            mailer,
+		readyCheck:        dbReadyCheck(db, cfg.readyMigrations),
+	}
+
+	app.feedCache = newFeedCache(cfg.feedTTL, app.buildFeed, app.background, logger)
+
+	if cfg.maxInFlight > 0 {
+		app.inFlight = make(chan struct{}, cfg.maxInFlight)
+	}
+
+	app.webhooks.start()
+
+	if cfg.metricsLogInterval > 0 {
+		go app.logMetrics(context.Background(), cfg.metricsLogInterval)
 	}
 
 	tlsConfig := &tls.Config{
@@ -72,7 +532,7 @@ func main() {
 	}
 
 	srv := &http.Server{
-		Addr:         *addr,
+		Addr:         cfg.addr,
 		Handler:      app.routes(),
 		ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelError),
 		TLSConfig:    tlsConfig,
@@ -81,19 +541,46 @@ func main() {
 		WriteTimeout: 10 * time.Second,
 	}
 
+	logger.Info("effective config", "version", version, "config", cfg, "db_max_open_conns", db.Stats().MaxOpenConnections)
 	logger.Info("starting server", "addr", srv.Addr)
 
-	err = srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
-	logger.Error(err.Error())
-	os.Exit(1)
+	err = app.serve(srv, "./tls/cert.pem", "./tls/key.pem")
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
 }
 
-func OpenDB(dsn string) (*sql.DB, error) {
-	db, err := sql.Open("mysql", dsn)
+// OpenDB connects to the MySQL database at dsn. A statementTimeout is set as
+// max_execution_time when each connection is opened, overriding one in the
+// DSN, so the server itself aborts SELECTs running longer, whatever happens
+// to the client. With a queryLog, every statement is logged.
+func OpenDB(dsn string, statementTimeout time.Duration, queryLog *queryLogger) (*sql.DB, error) {
+	mysqlConfig, err := mysql.ParseDSN(dsn)
 	if err != nil {
 		return nil, err
 	}
 
+	if statementTimeout > 0 {
+		if mysqlConfig.Params == nil {
+			mysqlConfig.Params = make(map[string]string)
+		}
+		mysqlConfig.Params["max_execution_time"] = strconv.FormatInt(statementTimeout.Milliseconds(), 10)
+	}
+
+	var connector driver.Connector
+
+	connector, err = mysql.NewConnector(mysqlConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if queryLog != nil {
+		connector = queryLog.wrap(connector)
+	}
+
+	db := sql.OpenDB(connector)
+
 	err = db.Ping()
 	if err != nil {
 		db.Close()
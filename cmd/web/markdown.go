@@ -0,0 +1,28 @@
+package main
+
+import (
+	"bytes"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+var (
+	markdown       = goldmark.New(goldmark.WithExtensions(extension.GFM))
+	markdownPolicy = bluemonday.UGCPolicy()
+)
+
+// renderMarkdown converts snippet content to sanitized HTML. Both the preview
+// and the markdown template function go through here, so a preview always
+// matches the final render.
+func renderMarkdown(content string) (string, error) {
+	var buf bytes.Buffer
+
+	err := markdown.Convert([]byte(content), &buf)
+	if err != nil {
+		return "", err
+	}
+
+	return markdownPolicy.Sanitize(buf.String()), nil
+}
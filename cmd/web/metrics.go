@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"time"
+)
+
+// logMetrics logs a snapshot of the server's metrics every interval until
+// ctx is done, for -metrics-log-interval. It gives a rough view of load and
+// resource use where no metrics system scrapes the server.
+func (app *application) logMetrics(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			app.logMetricsSnapshot()
+		}
+	}
+}
+
+// logMetricsSnapshot logs the requests served so far, the goroutines
+// running, the database pool and memory use. The pool is left out when
+// there is no database, as in tests.
+func (app *application) logMetricsSnapshot() {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	attrs := []any{
+		"requests_served", app.requestsServed.Load(),
+		"goroutines", runtime.NumGoroutine(),
+		slog.Group("memory",
+			"alloc_bytes", mem.Alloc,
+			"heap_inuse_bytes", mem.HeapInuse,
+			"sys_bytes", mem.Sys,
+			"num_gc", mem.NumGC,
+		),
+	}
+
+	if app.db != nil {
+		stats := app.db.Stats()
+
+		attrs = append(attrs, slog.Group("db",
+			"open_connections", stats.OpenConnections,
+			"in_use", stats.InUse,
+			"idle", stats.Idle,
+			"wait_count", stats.WaitCount,
+			"wait_duration", stats.WaitDuration,
+		))
+	}
+
+	app.logger.Info("metrics snapshot", attrs...)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/assert"
+)
+
+// logLines passes each line written to it on to a channel.
+type logLines chan []byte
+
+func (l logLines) Write(p []byte) (int, error) {
+	l <- append([]byte(nil), p...)
+	return len(p), nil
+}
+
+func TestLogMetrics(t *testing.T) {
+	app := newTestApplication(t)
+
+	app.db = sql.OpenDB(txConnector{store: &txStore{}})
+	defer app.db.Close()
+
+	handler := app.logRequest(http.HandlerFunc(ping))
+	for range 2 {
+		r, err := http.NewRequest(http.MethodGet, "/ping", nil)
+		assert.NilError(t, err)
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	lines := make(logLines, 1)
+	app.logger = slog.New(slog.NewJSONHandler(lines, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go app.logMetrics(ctx, 10*time.Millisecond)
+
+	var line []byte
+	select {
+	case line = <-lines:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no metrics snapshot was logged")
+	}
+
+	cancel()
+
+	var snapshot struct {
+		Msg            string
+		RequestsServed int `json:"requests_served"`
+		Goroutines     int
+		Memory         map[string]any
+		DB             map[string]any
+	}
+
+	err := json.Unmarshal(line, &snapshot)
+	assert.NilError(t, err)
+
+	assert.Equal(t, snapshot.Msg, "metrics snapshot")
+	assert.Equal(t, snapshot.RequestsServed, 2)
+	assert.Equal(t, snapshot.Goroutines > 0, true)
+
+	for _, field := range []string{"alloc_bytes", "heap_inuse_bytes", "sys_bytes", "num_gc"} {
+		_, ok := snapshot.Memory[field]
+		assert.Equal(t, ok, true)
+	}
+
+	for _, field := range []string{"open_connections", "in_use", "idle", "wait_count", "wait_duration"} {
+		_, ok := snapshot.DB[field]
+		assert.Equal(t, ok, true)
+	}
+}
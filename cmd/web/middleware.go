@@ -2,10 +2,18 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"net"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/Vadim-Makhnev/snippetbox/internal/models"
 	"github.com/justinas/nosurf"
 )
 
@@ -28,17 +36,243 @@ func commonHeaders(next http.Handler) http.Handler {
 	})
 }
 
+// appVersion reports which build served the response, so operators can
+// follow a rollout.
+func appVersion(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-App-Version", version)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// canonicalHost permanently redirects requests for any other host to the
+// -canonical-host one, keeping the scheme, path and query. It does nothing
+// when no canonical host is set.
+func (app *application) canonicalHost(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := app.config.canonicalHost
+
+		if host == "" || strings.EqualFold(r.Host, host) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+
+		u := url.URL{Scheme: scheme, Host: host, Path: r.URL.Path, RawPath: r.URL.RawPath, RawQuery: r.URL.RawQuery}
+
+		http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+	})
+}
+
+// collapseSlashes permanently redirects paths with runs of slashes, such as
+// /snippet//view//1, to the path with single slashes, keeping the query.
+// Slashes escaped as %2F are left alone. The redirected-to path has no
+// doubled slashes, so it can't redirect again. Requests other than GET and
+// HEAD get 308 rather than 301, so that clients resend the same method and
+// body.
+func collapseSlashes(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.EscapedPath()
+
+		if !strings.Contains(path, "//") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		for strings.Contains(path, "//") {
+			path = strings.ReplaceAll(path, "//", "/")
+		}
+
+		if r.URL.RawQuery != "" {
+			path += "?" + r.URL.RawQuery
+		}
+
+		status := http.StatusMovedPermanently
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			status = http.StatusPermanentRedirect
+		}
+
+		w.Header().Set("Location", path)
+		w.WriteHeader(status)
+	})
+}
+
+// checkOrigin rejects state-changing requests whose Origin, or failing that
+// Referer, names a site other than this one, as a second line of defence
+// behind the CSRF token. Same-origin requests, the -base-url origin and any
+// -trusted-origins pass. Requests carrying neither header are left to the
+// CSRF check.
+func (app *application) checkOrigin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			origin = r.Header.Get("Referer")
+		}
+
+		if origin != "" && !app.trustedOrigin(r, origin) {
+			app.logger.Warn("rejected cross-origin request", "origin", origin, "method", r.Method, "uri", r.URL.RequestURI())
+			app.clientError(w, r, http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// trustedOrigin reports whether origin, an Origin header or a Referer URL,
+// is this site.
+func (app *application) trustedOrigin(r *http.Request, origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	candidates := append([]string{app.config.baseURL}, app.config.trustedOrigins...)
+
+	for _, candidate := range candidates {
+		c, err := url.Parse(candidate)
+		if err != nil {
+			continue
+		}
+
+		if strings.EqualFold(c.Scheme, u.Scheme) && strings.EqualFold(c.Host, u.Host) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// defaultLogExcludePaths are the -log-exclude-paths defaults: probes and
+// scrapes that would otherwise flood the access log.
+var defaultLogExcludePaths = []string{"/healthz", "/readyz", "/metrics", "/favicon.ico"}
+
+// statusRecorder remembers the status of the response written through it,
+// for the access log.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// logRequest logs each request as it arrives and again with its status once
+// it is served. A panic passing through is logged as a 500, which is what
+// recoverPanic, outside this middleware, turns it into. Excluded paths are
+// not logged, but are still counted in requestsServed.
 func (app *application) logRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer app.requestsServed.Add(1)
+
+		for _, prefix := range app.config.logExcludePaths {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
 		var (
 			ip     = r.RemoteAddr
 			proto  = r.Proto
 			method = r.Method
 			uri    = r.URL.RequestURI()
+			start  = time.Now()
 		)
 
 		app.logger.Info("received request", "ip", ip, "proto", proto, "method", method, "uri", uri)
 
+		rec := &statusRecorder{ResponseWriter: w}
+
+		defer func() {
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			p := recover()
+			if p != nil {
+				status = http.StatusInternalServerError
+			}
+
+			app.logger.Info("completed request", "ip", ip, "proto", proto, "method", method, "uri", uri, "status", status, "duration", time.Since(start))
+
+			if p != nil {
+				panic(p)
+			}
+		}()
+
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// healthCheckPaths are the probes that limitInFlight always lets through,
+// so an overloaded server isn't also reported as dead.
+var healthCheckPaths = []string{"/ping", "/healthz", "/readyz"}
+
+// limitInFlight caps the requests being served at once at -max-in-flight,
+// so a burst can't queue up on the database pool. Requests over the cap are
+// turned away with 503 straight away instead of waiting.
+func (app *application) limitInFlight(next http.Handler) http.Handler {
+	if app.inFlight == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slices.Contains(healthCheckPaths, r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		select {
+		case app.inFlight <- struct{}{}:
+			defer func() { <-app.inFlight }()
+			next.ServeHTTP(w, r)
+		default:
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "The server is handling too many requests, please try again later", http.StatusServiceUnavailable)
+		}
+	})
+}
+
+// maxBytes limits request bodies to n bytes. Reading past the limit fails,
+// so a form that is too large doesn't parse.
+func maxBytes(n int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, n)
 		next.ServeHTTP(w, r)
 	})
 }
@@ -70,8 +304,36 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 	})
 }
 
+// requireAdmin lets through only authenticated admins. It sends anonymous
+// users to log in like requireAuthentication, and refuses other users.
+func (app *application) requireAdmin(next http.Handler) http.Handler {
+	return app.requireAuthentication(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		admin, err := app.users.IsAdmin(app.authenticatedUserID(r))
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		if !admin {
+			app.clientError(w, r, http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}))
+}
+
 func noSurf(next http.Handler) http.Handler {
-	csrfHandler := nosurf.New(next)
+	var csrfHandler *nosurf.CSRFHandler
+
+	// The handler is passed down in the context so that renewCSRFToken can
+	// reach it.
+	withHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), csrfHandlerContextKey, csrfHandler)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+
+	csrfHandler = nosurf.New(withHandler)
 	csrfHandler.SetBaseCookie(http.Cookie{
 		HttpOnly: true,
 		Path:     "/",
@@ -90,6 +352,22 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 			return
 		}
 
+		// The session's own lifetime rests on its expiry, which a tampered
+		// cookie or a skewed clock could stretch, so the login itself is
+		// also checked against -session-max-age. A session with no login
+		// time predates the check and is treated as too old.
+		if maxAge := app.config.sessionMaxAge; maxAge > 0 {
+			loginTime := app.sessionManager.GetTime(r.Context(), "loginTime")
+
+			if loginTime.IsZero() || time.Since(loginTime) > maxAge {
+				app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+				app.sessionManager.Remove(r.Context(), "loginTime")
+
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
 		exists, err := app.users.Exists(id)
 		if err != nil {
 			app.serverError(w, r, err)
@@ -98,9 +376,101 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 
 		if exists {
 			ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+			ctx = context.WithValue(ctx, authenticatedUserIDContextKey, id)
 			r = r.WithContext(ctx)
 		}
 
 		next.ServeHTTP(w, r)
 	})
 }
+
+func (app *application) authenticateToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Authorization")
+
+		authorizationHeader := r.Header.Get("Authorization")
+		if authorizationHeader == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, ok := strings.CutPrefix(authorizationHeader, "Bearer ")
+		if !ok || token == "" {
+			app.invalidAuthenticationToken(w, r)
+			return
+		}
+
+		id, err := app.tokens.UserID(models.ScopeAuthentication, token)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.invalidAuthenticationToken(w, r)
+			} else {
+				app.serverError(w, r, err)
+			}
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+		ctx = context.WithValue(ctx, authenticatedUserIDContextKey, id)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requireAPIAuthentication is the JSON counterpart of requireAuthentication.
+// It accepts a user authenticated either by bearer token or by session.
+func (app *application) requireAPIAuthentication(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.isAuthenticated(r) {
+			app.problem(w, r, http.StatusUnauthorized, "you must be authenticated to access this resource")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimitAPI limits API requests per authenticated user, falling back to
+// the client IP for anonymous calls, so users behind a shared NAT don't use
+// up each other's allowance. It must run after authentication.
+func (app *application) rateLimitAPI(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.config.limiter.enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		limiter, key := app.ipLimiter, ""
+
+		if app.isAuthenticated(r) {
+			limiter, key = app.userLimiter, strconv.Itoa(app.authenticatedUserID(r))
+		} else {
+			ip, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				ip = r.RemoteAddr
+			}
+			key = ip
+		}
+
+		allowed, remaining, reset := limiter.allow(key)
+
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limiter.burst))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		w.Header().Set("X-RateLimit-Reset", strconv.Itoa(int(math.Ceil(reset.Seconds()))))
+
+		if !allowed {
+			app.problem(w, r, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+
+		if app.globalLimiter != nil {
+			if delay := retryAfter(app.globalLimiter); delay > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+				app.problem(w, r, http.StatusServiceUnavailable, "the server is handling too many requests, please try again later")
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
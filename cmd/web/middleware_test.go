@@ -3,11 +3,17 @@ package main
 import (
 	"bytes"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/Vadim-Makhnev/snippetbox/internal/assert"
+	"github.com/Vadim-Makhnev/snippetbox/internal/models/mocks"
+	"golang.org/x/time/rate"
 )
 
 func TestCommonHeaders(t *testing.T) {
@@ -53,3 +59,544 @@ func TestCommonHeaders(t *testing.T) {
 	body = bytes.TrimSpace(body)
 	assert.Equal(t, string(body), "OK")
 }
+
+func TestRateLimitAPIPerUser(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.limiter.enabled = true
+	app.userLimiter = newRateLimiter(0.001, 3)
+	app.ipLimiter = newRateLimiter(0.001, 2)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	get := func(token string) (int, http.Header) {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+"/api/v1/snippets", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		rs, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs.Body.Close()
+
+		return rs.StatusCode, rs.Header
+	}
+
+	for i := 2; i >= 0; i-- {
+		code, header := get(mocks.AliceToken)
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, header.Get("X-RateLimit-Limit"), "3")
+		assert.Equal(t, header.Get("X-RateLimit-Remaining"), strconv.Itoa(i))
+		// At 0.001 requests per second, each spent request takes 1000
+		// seconds to come back.
+		assert.Equal(t, header.Get("X-RateLimit-Reset"), strconv.Itoa((3-i)*1000))
+	}
+
+	code, header := get(mocks.AliceToken)
+	assert.Equal(t, code, http.StatusTooManyRequests)
+	assert.Equal(t, header.Get("X-RateLimit-Limit"), "3")
+	assert.Equal(t, header.Get("X-RateLimit-Remaining"), "0")
+	assert.Equal(t, header.Get("X-RateLimit-Reset"), "3000")
+
+	// Anonymous calls from the same IP draw on the separate IP allowance.
+	code, header = get("")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("X-RateLimit-Limit"), "2")
+
+	code, _ = get("")
+	assert.Equal(t, code, http.StatusOK)
+
+	code, _ = get("")
+	assert.Equal(t, code, http.StatusTooManyRequests)
+}
+
+func TestRateLimitAPIGlobal(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.limiter.enabled = true
+	app.ipLimiter = newRateLimiter(1000, 1000)
+	app.globalLimiter = rate.NewLimiter(0.5, 5)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	const requests = 20
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		codes = make(map[int]int)
+	)
+
+	for range requests {
+		wg.Go(func() {
+			rs, err := ts.Client().Get(ts.URL + "/api/v1/snippets")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			rs.Body.Close()
+
+			if rs.StatusCode == http.StatusServiceUnavailable {
+				assert.Equal(t, rs.Header.Get("Retry-After") != "", true)
+			}
+
+			mu.Lock()
+			codes[rs.StatusCode]++
+			mu.Unlock()
+		})
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, codes[http.StatusOK], 5)
+	assert.Equal(t, codes[http.StatusServiceUnavailable], requests-5)
+}
+
+func TestAppVersion(t *testing.T) {
+	defaultVersion := version
+	t.Cleanup(func() { version = defaultVersion })
+
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, header, _ := ts.get(t, "/ping")
+	assert.Equal(t, header.Get("X-App-Version"), defaultVersion)
+
+	version = "1.4.2"
+
+	code, header, _ := ts.get(t, "/")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("X-App-Version"), "1.4.2")
+}
+
+func TestLogRequestExcludePaths(t *testing.T) {
+	app := newTestApplication(t)
+
+	var buf bytes.Buffer
+	app.logger = slog.New(slog.NewTextHandler(&buf, nil))
+	app.config.logExcludePaths = defaultLogExcludePaths
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+
+	tests := []struct {
+		name    string
+		urlPath string
+		wantLog bool
+	}{
+		{
+			name:    "Health check",
+			urlPath: "/healthz",
+			wantLog: false,
+		},
+		{
+			name:    "Metrics",
+			urlPath: "/metrics",
+			wantLog: false,
+		},
+		{
+			name:    "Favicon",
+			urlPath: "/favicon.ico",
+			wantLog: false,
+		},
+		{
+			name:    "Normal path",
+			urlPath: "/snippet/view/1",
+			wantLog: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.urlPath, nil)
+
+			app.logRequest(next).ServeHTTP(rr, r)
+
+			assert.Equal(t, rr.Code, http.StatusOK)
+			assert.Equal(t, strings.Contains(buf.String(), "received request"), tt.wantLog)
+		})
+	}
+}
+
+func TestCanonicalHost(t *testing.T) {
+	app := newTestApplication(t)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+
+	tests := []struct {
+		name          string
+		canonicalHost string
+		url           string
+		wantCode      int
+		wantLocation  string
+	}{
+		{
+			name:          "Non-canonical host",
+			canonicalHost: "snippetbox.example.com",
+			url:           "https://www.snippetbox.example.com/snippet/view/1?page=2",
+			wantCode:      http.StatusMovedPermanently,
+			wantLocation:  "https://snippetbox.example.com/snippet/view/1?page=2",
+		},
+		{
+			name:          "Non-canonical host over HTTP",
+			canonicalHost: "snippetbox.example.com",
+			url:           "http://www.snippetbox.example.com/",
+			wantCode:      http.StatusMovedPermanently,
+			wantLocation:  "http://snippetbox.example.com/",
+		},
+		{
+			name:          "Canonical host",
+			canonicalHost: "snippetbox.example.com",
+			url:           "https://snippetbox.example.com/snippet/view/1",
+			wantCode:      http.StatusOK,
+		},
+		{
+			name:          "Canonical host in another case",
+			canonicalHost: "snippetbox.example.com",
+			url:           "https://Snippetbox.Example.com/",
+			wantCode:      http.StatusOK,
+		},
+		{
+			name:          "Unset",
+			canonicalHost: "",
+			url:           "https://www.snippetbox.example.com/",
+			wantCode:      http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app.config.canonicalHost = tt.canonicalHost
+
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.url, nil)
+
+			app.canonicalHost(next).ServeHTTP(rr, r)
+
+			assert.Equal(t, rr.Code, tt.wantCode)
+			assert.Equal(t, rr.Header().Get("Location"), tt.wantLocation)
+		})
+	}
+}
+
+func TestCollapseSlashes(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+
+	tests := []struct {
+		name         string
+		method       string
+		url          string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Doubled slashes",
+			method:       http.MethodGet,
+			url:          "/snippet//view//1",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "/snippet/view/1",
+		},
+		{
+			name:         "Runs of slashes and a query",
+			method:       http.MethodGet,
+			url:          "///snippet///archive?from=2025-01-01&to=2025//02",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "/snippet/archive?from=2025-01-01&to=2025//02",
+		},
+		{
+			name:         "POST",
+			method:       http.MethodPost,
+			url:          "/snippet//create",
+			wantCode:     http.StatusPermanentRedirect,
+			wantLocation: "/snippet/create",
+		},
+		{
+			name:     "Clean path",
+			method:   http.MethodGet,
+			url:      "/snippet/view/1?page=2",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Escaped slashes",
+			method:   http.MethodGet,
+			url:      "/snippet/view/a%2F%2Fb",
+			wantCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest(tt.method, tt.url, nil)
+
+			collapseSlashes(next).ServeHTTP(rr, r)
+
+			assert.Equal(t, rr.Code, tt.wantCode)
+			assert.Equal(t, rr.Header().Get("Location"), tt.wantLocation)
+		})
+	}
+}
+
+func TestCollapseSlashesRouting(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, header, _ := ts.get(t, "/snippet//view//1")
+	assert.Equal(t, code, http.StatusMovedPermanently)
+	assert.Equal(t, header.Get("Location"), "/snippet/view/1")
+
+	code, _, body := ts.get(t, header.Get("Location"))
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "An old silent pond")
+}
+
+func TestCheckOrigin(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.trustedOrigins = []string{"https://admin.example.com"}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+
+	tests := []struct {
+		name     string
+		method   string
+		origin   string
+		referer  string
+		wantCode int
+	}{
+		{
+			name:     "Same origin",
+			method:   http.MethodPost,
+			origin:   "https://localhost:4000",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Base URL",
+			method:   http.MethodPost,
+			origin:   "https://snippetbox.example.com",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Trusted origin",
+			method:   http.MethodPost,
+			origin:   "https://admin.example.com",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Matching referer",
+			method:   http.MethodPost,
+			referer:  "https://snippetbox.example.com/snippet/create",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "No origin or referer",
+			method:   http.MethodPost,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Foreign origin",
+			method:   http.MethodPost,
+			origin:   "https://evil.example.com",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Base URL host over another scheme",
+			method:   http.MethodPost,
+			origin:   "http://snippetbox.example.com",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Foreign referer",
+			method:   http.MethodPost,
+			referer:  "https://evil.example.com/form",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Opaque origin",
+			method:   http.MethodPost,
+			origin:   "null",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Foreign origin on a safe method",
+			method:   http.MethodGet,
+			origin:   "https://evil.example.com",
+			wantCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest(tt.method, "https://localhost:4000/user/login", nil)
+
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if tt.referer != "" {
+				r.Header.Set("Referer", tt.referer)
+			}
+
+			app.checkOrigin(next).ServeHTTP(rr, r)
+
+			assert.Equal(t, rr.Code, tt.wantCode)
+		})
+	}
+}
+
+func TestLimitInFlight(t *testing.T) {
+	app := newTestApplication(t)
+	app.inFlight = make(chan struct{}, 2)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			started <- struct{}{}
+			<-release
+		}
+		w.Write([]byte("OK"))
+	})
+
+	ts := httptest.NewServer(app.limitInFlight(next))
+	defer ts.Close()
+
+	get := func(urlPath string) (int, http.Header) {
+		rs, err := ts.Client().Get(ts.URL + urlPath)
+		if err != nil {
+			t.Error(err)
+			return 0, nil
+		}
+		rs.Body.Close()
+
+		return rs.StatusCode, rs.Header
+	}
+
+	var wg sync.WaitGroup
+	codes := make([]int, 2)
+
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes[i], _ = get("/slow")
+		}()
+	}
+
+	// Both slots are taken once both slow requests have reached the handler.
+	<-started
+	<-started
+
+	for range 3 {
+		code, header := get("/fast")
+		assert.Equal(t, code, http.StatusServiceUnavailable)
+		assert.Equal(t, header.Get("Retry-After"), "1")
+	}
+
+	code, _ := get("/healthz")
+	assert.Equal(t, code, http.StatusOK)
+
+	close(release)
+	wg.Wait()
+
+	for _, code := range codes {
+		assert.Equal(t, code, http.StatusOK)
+	}
+}
+
+func middlewareNames(ms []middleware) []string {
+	names := make([]string, len(ms))
+	for i, m := range ms {
+		names[i] = m.name
+	}
+
+	return names
+}
+
+// TestMiddlewareOrder pins the order of the chains routes assembles. A
+// middleware added to or moved in a chain fails it until the order is
+// updated here, and the cases below are checked to still hold.
+func TestMiddlewareOrder(t *testing.T) {
+	app := newTestApplication(t)
+
+	assert.Equal(t, strings.Join(middlewareNames(app.standardMiddleware()), ","),
+		"recoverPanic,logRequest,limitInFlight,canonicalHost,collapseSlashes,commonHeaders,appVersion")
+	assert.Equal(t, strings.Join(middlewareNames(app.dynamicMiddleware()), ","),
+		"checkOrigin,session,noSurf,authenticate")
+
+	var buf bytes.Buffer
+	app.logger = slog.New(slog.NewTextHandler(&buf, nil))
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/panic" {
+			panic("boom")
+		}
+		w.Write([]byte("OK"))
+	})
+
+	serve := func(urlPath string) *httptest.ResponseRecorder {
+		buf.Reset()
+
+		rr := httptest.NewRecorder()
+		chain(app.standardMiddleware()).Then(next).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, urlPath, nil))
+
+		return rr
+	}
+
+	t.Run("Panic is recovered and logged as a 500", func(t *testing.T) {
+		rr := serve("/panic")
+
+		assert.Equal(t, rr.Code, http.StatusInternalServerError)
+		assert.Equal(t, rr.Header().Get("Connection"), "close")
+		// commonHeaders ran before the panic, inside recoverPanic.
+		assert.Equal(t, rr.Header().Get("X-Frame-Options"), "deny")
+		assert.StringContains(t, buf.String(), `msg="completed request"`)
+		assert.StringContains(t, buf.String(), "uri=/panic status=500")
+	})
+
+	t.Run("Redirects are logged", func(t *testing.T) {
+		rr := serve("/snippet//view")
+
+		assert.Equal(t, rr.Code, http.StatusMovedPermanently)
+		assert.StringContains(t, buf.String(), "status=301")
+	})
+
+	t.Run("Requests over the in-flight cap are logged", func(t *testing.T) {
+		app.inFlight = make(chan struct{}, 1)
+		app.inFlight <- struct{}{}
+		defer func() { app.inFlight = nil }()
+
+		rr := serve("/")
+
+		assert.Equal(t, rr.Code, http.StatusServiceUnavailable)
+		assert.StringContains(t, buf.String(), "status=503")
+	})
+
+	t.Run("Successful requests are logged", func(t *testing.T) {
+		rr := serve("/")
+
+		assert.Equal(t, rr.Code, http.StatusOK)
+		assert.Equal(t, rr.Header().Get("X-App-Version"), version)
+		assert.StringContains(t, buf.String(), "status=200")
+	})
+}
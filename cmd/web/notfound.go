@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// suggestion is a link offered on a 404 page.
+type suggestion struct {
+	Path string
+	Text string
+}
+
+// notFoundSuggestions returns links that may be what a request for the
+// unknown path was after. It only looks at the path itself, never the
+// database, so it can't fail while rendering an error.
+func notFoundSuggestions(urlPath string) []suggestion {
+	if !strings.HasPrefix(urlPath, "/snippet/") {
+		return nil
+	}
+
+	var suggestions []suggestion
+
+	// A mistyped /snippet/view/{id} still ends in the ID.
+	if _, ok := snippetIDs.decode(path.Base(urlPath)); ok {
+		suggestions = append(suggestions, suggestion{
+			Path: "/snippet/view/" + path.Base(urlPath),
+			Text: "Snippet #" + path.Base(urlPath),
+		})
+	}
+
+	suggestions = append(suggestions, suggestion{Path: "/archive", Text: "Browse the snippet archive"})
+
+	return suggestions
+}
+
+// notFoundWriter swallows the mux's own 404 response, so that
+// notFoundFallback can write its page instead. Any other response, such as
+// the mux's 405, goes through unchanged.
+type notFoundWriter struct {
+	http.ResponseWriter
+	notFound bool
+}
+
+func (w *notFoundWriter) WriteHeader(status int) {
+	if status == http.StatusNotFound {
+		w.notFound = true
+		return
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *notFoundWriter) Write(b []byte) (int, error) {
+	if w.notFound {
+		return len(b), nil
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// notFoundFallback replaces the mux's plain text response for requests no
+// route matches: API requests get a problem document, and everything else
+// the 404 page with suggestions. Handlers' own 404s are left alone.
+func (app *application) notFoundFallback(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h, pattern := mux.Handler(r)
+		if pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		nw := &notFoundWriter{ResponseWriter: w}
+		h.ServeHTTP(nw, r)
+
+		if !nw.notFound {
+			return
+		}
+
+		if isAPIRequest(r) {
+			app.problem(w, r, http.StatusNotFound, "the requested resource could not be found")
+			return
+		}
+
+		app.errorPage(w, r, http.StatusNotFound, nil, notFoundSuggestions(r.URL.Path)...)
+	})
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/assert"
+)
+
+func TestNotFoundFallback(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Unknown HTML route", func(t *testing.T) {
+		code, header, body := ts.get(t, "/snippet/veiw/1")
+
+		assert.Equal(t, code, http.StatusNotFound)
+		assert.Equal(t, header.Get("Content-Type"), "text/html; charset=utf-8")
+		assert.StringContains(t, body, "<title>Not Found - Snippetbox</title>")
+		assert.StringContains(t, body, "<a href='/snippet/view/1'>Snippet #1</a>")
+		assert.StringContains(t, body, "<a href='/archive'>Browse the snippet archive</a>")
+	})
+
+	t.Run("Unknown route outside /snippet", func(t *testing.T) {
+		code, _, body := ts.get(t, "/no/such/page")
+
+		assert.Equal(t, code, http.StatusNotFound)
+		assert.StringContains(t, body, "The page you were looking for doesn't exist")
+		assert.Equal(t, strings.Contains(body, "Perhaps you were looking for"), false)
+	})
+
+	t.Run("Unknown API route", func(t *testing.T) {
+		code, header, body := ts.get(t, "/api/v1/nothing")
+
+		assert.Equal(t, code, http.StatusNotFound)
+		assert.Equal(t, header.Get("Content-Type"), "application/problem+json")
+		assert.StringContains(t, body, `"status":404`)
+	})
+
+	t.Run("Wrong method", func(t *testing.T) {
+		code, header, _ := ts.postForm(t, "/snippet/view/1", url.Values{})
+
+		assert.Equal(t, code, http.StatusMethodNotAllowed)
+		assert.Equal(t, header.Get("Allow"), "GET, HEAD")
+	})
+}
@@ -0,0 +1,49 @@
+//go:build integration
+
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/assert"
+	"github.com/go-sql-driver/mysql"
+)
+
+// errMaxExecutionTime is the MySQL error for a statement aborted by
+// max_execution_time.
+const errMaxExecutionTime = 3024
+
+// TestOpenDBStatementTimeout needs the test database of the models
+// integration tests. Run it with go test -tags integration.
+func TestOpenDBStatementTimeout(t *testing.T) {
+	db, err := OpenDB("test_web:pass@/test_snippetbox?parseTime=true", 100*time.Millisecond, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var timeout int
+	err = db.QueryRow("SELECT @@SESSION.max_execution_time").Scan(&timeout)
+	assert.NilError(t, err)
+	assert.Equal(t, timeout, 100)
+
+	// SLEEP alone returns 1 when interrupted rather than failing, so the
+	// slow query is a BENCHMARK, which fails.
+	start := time.Now()
+
+	var result int
+	err = db.QueryRow("SELECT BENCHMARK(10000000000, SHA2('snippetbox', 256))").Scan(&result)
+
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		t.Fatalf("got %v; want MySQL error %d", err, errMaxExecutionTime)
+	}
+	assert.Equal(t, mysqlErr.Number, uint16(errMaxExecutionTime))
+	assert.Equal(t, time.Since(start) < 10*time.Second, true)
+
+	// The connection is still usable after the abort.
+	err = db.QueryRow("SELECT 1").Scan(&result)
+	assert.NilError(t, err)
+}
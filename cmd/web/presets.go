@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+
+	"github.com/Vadim-Makhnev/snippetbox/ui"
+)
+
+// preset is a starting point for a new snippet, chosen on the create form
+// with ?preset=name.
+type preset struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+// presets maps preset names to presets.
+type presets map[string]preset
+
+// newPresets reads the presets from the embedded ui/presets.json.
+func newPresets() (presets, error) {
+	data, err := ui.Files.ReadFile("presets.json")
+	if err != nil {
+		return nil, err
+	}
+
+	var p presets
+
+	err = json.Unmarshal(data, &p)
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// names returns the preset names in alphabetical order.
+func (p presets) names() []string {
+	return slices.Sorted(maps.Keys(p))
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/models"
+	"github.com/Vadim-Makhnev/snippetbox/internal/validator"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+const (
+	// defaultQRSize is the width and height of a QR code in pixels when no
+	// size is asked for; minQRSize and maxQRSize bound the sizes allowed.
+	defaultQRSize = 256
+	minQRSize     = 64
+	maxQRSize     = 1024
+	// maxQRCacheAge is the longest a QR code may be cached, unless the
+	// snippet expires sooner.
+	maxQRCacheAge = 24 * time.Hour
+)
+
+// snippetQR godoc
+// @Summary      Get a QR code of a snippet's link
+// @Description  Render a PNG QR code encoding the snippet's absolute URL under -base-url, for opening it on a phone. Like the snippet itself, expired snippets and other users' private snippets are not found. The code may be cached for a day, or until the snippet expires if that is sooner; only by the browser for private snippets and responses that set a cookie.
+// @Tags         snippets
+// @Produce      png
+// @Param        id path string true "Snippet ID, or its opaque code when -obfuscate-ids is set"
+// @Param        size query int false "Width and height of the image in pixels, from 64 to 1024" default(256)
+// @Success      200 {file} file "PNG image"
+// @Failure      400 {string} string "Bad request - invalid size"
+// @Failure      404 {string} string "Snippet not found"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /snippet/view/{id}/qr.png [get]
+func (app *application) snippetQR(w http.ResponseWriter, r *http.Request) {
+	id, ok := snippetIDs.decode(r.PathValue("id"))
+	if !ok {
+		app.notFound(w, r)
+		return
+	}
+
+	var v validator.Validator
+	size := app.readInt(r.URL.Query(), "size", defaultQRSize, &v)
+	if !v.Valid() || size < minQRSize || size > maxQRSize {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if snippet.Private && snippet.UserID != app.authenticatedUserID(r) {
+		app.notFound(w, r)
+		return
+	}
+
+	link := app.config.baseURL + "/snippet/view/" + snippetIDs.encode(snippet.ID)
+
+	png, err := qrcode.Encode(link, qrcode.Medium, size)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Shared caches may only keep the code of a public snippet, and not a
+	// response that sets a cookie, such as the CSRF cookie on a first visit.
+	visibility := "public"
+	if snippet.Private || len(w.Header().Values("Set-Cookie")) > 0 {
+		visibility = "private"
+	}
+
+	maxAge := max(min(time.Until(snippet.Expires), maxQRCacheAge), 0)
+
+	w.Header().Set("Cache-Control", fmt.Sprintf("%s, max-age=%d", visibility, int(maxAge.Seconds())))
+	w.Header().Set("Content-Type", "image/png")
+
+	w.Write(png)
+}
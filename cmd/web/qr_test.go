@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/assert"
+)
+
+func TestSnippetQR(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// The first visit sets the CSRF cookie, which no shared cache may keep.
+	code, header, _ := ts.get(t, "/snippet/view/1/qr.png")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(header.Values("Set-Cookie")) > 0, true)
+	assert.Equal(t, strings.HasPrefix(header.Get("Cache-Control"), "private, max-age="), true)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantSize int
+	}{
+		{"Default size", "/snippet/view/1/qr.png", http.StatusOK, 256},
+		{"Custom size", "/snippet/view/1/qr.png?size=128", http.StatusOK, 128},
+		{"Too small", "/snippet/view/1/qr.png?size=10", http.StatusBadRequest, 0},
+		{"Too large", "/snippet/view/1/qr.png?size=4096", http.StatusBadRequest, 0},
+		{"Not a number", "/snippet/view/1/qr.png?size=big", http.StatusBadRequest, 0},
+		{"Missing snippet", "/snippet/view/2/qr.png", http.StatusNotFound, 0},
+		{"Private snippet", "/snippet/view/5/qr.png", http.StatusNotFound, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.get(t, tt.urlPath)
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			assert.Equal(t, header.Get("Content-Type"), "image/png")
+			assert.Equal(t, strings.HasPrefix(header.Get("Cache-Control"), "public, max-age="), true)
+
+			img, err := png.Decode(bytes.NewReader([]byte(body)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, img.Bounds().Dx(), tt.wantSize)
+			assert.Equal(t, img.Bounds().Dy(), tt.wantSize)
+		})
+	}
+
+	ts.login(t)
+
+	code, header, body := ts.get(t, "/snippet/view/5/qr.png")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, strings.HasPrefix(header.Get("Cache-Control"), "private, max-age="), true)
+
+	_, err := png.Decode(bytes.NewReader([]byte(body)))
+	assert.NilError(t, err)
+}
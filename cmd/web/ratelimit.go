@@ -0,0 +1,98 @@
+package main
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// rateLimiterIdleTTL is how long a client's bucket is kept after its last
+// request.
+const rateLimiterIdleTTL = 3 * time.Minute
+
+// rateLimiter hands out a token bucket per key, such as a user ID or an IP
+// address. Buckets for clients that have gone quiet are swept periodically.
+type rateLimiter struct {
+	mu        sync.Mutex
+	rps       rate.Limit
+	burst     int
+	clients   map[string]*rateLimiterClient
+	lastSweep time.Time
+}
+
+type rateLimiterClient struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newRateLimiter(rps float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rps:       rate.Limit(rps),
+		burst:     burst,
+		clients:   make(map[string]*rateLimiterClient),
+		lastSweep: time.Now(),
+	}
+}
+
+// allow reports whether a request for key may proceed, along with the number
+// of requests the key has left right now and how long until its allowance is
+// back to a full burst.
+func (l *rateLimiter) allow(key string) (bool, int, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+
+	if now.Sub(l.lastSweep) > time.Minute {
+		for k, c := range l.clients {
+			if now.Sub(c.lastSeen) > rateLimiterIdleTTL {
+				delete(l.clients, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	c, ok := l.clients[key]
+	if !ok {
+		c = &rateLimiterClient{limiter: rate.NewLimiter(l.rps, l.burst)}
+		l.clients[key] = c
+	}
+
+	c.lastSeen = now
+
+	allowed := c.limiter.AllowN(now, 1)
+	tokens := c.limiter.TokensAt(now)
+	remaining := max(int(math.Floor(tokens)), 0)
+
+	var reset time.Duration
+	if l.rps > 0 {
+		reset = time.Duration((float64(l.burst) - tokens) / float64(l.rps) * float64(time.Second))
+	}
+
+	return allowed, remaining, reset
+}
+
+// newGlobalLimiter returns the limiter shared by every API client, allowing
+// bursts of one second's worth of requests, or nil if rps is not positive.
+func newGlobalLimiter(rps float64) *rate.Limiter {
+	if rps <= 0 {
+		return nil
+	}
+
+	return rate.NewLimiter(rate.Limit(rps), max(int(math.Ceil(rps)), 1))
+}
+
+// retryAfter reserves a token from l if one is free now. Otherwise it returns
+// how long until one will be, without keeping the reservation.
+func retryAfter(l *rate.Limiter) time.Duration {
+	r := l.Reserve()
+
+	delay := r.Delay()
+	if delay > 0 {
+		r.Cancel()
+	}
+
+	return delay
+}
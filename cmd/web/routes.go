@@ -4,23 +4,89 @@ import (
 	"net/http"
 
 	_ "github.com/Vadim-Makhnev/snippetbox/cmd/web/docs"
-	"github.com/Vadim-Makhnev/snippetbox/ui"
 	"github.com/justinas/alice"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
+// middleware is one named link of a chain, so tests can check the order the
+// chains are assembled in.
+type middleware struct {
+	name string
+	fn   alice.Constructor
+}
+
+// chain returns the alice chain of ms, the first outermost.
+func chain(ms []middleware) alice.Chain {
+	fns := make([]alice.Constructor, len(ms))
+	for i, m := range ms {
+		fns[i] = m.fn
+	}
+
+	return alice.New(fns...)
+}
+
+// standardMiddleware wraps every request. recoverPanic is outermost so that
+// a panic anywhere becomes a 500, and logRequest next so that everything
+// else, including the 503s of limitInFlight and the redirects of
+// canonicalHost and collapseSlashes, is logged.
+func (app *application) standardMiddleware() []middleware {
+	return []middleware{
+		{"recoverPanic", app.recoverPanic},
+		{"logRequest", app.logRequest},
+		{"limitInFlight", app.limitInFlight},
+		{"canonicalHost", app.canonicalHost},
+		{"collapseSlashes", collapseSlashes},
+		{"commonHeaders", commonHeaders},
+		{"appVersion", appVersion},
+	}
+}
+
+// dynamicMiddleware wraps the pages using sessions. The session has to be
+// loaded before noSurf and authenticate, which read from it, and origins are
+// checked before anything is loaded.
+func (app *application) dynamicMiddleware() []middleware {
+	return []middleware{
+		{"checkOrigin", app.checkOrigin},
+		{"session", app.sessionManager.LoadAndSave},
+		{"noSurf", noSurf},
+		{"authenticate", app.authenticate},
+	}
+}
+
 func (app *application) routes() http.Handler {
 	mux := http.NewServeMux()
-	mux.Handle("GET /static/", http.StripPrefix("/static", http.FileServerFS(ui.Files)))
+	mux.Handle("GET /static/", http.StripPrefix("/static", assets.fileServer()))
 
 	mux.Handle("GET /swagger/", httpSwagger.WrapHandler)
 
 	mux.HandleFunc("GET /ping", ping)
+	mux.HandleFunc("GET /healthz", healthz)
+	mux.HandleFunc("GET /readyz", app.readyz)
+	mux.HandleFunc("GET /favicon.ico", favicon)
+	mux.HandleFunc("GET /site.webmanifest", app.webManifest)
+	mux.HandleFunc("GET /feed.xml", app.feed)
+
+	api := alice.New(app.authenticateToken, app.rateLimitAPI)
 
-	dynamic := alice.New(app.sessionManager.LoadAndSave, noSurf, app.authenticate)
+	mux.Handle("GET /api/v1/snippets", api.ThenFunc(app.apiSnippetList))
+	mux.Handle("GET /api/v1/snippets/{id}", api.ThenFunc(app.apiSnippetGet))
+	mux.Handle("POST /api/v1/snippets", api.Append(app.requireAPIAuthentication).ThenFunc(app.apiSnippetCreate))
+	mux.Handle("POST /api/v1/users", api.ThenFunc(app.apiUserSignup))
+	mux.Handle("POST /api/v1/tokens/authentication", api.ThenFunc(app.apiCreateAuthenticationToken))
+	mux.Handle("GET /api/v1/meta/expiry-options", api.ThenFunc(app.apiExpiryOptions))
+	mux.HandleFunc("POST /api/v1/inbound", app.inboundEmail)
+	mux.Handle("POST /graphql", api.Then(app.graphql()))
+
+	dynamic := chain(app.dynamicMiddleware())
 
 	mux.Handle("GET /{$}", dynamic.ThenFunc(app.home))
 	mux.Handle("GET /snippet/view/{id}", dynamic.ThenFunc(app.snippetView))
+	mux.Handle("GET /snippet/view/{id}/qr.png", dynamic.ThenFunc(app.snippetQR))
+	mux.Handle("GET /snippet/raw/{id}", dynamic.ThenFunc(app.snippetRaw))
+	mux.Handle("GET /snippet/archive", dynamic.ThenFunc(app.snippetArchive))
+	mux.Handle("GET /archive", dynamic.ThenFunc(app.archiveIndex))
+	mux.Handle("GET /u/{id}", dynamic.ThenFunc(app.userProfile))
+	mux.Handle("GET /account/email/confirm", dynamic.ThenFunc(app.accountEmailConfirm))
 	mux.Handle("GET /user/signup", dynamic.ThenFunc(app.userSignup))
 	mux.Handle("POST /user/signup", dynamic.ThenFunc(app.userSignupPost))
 	mux.Handle("GET /user/login", dynamic.ThenFunc(app.userLogin))
@@ -29,9 +95,38 @@ func (app *application) routes() http.Handler {
 	protected := dynamic.Append(app.requireAuthentication)
 
 	mux.Handle("GET /snippet/create", protected.ThenFunc(app.snippetCreate))
-	mux.Handle("POST /snippet/create", protected.ThenFunc(app.snippetCreatePost))
+	mux.Handle("POST /snippet/create", protected.Then(app.txHandler(app.snippetCreatePost)))
+	mux.Handle("POST /snippet/preview", protected.ThenFunc(app.snippetPreview))
+	mux.Handle("POST /snippet/import-url", protected.ThenFunc(app.snippetImportURL))
+	// The upload is bounded before the CSRF check parses the form. The slack
+	// leaves room for the multipart encoding and the other fields, so that a
+	// file just over the limit still gets a form error.
+	mux.Handle("POST /snippet/upload", maxBytes(app.config.importMaxBytes+64<<10, protected.ThenFunc(app.snippetUpload)))
+	mux.Handle("POST /snippet/{id}/comments", protected.ThenFunc(app.snippetCommentCreate))
+	mux.Handle("POST /snippet/{id}/comments/{cid}/delete", protected.ThenFunc(app.snippetCommentDelete))
+	mux.Handle("POST /snippet/{id}/pin", protected.ThenFunc(app.snippetPin))
+	mux.Handle("POST /snippet/{id}/unpin", protected.ThenFunc(app.snippetUnpin))
+	mux.Handle("GET /snippet/stats/{id}", protected.ThenFunc(app.snippetStats))
 	mux.Handle("POST /user/logout", protected.ThenFunc(app.userLogoutPost))
+	mux.Handle("GET /account/password/update", protected.ThenFunc(app.accountPasswordUpdate))
+	mux.Handle("POST /account/password/update", protected.ThenFunc(app.accountPasswordUpdatePost))
+	mux.Handle("GET /account/email/update", protected.ThenFunc(app.accountEmailUpdate))
+	mux.Handle("POST /account/email/update", protected.ThenFunc(app.accountEmailUpdatePost))
+
+	admin := dynamic.Append(app.requireAdmin)
+
+	mux.Handle("GET /admin", admin.ThenFunc(app.adminDashboard))
+	mux.Handle("GET /admin/stats.json", admin.ThenFunc(app.adminStats))
+	mux.Handle("GET /admin/debug/vars", admin.ThenFunc(app.adminDebugVars))
+	mux.Handle("GET /admin/users", admin.ThenFunc(app.adminUsers))
+	mux.Handle("POST /admin/snippets/bulk", admin.ThenFunc(app.adminSnippetsBulk))
+	mux.Handle("POST /admin/snippets/{id}/featured", admin.ThenFunc(app.adminSnippetFeatured))
+	mux.Handle("POST /admin/invites", admin.ThenFunc(app.adminInviteCreate))
+
+	drafts := dynamic.Append(app.rateLimitAPI, app.requireAPIAuthentication)
+
+	mux.Handle("GET /api/v1/drafts", drafts.ThenFunc(app.apiDraftGet))
+	mux.Handle("POST /api/v1/drafts", drafts.ThenFunc(app.apiDraftSave))
 
-	standard := alice.New(app.recoverPanic, app.logRequest, commonHeaders)
-	return standard.Then(mux)
+	return chain(app.standardMiddleware()).Then(app.notFoundFallback(mux))
 }
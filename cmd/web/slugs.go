@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// transliterations spells the accented and ligature Latin letters most
+// common in titles and names with plain ASCII. Anything else outside ASCII
+// is dropped by slugify.
+var transliterations = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a",
+	'æ': "ae",
+	'ç': "c", 'ć': "c", 'č': "c",
+	'ď': "d", 'đ': "d", 'ð': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ė': "e", 'ę': "e", 'ě': "e",
+	'ğ': "g",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i", 'į': "i", 'ı': "i",
+	'ł': "l", 'ľ': "l",
+	'ñ': "n", 'ń': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ő': "o",
+	'œ': "oe",
+	'ř': "r",
+	'ś': "s", 'š': "s", 'ş': "s",
+	'ß': "ss",
+	'ť': "t", 'ţ': "t",
+	'þ': "th",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ů': "u", 'ű': "u", 'ų': "u",
+	'ý': "y", 'ÿ': "y",
+	'ź': "z", 'ż': "z", 'ž': "z",
+}
+
+// slugify turns s into a lowercase, URL-safe slug of ASCII letters and
+// digits separated by single hyphens, for snippet titles and user names.
+// Slugs longer than maxLen are cut at the last hyphen that fits, or mid-word
+// if there is none. If nothing of s survives, fallback is returned instead,
+// so callers can always use the result; it should be derived from an ID.
+func slugify(s, fallback string, maxLen int) string {
+	var b strings.Builder
+
+	// sep records a pending separator, written only before the next letter
+	// or digit so that runs collapse and no hyphen leads or trails.
+	sep := false
+
+	for _, r := range strings.ToLower(s) {
+		var part string
+
+		switch {
+		case r <= unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			part = string(r)
+		case transliterations[r] != "":
+			part = transliterations[r]
+		default:
+			sep = true
+			continue
+		}
+
+		if sep && b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		sep = false
+
+		b.WriteString(part)
+	}
+
+	slug := b.String()
+
+	if len(slug) > maxLen {
+		cut := slug[:maxLen]
+
+		if slug[maxLen] != '-' {
+			if i := strings.LastIndexByte(cut, '-'); i > 0 {
+				cut = cut[:i]
+			}
+		}
+
+		slug = cut
+	}
+
+	if slug == "" {
+		return fallback
+	}
+
+	return slug
+}
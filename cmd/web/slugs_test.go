@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/assert"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		maxLen int
+		want   string
+	}{
+		{
+			name:   "ASCII",
+			input:  "An old silent pond",
+			maxLen: 60,
+			want:   "an-old-silent-pond",
+		},
+		{
+			name:   "Separators collapse",
+			input:  "  Hello,   World!! -- again_and again ",
+			maxLen: 60,
+			want:   "hello-world-again-and-again",
+		},
+		{
+			name:   "Unicode",
+			input:  "Crème Brûlée für Straße Łódź",
+			maxLen: 60,
+			want:   "creme-brulee-fur-strasse-lodz",
+		},
+		{
+			name:   "Untransliterable letters stripped",
+			input:  "Go 日本語 tips",
+			maxLen: 60,
+			want:   "go-tips",
+		},
+		{
+			name:   "All symbols",
+			input:  "!@#$%^&*() 🎉",
+			maxLen: 60,
+			want:   "snippet-42",
+		},
+		{
+			name:   "All non-Latin",
+			input:  "こんにちは",
+			maxLen: 60,
+			want:   "snippet-42",
+		},
+		{
+			name:   "Truncated on a word boundary",
+			input:  "The quick brown fox jumps",
+			maxLen: 18,
+			want:   "the-quick-brown",
+		},
+		{
+			name:   "Cut exactly before a separator",
+			input:  "The quick brown fox",
+			maxLen: 15,
+			want:   "the-quick-brown",
+		},
+		{
+			name:   "Single long word",
+			input:  "Supercalifragilistic",
+			maxLen: 10,
+			want:   "supercalif",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := slugify(tt.input, "snippet-42", tt.maxLen)
+			assert.Equal(t, got, tt.want)
+			assert.Equal(t, len(got) <= tt.maxLen, true)
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/validator"
+)
+
+var tagRX = regexp.MustCompile(`^[\p{L}\p{N}+#._-]{1,32}$`)
+
+// splitTags splits a comma-separated list of tags, such as "go, sql".
+func splitTags(s string) []string {
+	return strings.Split(s, ",")
+}
+
+// normalizeTags trims and lowercases tags, dropping blanks and duplicates,
+// so "go, Go, GO" is a single tag.
+func normalizeTags(tags []string) []string {
+	var normalized []string
+
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+
+	return normalized
+}
+
+// checkTags validates normalized tags, the number of which is capped by
+// -max-tags-per-snippet.
+func (app *application) checkTags(v *validator.Validator, tags []string) {
+	v.CheckField(len(tags) <= app.config.maxTags, "tags", fmt.Sprintf("This field cannot have more than %d different tags", app.config.maxTags))
+
+	for _, tag := range tags {
+		v.CheckField(tagRX.MatchString(tag), "tags", "Tags must be up to 32 letters, digits or +#._-")
+	}
+}
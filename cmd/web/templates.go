@@ -1,8 +1,14 @@
 package main
 
 import (
+	"fmt"
+	"io"
 	"io/fs"
+	"log/slog"
+	"maps"
 	"path/filepath"
+	"slices"
+	"strings"
 	"text/template"
 	"time"
 
@@ -11,28 +17,169 @@ import (
 )
 
 type templateData struct {
-	CurrentYear     int
-	Snippet         models.Snippet
-	Snippets        []models.Snippet
-	Form            any
-	Flash           string
-	IsAuthenticated bool
-	CSRFToken       string
+	CurrentYear         int
+	Version             string
+	BaseURL             string
+	Snippet             models.Snippet
+	Truncated           bool
+	ViewStats           models.ViewStats
+	Snippets            []models.Snippet
+	Pinned              []models.Snippet
+	SnippetPinned       bool
+	Profile             models.User
+	SnippetOfDay        *models.Snippet
+	Featured            []models.Snippet
+	Personalized        bool
+	ExpiryOptions       []expiryOption
+	Languages           []string
+	Presets             []string
+	SignupEnabled       bool
+	RequireInvite       bool
+	RequireTerms        bool
+	Authors             map[int]models.User
+	Users               []models.User
+	Comments            []models.Comment
+	CanModerate         bool
+	MonthlyCounts       []models.MonthCount
+	UserCounts          models.UserCounts
+	SnippetCounts       models.SnippetCounts
+	Page                int
+	LastPage            int
+	Form                any
+	Flash               *flashMessage
+	IsAuthenticated     bool
+	AuthenticatedUserID int
+	CSRFToken           string
+	Location            *time.Location
+	Status              int
+	StatusText          string
+	ErrorDetail         string
+	Suggestions         []suggestion
 }
 
-func humanDate(t time.Time) string {
+// inLocation converts t to the optional location passed to the date helpers.
+// Templates pass the reader's time zone; without one, or with a nil one,
+// times render in UTC.
+func inLocation(t time.Time, loc []*time.Location) time.Time {
+	if len(loc) > 0 && loc[0] != nil {
+		return t.In(loc[0])
+	}
+
+	return t.UTC()
+}
+
+func humanDate(t time.Time, loc ...*time.Location) string {
 	if t.IsZero() {
 		return ""
 	}
 
-	return t.UTC().Format("02 Jan 2006 at 15:04")
+	return inLocation(t, loc).Format("02 Jan 2006 at 15:04")
+}
+
+// defaultDateLayout is the default of -date-layout.
+const defaultDateLayout = "2006-01-02 15:04"
+
+func formatDate(t time.Time, layout string, loc ...*time.Location) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return inLocation(t, loc).Format(layout)
+}
+
+// numberedLine is one line of snippet content with its 1-based number.
+type numberedLine struct {
+	Num  int
+	Text string
+}
+
+// withLineNumbers splits content into numbered lines for the view page. A
+// trailing newline ends the last line rather than starting an empty one, and
+// empty content has no lines.
+func withLineNumbers(s string) []numberedLine {
+	s = strings.TrimSuffix(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	if s == "" {
+		return nil
+	}
+
+	var lines []numberedLine
+
+	for i, text := range strings.Split(s, "\n") {
+		lines = append(lines, numberedLine{Num: i + 1, Text: text})
+	}
+
+	return lines
+}
+
+// pageGap is the sentinel pageWindow uses for a run of omitted pages, which
+// the pager renders as an ellipsis.
+const pageGap = 0
+
+// pageWindow returns the page numbers a pager should link to: the first and
+// last pages plus span pages either side of current, with pageGap marking
+// omitted runs. A gap hiding a single page is replaced by that page.
+func pageWindow(current, last, span int) []int {
+	if last < 1 {
+		return nil
+	}
+
+	current = max(1, min(current, last))
+
+	start := max(1, current-span)
+	end := min(last, current+span)
+
+	var pages []int
+
+	if start > 1 {
+		pages = append(pages, 1)
+
+		switch {
+		case start == 3:
+			pages = append(pages, 2)
+		case start > 3:
+			pages = append(pages, pageGap)
+		}
+	}
+
+	for p := start; p <= end; p++ {
+		pages = append(pages, p)
+	}
+
+	if end < last {
+		switch {
+		case end == last-2:
+			pages = append(pages, last-1)
+		case end < last-2:
+			pages = append(pages, pageGap)
+		}
+
+		pages = append(pages, last)
+	}
+
+	return pages
 }
 
-var functions = template.FuncMap{
-	"humanDate": humanDate,
+// templateFunctions returns the functions available to templates. An empty
+// formatDate layout stands for dateLayout, set by -date-layout.
+func templateFunctions(dateLayout string) template.FuncMap {
+	return template.FuncMap{
+		"humanDate": humanDate,
+		"formatDate": func(t time.Time, layout string, loc ...*time.Location) string {
+			if layout == "" {
+				layout = dateLayout
+			}
+
+			return formatDate(t, layout, loc...)
+		},
+		"pageWindow":      pageWindow,
+		"asset":           assetURL,
+		"snippetID":       snippetID,
+		"markdown":        renderMarkdown,
+		"withLineNumbers": withLineNumbers,
+	}
 }
 
-func newTemplateCache() (map[string]*template.Template, error) {
+func newTemplateCache(dateLayout string) (map[string]*template.Template, error) {
 
 	cache := map[string]*template.Template{}
 
@@ -41,6 +188,8 @@ func newTemplateCache() (map[string]*template.Template, error) {
 		return nil, err
 	}
 
+	functions := templateFunctions(dateLayout)
+
 	for _, page := range pages {
 
 		name := filepath.Base(page)
@@ -62,3 +211,43 @@ func newTemplateCache() (map[string]*template.Template, error) {
 	return cache, nil
 
 }
+
+// selfTestForms are the empty forms pages with a form are always rendered
+// with, for selfTestTemplates.
+var selfTestForms = map[string]any{
+	"archive.tmpl":  snippetArchiveForm{},
+	"create.tmpl":   snippetCreateForm{},
+	"email.tmpl":    accountEmailUpdateForm{},
+	"login.tmpl":    userLoginForm{},
+	"password.tmpl": accountPasswordUpdateForm{},
+	"signup.tmpl":   userSignupForm{},
+	"users.tmpl":    adminUsersForm{},
+	"view.tmpl":     commentCreateForm{},
+}
+
+// selfTestTemplates executes every cached page against empty template data,
+// so a template that fails to render is found at startup rather than by the
+// first user to request it. Each failure is logged; with strict set, they
+// are also returned as an error, to stop the server from starting.
+func selfTestTemplates(cache map[string]*template.Template, strict bool, logger *slog.Logger) error {
+	var failed []string
+
+	for _, name := range slices.Sorted(maps.Keys(cache)) {
+		err := cache[name].ExecuteTemplate(io.Discard, "base", templateData{Form: selfTestForms[name]})
+		if err != nil {
+			if strict {
+				logger.Error("template self-test failed", "template", name, "error", err.Error())
+			} else {
+				logger.Warn("template self-test failed", "template", name, "error", err.Error())
+			}
+
+			failed = append(failed, name)
+		}
+	}
+
+	if strict && len(failed) > 0 {
+		return fmt.Errorf("templates failed self-test: %s", strings.Join(failed, ", "))
+	}
+
+	return nil
+}
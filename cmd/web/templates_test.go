@@ -1,7 +1,11 @@
 package main
 
 import (
+	"bytes"
+	"fmt"
+	"log/slog"
 	"testing"
+	"text/template"
 	"time"
 
 	"github.com/Vadim-Makhnev/snippetbox/internal/assert"
@@ -38,3 +42,272 @@ func TestHumanDate(t *testing.T) {
 		})
 	}
 }
+
+func TestHumanDateInLocation(t *testing.T) {
+	tm := time.Date(2024, 3, 17, 10, 15, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		zone string
+		want string
+	}{
+		{
+			name: "New York",
+			zone: "America/New_York",
+			want: "17 Mar 2024 at 06:15",
+		},
+		{
+			name: "Tokyo",
+			zone: "Asia/Tokyo",
+			want: "17 Mar 2024 at 19:15",
+		},
+		{
+			name: "Kolkata",
+			zone: "Asia/Kolkata",
+			want: "17 Mar 2024 at 15:45",
+		},
+		{
+			name: "Bogus zone",
+			zone: "Mars/Olympus_Mons",
+			want: "17 Mar 2024 at 10:15",
+		},
+		{
+			name: "Local",
+			zone: "Local",
+			want: "17 Mar 2024 at 10:15",
+		},
+		{
+			name: "Empty",
+			zone: "",
+			want: "17 Mar 2024 at 10:15",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, humanDate(tm, loadLocation(tt.zone)), tt.want)
+		})
+	}
+}
+
+func TestFormatDate(t *testing.T) {
+	tm := time.Date(2024, 3, 17, 10, 15, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		tm     time.Time
+		layout string
+		want   string
+	}{
+		{
+			name:   "ISO date",
+			tm:     tm,
+			layout: "2006-01-02",
+			want:   "2024-03-17",
+		},
+		{
+			name:   "RFC3339",
+			tm:     tm,
+			layout: time.RFC3339,
+			want:   "2024-03-17T10:15:00Z",
+		},
+		{
+			name:   "Long form",
+			tm:     tm,
+			layout: "Monday, January 2, 2006",
+			want:   "Sunday, March 17, 2024",
+		},
+		{
+			name:   "Converted to UTC",
+			tm:     time.Date(2024, 3, 17, 10, 15, 0, 0, time.FixedZone("CET", 1*60*60)),
+			layout: "15:04",
+			want:   "09:15",
+		},
+		{
+			name:   "Zero time",
+			tm:     time.Time{},
+			layout: time.RFC3339,
+			want:   "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, formatDate(tt.tm, tt.layout), tt.want)
+		})
+	}
+}
+
+func TestFormatDateDefaultLayout(t *testing.T) {
+	tm := time.Date(2024, 3, 17, 10, 15, 0, 0, time.UTC)
+
+	format := templateFunctions("02/01/2006")["formatDate"].(func(time.Time, string, ...*time.Location) string)
+
+	assert.Equal(t, format(tm, ""), "17/03/2024")
+	assert.Equal(t, format(tm, "15:04"), "10:15")
+}
+
+func TestFormatDateInLocation(t *testing.T) {
+	tm := time.Date(2024, 12, 31, 22, 30, 0, 0, time.UTC)
+
+	assert.Equal(t, formatDate(tm, "2006-01-02 15:04", loadLocation("Europe/Berlin")), "2024-12-31 23:30")
+	assert.Equal(t, formatDate(tm, "2006-01-02 15:04", loadLocation("Australia/Sydney")), "2025-01-01 09:30")
+	assert.Equal(t, formatDate(tm, "2006-01-02 15:04", loadLocation("Not/A_Zone")), "2024-12-31 22:30")
+	assert.Equal(t, formatDate(tm, "2006-01-02 15:04", nil), "2024-12-31 22:30")
+}
+
+func TestPageWindow(t *testing.T) {
+	tests := []struct {
+		name    string
+		current int
+		last    int
+		span    int
+		want    []int
+	}{
+		{
+			name:    "Start",
+			current: 1,
+			last:    20,
+			span:    2,
+			want:    []int{1, 2, 3, pageGap, 20},
+		},
+		{
+			name:    "Middle",
+			current: 6,
+			last:    20,
+			span:    2,
+			want:    []int{1, pageGap, 4, 5, 6, 7, 8, pageGap, 20},
+		},
+		{
+			name:    "End",
+			current: 20,
+			last:    20,
+			span:    2,
+			want:    []int{1, pageGap, 18, 19, 20},
+		},
+		{
+			name:    "Single hidden page is shown",
+			current: 5,
+			last:    20,
+			span:    2,
+			want:    []int{1, 2, 3, 4, 5, 6, 7, pageGap, 20},
+		},
+		{
+			name:    "Fewer pages than window",
+			current: 2,
+			last:    3,
+			span:    2,
+			want:    []int{1, 2, 3},
+		},
+		{
+			name:    "Current out of range",
+			current: 99,
+			last:    4,
+			span:    1,
+			want:    []int{1, 2, 3, 4},
+		},
+		{
+			name:    "No pages",
+			current: 1,
+			last:    0,
+			span:    2,
+			want:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, fmt.Sprint(pageWindow(tt.current, tt.last, tt.span)), fmt.Sprint(tt.want))
+		})
+	}
+}
+
+func TestWithLineNumbers(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []numberedLine
+	}{
+		{
+			name:    "Single line",
+			content: "An old silent pond...",
+			want:    []numberedLine{{1, "An old silent pond..."}},
+		},
+		{
+			name:    "Multiple lines",
+			content: "An old silent pond...\nA frog jumps into the pond,\nsplash! Silence again.",
+			want: []numberedLine{
+				{1, "An old silent pond..."},
+				{2, "A frog jumps into the pond,"},
+				{3, "splash! Silence again."},
+			},
+		},
+		{
+			name:    "Trailing newline",
+			content: "O snail\nClimb Mount Fuji,\n",
+			want:    []numberedLine{{1, "O snail"}, {2, "Climb Mount Fuji,"}},
+		},
+		{
+			name:    "Windows line endings",
+			content: "O snail\r\nClimb Mount Fuji,\r\n",
+			want:    []numberedLine{{1, "O snail"}, {2, "Climb Mount Fuji,"}},
+		},
+		{
+			name:    "Blank lines kept",
+			content: "O snail\n\nClimb",
+			want:    []numberedLine{{1, "O snail"}, {2, ""}, {3, "Climb"}},
+		},
+		{
+			name:    "Empty",
+			content: "",
+			want:    nil,
+		},
+		{
+			name:    "Only a newline",
+			content: "\n",
+			want:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, fmt.Sprint(withLineNumbers(tt.content)), fmt.Sprint(tt.want))
+		})
+	}
+}
+
+func TestSelfTestTemplates(t *testing.T) {
+	cache, err := newTemplateCache(defaultDateLayout)
+	assert.NilError(t, err)
+
+	var logs bytes.Buffer
+
+	// Every shipped page renders with empty data.
+	err = selfTestTemplates(cache, true, slog.New(slog.NewTextHandler(&logs, nil)))
+	assert.NilError(t, err)
+	assert.Equal(t, logs.String(), "")
+
+	broken, err := template.New("broken.tmpl").Parse(`{{define "base"}}{{.NoSuchField}}{{end}}`)
+	assert.NilError(t, err)
+
+	cache["broken.tmpl"] = broken
+
+	t.Run("Strict", func(t *testing.T) {
+		var logs bytes.Buffer
+
+		err := selfTestTemplates(cache, true, slog.New(slog.NewTextHandler(&logs, nil)))
+		assert.Equal(t, err != nil, true)
+		assert.StringContains(t, err.Error(), "broken.tmpl")
+		assert.StringContains(t, logs.String(), "level=ERROR")
+		assert.StringContains(t, logs.String(), "template=broken.tmpl")
+	})
+
+	t.Run("Lenient", func(t *testing.T) {
+		var logs bytes.Buffer
+
+		err := selfTestTemplates(cache, false, slog.New(slog.NewTextHandler(&logs, nil)))
+		assert.NilError(t, err)
+		assert.StringContains(t, logs.String(), "level=WARN")
+		assert.StringContains(t, logs.String(), "template=broken.tmpl")
+	})
+}
@@ -2,17 +2,22 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
 	"html"
 	"io"
 	"log/slog"
+	"maps"
 	"net/http"
 	"net/http/cookiejar"
 	"net/http/httptest"
 	"net/url"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/Vadim-Makhnev/snippetbox/internal/models"
 	"github.com/Vadim-Makhnev/snippetbox/internal/models/mocks"
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-playground/form/v4"
@@ -20,7 +25,12 @@ import (
 
 func newTestApplication(t *testing.T) *application {
 
-	templateCache, err := newTemplateCache()
+	templateCache, err := newTemplateCache(defaultDateLayout)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	presets, err := newPresets()
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -31,14 +41,64 @@ func newTestApplication(t *testing.T) *application {
 	sessionManager.Lifetime = 12 * time.Hour
 	sessionManager.Cookie.Secure = true
 
-	return &application{
-		logger:         slog.New(slog.DiscardHandler),
+	logger := slog.New(slog.DiscardHandler)
+
+	app := &application{
+		config: config{
+			baseURL:           "https://snippetbox.example.com",
+			appName:           "Snippetbox",
+			themeColor:        "#34495E",
+			snippetOfDay:      true,
+			featuredLimit:     5,
+			dateLayout:        defaultDateLayout,
+			maxPins:           3,
+			minPasswordLength: 8,
+			signupEnabled:     true,
+			expiryOptions:     defaultExpiryOptions,
+			languages:         defaultLanguages,
+			maxTags:           5,
+			slugMaxLength:     60,
+			feedTTL:           5 * time.Minute,
+			homeCacheMaxAge:   30 * time.Second,
+			sessionMaxAge:     12 * time.Hour,
+			importMaxBytes:    1024,
+
+			uploadAllowedTypes: defaultUploadAllowedTypes,
+		},
+		logger:         logger,
 		snippets:       &mocks.SnippetModel{},
 		users:          &mocks.UserModel{},
+		tokens:         &mocks.TokenModel{},
+		drafts:         &mocks.DraftModel{},
+		invites:        &mocks.InviteModel{},
+		comments:       &mocks.CommentModel{},
 		templateCache:  templateCache,
+		presets:        presets,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
+		idempotency:    newIdempotencyStore(time.Hour),
+		webhooks:       newWebhookDispatcher(nil, "", logger),
+		fetcher:        newURLFetcher(5*time.Second, 1024),
+		userLimiter:    newRateLimiter(10, 20),
+		ipLimiter:      newRateLimiter(2, 4),
 	}
+
+	// The database only goes as far as transactions, for the handlers run
+	// under txHandler; the models are mocks.
+	app.db = sql.OpenDB(txConnector{store: &txStore{}})
+	t.Cleanup(func() { app.db.Close() })
+
+	app.config.loginRedirect.user = "/snippet/create"
+	app.config.loginRedirect.admin = "/admin"
+	app.config.pageSizes.home = models.PageSizeLimits{Min: 1, Default: 10, Max: 50}
+	app.config.pageSizes.search = models.PageSizeLimits{Min: 1, Default: 20, Max: 50}
+	app.config.pageSizes.api = models.PageSizeLimits{Min: 1, Default: 20, Max: models.MaxPageSize}
+
+	app.readyCheck = func(ctx context.Context) error { return nil }
+
+	app.feedCache = newFeedCache(app.config.feedTTL, app.buildFeed, app.background, logger)
+
+	return app
 }
 
 var csrfTokenRX = regexp.MustCompile(`<input type='hidden' name='csrf_token' value='(.+)'>`)
@@ -75,6 +135,20 @@ func newTestServer(t *testing.T, h http.Handler) *testServer {
 	return &testServer{ts}
 }
 
+func (ts *testServer) login(t *testing.T) {
+	_, _, body := ts.get(t, "/user/login")
+
+	form := url.Values{}
+	form.Add("email", "alice@example.com")
+	form.Add("password", "pa$$word")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("login failed with status %d", code)
+	}
+}
+
 func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, string) {
 	rs, err := ts.Client().Get(ts.URL + urlPath)
 	if err != nil {
@@ -91,6 +165,29 @@ func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, strin
 	return rs.StatusCode, rs.Header, string(body)
 }
 
+func (ts *testServer) postJSON(t *testing.T, urlPath string, header http.Header, body string) (int, http.Header, string) {
+	req, err := http.NewRequest(http.MethodPost, ts.URL+urlPath, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	maps.Copy(req.Header, header)
+	req.Header.Set("Content-Type", "application/json")
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rs.Body.Close()
+	respBody, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rs.StatusCode, rs.Header, string(respBody)
+}
+
 func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (int, http.Header, string) {
 	rs, err := ts.Client().PostForm(ts.URL+urlPath, form)
 	if err != nil {
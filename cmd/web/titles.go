@@ -0,0 +1,35 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// normalizeTitle trims a title and collapses each run of internal whitespace
+// to a single space. With titleCase, the first letter of every word is also
+// upper-cased; the rest of each word is left alone so acronyms survive.
+func normalizeTitle(title string, titleCase bool) string {
+	words := strings.Fields(title)
+
+	if titleCase {
+		for i, word := range words {
+			r, size := utf8.DecodeRuneInString(word)
+			words[i] = string(unicode.ToTitle(r)) + word[size:]
+		}
+	}
+
+	return strings.Join(words, " ")
+}
+
+// normalizeTitle applies the configured title normalization, if any. It has
+// to run before validation, so a title that only fits once tidied is not
+// rejected, and before duplicate detection, so equivalent titles compare
+// equal.
+func (app *application) normalizeTitle(title string) string {
+	if !app.config.titles.normalize && !app.config.titles.titleCase {
+		return title
+	}
+
+	return normalizeTitle(title, app.config.titles.titleCase)
+}
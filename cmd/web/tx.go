@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"net/http"
+)
+
+// txHandler adapts a handler that makes several writes so that they persist
+// together or not at all. fn runs with a transaction in its request context,
+// available from app.tx, and its response is held back until the commit
+// succeeds. If fn returns an error or panics, the transaction is rolled back
+// and the client gets a server error instead. Side effects registered with
+// app.afterCommit run only once the commit succeeds.
+func (app *application) txHandler(fn func(w http.ResponseWriter, r *http.Request) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tx, err := app.db.BeginTx(r.Context(), nil)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		// Rolling back a committed transaction does nothing, and on a panic
+		// this runs before recoverPanic reports it.
+		defer tx.Rollback()
+
+		buf := &bufferedResponseWriter{header: w.Header().Clone()}
+
+		var hooks []func()
+
+		ctx := context.WithValue(r.Context(), txContextKey, tx)
+		ctx = context.WithValue(ctx, txHooksContextKey, &hooks)
+
+		err = fn(buf, r.WithContext(ctx))
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		for _, hook := range hooks {
+			hook()
+		}
+
+		buf.flush(w)
+	})
+}
+
+// tx returns the transaction txHandler opened for the request, or nil
+// outside of one.
+func (app *application) tx(r *http.Request) *sql.Tx {
+	tx, _ := r.Context().Value(txContextKey).(*sql.Tx)
+	return tx
+}
+
+// afterCommit defers fn, a side effect of the request's writes such as a
+// notification, until the transaction txHandler opened for the request
+// commits, and drops it if the transaction is rolled back. Outside of a
+// transaction fn runs straight away.
+func (app *application) afterCommit(r *http.Request, fn func()) {
+	hooks, ok := r.Context().Value(txHooksContextKey).(*[]func())
+	if !ok {
+		fn()
+		return
+	}
+
+	*hooks = append(*hooks, fn)
+}
+
+// bufferedResponseWriter holds a response in memory until it is flushed to
+// the real ResponseWriter.
+type bufferedResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (bw *bufferedResponseWriter) Header() http.Header {
+	return bw.header
+}
+
+func (bw *bufferedResponseWriter) WriteHeader(status int) {
+	if bw.status == 0 {
+		bw.status = status
+	}
+}
+
+func (bw *bufferedResponseWriter) Write(b []byte) (int, error) {
+	bw.WriteHeader(http.StatusOK)
+	return bw.body.Write(b)
+}
+
+func (bw *bufferedResponseWriter) flush(w http.ResponseWriter) {
+	for key, values := range bw.header {
+		w.Header()[key] = values
+	}
+
+	bw.WriteHeader(http.StatusOK)
+
+	w.WriteHeader(bw.status)
+	w.Write(bw.body.Bytes())
+}
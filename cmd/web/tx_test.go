@@ -0,0 +1,352 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/assert"
+	"github.com/Vadim-Makhnev/snippetbox/internal/models"
+	"github.com/Vadim-Makhnev/snippetbox/internal/models/mocks"
+)
+
+// txStore is the state behind txConnector: statements executed in a
+// transaction are only recorded as committed once it commits.
+type txStore struct {
+	mu         sync.Mutex
+	committed  []string
+	failCommit bool
+}
+
+func (s *txStore) rows() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.committed)
+}
+
+type txConnector struct{ store *txStore }
+
+func (c txConnector) Connect(context.Context) (driver.Conn, error) {
+	return &txConn{store: c.store}, nil
+}
+
+func (c txConnector) Driver() driver.Driver { return nil }
+
+type txConn struct {
+	store   *txStore
+	pending []string
+}
+
+func (c *txConn) Prepare(query string) (driver.Stmt, error) {
+	return &txStmt{conn: c, query: query}, nil
+}
+
+func (c *txConn) Close() error              { return nil }
+func (c *txConn) Begin() (driver.Tx, error) { return c, nil }
+
+func (c *txConn) Commit() error {
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+
+	if c.store.failCommit {
+		c.pending = nil
+		return errors.New("commit failed")
+	}
+
+	c.store.committed = append(c.store.committed, c.pending...)
+	c.pending = nil
+	return nil
+}
+
+func (c *txConn) Rollback() error {
+	c.pending = nil
+	return nil
+}
+
+type txStmt struct {
+	conn  *txConn
+	query string
+}
+
+func (s *txStmt) Close() error  { return nil }
+func (s *txStmt) NumInput() int { return -1 }
+
+func (s *txStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.conn.pending = append(s.conn.pending, s.query)
+	return driver.RowsAffected(1), nil
+}
+
+func (s *txStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func TestTxHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		handler  func(app *application) func(w http.ResponseWriter, r *http.Request) error
+		wantCode int
+		wantRows int
+		wantBody string
+	}{
+		{
+			name: "Commits",
+			handler: func(app *application) func(w http.ResponseWriter, r *http.Request) error {
+				return func(w http.ResponseWriter, r *http.Request) error {
+					for range 2 {
+						_, err := app.tx(r).Exec("INSERT INTO snippets VALUES ()")
+						if err != nil {
+							return err
+						}
+					}
+
+					w.WriteHeader(http.StatusCreated)
+					w.Write([]byte("created"))
+					return nil
+				}
+			},
+			wantCode: http.StatusCreated,
+			wantRows: 2,
+			wantBody: "created",
+		},
+		{
+			name: "Error after first write",
+			handler: func(app *application) func(w http.ResponseWriter, r *http.Request) error {
+				return func(w http.ResponseWriter, r *http.Request) error {
+					_, err := app.tx(r).Exec("INSERT INTO snippets VALUES ()")
+					if err != nil {
+						return err
+					}
+
+					w.WriteHeader(http.StatusCreated)
+					return errors.New("second write failed")
+				}
+			},
+			wantCode: http.StatusInternalServerError,
+		},
+		{
+			name: "Panic after first write",
+			handler: func(app *application) func(w http.ResponseWriter, r *http.Request) error {
+				return func(w http.ResponseWriter, r *http.Request) error {
+					app.tx(r).Exec("INSERT INTO snippets VALUES ()")
+					panic("second write failed")
+				}
+			},
+			wantCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+
+			store := &txStore{}
+			app.db = sql.OpenDB(txConnector{store: store})
+			defer app.db.Close()
+
+			rr := httptest.NewRecorder()
+			r, err := http.NewRequest(http.MethodPost, "/", nil)
+			assert.NilError(t, err)
+
+			app.recoverPanic(app.txHandler(tt.handler(app))).ServeHTTP(rr, r)
+
+			assert.Equal(t, rr.Code, tt.wantCode)
+			assert.Equal(t, store.rows(), tt.wantRows)
+			assert.StringContains(t, rr.Body.String(), tt.wantBody)
+		})
+	}
+}
+
+func TestTxHandlerAfterCommit(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		failCommit bool
+		wantCode   int
+		wantRan    bool
+	}{
+		{
+			name:     "Committed",
+			wantCode: http.StatusCreated,
+			wantRan:  true,
+		},
+		{
+			name:     "Rolled back",
+			err:      errors.New("second write failed"),
+			wantCode: http.StatusInternalServerError,
+		},
+		{
+			name:       "Commit failed",
+			failCommit: true,
+			wantCode:   http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+
+			store := &txStore{failCommit: tt.failCommit}
+			app.db = sql.OpenDB(txConnector{store: store})
+			defer app.db.Close()
+
+			var ran bool
+
+			handler := func(w http.ResponseWriter, r *http.Request) error {
+				_, err := app.tx(r).Exec("INSERT INTO snippets VALUES ()")
+				if err != nil {
+					return err
+				}
+
+				app.afterCommit(r, func() { ran = true })
+
+				w.WriteHeader(http.StatusCreated)
+				return tt.err
+			}
+
+			rr := httptest.NewRecorder()
+			r, err := http.NewRequest(http.MethodPost, "/", nil)
+			assert.NilError(t, err)
+
+			app.txHandler(handler).ServeHTTP(rr, r)
+
+			assert.Equal(t, rr.Code, tt.wantCode)
+			assert.Equal(t, ran, tt.wantRan)
+		})
+	}
+}
+
+// txSnippetModel writes its snippets and tags to the transaction it is
+// given, failing to save tags if failTags is set.
+type txSnippetModel struct {
+	mocks.SnippetModel
+	tx       *sql.Tx
+	failTags bool
+}
+
+func (m *txSnippetModel) WithTx(tx *sql.Tx) models.SnippetModelInterface {
+	return &txSnippetModel{tx: tx, failTags: m.failTags}
+}
+
+func (m *txSnippetModel) Insert(userID int, title, content, language string, private bool, expires int) (int, error) {
+	_, err := m.tx.Exec("INSERT INTO snippets VALUES ()")
+	return 2, err
+}
+
+func (m *txSnippetModel) SetTags(id int, tags []string) error {
+	if m.failTags {
+		return errors.New("tags not saved")
+	}
+
+	_, err := m.tx.Exec("INSERT INTO snippet_tags VALUES ()")
+	return err
+}
+
+func TestSnippetCreatePostTx(t *testing.T) {
+	tests := []struct {
+		name      string
+		failTags  bool
+		wantCode  int
+		wantRows  int
+		wantDraft bool
+	}{
+		{
+			name:     "Saved",
+			wantCode: http.StatusSeeOther,
+			wantRows: 2,
+		},
+		{
+			name:      "Tags not saved",
+			failTags:  true,
+			wantCode:  http.StatusInternalServerError,
+			wantDraft: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.snippets = &txSnippetModel{failTags: tt.failTags}
+			assert.NilError(t, app.drafts.Upsert(1, "O snail", "Climb Mount Fuji,", 7))
+
+			store := &txStore{}
+			app.db = sql.OpenDB(txConnector{store: store})
+			defer app.db.Close()
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t)
+
+			_, _, body := ts.get(t, "/snippet/create")
+
+			matches := formTokenRX.FindStringSubmatch(body)
+			if len(matches) < 2 {
+				t.Fatal("no form token found in body")
+			}
+
+			form := url.Values{}
+			form.Add("title", "O snail")
+			form.Add("content", "Climb Mount Fuji,")
+			form.Add("tags", "go")
+			form.Add("expires", "7")
+			form.Add("csrf_token", extractCSRFToken(t, body))
+			form.Add("form_token", matches[1])
+
+			code, _, _ := ts.postForm(t, "/snippet/create", form)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, store.rows(), tt.wantRows)
+
+			_, err := app.drafts.Get(1)
+			assert.Equal(t, err == nil, tt.wantDraft)
+		})
+	}
+}
+
+func TestAPISnippetCreateTx(t *testing.T) {
+	tests := []struct {
+		name     string
+		failTags bool
+		wantCode int
+		wantRows int
+	}{
+		{
+			name:     "Saved",
+			wantCode: http.StatusCreated,
+			wantRows: 2,
+		},
+		{
+			name:     "Tags not saved",
+			failTags: true,
+			wantCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.snippets = &txSnippetModel{failTags: tt.failTags}
+
+			store := &txStore{}
+			app.db = sql.OpenDB(txConnector{store: store})
+			defer app.db.Close()
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			header := http.Header{}
+			header.Set("Authorization", "Bearer "+mocks.AliceToken)
+			header.Set("Idempotency-Key", "7c4a8d09")
+
+			code, _, _ := ts.postJSON(t, "/api/v1/snippets", header, `{"title": "O snail", "content": "Climb Mount Fuji,", "tags": ["go"], "expires": 7}`)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, store.rows(), tt.wantRows)
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// defaultUploadAllowedTypes are the -upload-allowed-types defaults: plain
+// text, with the extensions of common code and config files.
+var defaultUploadAllowedTypes = []string{
+	"text/plain",
+	".txt", ".md", ".c", ".h", ".cpp", ".cs", ".css", ".go", ".java", ".js", ".json",
+	".php", ".py", ".rb", ".rs", ".sh", ".sql", ".toml", ".ts", ".yaml", ".yml",
+}
+
+// parseUploadTypes parses a comma-separated list of MIME types and file
+// extensions, such as "text/plain,.go,.txt". Entries are lowercased;
+// extensions start with a dot. At least one MIME type is required, as
+// uploads are checked against their sniffed type.
+func parseUploadTypes(s string) ([]string, error) {
+	var types []string
+
+	for t := range strings.SplitSeq(s, ",") {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" {
+			continue
+		}
+
+		if strings.HasPrefix(t, ".") {
+			if len(t) == 1 || strings.ContainsAny(t[1:], "./\\ ") {
+				return nil, fmt.Errorf("invalid file extension %q", t)
+			}
+		} else {
+			mediaType, params, err := mime.ParseMediaType(t)
+			if err != nil || len(params) > 0 || !strings.Contains(mediaType, "/") {
+				return nil, fmt.Errorf("invalid MIME type %q: use a type such as text/plain or an extension such as .go", t)
+			}
+		}
+
+		if !slices.Contains(types, t) {
+			types = append(types, t)
+		}
+	}
+
+	if !slices.ContainsFunc(types, func(t string) bool { return !strings.HasPrefix(t, ".") }) {
+		return nil, errors.New("at least one MIME type is required")
+	}
+
+	return types, nil
+}
+
+// uploadAllowed reports whether a file with the given name and content is
+// one of the allowed types. The type is sniffed from the content, so a
+// binary file can't pass for text by its name. If any extensions are
+// allowed, the name must also have one of them.
+func uploadAllowed(allowed []string, name string, content []byte) bool {
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(content))
+	if err != nil || !slices.Contains(allowed, mediaType) {
+		return false
+	}
+
+	if !slices.ContainsFunc(allowed, func(t string) bool { return strings.HasPrefix(t, ".") }) {
+		return true
+	}
+
+	ext := strings.ToLower(filepath.Ext(name))
+
+	return ext != "" && slices.Contains(allowed, ext)
+}
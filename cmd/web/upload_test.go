@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/assert"
+)
+
+func TestParseUploadTypes(t *testing.T) {
+	types, err := parseUploadTypes(" Text/Plain, .GO,,.txt,.go ")
+	assert.NilError(t, err)
+	assert.Equal(t, slices.Equal(types, []string{"text/plain", ".go", ".txt"}), true)
+
+	for _, s := range []string{"", ".go,.txt", "text", "text/plain; charset=utf-8", ".", ".tar.gz", "text/plain,. go"} {
+		_, err := parseUploadTypes(s)
+		if err == nil {
+			t.Errorf("parseUploadTypes(%q) succeeded; want an error", s)
+		}
+	}
+}
+
+func TestUploadAllowed(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+	tests := []struct {
+		name    string
+		allowed []string
+		file    string
+		content []byte
+		want    bool
+	}{
+		{"Text", defaultUploadAllowedTypes, "main.go", []byte("package main\n"), true},
+		{"Upper case extension", defaultUploadAllowedTypes, "NOTES.TXT", []byte("Remember the milk"), true},
+		{"Binary named as text", defaultUploadAllowedTypes, "notes.txt", png, false},
+		{"Extension not allowed", defaultUploadAllowedTypes, "notes.exe", []byte("Remember the milk"), false},
+		{"No extension", defaultUploadAllowedTypes, "Makefile", []byte("all:\n"), false},
+		{"Sniffed as HTML", defaultUploadAllowedTypes, "page.txt", []byte("<html><body>Hi</body></html>"), false},
+		{"Only MIME types", []string{"text/plain"}, "Makefile", []byte("all:\n"), true},
+		{"Image allowed", []string{"image/png", ".png"}, "logo.png", png, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, uploadAllowed(tt.allowed, tt.file, tt.content), tt.want)
+		})
+	}
+}
+
+func TestSnippetUpload(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		content   string
+		wantCode  int
+		wantTitle string
+		wantError string
+	}{
+		{
+			name:      "Text file",
+			file:      "hello.go",
+			content:   "package main\n",
+			wantCode:  http.StatusSeeOther,
+			wantTitle: "hello.go",
+		},
+		{
+			name:      "Binary file named as text",
+			file:      "hello.txt",
+			content:   "\x7fELF\x02\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00",
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "This type of file is not allowed",
+		},
+		{
+			name:      "Extension not allowed",
+			file:      "hello.bat",
+			content:   "echo hello\n",
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "This type of file is not allowed",
+		},
+		{
+			name:      "Too large",
+			file:      "big.txt",
+			content:   strings.Repeat("a", 2048),
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "This file is larger than 1024 bytes",
+		},
+		{
+			name:      "Empty",
+			file:      "empty.txt",
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "This file is empty",
+		},
+		{
+			name:      "No file",
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "Choose a file to upload",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+
+			snippets := &contentRecordingSnippetModel{}
+			app.snippets = snippets
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t)
+
+			_, _, body := ts.get(t, "/snippet/create")
+			assert.StringContains(t, body, "<form action='/snippet/upload' method='POST' enctype='multipart/form-data'>")
+
+			var buf bytes.Buffer
+			mw := multipart.NewWriter(&buf)
+			assert.NilError(t, mw.WriteField("csrf_token", extractCSRFToken(t, body)))
+
+			if tt.file != "" {
+				fw, err := mw.CreateFormFile("file", tt.file)
+				assert.NilError(t, err)
+				_, err = io.WriteString(fw, tt.content)
+				assert.NilError(t, err)
+			}
+
+			assert.NilError(t, mw.Close())
+
+			rs, err := ts.Client().Post(ts.URL+"/snippet/upload", mw.FormDataContentType(), &buf)
+			assert.NilError(t, err)
+			defer rs.Body.Close()
+
+			respBody, err := io.ReadAll(rs.Body)
+			assert.NilError(t, err)
+
+			assert.Equal(t, rs.StatusCode, tt.wantCode)
+			assert.Equal(t, snippets.title, tt.wantTitle)
+
+			if tt.wantError != "" {
+				assert.StringContains(t, string(respBody), tt.wantError)
+				assert.Equal(t, snippets.content, "")
+			} else {
+				assert.Equal(t, snippets.content, tt.content)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const eventSnippetCreated = "snippet.created"
+
+const (
+	webhookSignatureHeader = "X-Snippetbox-Signature"
+	webhookEventHeader     = "X-Snippetbox-Event"
+	webhookMaxAttempts     = 5
+	webhookQueueSize       = 100
+)
+
+type webhookEvent struct {
+	Event      string    `json:"event"`
+	OccurredAt time.Time `json:"occurred_at"`
+	SnippetID  int       `json:"snippet_id"`
+	UserID     int       `json:"user_id"`
+	Title      string    `json:"title,omitempty"`
+}
+
+// webhookDispatcher delivers snippet lifecycle events to the configured
+// endpoints from a background worker, so a slow or failing endpoint never
+// holds up the request that triggered the event. Each payload is signed with
+// an HMAC-SHA256 of the body using the shared secret.
+type webhookDispatcher struct {
+	urls      []string
+	secret    []byte
+	client    *http.Client
+	logger    *slog.Logger
+	queue     chan webhookEvent
+	baseDelay time.Duration
+}
+
+func newWebhookDispatcher(urls []string, secret string, logger *slog.Logger) *webhookDispatcher {
+	return &webhookDispatcher{
+		urls:      urls,
+		secret:    []byte(secret),
+		client:    &http.Client{Timeout: 10 * time.Second},
+		logger:    logger,
+		queue:     make(chan webhookEvent, webhookQueueSize),
+		baseDelay: time.Second,
+	}
+}
+
+// start runs the delivery worker. It returns immediately when no endpoints
+// are configured.
+func (d *webhookDispatcher) start() {
+	if len(d.urls) == 0 {
+		return
+	}
+
+	go func() {
+		for event := range d.queue {
+			body, err := json.Marshal(event)
+			if err != nil {
+				d.logger.Error("could not encode webhook event", "event", event.Event, "error", err.Error())
+				continue
+			}
+
+			for _, url := range d.urls {
+				d.deliver(url, event.Event, body)
+			}
+		}
+	}()
+}
+
+// dispatch queues an event without blocking. Events are dropped, and the drop
+// logged, if the queue is full.
+func (d *webhookDispatcher) dispatch(event webhookEvent) {
+	if len(d.urls) == 0 {
+		return
+	}
+
+	event.OccurredAt = time.Now().UTC()
+
+	select {
+	case d.queue <- event:
+	default:
+		d.logger.Error("webhook queue full, dropping event", "event", event.Event, "snippet_id", event.SnippetID)
+	}
+}
+
+func (d *webhookDispatcher) deliver(url, event string, body []byte) {
+	delay := d.baseDelay
+
+	for attempt := 1; ; attempt++ {
+		err := d.post(url, event, body)
+		if err == nil {
+			return
+		}
+
+		if attempt == webhookMaxAttempts {
+			d.logger.Error("webhook delivery failed", "url", url, "event", event, "attempts", attempt, "error", err.Error())
+			return
+		}
+
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func (d *webhookDispatcher) post(url, event string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookEventHeader, event)
+	req.Header.Set(webhookSignatureHeader, "sha256="+signWebhook(d.secret, body))
+
+	rs, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rs.Body.Close()
+
+	if rs.StatusCode < 200 || rs.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %d", rs.StatusCode)
+	}
+
+	return nil
+}
+
+func signWebhook(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
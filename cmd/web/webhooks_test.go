@@ -0,0 +1,77 @@
+package main
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/assert"
+	"github.com/Vadim-Makhnev/snippetbox/internal/models/mocks"
+)
+
+func TestWebhookOnSnippetCreate(t *testing.T) {
+	const secret = "whsec"
+
+	received := make(chan webhookEvent, 1)
+	attempts := 0
+
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		want := "sha256=" + signWebhook([]byte(secret), body)
+		if !hmac.Equal([]byte(r.Header.Get(webhookSignatureHeader)), []byte(want)) {
+			t.Errorf("invalid signature %q", r.Header.Get(webhookSignatureHeader))
+		}
+
+		var event webhookEvent
+
+		err = json.Unmarshal(body, &event)
+		if err != nil {
+			t.Error(err)
+		}
+
+		received <- event
+	}))
+	defer hook.Close()
+
+	app := newTestApplication(t)
+	app.webhooks = newWebhookDispatcher([]string{hook.URL}, secret, slog.New(slog.DiscardHandler))
+	app.webhooks.baseDelay = time.Millisecond
+	app.webhooks.start()
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+mocks.AliceToken)
+
+	code, _, _ := ts.postJSON(t, "/api/v1/snippets", header, `{"title": "O snail", "content": "Climb Mount Fuji,", "expires": 7}`)
+	assert.Equal(t, code, http.StatusCreated)
+
+	select {
+	case event := <-received:
+		assert.Equal(t, event.Event, eventSnippetCreated)
+		assert.Equal(t, event.SnippetID, 2)
+		assert.Equal(t, event.UserID, 1)
+		assert.Equal(t, event.Title, "O snail")
+	case <-time.After(5 * time.Second):
+		t.Fatal("no webhook received")
+	}
+
+	assert.Equal(t, attempts, 2)
+}
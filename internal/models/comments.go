@@ -0,0 +1,166 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Comment is a logged-in user's remark on a snippet. Author is the name of
+// the user who wrote it. A deleted comment keeps its place in the thread,
+// but its body is not returned.
+type Comment struct {
+	ID        int       `json:"id"`
+	SnippetID int       `json:"snippet_id"`
+	UserID    int       `json:"user_id"`
+	Author    string    `json:"author"`
+	Body      string    `json:"body"`
+	Created   time.Time `json:"created"`
+	Deleted   bool      `json:"deleted"`
+}
+
+// CommentPage is one page of a snippet's comments. Page is the page actually
+// returned, which may differ from the one asked for if that was out of range.
+type CommentPage struct {
+	Comments []Comment
+	Page     int
+	LastPage int
+	Total    int
+}
+
+type CommentModel struct {
+	DB *sql.DB
+}
+
+type CommentModelInterface interface {
+	Insert(snippetID, userID int, body string) (int, error)
+	ForSnippet(snippetID int, f Filters) (CommentPage, error)
+	Delete(snippetID, id int) error
+	LastCreatedAt(userID int) (time.Time, error)
+}
+
+// Insert adds a comment by userID to the snippet. Whether the user may see
+// the snippet is for the caller to check.
+func (m *CommentModel) Insert(snippetID, userID int, body string) (int, error) {
+	stmt := `INSERT INTO comments (snippet_id, user_id, body, created)
+	VALUES (?, ?, ?, UTC_TIMESTAMP())`
+
+	var result sql.Result
+
+	err := withRetry(func() error {
+		var err error
+		result, err = m.DB.Exec(stmt, snippetID, userID, body)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// ForSnippet returns a page of the snippet's comments, oldest first,
+// including deleted ones with an empty body. Only the page and page size of
+// f are used. A page past the comments is clamped to the last one.
+func (m *CommentModel) ForSnippet(snippetID int, f Filters) (CommentPage, error) {
+	var p CommentPage
+
+	err := withReconnect(func() error {
+		return m.DB.QueryRow("SELECT COUNT(*) FROM comments WHERE snippet_id = ?", snippetID).Scan(&p.Total)
+	})
+	if err != nil {
+		return CommentPage{}, err
+	}
+
+	p.LastPage = f.LastPage(p.Total)
+	p.Page = min(f.Page, p.LastPage)
+	f.Page = p.Page
+
+	stmt := `SELECT c.id, c.snippet_id, c.user_id, COALESCE(u.name, ''), IF(c.deleted, '', c.body), c.created, c.deleted
+	FROM comments c LEFT JOIN users u ON u.id = c.user_id
+	WHERE c.snippet_id = ?
+	ORDER BY c.created, c.id LIMIT ? OFFSET ?`
+
+	var rows *sql.Rows
+
+	err = withReconnect(func() error {
+		var err error
+		rows, err = m.DB.Query(stmt, snippetID, f.limit(), f.offset())
+		return err
+	})
+	if err != nil {
+		return CommentPage{}, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var c Comment
+
+		err = rows.Scan(&c.ID, &c.SnippetID, &c.UserID, &c.Author, &c.Body, &c.Created, &c.Deleted)
+		if err != nil {
+			return CommentPage{}, err
+		}
+
+		p.Comments = append(p.Comments, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return CommentPage{}, err
+	}
+
+	return p, nil
+}
+
+// Delete marks a comment on the snippet as deleted. It returns ErrNoRecord
+// if the snippet has no such comment or it was already deleted.
+func (m *CommentModel) Delete(snippetID, id int) error {
+	stmt := "UPDATE comments SET deleted = TRUE WHERE id = ? AND snippet_id = ? AND NOT deleted"
+
+	var result sql.Result
+
+	err := withRetry(func() error {
+		var err error
+		result, err = m.DB.Exec(stmt, id, snippetID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// LastCreatedAt returns when the user last commented, counting deleted
+// comments. It returns ErrNoRecord if they never have.
+func (m *CommentModel) LastCreatedAt(userID int) (time.Time, error) {
+	var created sql.NullTime
+
+	stmt := "SELECT MAX(created) FROM comments WHERE user_id = ?"
+
+	err := withReconnect(func() error {
+		return m.DB.QueryRow(stmt, userID).Scan(&created)
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if !created.Valid {
+		return time.Time{}, ErrNoRecord
+	}
+
+	return created.Time, nil
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/assert"
+)
+
+func TestCommentModel(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := CommentModel{db}
+
+	_, err := m.LastCreatedAt(1)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	for _, body := range []string{"First", "Second", "Third"} {
+		_, err := m.Insert(1, 1, body)
+		assert.NilError(t, err)
+	}
+
+	_, err = m.Insert(2, 1, "On another snippet")
+	assert.NilError(t, err)
+
+	last, err := m.LastCreatedAt(1)
+	assert.NilError(t, err)
+	assert.Equal(t, time.Since(last) < time.Minute, true)
+
+	p, err := m.ForSnippet(1, Filters{Page: 1, PageSize: 2})
+	assert.NilError(t, err)
+	assert.Equal(t, p.Total, 3)
+	assert.Equal(t, p.LastPage, 2)
+	assert.Equal(t, len(p.Comments), 2)
+	assert.Equal(t, p.Comments[0].Body, "First")
+	assert.Equal(t, p.Comments[0].Author, "Alice Jones")
+	assert.Equal(t, p.Comments[1].Body, "Second")
+
+	p, err = m.ForSnippet(1, Filters{Page: 5, PageSize: 2})
+	assert.NilError(t, err)
+	assert.Equal(t, p.Page, 2)
+	assert.Equal(t, len(p.Comments), 1)
+	assert.Equal(t, p.Comments[0].Body, "Third")
+
+	err = m.Delete(1, p.Comments[0].ID)
+	assert.NilError(t, err)
+
+	err = m.Delete(1, p.Comments[0].ID)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	err = m.Delete(2, p.Comments[0].ID)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	p, err = m.ForSnippet(1, Filters{Page: 2, PageSize: 2})
+	assert.NilError(t, err)
+	assert.Equal(t, p.Total, 3)
+	assert.Equal(t, p.Comments[0].Deleted, true)
+	assert.Equal(t, p.Comments[0].Body, "")
+
+	p, err = m.ForSnippet(3, Filters{Page: 1, PageSize: 2})
+	assert.NilError(t, err)
+	assert.Equal(t, p.Total, 0)
+	assert.Equal(t, p.Page, 1)
+	assert.Equal(t, len(p.Comments), 0)
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+)
+
+// ContentCipher encrypts snippet content with AES-256-GCM. Ciphertext is
+// stored base64 encoded, with the random nonce in front, so it fits the
+// existing text column.
+type ContentCipher struct {
+	aead cipher.AEAD
+}
+
+// NewContentCipher returns a ContentCipher for a 32-byte key.
+func NewContentCipher(key []byte) (*ContentCipher, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("models: content encryption key must be 32 bytes, got %d", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ContentCipher{aead: aead}, nil
+}
+
+// Encrypt seals plaintext under a fresh random nonce.
+func (c *ContentCipher) Encrypt(plaintext string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens content sealed by Encrypt. Content that is malformed, was
+// tampered with or was sealed under another key fails with ErrDecrypt
+// instead of coming back as garbage.
+func (c *ContentCipher) Decrypt(ciphertext string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrDecrypt, err)
+	}
+
+	if len(sealed) < c.aead.NonceSize() {
+		return "", fmt.Errorf("%w: ciphertext too short", ErrDecrypt)
+	}
+
+	nonce, sealed := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+
+	plaintext, err := c.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", fmt.Errorf("%w: wrong key or corrupted content", ErrDecrypt)
+	}
+
+	return string(plaintext), nil
+}
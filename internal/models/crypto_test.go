@@ -0,0 +1,39 @@
+package models
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/assert"
+)
+
+func TestContentCipher(t *testing.T) {
+	c, err := NewContentCipher(bytes.Repeat([]byte{1}, 32))
+	assert.NilError(t, err)
+
+	ciphertext, err := c.Encrypt("An old silent pond...")
+	assert.NilError(t, err)
+	assert.Equal(t, ciphertext == "An old silent pond...", false)
+
+	plaintext, err := c.Decrypt(ciphertext)
+	assert.NilError(t, err)
+	assert.Equal(t, plaintext, "An old silent pond...")
+
+	// Each encryption uses a fresh nonce.
+	again, err := c.Encrypt("An old silent pond...")
+	assert.NilError(t, err)
+	assert.Equal(t, again == ciphertext, false)
+
+	wrong, err := NewContentCipher(bytes.Repeat([]byte{2}, 32))
+	assert.NilError(t, err)
+
+	_, err = wrong.Decrypt(ciphertext)
+	assert.Equal(t, errors.Is(err, ErrDecrypt), true)
+
+	_, err = c.Decrypt("not base64!")
+	assert.Equal(t, errors.Is(err, ErrDecrypt), true)
+
+	_, err = NewContentCipher([]byte("short"))
+	assert.Equal(t, err != nil, true)
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type Draft struct {
+	UserID  int       `json:"-"`
+	Title   string    `json:"title"`
+	Content string    `json:"content"`
+	Expires int       `json:"expires"`
+	Updated time.Time `json:"updated"`
+}
+
+type DraftModel struct {
+	DB *sql.DB
+}
+
+type DraftModelInterface interface {
+	Upsert(userID int, title string, content string, expires int) error
+	Get(userID int) (Draft, error)
+	Delete(userID int) error
+}
+
+// Upsert saves the draft for a user, replacing any earlier one, since only
+// the latest draft per user is kept.
+func (m *DraftModel) Upsert(userID int, title string, content string, expires int) error {
+	stmt := `INSERT INTO drafts (user_id, title, content, expires, updated)
+	VALUES (?, ?, ?, ?, UTC_TIMESTAMP())
+	ON DUPLICATE KEY UPDATE title = VALUES(title), content = VALUES(content),
+	expires = VALUES(expires), updated = VALUES(updated)`
+
+	return withRetry(func() error {
+		_, err := m.DB.Exec(stmt, userID, title, content, expires)
+		return err
+	})
+}
+
+func (m *DraftModel) Get(userID int) (Draft, error) {
+	stmt := `SELECT user_id, title, content, expires, updated FROM drafts WHERE user_id = ?`
+
+	var d Draft
+
+	err := withReconnect(func() error {
+		return m.DB.QueryRow(stmt, userID).Scan(&d.UserID, &d.Title, &d.Content, &d.Expires, &d.Updated)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Draft{}, ErrNoRecord
+		} else {
+			return Draft{}, err
+		}
+	}
+
+	return d, nil
+}
+
+func (m *DraftModel) Delete(userID int) error {
+	stmt := `DELETE FROM drafts WHERE user_id = ?`
+
+	return withRetry(func() error {
+		_, err := m.DB.Exec(stmt, userID)
+		return err
+	})
+}
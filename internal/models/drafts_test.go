@@ -0,0 +1,39 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/assert"
+)
+
+func TestDraftModel(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := DraftModel{db}
+
+	_, err := m.Get(1)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	err = m.Upsert(1, "First draft", "", 0)
+	assert.NilError(t, err)
+
+	err = m.Upsert(1, "O snail", "Climb Mount Fuji,", 7)
+	assert.NilError(t, err)
+
+	draft, err := m.Get(1)
+	assert.NilError(t, err)
+	assert.Equal(t, draft.Title, "O snail")
+	assert.Equal(t, draft.Content, "Climb Mount Fuji,")
+	assert.Equal(t, draft.Expires, 7)
+
+	err = m.Delete(1)
+	assert.NilError(t, err)
+
+	_, err = m.Get(1)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+}
@@ -8,4 +8,12 @@ var (
 	ErrInvalidCredentials = errors.New("models: invalid credentials")
 
 	ErrDuplicateEmail = errors.New("models: duplicate email")
+
+	ErrConnLost = errors.New("models: database connection lost")
+
+	ErrDecrypt = errors.New("models: snippet content could not be decrypted")
+
+	ErrNotFeaturable = errors.New("models: only live public snippets can be featured")
+
+	ErrTooManyPins = errors.New("models: too many pinned snippets")
 )
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/validator"
+)
+
+// MaxPageSize is the largest page any list can be configured to allow.
+const MaxPageSize = 100
+
+// PageSizeLimits are the page sizes a list allows, and the size it uses when
+// none is asked for.
+type PageSizeLimits struct {
+	Min     int
+	Default int
+	Max     int
+}
+
+// DefaultPageSizeLimits apply to Filters without limits of their own.
+var DefaultPageSizeLimits = PageSizeLimits{Min: 1, Default: 20, Max: MaxPageSize}
+
+// Check returns an error unless 1 <= Min <= Default <= Max <= MaxPageSize.
+func (l PageSizeLimits) Check() error {
+	if l.Min < 1 || l.Min > l.Default || l.Default > l.Max || l.Max > MaxPageSize {
+		return errors.New("page sizes must satisfy 1 <= min <= default <= max <= 100")
+	}
+
+	return nil
+}
+
+// Allows reports whether n is a page size within the limits.
+func (l PageSizeLimits) Allows(n int) bool {
+	return n >= l.Min && n <= l.Max
+}
+
+// Clamp returns the page size within the limits closest to n.
+func (l PageSizeLimits) Clamp(n int) int {
+	return max(l.Min, min(n, l.Max))
+}
+
+// Message is the validation error for a page size the limits don't allow.
+func (l PageSizeLimits) Message() string {
+	return fmt.Sprintf("This field must be between %d and %d", l.Min, l.Max)
+}
+
+// Filters are the paging and sorting options of a list. Sort is a column
+// name, prefixed with - for descending order, and must be one of
+// SortSafelist; it is placed into SQL, so Validate has to pass first.
+// PageSize must be within PageSizeLimits, or DefaultPageSizeLimits when
+// they are zero.
+type Filters struct {
+	Page           int
+	PageSize       int
+	PageSizeLimits PageSizeLimits
+	Sort           string
+	SortSafelist   []string
+}
+
+// Validate records an error in v for each filter out of bounds, keyed by
+// its query parameter: page, page_size or sort.
+func (f Filters) Validate(v *validator.Validator) {
+	limits := f.PageSizeLimits
+	if limits == (PageSizeLimits{}) {
+		limits = DefaultPageSizeLimits
+	}
+
+	v.CheckField(f.Page >= 1, "page", "This field must be a positive number")
+	v.CheckField(f.Page <= 10_000_000, "page", "This field must be at most 10 million")
+	v.CheckField(limits.Allows(f.PageSize), "page_size", limits.Message())
+	v.CheckField(validator.PermittedValue(f.Sort, f.SortSafelist...), "sort", "This field must be one of "+strings.Join(f.SortSafelist, ", "))
+}
+
+// sortColumn returns the column to order by. It panics on a sort that isn't
+// safelisted, rather than let one into a query.
+func (f Filters) sortColumn() string {
+	if !slices.Contains(f.SortSafelist, f.Sort) {
+		panic("models: unsafe sort parameter: " + f.Sort)
+	}
+
+	return strings.TrimPrefix(f.Sort, "-")
+}
+
+// sortDirection returns the SQL direction for Sort.
+func (f Filters) sortDirection() string {
+	if strings.HasPrefix(f.Sort, "-") {
+		return "DESC"
+	}
+
+	return "ASC"
+}
+
+func (f Filters) limit() int {
+	return f.PageSize
+}
+
+func (f Filters) offset() int {
+	return (f.Page - 1) * f.PageSize
+}
+
+// LastPage returns the number of pages total results fill, which is at
+// least 1 so an empty list still has a page to show.
+func (f Filters) LastPage(total int) int {
+	return max(1, (total+f.PageSize-1)/f.PageSize)
+}
@@ -0,0 +1,133 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/assert"
+	"github.com/Vadim-Makhnev/snippetbox/internal/validator"
+)
+
+func TestFiltersValidate(t *testing.T) {
+	safelist := []string{"id", "-id", "created", "-created"}
+
+	tests := []struct {
+		name       string
+		filters    Filters
+		wantErrors map[string]string
+	}{
+		{
+			name:    "Valid",
+			filters: Filters{Page: 1, PageSize: 20, Sort: "id"},
+		},
+		{
+			name:    "Largest",
+			filters: Filters{Page: 10_000_000, PageSize: 100, Sort: "-created"},
+		},
+		{
+			name:       "Page zero",
+			filters:    Filters{Page: 0, PageSize: 20, Sort: "id"},
+			wantErrors: map[string]string{"page": "This field must be a positive number"},
+		},
+		{
+			name:       "Page too large",
+			filters:    Filters{Page: 10_000_001, PageSize: 20, Sort: "id"},
+			wantErrors: map[string]string{"page": "This field must be at most 10 million"},
+		},
+		{
+			name:       "Page size zero",
+			filters:    Filters{Page: 1, PageSize: 0, Sort: "id"},
+			wantErrors: map[string]string{"page_size": "This field must be between 1 and 100"},
+		},
+		{
+			name:       "Page size too large",
+			filters:    Filters{Page: 1, PageSize: 101, Sort: "id"},
+			wantErrors: map[string]string{"page_size": "This field must be between 1 and 100"},
+		},
+		{
+			name:    "Within configured limits",
+			filters: Filters{Page: 1, PageSize: 5, PageSizeLimits: PageSizeLimits{Min: 5, Default: 10, Max: 10}, Sort: "id"},
+		},
+		{
+			name:       "Outside configured limits",
+			filters:    Filters{Page: 1, PageSize: 20, PageSizeLimits: PageSizeLimits{Min: 5, Default: 10, Max: 10}, Sort: "id"},
+			wantErrors: map[string]string{"page_size": "This field must be between 5 and 10"},
+		},
+		{
+			name:       "Sort not safelisted",
+			filters:    Filters{Page: 1, PageSize: 20, Sort: "hashed_password"},
+			wantErrors: map[string]string{"sort": "This field must be one of id, -id, created, -created"},
+		},
+		{
+			name:       "Empty sort",
+			filters:    Filters{Page: 1, PageSize: 20},
+			wantErrors: map[string]string{"sort": "This field must be one of id, -id, created, -created"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.filters.SortSafelist = safelist
+
+			var v validator.Validator
+			tt.filters.Validate(&v)
+
+			assert.Equal(t, len(v.FieldErrors), len(tt.wantErrors))
+			for key, want := range tt.wantErrors {
+				assert.Equal(t, v.FieldErrors[key], want)
+			}
+		})
+	}
+}
+
+func TestFiltersSort(t *testing.T) {
+	f := Filters{Sort: "-created", SortSafelist: []string{"created", "-created"}}
+
+	assert.Equal(t, f.sortColumn(), "created")
+	assert.Equal(t, f.sortDirection(), "DESC")
+
+	f.Sort = "created"
+	assert.Equal(t, f.sortDirection(), "ASC")
+
+	defer func() {
+		assert.Equal(t, recover() != nil, true)
+	}()
+
+	f.Sort = "created; DROP TABLE snippets"
+	f.sortColumn()
+	t.Error("sortColumn accepted a sort outside the safelist")
+}
+
+func TestFiltersPaging(t *testing.T) {
+	f := Filters{Page: 3, PageSize: 20}
+
+	assert.Equal(t, f.limit(), 20)
+	assert.Equal(t, f.offset(), 40)
+
+	assert.Equal(t, f.LastPage(0), 1)
+	assert.Equal(t, f.LastPage(20), 1)
+	assert.Equal(t, f.LastPage(21), 2)
+}
+
+func TestPageSizeLimits(t *testing.T) {
+	l := PageSizeLimits{Min: 2, Default: 5, Max: 10}
+
+	assert.NilError(t, l.Check())
+	assert.Equal(t, l.Allows(1), false)
+	assert.Equal(t, l.Allows(2), true)
+	assert.Equal(t, l.Allows(10), true)
+	assert.Equal(t, l.Allows(11), false)
+	assert.Equal(t, l.Clamp(0), 2)
+	assert.Equal(t, l.Clamp(7), 7)
+	assert.Equal(t, l.Clamp(50), 10)
+
+	for _, bad := range []PageSizeLimits{
+		{Min: 0, Default: 5, Max: 10},
+		{Min: 6, Default: 5, Max: 10},
+		{Min: 1, Default: 11, Max: 10},
+		{Min: 1, Default: 5, Max: 101},
+	} {
+		if bad.Check() == nil {
+			t.Errorf("%+v passed Check; want an error", bad)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"time"
+)
+
+// Invite is a single-use code letting someone sign up when -require-invite
+// is set. Only a hash of the code is stored, so the plaintext is available
+// once, when the invite is created.
+type Invite struct {
+	Code      string    `json:"code"`
+	CreatedBy int       `json:"-"`
+	Created   time.Time `json:"created"`
+}
+
+type InviteModel struct {
+	DB *sql.DB
+
+	// tx, set by WithTx, is the caller's transaction the statements run in.
+	tx *sql.Tx
+}
+
+type InviteModelInterface interface {
+	WithTx(tx *sql.Tx) InviteModelInterface
+	New(createdBy int) (*Invite, error)
+	Valid(code string) (bool, error)
+	Use(code string) error
+}
+
+// WithTx returns a copy of the model whose statements run in tx, so that an
+// invite is only used up if the signup using it persists. Committing is up
+// to the caller, and a write in tx is not retried.
+func (m *InviteModel) WithTx(tx *sql.Tx) InviteModelInterface {
+	return &InviteModel{DB: m.DB, tx: tx}
+}
+
+// db returns what the model's statements run on: its transaction if it has
+// one, or else the pool.
+func (m *InviteModel) db() DBTX {
+	if m.tx != nil {
+		return m.tx
+	}
+
+	return m.DB
+}
+
+// retry runs a write with withRetry, or just once in a transaction.
+func (m *InviteModel) retry(fn func() error) error {
+	if m.tx != nil {
+		return fn()
+	}
+
+	return withRetry(fn)
+}
+
+func (m *InviteModel) New(createdBy int) (*Invite, error) {
+	invite := &Invite{
+		Code:      rand.Text(),
+		CreatedBy: createdBy,
+		Created:   time.Now().UTC(),
+	}
+
+	hash := sha256.Sum256([]byte(invite.Code))
+
+	stmt := `INSERT INTO invites (hash, created_by, created)
+	VALUES (?, ?, ?)`
+
+	err := m.retry(func() error {
+		_, err := m.db().Exec(stmt, hash[:], invite.CreatedBy, invite.Created)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return invite, nil
+}
+
+// Valid reports whether code is an invite that hasn't been used yet.
+func (m *InviteModel) Valid(code string) (bool, error) {
+	hash := sha256.Sum256([]byte(code))
+
+	var valid bool
+
+	stmt := "SELECT EXISTS(SELECT true FROM invites WHERE hash = ? AND used IS NULL)"
+
+	err := withReconnect(func() error {
+		return m.db().QueryRow(stmt, hash[:]).Scan(&valid)
+	})
+	return valid, err
+}
+
+// Use marks the invite as used. It returns ErrNoRecord if there is no such
+// invite or it was already used. In a transaction the invite stays locked
+// until it ends, so of two signups racing on one code only the first
+// succeeds, and the second does once the first rolls back.
+func (m *InviteModel) Use(code string) error {
+	hash := sha256.Sum256([]byte(code))
+
+	stmt := "UPDATE invites SET used = UTC_TIMESTAMP() WHERE hash = ? AND used IS NULL"
+
+	var result sql.Result
+
+	err := m.retry(func() error {
+		var err error
+		result, err = m.db().Exec(stmt, hash[:])
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
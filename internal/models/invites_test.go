@@ -0,0 +1,51 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/assert"
+)
+
+func TestInviteModel(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := InviteModel{DB: db}
+
+	invite, err := m.New(1)
+	assert.NilError(t, err)
+
+	valid, err := m.Valid(invite.Code)
+	assert.NilError(t, err)
+	assert.Equal(t, valid, true)
+
+	valid, err = m.Valid("not-an-invite")
+	assert.NilError(t, err)
+	assert.Equal(t, valid, false)
+
+	err = m.Use(invite.Code)
+	assert.NilError(t, err)
+
+	valid, err = m.Valid(invite.Code)
+	assert.NilError(t, err)
+	assert.Equal(t, valid, false)
+
+	err = m.Use(invite.Code)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	invite, err = m.New(1)
+	assert.NilError(t, err)
+
+	tx, err := db.Begin()
+	assert.NilError(t, err)
+	assert.NilError(t, m.WithTx(tx).Use(invite.Code))
+	assert.NilError(t, tx.Rollback())
+
+	valid, err = m.Valid(invite.Code)
+	assert.NilError(t, err)
+	assert.Equal(t, valid, true)
+}
@@ -0,0 +1,87 @@
+package mocks
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/models"
+)
+
+// CommentModel remembers the comments added with Insert, all written by
+// Alice.
+type CommentModel struct {
+	mu       sync.Mutex
+	comments []models.Comment
+}
+
+func (m *CommentModel) Insert(snippetID, userID int, body string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c := models.Comment{
+		ID:        len(m.comments) + 1,
+		SnippetID: snippetID,
+		UserID:    userID,
+		Author:    "Alice",
+		Body:      body,
+		Created:   time.Now(),
+	}
+
+	m.comments = append(m.comments, c)
+
+	return c.ID, nil
+}
+
+func (m *CommentModel) ForSnippet(snippetID int, f models.Filters) (models.CommentPage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var all []models.Comment
+	for _, c := range m.comments {
+		if c.SnippetID == snippetID {
+			all = append(all, c)
+		}
+	}
+
+	p := models.CommentPage{Total: len(all), LastPage: f.LastPage(len(all))}
+	p.Page = min(f.Page, p.LastPage)
+
+	start := min((p.Page-1)*f.PageSize, len(all))
+	end := min(start+f.PageSize, len(all))
+	p.Comments = all[start:end]
+
+	return p, nil
+}
+
+func (m *CommentModel) Delete(snippetID, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, c := range m.comments {
+		if c.ID == id && c.SnippetID == snippetID && !c.Deleted {
+			m.comments[i].Deleted = true
+			m.comments[i].Body = ""
+			return nil
+		}
+	}
+
+	return models.ErrNoRecord
+}
+
+func (m *CommentModel) LastCreatedAt(userID int) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var last time.Time
+	for _, c := range m.comments {
+		if c.UserID == userID && c.Created.After(last) {
+			last = c.Created
+		}
+	}
+
+	if last.IsZero() {
+		return time.Time{}, models.ErrNoRecord
+	}
+
+	return last, nil
+}
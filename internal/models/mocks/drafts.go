@@ -0,0 +1,53 @@
+package mocks
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/models"
+)
+
+type DraftModel struct {
+	mu     sync.Mutex
+	drafts map[int]models.Draft
+}
+
+func (m *DraftModel) Upsert(userID int, title string, content string, expires int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.drafts == nil {
+		m.drafts = make(map[int]models.Draft)
+	}
+
+	m.drafts[userID] = models.Draft{
+		UserID:  userID,
+		Title:   title,
+		Content: content,
+		Expires: expires,
+		Updated: time.Now(),
+	}
+
+	return nil
+}
+
+func (m *DraftModel) Get(userID int) (models.Draft, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	d, ok := m.drafts[userID]
+	if !ok {
+		return models.Draft{}, models.ErrNoRecord
+	}
+
+	return d, nil
+}
+
+func (m *DraftModel) Delete(userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.drafts, userID)
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package mocks
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/models"
+)
+
+// InviteModel remembers the invites created with New and which of them
+// have been used.
+type InviteModel struct {
+	mu      sync.Mutex
+	invites map[string]bool
+}
+
+func (m *InviteModel) WithTx(tx *sql.Tx) models.InviteModelInterface {
+	return m
+}
+
+func (m *InviteModel) New(createdBy int) (*models.Invite, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.invites == nil {
+		m.invites = make(map[string]bool)
+	}
+
+	invite := &models.Invite{
+		Code:      rand.Text(),
+		CreatedBy: createdBy,
+		Created:   time.Now(),
+	}
+
+	m.invites[invite.Code] = false
+
+	return invite, nil
+}
+
+func (m *InviteModel) Valid(code string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	used, ok := m.invites[code]
+	return ok && !used, nil
+}
+
+func (m *InviteModel) Use(code string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	used, ok := m.invites[code]
+	if !ok || used {
+		return models.ErrNoRecord
+	}
+
+	m.invites[code] = true
+
+	return nil
+}
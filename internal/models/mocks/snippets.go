@@ -1,6 +1,11 @@
 package mocks
 
 import (
+	"database/sql"
+	"io"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/Vadim-Makhnev/snippetbox/internal/models"
@@ -8,25 +13,308 @@ import (
 
 var mockSnippet = models.Snippet{
 	ID:      1,
+	UserID:  1,
 	Title:   "An old silent pond",
 	Content: "An old silent pond...",
 	Created: time.Now(),
 	Expires: time.Now(),
 }
 
-type SnippetModel struct{}
+var mockHTMLSnippet = models.Snippet{
+	ID:      3,
+	UserID:  1,
+	Title:   "Markup",
+	Content: "<script>alert('pwned')</script><b>bold</b>",
+	Created: time.Now(),
+	Expires: time.Now(),
+}
+
+var mockLargeSnippet = models.Snippet{
+	ID:      4,
+	UserID:  1,
+	Title:   "Large",
+	Content: strings.Repeat("0123456789abcdef\n", 16*1024),
+	Created: time.Now(),
+	Expires: time.Now(),
+}
+
+var mockPrivateSnippet = models.Snippet{
+	ID:      5,
+	UserID:  1,
+	Title:   "Private",
+	Content: "A private haiku...",
+	Private: true,
+	Created: time.Now(),
+	Expires: time.Now(),
+}
+
+// SnippetModel remembers the snippets pinned with Pin.
+type SnippetModel struct {
+	mu   sync.Mutex
+	pins []pin
+}
+
+type pin struct {
+	userID, snippetID int
+}
 
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
+func (m *SnippetModel) WithTx(tx *sql.Tx) models.SnippetModelInterface {
+	return m
+}
+
+func (m *SnippetModel) Insert(userID int, title, content, language string, private bool, expires int) (int, error) {
 	return 2, nil
 }
+
+func (m *SnippetModel) InsertUntil(userID int, title, content, language string, private bool, expires time.Time) (int, error) {
+	return 2, nil
+}
+
 func (m *SnippetModel) Get(id int) (models.Snippet, error) {
 	switch id {
 	case 1:
 		return mockSnippet, nil
+	case 3:
+		return mockHTMLSnippet, nil
+	case 4:
+		return mockLargeSnippet, nil
+	case 5:
+		return mockPrivateSnippet, nil
 	default:
 		return models.Snippet{}, models.ErrNoRecord
 	}
 }
-func (m *SnippetModel) Latest() ([]models.Snippet, error) {
+
+func (m *SnippetModel) SetTags(id int, tags []string) error {
+	return nil
+}
+
+func (m *SnippetModel) TagsFor(ids []int) (map[int][]string, error) {
+	return map[int][]string{}, nil
+}
+
+func (m *SnippetModel) ContentReader(id, viewerID int) (io.ReadSeeker, error) {
+	s, err := m.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Private && s.UserID != viewerID {
+		return nil, models.ErrNoRecord
+	}
+
+	return strings.NewReader(s.Content), nil
+}
+func (m *SnippetModel) Latest(limit int) ([]models.Snippet, error) {
+	if limit < 1 {
+		return nil, nil
+	}
+
+	return []models.Snippet{mockSnippet}, nil
+}
+
+// LatestByUser lists the mock snippet, which belongs to user 1, for user 1.
+func (m *SnippetModel) LatestByUser(userID, limit int, includePrivate bool) ([]models.Snippet, error) {
+	if limit < 1 || userID != mockSnippet.UserID {
+		return nil, nil
+	}
+
 	return []models.Snippet{mockSnippet}, nil
 }
+
+func (m *SnippetModel) Pin(userID, id, maxPins int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var n int
+	for _, p := range m.pins {
+		if p.userID != userID {
+			continue
+		}
+
+		if p.snippetID == id {
+			return nil
+		}
+
+		n++
+	}
+
+	if n >= maxPins {
+		return models.ErrTooManyPins
+	}
+
+	m.pins = append(m.pins, pin{userID: userID, snippetID: id})
+
+	return nil
+}
+
+func (m *SnippetModel) Unpin(userID, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pins = slices.DeleteFunc(m.pins, func(p pin) bool {
+		return p.userID == userID && p.snippetID == id
+	})
+
+	return nil
+}
+
+func (m *SnippetModel) Pinned(userID int) ([]models.Snippet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var snippets []models.Snippet
+	for _, p := range m.pins {
+		if p.userID != userID {
+			continue
+		}
+
+		s, err := m.Get(p.snippetID)
+		if err == nil && !s.Private {
+			snippets = append(snippets, s)
+		}
+	}
+
+	return snippets, nil
+}
+
+func (m *SnippetModel) ExtendExpiry(id int, expires time.Time) error {
+	return nil
+}
+
+func (m *SnippetModel) TitleExistsForUser(userID int, title string) (bool, error) {
+	return userID == mockSnippet.UserID && title == mockSnippet.Title, nil
+}
+
+func (m *SnippetModel) LastCreatedAt(userID int) (time.Time, error) {
+	return time.Time{}, models.ErrNoRecord
+}
+
+func (m *SnippetModel) OfTheDay(day time.Time) (models.Snippet, error) {
+	return mockSnippet, nil
+}
+
+func (m *SnippetModel) Featured(limit int) ([]models.Snippet, error) {
+	return nil, nil
+}
+
+func (m *SnippetModel) SetFeatured(id int, featured bool) error {
+	s, err := m.Get(id)
+	if err != nil {
+		return err
+	}
+
+	if featured && s.Private {
+		return models.ErrNotFeaturable
+	}
+
+	return nil
+}
+
+func (m *SnippetModel) LatestAfter(after, limit int) ([]models.Snippet, error) {
+	var snippets []models.Snippet
+
+	for _, s := range []models.Snippet{mockHTMLSnippet, mockSnippet} {
+		if after != 0 && s.ID >= after {
+			continue
+		}
+
+		if len(snippets) == limit {
+			break
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	return snippets, nil
+}
+
+func (m *SnippetModel) Between(from, to time.Time, f models.Filters) ([]models.Snippet, int, error) {
+	var matches []models.Snippet
+
+	for _, s := range []models.Snippet{mockSnippet, mockHTMLSnippet} {
+		if !s.Created.Before(from) && s.Created.Before(to) {
+			matches = append(matches, s)
+		}
+	}
+
+	if strings.HasPrefix(f.Sort, "-") {
+		slices.Reverse(matches)
+	}
+
+	total := len(matches)
+	offset := (f.Page - 1) * f.PageSize
+	matches = matches[min(offset, total):min(offset+f.PageSize, total)]
+
+	return matches, total, nil
+}
+
+func (m *SnippetModel) List(f models.Filters) ([]models.Snippet, int, error) {
+	matches := []models.Snippet{mockSnippet, mockHTMLSnippet}
+
+	if strings.HasPrefix(f.Sort, "-") {
+		slices.Reverse(matches)
+	}
+
+	total := len(matches)
+	offset := (f.Page - 1) * f.PageSize
+	matches = matches[min(offset, total):min(offset+f.PageSize, total)]
+
+	return matches, total, nil
+}
+
+func (m *SnippetModel) MonthlyCounts() ([]models.MonthCount, error) {
+	created := mockSnippet.Created.UTC()
+
+	return []models.MonthCount{
+		{Year: created.Year(), Month: created.Month(), Count: 2},
+	}, nil
+}
+
+// Counts counts the mock snippets, which are all created just now.
+func (m *SnippetModel) Counts() (models.SnippetCounts, error) {
+	return models.SnippetCounts{Total: 4, Live: 4, CreatedDay: 4, CreatedWeek: 4}, nil
+}
+
+func (m *SnippetModel) RecordView(id int, referrer string) error {
+	return nil
+}
+
+func (m *SnippetModel) ViewStats(id int) (models.ViewStats, error) {
+	if id != 1 {
+		return models.ViewStats{}, nil
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	return models.ViewStats{
+		Total: 5,
+		Days: []models.DayViews{
+			{Day: today.AddDate(0, 0, -1), Views: 2},
+			{Day: today, Views: 3},
+		},
+		Referrers: []models.ReferrerViews{
+			{Referrer: "news.example.com", Views: 4},
+		},
+	}, nil
+}
+
+func (m *SnippetModel) Bulk(action string, ids []int) ([]models.BulkResult, error) {
+	status := map[string]string{
+		models.BulkDelete: "deleted",
+		models.BulkHide:   "hidden",
+		models.BulkUnhide: "unhidden",
+	}[action]
+
+	var results []models.BulkResult
+
+	for _, id := range ids {
+		if _, err := m.Get(id); err != nil {
+			results = append(results, models.BulkResult{ID: id, Status: "not_found"})
+		} else {
+			results = append(results, models.BulkResult{ID: id, Status: status})
+		}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,32 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/models"
+)
+
+const AliceToken = "ALICEALICEALICEALICEALICE2"
+
+type TokenModel struct{}
+
+func (m *TokenModel) New(userID int, ttl time.Duration, scope string) (*models.Token, error) {
+	return &models.Token{
+		Plaintext: AliceToken,
+		UserID:    userID,
+		Expiry:    time.Now().Add(ttl),
+		Scope:     scope,
+	}, nil
+}
+
+func (m *TokenModel) UserID(scope, plaintext string) (int, error) {
+	if (scope == models.ScopeAuthentication || scope == models.ScopeEmailChange) && plaintext == AliceToken {
+		return 1, nil
+	}
+
+	return 0, models.ErrNoRecord
+}
+
+func (m *TokenModel) DeleteAllForUser(scope string, userID int) error {
+	return nil
+}
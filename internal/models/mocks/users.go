@@ -1,20 +1,33 @@
 package mocks
 
 import (
+	"database/sql"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/Vadim-Makhnev/snippetbox/internal/models"
 )
 
-type UserModel struct{}
+// UserModel knows Alice as user 1 and remembers users added with Insert.
+type UserModel struct {
+	mu    sync.Mutex
+	users []models.User
+}
+
+func (m *UserModel) WithTx(tx *sql.Tx) models.UserModelInterface {
+	return m
+}
 
 func (m *UserModel) Get(id int) (*models.User, error) {
 	if id == 1 {
 		u := &models.User{
-			ID:      1,
-			Name:    "Alice",
-			Email:   "alice@example.com",
-			Created: time.Now(),
+			ID:        1,
+			Name:      "Alice",
+			Email:     "alice@example.com",
+			Created:   time.Now(),
+			Activated: true,
 		}
 
 		return u, nil
@@ -23,17 +36,120 @@ func (m *UserModel) Get(id int) (*models.User, error) {
 	return nil, models.ErrNoRecord
 }
 
-func (m *UserModel) Insert(name, email, password string) error {
+// IsAdmin reports Alice as the only admin.
+func (m *UserModel) IsAdmin(id int) (bool, error) {
+	return id == 1, nil
+}
+
+func (m *UserModel) GetByEmail(email string) (*models.User, error) {
+	email = models.NormalizeEmail(email)
+
+	if email == "alice@example.com" {
+		return m.Get(1)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.users {
+		if u.Email == email {
+			return &u, nil
+		}
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+func (m *UserModel) GetMany(ids []int) (map[int]models.User, error) {
+	users := make(map[int]models.User)
+
+	for _, id := range ids {
+		if u, err := m.Get(id); err == nil {
+			users[id] = *u
+		}
+	}
+
+	return users, nil
+}
+
+// Counts counts Alice and any users added with Insert, all activated.
+func (m *UserModel) Counts() (models.UserCounts, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return models.UserCounts{Total: len(m.users) + 1, Activated: len(m.users) + 1}, nil
+}
+
+// Search pages through Alice and any users added with Insert, matching q
+// against their names and emails like the real model. Only sorting by ID is
+// supported.
+func (m *UserModel) Search(q string, f models.Filters) (models.UserPage, error) {
+	alice, _ := m.Get(1)
+
+	m.mu.Lock()
+	all := append([]models.User{*alice}, m.users...)
+	m.mu.Unlock()
+
+	q = strings.ToLower(strings.TrimSpace(q))
+
+	var found []models.User
+
+	for _, u := range all {
+		if strings.Contains(strings.ToLower(u.Name), q) || strings.Contains(u.Email, q) {
+			found = append(found, u)
+		}
+	}
+
+	if f.Sort == "-id" {
+		slices.Reverse(found)
+	}
+
+	p := models.UserPage{Total: len(found)}
+	p.LastPage = f.LastPage(p.Total)
+	p.Page = min(f.Page, p.LastPage)
+
+	start := (p.Page - 1) * f.PageSize
+	p.Users = found[start:min(start+f.PageSize, len(found))]
+
+	return p, nil
+}
+
+func (m *UserModel) Insert(name, email, password string, termsAccepted bool) error {
+	email = models.NormalizeEmail(email)
+
 	switch email {
-	case "dupe@example.com":
+	case "dupe@example.com", "alice@example.com":
 		return models.ErrDuplicateEmail
-	default:
-		return nil
 	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.users {
+		if u.Email == email {
+			return models.ErrDuplicateEmail
+		}
+	}
+
+	u := models.User{
+		ID:        len(m.users) + 2,
+		Name:      name,
+		Email:     email,
+		Created:   time.Now(),
+		Activated: true,
+	}
+
+	if termsAccepted {
+		u.TermsAcceptedAt = u.Created
+	}
+
+	m.users = append(m.users, u)
+
+	return nil
 }
 
 func (m *UserModel) Authenticate(email, password string) (int, error) {
-	if email == "alice@example.com" && password == "pa$$word" {
+	if models.NormalizeEmail(email) == "alice@example.com" && password == "pa$$word" {
 		return 1, nil
 	}
 
@@ -60,3 +176,28 @@ func (m *UserModel) PasswordUpdate(id int, currentPassword, newPassword string)
 
 	return models.ErrNoRecord
 }
+
+func (m *UserModel) SetPendingEmail(id int, currentPassword, newEmail string) error {
+	if id != 1 {
+		return models.ErrNoRecord
+	}
+
+	if currentPassword != "pa$$word" {
+		return models.ErrInvalidCredentials
+	}
+
+	switch models.NormalizeEmail(newEmail) {
+	case "dupe@example.com", "alice@example.com":
+		return models.ErrDuplicateEmail
+	default:
+		return nil
+	}
+}
+
+func (m *UserModel) ConfirmEmail(id int) error {
+	if id != 1 {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	mySQLErrLockWaitTimeout = 1205
+	mySQLErrDeadlock        = 1213
+)
+
+// maxWriteAttempts bounds how many times a write is tried when MySQL reports
+// a deadlock or lock wait timeout.
+const maxWriteAttempts = 3
+
+var retryBaseDelay = 20 * time.Millisecond
+
+func isRetryable(err error) bool {
+	var mySQLError *mysql.MySQLError
+	if errors.As(err, &mySQLError) {
+		return mySQLError.Number == mySQLErrDeadlock || mySQLError.Number == mySQLErrLockWaitTimeout
+	}
+
+	return false
+}
+
+// isConnLost reports whether err means the connection to MySQL was dropped,
+// as happens during a failover.
+func isConnLost(err error) bool {
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn)
+}
+
+// withReconnect runs the read-only fn and runs it once more if the connection
+// was dropped; the pool discards the broken connection, so the second attempt
+// gets a fresh one. If the connection is lost again the error is wrapped in
+// ErrConnLost. Writes must not use this, since a dropped write may still have
+// been applied.
+func withReconnect(fn func() error) error {
+	err := fn()
+	if isConnLost(err) {
+		err = fn()
+	}
+
+	if isConnLost(err) {
+		return fmt.Errorf("%w: %w", ErrConnLost, err)
+	}
+
+	return err
+}
+
+// withRetry runs fn, retrying with jittered exponential backoff while it fails
+// with a deadlock or lock wait timeout. Any other error is returned at once,
+// and the last error is returned once all attempts are used up. A dropped
+// connection is not retried but is wrapped in ErrConnLost.
+func withRetry(fn func() error) error {
+	var err error
+
+	for attempt := 1; attempt <= maxWriteAttempts; attempt++ {
+		err = fn()
+		if isConnLost(err) {
+			return fmt.Errorf("%w: %w", ErrConnLost, err)
+		}
+
+		if err == nil || !isRetryable(err) {
+			return err
+		}
+
+		if attempt < maxWriteAttempts {
+			delay := retryBaseDelay << (attempt - 1)
+			time.Sleep(delay + rand.N(delay))
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/assert"
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestWithRetry(t *testing.T) {
+	defaultDelay := retryBaseDelay
+	retryBaseDelay = time.Millisecond
+	t.Cleanup(func() { retryBaseDelay = defaultDelay })
+
+	deadlock := &mysql.MySQLError{Number: mySQLErrDeadlock, Message: "Deadlock found when trying to get lock"}
+	lockWait := &mysql.MySQLError{Number: mySQLErrLockWaitTimeout, Message: "Lock wait timeout exceeded"}
+	duplicate := &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}
+
+	tests := []struct {
+		name      string
+		errs      []error
+		wantErr   error
+		wantCalls int
+	}{
+		{
+			name:      "Deadlock twice then success",
+			errs:      []error{deadlock, deadlock, nil},
+			wantErr:   nil,
+			wantCalls: 3,
+		},
+		{
+			name:      "Lock wait timeout then success",
+			errs:      []error{lockWait, nil},
+			wantErr:   nil,
+			wantCalls: 2,
+		},
+		{
+			name:      "Retries exhausted",
+			errs:      []error{deadlock, deadlock, deadlock, nil},
+			wantErr:   deadlock,
+			wantCalls: 3,
+		},
+		{
+			name:      "Non-retryable error",
+			errs:      []error{duplicate, nil},
+			wantErr:   duplicate,
+			wantCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+
+			err := withRetry(func() error {
+				err := tt.errs[calls]
+				calls++
+				return err
+			})
+
+			assert.Equal(t, errors.Is(err, tt.wantErr), true)
+			assert.Equal(t, calls, tt.wantCalls)
+		})
+	}
+}
+
+func TestWithReconnect(t *testing.T) {
+	tests := []struct {
+		name      string
+		errs      []error
+		wantErr   error
+		wantCalls int
+		wantLost  bool
+	}{
+		{
+			name:      "Bad connection then success",
+			errs:      []error{driver.ErrBadConn, nil},
+			wantErr:   nil,
+			wantCalls: 2,
+		},
+		{
+			name:      "Invalid connection then success",
+			errs:      []error{mysql.ErrInvalidConn, nil},
+			wantErr:   nil,
+			wantCalls: 2,
+		},
+		{
+			name:      "Connection lost twice",
+			errs:      []error{driver.ErrBadConn, driver.ErrBadConn, nil},
+			wantErr:   driver.ErrBadConn,
+			wantCalls: 2,
+			wantLost:  true,
+		},
+		{
+			name:      "Other error",
+			errs:      []error{sql.ErrNoRows, nil},
+			wantErr:   sql.ErrNoRows,
+			wantCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+
+			err := withReconnect(func() error {
+				err := tt.errs[calls]
+				calls++
+				return err
+			})
+
+			assert.Equal(t, errors.Is(err, tt.wantErr), true)
+			assert.Equal(t, errors.Is(err, ErrConnLost), tt.wantLost)
+			assert.Equal(t, calls, tt.wantCalls)
+		})
+	}
+}
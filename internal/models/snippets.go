@@ -3,32 +3,197 @@ package models
 import (
 	"database/sql"
 	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"slices"
+	"strings"
 	"time"
+	"unicode"
 )
 
 type Snippet struct {
-	ID      int
-	Title   string
-	Content string
-	Created time.Time
-	Expires time.Time
+	ID       int       `json:"id"`
+	UserID   int       `json:"user_id"`
+	Title    string    `json:"title"`
+	Content  string    `json:"content"`
+	Language string    `json:"language"`
+	Private  bool      `json:"private"`
+	Tags     []string  `json:"tags"`
+	Created  time.Time `json:"created"`
+	Expires  time.Time `json:"expires"`
+}
+
+// MonthCount is the number of live snippets created in one calendar month,
+// in UTC.
+type MonthCount struct {
+	Year  int
+	Month time.Month
+	Count int
+}
+
+// Start returns the first day of the month.
+func (c MonthCount) Start() time.Time {
+	return time.Date(c.Year, c.Month, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// End returns the last day of the month.
+func (c MonthCount) End() time.Time {
+	return c.Start().AddDate(0, 1, -1)
+}
+
+// ViewStats summarises who viewed a snippet and when, without identifying
+// any viewer.
+type ViewStats struct {
+	Total     int
+	Days      []DayViews
+	Referrers []ReferrerViews
+}
+
+// DayViews is the number of views on one UTC day.
+type DayViews struct {
+	Day   time.Time
+	Views int
+}
+
+// ReferrerViews is the number of views referred by one site.
+type ReferrerViews struct {
+	Referrer string
+	Views    int
+}
+
+// SnippetCounts are aggregate numbers of snippets, hidden and private ones
+// included.
+type SnippetCounts struct {
+	Total       int `json:"total"`
+	Live        int `json:"live"`
+	Expired     int `json:"expired"`
+	CreatedDay  int `json:"created_last_24h"`
+	CreatedWeek int `json:"created_last_7d"`
+}
+
+// Bulk actions an admin can apply to many snippets at once.
+const (
+	BulkDelete = "delete"
+	BulkHide   = "hide"
+	BulkUnhide = "unhide"
+)
+
+// BulkResult is the outcome of a bulk action for one snippet: the action's
+// past tense, such as "deleted", or "not_found".
+type BulkResult struct {
+	ID     int    `json:"id"`
+	Status string `json:"status"`
 }
 
 type SnippetModel struct {
 	DB *sql.DB
+
+	// Cipher, when set, encrypts the content of private snippets at rest.
+	Cipher *ContentCipher
+
+	// tx, set by WithTx, is the caller's transaction the statements run in.
+	tx *sql.Tx
 }
 
 type SnippetModelInterface interface {
-	Insert(title string, content string, expires int) (int, error)
+	WithTx(tx *sql.Tx) SnippetModelInterface
+	Insert(userID int, title, content, language string, private bool, expires int) (int, error)
+	InsertUntil(userID int, title, content, language string, private bool, expires time.Time) (int, error)
 	Get(id int) (Snippet, error)
-	Latest() ([]Snippet, error)
+	SetTags(id int, tags []string) error
+	TagsFor(ids []int) (map[int][]string, error)
+	ContentReader(id, viewerID int) (io.ReadSeeker, error)
+	Latest(limit int) ([]Snippet, error)
+	LatestByUser(userID, limit int, includePrivate bool) ([]Snippet, error)
+	Pin(userID, id, maxPins int) error
+	Unpin(userID, id int) error
+	Pinned(userID int) ([]Snippet, error)
+	OfTheDay(day time.Time) (Snippet, error)
+	Featured(limit int) ([]Snippet, error)
+	SetFeatured(id int, featured bool) error
+	LatestAfter(after, limit int) ([]Snippet, error)
+	Between(from, to time.Time, f Filters) ([]Snippet, int, error)
+	List(f Filters) ([]Snippet, int, error)
+	ExtendExpiry(id int, expires time.Time) error
+	TitleExistsForUser(userID int, title string) (bool, error)
+	LastCreatedAt(userID int) (time.Time, error)
+	MonthlyCounts() ([]MonthCount, error)
+	Counts() (SnippetCounts, error)
+	RecordView(id int, referrer string) error
+	ViewStats(id int) (ViewStats, error)
+	Bulk(action string, ids []int) ([]BulkResult, error)
 }
 
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
-	stmt := `INSERT INTO snippets (title, content, created, expires)
-	VALUES (?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+// WithTx returns a copy of the model whose statements run in tx, so that
+// several writes, such as Insert and SetTags, persist together or not at all.
+// Committing is up to the caller. A write in tx is not retried on a deadlock,
+// which has rolled the whole transaction back; Pin and Bulk still run in
+// transactions of their own.
+func (m *SnippetModel) WithTx(tx *sql.Tx) SnippetModelInterface {
+	return &SnippetModel{DB: m.DB, Cipher: m.Cipher, tx: tx}
+}
+
+// db returns what the model's statements run on: its transaction if it has
+// one, or else the pool.
+func (m *SnippetModel) db() DBTX {
+	if m.tx != nil {
+		return m.tx
+	}
 
-	result, err := m.DB.Exec(stmt, title, content, expires)
+	return m.DB
+}
+
+// retry runs a write with withRetry, or just once in a transaction.
+func (m *SnippetModel) retry(fn func() error) error {
+	if m.tx != nil {
+		return fn()
+	}
+
+	return withRetry(fn)
+}
+
+func (m *SnippetModel) Insert(userID int, title, content, language string, private bool, expires int) (int, error) {
+	stmt := `INSERT INTO snippets (user_id, title, content, language, private, encrypted, created, expires)
+	VALUES (?, ?, ?, ?, ?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+
+	return m.insert(stmt, userID, title, content, language, private, expires)
+}
+
+// InsertUntil is Insert for a snippet that expires at an exact time rather
+// than after a number of days.
+func (m *SnippetModel) InsertUntil(userID int, title, content, language string, private bool, expires time.Time) (int, error) {
+	stmt := `INSERT INTO snippets (user_id, title, content, language, private, encrypted, created, expires)
+	VALUES (?, ?, ?, ?, ?, ?, UTC_TIMESTAMP(), ?)`
+
+	return m.insert(stmt, userID, title, content, language, private, expires.UTC())
+}
+
+// insert runs an insert statement whose placeholders start with user ID,
+// title, content, language, private and encrypted, followed by the expiry.
+// Control characters are stripped from the title; content containing them
+// is expected to have been rejected by validation.
+// The content of a private snippet is encrypted first when a Cipher is set;
+// public snippets stay plaintext so they remain searchable.
+func (m *SnippetModel) insert(stmt string, userID int, title, content, language string, private bool, expires any) (int, error) {
+	title = stripControlChars(title)
+
+	encrypted := private && m.Cipher != nil
+	if encrypted {
+		var err error
+		content, err = m.Cipher.Encrypt(content)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	var result sql.Result
+
+	err := m.retry(func() error {
+		var err error
+		result, err = m.db().Exec(stmt, userID, title, content, language, private, encrypted, expires)
+		return err
+	})
 	if err != nil {
 		return 0, err
 	}
@@ -41,13 +206,516 @@ func (m *SnippetModel) Insert(title string, content string, expires int) (int, e
 	return int(id), nil
 }
 
+// stripControlChars removes control characters such as null bytes from s,
+// keeping tabs, newlines and carriage returns.
+func stripControlChars(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && r != '\t' && r != '\n' && r != '\r' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// decrypt returns content as stored, decrypting it if the row is flagged as
+// encrypted. Rows written before encryption was enabled are plaintext.
+func (m *SnippetModel) decrypt(content string, encrypted bool) (string, error) {
+	if !encrypted {
+		return content, nil
+	}
+
+	if m.Cipher == nil {
+		return "", fmt.Errorf("%w: no content encryption key is configured", ErrDecrypt)
+	}
+
+	return m.Cipher.Decrypt(content)
+}
+
 func (m *SnippetModel) Get(id int) (Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-	WHERE expires > UTC_TIMESTAMP() AND id = ?`
+	stmt := `SELECT id, COALESCE(user_id, 0), title, content, language, private, encrypted, created, expires FROM snippets
+	WHERE expires > UTC_TIMESTAMP() AND NOT hidden AND id = ?`
+
+	var (
+		s         Snippet
+		encrypted bool
+	)
+
+	err := withReconnect(func() error {
+		return m.db().QueryRow(stmt, id).Scan(&s.ID, &s.UserID, &s.Title, &s.Content, &s.Language, &s.Private, &encrypted, &s.Created, &s.Expires)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Snippet{}, ErrNoRecord
+		} else {
+			return Snippet{}, err
+		}
+	}
+
+	s.Content, err = m.decrypt(s.Content, encrypted)
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	s.Tags, err = m.tags(id)
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	return s, nil
+}
+
+// tags returns a snippet's tags in alphabetical order.
+func (m *SnippetModel) tags(id int) ([]string, error) {
+	var rows *sql.Rows
+
+	err := withReconnect(func() (err error) {
+		rows, err = m.db().Query("SELECT tag FROM snippet_tags WHERE snippet_id = ? ORDER BY tag", id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var tags []string
+
+	for rows.Next() {
+		var tag string
+
+		err = rows.Scan(&tag)
+		if err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, tag)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// TagsFor fetches the tags of all the given snippets in a single query,
+// keyed by snippet ID. Snippets without tags are absent from the returned
+// map.
+func (m *SnippetModel) TagsFor(ids []int) (map[int][]string, error) {
+	tags := make(map[int][]string, len(ids))
+
+	if len(ids) == 0 {
+		return tags, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	stmt := "SELECT snippet_id, tag FROM snippet_tags WHERE snippet_id IN (" + placeholders + ") ORDER BY snippet_id, tag"
+
+	var rows *sql.Rows
+
+	err := withReconnect(func() (err error) {
+		rows, err = m.db().Query(stmt, args...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var tag string
+
+		err = rows.Scan(&id, &tag)
+		if err != nil {
+			return nil, err
+		}
+
+		tags[id] = append(tags[id], tag)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// SetTags replaces a snippet's tags. Tags are expected to be normalized and
+// free of duplicates already.
+func (m *SnippetModel) SetTags(id int, tags []string) error {
+	if m.tx != nil {
+		return setTags(m.tx, id, tags)
+	}
+
+	return withRetry(func() error {
+		tx, err := m.DB.Begin()
+		if err != nil {
+			return err
+		}
+
+		defer tx.Rollback()
+
+		err = setTags(tx, id, tags)
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+}
+
+func setTags(db DBTX, id int, tags []string) error {
+	_, err := db.Exec("DELETE FROM snippet_tags WHERE snippet_id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		_, err = db.Exec("INSERT INTO snippet_tags (snippet_id, tag) VALUES (?, ?)", id, tag)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Pin pins one of the user's snippets to their profile. Pinning a snippet
+// that is already pinned does nothing. It returns ErrTooManyPins if the user
+// already has maxPins pinned snippets; their pins are locked while counting,
+// so concurrent pins can't exceed the cap. Whether the snippet is the user's
+// is for the caller to check.
+func (m *SnippetModel) Pin(userID, id, maxPins int) error {
+	if m.tx != nil {
+		return pin(m.tx, userID, id, maxPins)
+	}
+
+	return withRetry(func() error {
+		tx, err := m.DB.Begin()
+		if err != nil {
+			return err
+		}
+
+		defer tx.Rollback()
+
+		err = pin(tx, userID, id, maxPins)
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+}
+
+func pin(db DBTX, userID, id, maxPins int) error {
+	rows, err := db.Query("SELECT snippet_id FROM snippet_pins WHERE user_id = ? FOR UPDATE", userID)
+	if err != nil {
+		return err
+	}
+
+	var pinned []int
+
+	for rows.Next() {
+		var snippetID int
+
+		err = rows.Scan(&snippetID)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+
+		pinned = append(pinned, snippetID)
+	}
+
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if slices.Contains(pinned, id) {
+		return nil
+	}
+
+	if len(pinned) >= maxPins {
+		return ErrTooManyPins
+	}
+
+	_, err = db.Exec("INSERT INTO snippet_pins (snippet_id, user_id, pinned) VALUES (?, ?, UTC_TIMESTAMP(6))", id, userID)
+	return err
+}
+
+// Unpin removes the user's snippet from their profile. Unpinning a snippet
+// that isn't pinned does nothing.
+func (m *SnippetModel) Unpin(userID, id int) error {
+	return m.retry(func() error {
+		_, err := m.db().Exec("DELETE FROM snippet_pins WHERE snippet_id = ? AND user_id = ?", id, userID)
+		return err
+	})
+}
+
+// Pinned returns the user's pinned snippets that are live and public, in
+// the order they were pinned.
+func (m *SnippetModel) Pinned(userID int) ([]Snippet, error) {
+	stmt := `SELECT s.id, COALESCE(s.user_id, 0), s.title, s.content, s.language, s.created, s.expires
+	FROM snippet_pins p JOIN snippets s ON s.id = p.snippet_id
+	WHERE p.user_id = ? AND s.expires > UTC_TIMESTAMP() AND NOT s.hidden AND NOT s.private
+	ORDER BY p.pinned, s.id`
+
+	var rows *sql.Rows
+
+	err := withReconnect(func() (err error) {
+		rows, err = m.db().Query(stmt, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var snippets []Snippet
+
+	for rows.Next() {
+		var s Snippet
+
+		err = rows.Scan(&s.ID, &s.UserID, &s.Title, &s.Content, &s.Language, &s.Created, &s.Expires)
+		if err != nil {
+			return nil, err
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// contentChunkBytes is how many bytes of content a snippet content reader
+// fetches per query.
+const contentChunkBytes = 64 * 1024
+
+// ContentReader returns a reader over a live snippet's content that fetches
+// it from the database one chunk at a time, so serving a large snippet never
+// holds all of it in memory. The reader can seek, for serving byte ranges.
+// The first chunk is read up front, which means a missing or expired
+// snippet, or a private one that viewerID does not own, is reported here as
+// ErrNoRecord. Encrypted content is read and decrypted whole, since it
+// cannot be decrypted a chunk at a time.
+func (m *SnippetModel) ContentReader(id, viewerID int) (io.ReadSeeker, error) {
+	cr := &contentReader{db: m.DB, id: id}
+
+	var (
+		userID             int
+		private, encrypted bool
+	)
+
+	err := withReconnect(func() error {
+		return m.db().QueryRow(`SELECT COALESCE(user_id, 0), private, encrypted, LENGTH(content),
+		IF(encrypted, content, SUBSTRING(CAST(content AS BINARY), 1, ?)) FROM snippets
+		WHERE expires > UTC_TIMESTAMP() AND NOT hidden AND id = ?`, contentChunkBytes, id).Scan(&userID, &private, &encrypted, &cr.size, &cr.buf)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	if private && userID != viewerID {
+		return nil, ErrNoRecord
+	}
+
+	if encrypted {
+		content, err := m.decrypt(string(cr.buf), true)
+		if err != nil {
+			return nil, err
+		}
+
+		return strings.NewReader(content), nil
+	}
+
+	return cr, nil
+}
+
+// contentReader reads snippet content in chunks of bytes, starting at off.
+// buf holds the fetched content from off onwards. The content is cast to
+// binary so that SUBSTRING counts bytes rather than characters; chunks may
+// split a multi-byte character, which the reader's consumer reassembles.
+type contentReader struct {
+	db   *sql.DB
+	id   int
+	size int64
+	off  int64
+	buf  []byte
+}
+
+func (cr *contentReader) Read(p []byte) (int, error) {
+	if len(cr.buf) == 0 {
+		if cr.off >= cr.size {
+			return 0, io.EOF
+		}
+
+		err := withReconnect(func() error {
+			return cr.db.QueryRow(`SELECT SUBSTRING(CAST(content AS BINARY), ?, ?) FROM snippets WHERE id = ?`,
+				cr.off+1, contentChunkBytes, cr.id).Scan(&cr.buf)
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		// The snippet was shortened or deleted since the reader was made.
+		if len(cr.buf) == 0 {
+			return 0, io.EOF
+		}
+	}
+
+	n := copy(p, cr.buf)
+	cr.buf = cr.buf[n:]
+	cr.off += int64(n)
+
+	return n, nil
+}
+
+func (cr *contentReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += cr.off
+	case io.SeekEnd:
+		offset += cr.size
+	}
+
+	if offset < 0 {
+		return 0, errors.New("models: seek to a negative position")
+	}
+
+	if offset != cr.off {
+		cr.off = offset
+		cr.buf = nil
+	}
+
+	return offset, nil
+}
+
+func (m *SnippetModel) Latest(limit int) ([]Snippet, error) {
+	stmt := `SELECT id, COALESCE(user_id, 0), title, content, language, created, expires FROM snippets
+	WHERE expires > UTC_TIMESTAMP() AND NOT hidden AND NOT private ORDER BY id DESC LIMIT ?`
+
+	var rows *sql.Rows
+
+	err := withReconnect(func() (err error) {
+		rows, err = m.db().Query(stmt, limit)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var snippets []Snippet
+
+	for rows.Next() {
+		var s Snippet
+
+		err = rows.Scan(&s.ID, &s.UserID, &s.Title, &s.Content, &s.Language, &s.Created, &s.Expires)
+		if err != nil {
+			return nil, err
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// LatestByUser returns up to limit of the user's live snippets, newest
+// first, leaving out ones hidden by an admin. With includePrivate, for the
+// user themselves, their private snippets are included, decrypted.
+func (m *SnippetModel) LatestByUser(userID, limit int, includePrivate bool) ([]Snippet, error) {
+	stmt := `SELECT id, COALESCE(user_id, 0), title, content, language, private, encrypted, created, expires FROM snippets
+	WHERE user_id = ? AND expires > UTC_TIMESTAMP() AND NOT hidden AND (? OR NOT private) ORDER BY id DESC LIMIT ?`
+
+	var rows *sql.Rows
+
+	err := withReconnect(func() (err error) {
+		rows, err = m.db().Query(stmt, userID, includePrivate, limit)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var snippets []Snippet
+
+	for rows.Next() {
+		var s Snippet
+		var encrypted bool
+
+		err = rows.Scan(&s.ID, &s.UserID, &s.Title, &s.Content, &s.Language, &s.Private, &encrypted, &s.Created, &s.Expires)
+		if err != nil {
+			return nil, err
+		}
+
+		s.Content, err = m.decrypt(s.Content, encrypted)
+		if err != nil {
+			return nil, err
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// OfTheDay picks one live snippet for the given day. The choice is seeded by
+// the calendar date, so every call on the same day returns the same snippet.
+func (m *SnippetModel) OfTheDay(day time.Time) (Snippet, error) {
+	var count int
+
+	err := withReconnect(func() error {
+		return m.db().QueryRow(`SELECT COUNT(*) FROM snippets WHERE expires > UTC_TIMESTAMP() AND NOT hidden AND NOT private`).Scan(&count)
+	})
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	if count == 0 {
+		return Snippet{}, ErrNoRecord
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(day.UTC().Format("2006-01-02")))
+	offset := int(h.Sum32() % uint32(count))
+
+	stmt := `SELECT id, COALESCE(user_id, 0), title, content, language, created, expires FROM snippets
+	WHERE expires > UTC_TIMESTAMP() AND NOT hidden AND NOT private ORDER BY id LIMIT 1 OFFSET ?`
 
 	var s Snippet
 
-	err := m.DB.QueryRow(stmt, id).Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	err = withReconnect(func() error {
+		return m.db().QueryRow(stmt, offset).Scan(&s.ID, &s.UserID, &s.Title, &s.Content, &s.Language, &s.Created, &s.Expires)
+	})
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return Snippet{}, ErrNoRecord
@@ -55,14 +723,86 @@ func (m *SnippetModel) Get(id int) (Snippet, error) {
 			return Snippet{}, err
 		}
 	}
+
 	return s, nil
 }
 
-func (m *SnippetModel) Latest() ([]Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-	WHERE expires > UTC_TIMESTAMP() ORDER BY id DESC LIMIT 10`
+// Featured returns up to limit featured snippets, newest first. Featured
+// snippets that have since expired or been hidden drop out.
+func (m *SnippetModel) Featured(limit int) ([]Snippet, error) {
+	stmt := `SELECT id, COALESCE(user_id, 0), title, content, language, created, expires FROM snippets
+	WHERE featured AND expires > UTC_TIMESTAMP() AND NOT hidden AND NOT private ORDER BY id DESC LIMIT ?`
+
+	var rows *sql.Rows
+
+	err := withReconnect(func() (err error) {
+		rows, err = m.db().Query(stmt, limit)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var snippets []Snippet
+
+	for rows.Next() {
+		var s Snippet
+
+		err = rows.Scan(&s.ID, &s.UserID, &s.Title, &s.Content, &s.Language, &s.Created, &s.Expires)
+		if err != nil {
+			return nil, err
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// SetFeatured features or un-features a snippet. Only a live, public snippet
+// can be featured; featuring an expired or private one returns
+// ErrNotFeaturable. Any snippet can be un-featured.
+func (m *SnippetModel) SetFeatured(id int, featured bool) error {
+	var private, live bool
 
-	rows, err := m.DB.Query(stmt)
+	err := withReconnect(func() error {
+		return m.db().QueryRow(`SELECT private, expires > UTC_TIMESTAMP() FROM snippets WHERE id = ?`, id).Scan(&private, &live)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	if featured && (private || !live) {
+		return ErrNotFeaturable
+	}
+
+	return m.retry(func() error {
+		_, err := m.db().Exec(`UPDATE snippets SET featured = ? WHERE id = ?`, featured, id)
+		return err
+	})
+}
+
+// LatestAfter returns up to limit live snippets, newest first, whose ID is
+// lower than the after cursor. An after value of 0 starts from the newest.
+func (m *SnippetModel) LatestAfter(after, limit int) ([]Snippet, error) {
+	stmt := `SELECT id, COALESCE(user_id, 0), title, content, language, created, expires FROM snippets
+	WHERE expires > UTC_TIMESTAMP() AND NOT hidden AND NOT private AND (? = 0 OR id < ?) ORDER BY id DESC LIMIT ?`
+
+	var rows *sql.Rows
+
+	err := withReconnect(func() (err error) {
+		rows, err = m.db().Query(stmt, after, after, limit)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -74,7 +814,7 @@ func (m *SnippetModel) Latest() ([]Snippet, error) {
 	for rows.Next() {
 		var s Snippet
 
-		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		err = rows.Scan(&s.ID, &s.UserID, &s.Title, &s.Content, &s.Language, &s.Created, &s.Expires)
 		if err != nil {
 			return nil, err
 		}
@@ -88,3 +828,328 @@ func (m *SnippetModel) Latest() ([]Snippet, error) {
 
 	return snippets, nil
 }
+
+// ExtendExpiry pushes a snippet's expiry back by one day, provided it still
+// expires at the given time. Views racing to extend the same snippet then
+// extend it only once.
+func (m *SnippetModel) ExtendExpiry(id int, expires time.Time) error {
+	stmt := `UPDATE snippets SET expires = DATE_ADD(expires, INTERVAL 1 DAY)
+	WHERE id = ? AND expires = ?`
+
+	return m.retry(func() error {
+		_, err := m.db().Exec(stmt, id, expires.UTC())
+		return err
+	})
+}
+
+// TitleExistsForUser reports whether the user has a live snippet with
+// exactly this title, compared case-sensitively.
+func (m *SnippetModel) TitleExistsForUser(userID int, title string) (bool, error) {
+	var exists bool
+
+	stmt := `SELECT EXISTS(SELECT true FROM snippets
+	WHERE expires > UTC_TIMESTAMP() AND user_id = ? AND BINARY title = ?)`
+
+	err := withReconnect(func() error {
+		return m.db().QueryRow(stmt, userID, title).Scan(&exists)
+	})
+	return exists, err
+}
+
+// LastCreatedAt returns when the user last created a snippet, counting
+// expired and hidden ones. It returns ErrNoRecord if they never have.
+func (m *SnippetModel) LastCreatedAt(userID int) (time.Time, error) {
+	var created sql.NullTime
+
+	stmt := "SELECT MAX(created) FROM snippets WHERE user_id = ?"
+
+	err := withReconnect(func() error {
+		return m.db().QueryRow(stmt, userID).Scan(&created)
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if !created.Valid {
+		return time.Time{}, ErrNoRecord
+	}
+
+	return created.Time, nil
+}
+
+// Between returns a page of live snippets created at or after from and before
+// to, in the order of f.Sort, along with the total number of matching
+// snippets. Snippets created at the same time are ordered by ID.
+func (m *SnippetModel) Between(from, to time.Time, f Filters) ([]Snippet, int, error) {
+	return m.list("created >= ? AND created < ?", []any{from.UTC(), to.UTC()}, f)
+}
+
+// List returns a page of all live snippets, in the order of f.Sort, along
+// with the total number of them. Hidden and private snippets are left out.
+func (m *SnippetModel) List(f Filters) ([]Snippet, int, error) {
+	return m.list("TRUE", nil, f)
+}
+
+// list runs the queries of Between and List: a page of the live, public
+// snippets also matching cond, whose placeholders take args, and their total.
+func (m *SnippetModel) list(cond string, args []any, f Filters) ([]Snippet, int, error) {
+	var total int
+
+	err := withReconnect(func() error {
+		return m.db().QueryRow(`SELECT COUNT(*) FROM snippets
+		WHERE expires > UTC_TIMESTAMP() AND NOT hidden AND NOT private AND `+cond, args...).Scan(&total)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stmt := fmt.Sprintf(`SELECT id, COALESCE(user_id, 0), title, content, language, created, expires FROM snippets
+	WHERE expires > UTC_TIMESTAMP() AND NOT hidden AND NOT private AND %s
+	ORDER BY %s %s, id %[3]s LIMIT ? OFFSET ?`, cond, f.sortColumn(), f.sortDirection())
+
+	var rows *sql.Rows
+
+	err = withReconnect(func() (err error) {
+		rows, err = m.db().Query(stmt, append(args, f.limit(), f.offset())...)
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer rows.Close()
+
+	var snippets []Snippet
+
+	for rows.Next() {
+		var s Snippet
+
+		err = rows.Scan(&s.ID, &s.UserID, &s.Title, &s.Content, &s.Language, &s.Created, &s.Expires)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return snippets, total, nil
+}
+
+// MonthlyCounts returns the number of live snippets created in each month,
+// newest month first. Created times are stored in UTC, so months are UTC
+// months regardless of the connection time zone.
+func (m *SnippetModel) MonthlyCounts() ([]MonthCount, error) {
+	stmt := `SELECT YEAR(created), MONTH(created), COUNT(*) FROM snippets
+	WHERE expires > UTC_TIMESTAMP() AND NOT hidden AND NOT private
+	GROUP BY YEAR(created), MONTH(created)
+	ORDER BY YEAR(created) DESC, MONTH(created) DESC`
+
+	var rows *sql.Rows
+
+	err := withReconnect(func() (err error) {
+		rows, err = m.db().Query(stmt)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var counts []MonthCount
+
+	for rows.Next() {
+		var c MonthCount
+
+		err = rows.Scan(&c.Year, &c.Month, &c.Count)
+		if err != nil {
+			return nil, err
+		}
+
+		counts = append(counts, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// Counts returns the number of snippets in total, live and expired, and how
+// many were created in the last day and week.
+func (m *SnippetModel) Counts() (SnippetCounts, error) {
+	stmt := `SELECT COUNT(*),
+	COALESCE(SUM(expires > UTC_TIMESTAMP()), 0),
+	COALESCE(SUM(expires <= UTC_TIMESTAMP()), 0),
+	COALESCE(SUM(created > DATE_SUB(UTC_TIMESTAMP(), INTERVAL 1 DAY)), 0),
+	COALESCE(SUM(created > DATE_SUB(UTC_TIMESTAMP(), INTERVAL 7 DAY)), 0)
+	FROM snippets`
+
+	var c SnippetCounts
+
+	err := withReconnect(func() error {
+		return m.db().QueryRow(stmt).Scan(&c.Total, &c.Live, &c.Expired, &c.CreatedDay, &c.CreatedWeek)
+	})
+	if err != nil {
+		return SnippetCounts{}, err
+	}
+
+	return c, nil
+}
+
+// RecordView stores a view of the snippet with the referring site, which
+// should be a bare host name or empty. Nothing identifying the viewer is kept.
+func (m *SnippetModel) RecordView(id int, referrer string) error {
+	stmt := `INSERT INTO snippet_views (snippet_id, viewed, referrer)
+	VALUES (?, UTC_TIMESTAMP(), ?)`
+
+	return m.retry(func() error {
+		_, err := m.db().Exec(stmt, id, referrer)
+		return err
+	})
+}
+
+// ViewStats returns the snippet's total views, its views per UTC day over the
+// last 30 days, oldest first and skipping days without views, and the ten
+// sites that referred the most views.
+func (m *SnippetModel) ViewStats(id int) (ViewStats, error) {
+	var stats ViewStats
+
+	err := withReconnect(func() error {
+		return m.db().QueryRow("SELECT COUNT(*) FROM snippet_views WHERE snippet_id = ?", id).Scan(&stats.Total)
+	})
+	if err != nil {
+		return ViewStats{}, err
+	}
+
+	stmt := `SELECT DATE(viewed), COUNT(*) FROM snippet_views
+	WHERE snippet_id = ? AND viewed >= DATE_SUB(UTC_DATE(), INTERVAL 29 DAY)
+	GROUP BY DATE(viewed)
+	ORDER BY DATE(viewed)`
+
+	var rows *sql.Rows
+
+	err = withReconnect(func() (err error) {
+		rows, err = m.db().Query(stmt, id)
+		return err
+	})
+	if err != nil {
+		return ViewStats{}, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var d DayViews
+
+		err = rows.Scan(&d.Day, &d.Views)
+		if err != nil {
+			return ViewStats{}, err
+		}
+
+		stats.Days = append(stats.Days, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return ViewStats{}, err
+	}
+
+	stmt = `SELECT referrer, COUNT(*) FROM snippet_views
+	WHERE snippet_id = ? AND referrer <> ''
+	GROUP BY referrer
+	ORDER BY COUNT(*) DESC, referrer LIMIT 10`
+
+	var referrerRows *sql.Rows
+
+	err = withReconnect(func() (err error) {
+		referrerRows, err = m.db().Query(stmt, id)
+		return err
+	})
+	if err != nil {
+		return ViewStats{}, err
+	}
+
+	defer referrerRows.Close()
+
+	for referrerRows.Next() {
+		var rv ReferrerViews
+
+		err = referrerRows.Scan(&rv.Referrer, &rv.Views)
+		if err != nil {
+			return ViewStats{}, err
+		}
+
+		stats.Referrers = append(stats.Referrers, rv)
+	}
+
+	if err = referrerRows.Err(); err != nil {
+		return ViewStats{}, err
+	}
+
+	return stats, nil
+}
+
+// Bulk applies one of the Bulk actions to each of the snippets in a single
+// transaction, expired and hidden snippets included, and reports what became
+// of each ID. IDs with no snippet are reported as not found without failing
+// the rest; any other error rolls the whole batch back.
+func (m *SnippetModel) Bulk(action string, ids []int) ([]BulkResult, error) {
+	var stmt, status string
+
+	switch action {
+	case BulkDelete:
+		stmt, status = "DELETE FROM snippets WHERE id = ?", "deleted"
+	case BulkHide:
+		stmt, status = "UPDATE snippets SET hidden = TRUE WHERE id = ?", "hidden"
+	case BulkUnhide:
+		stmt, status = "UPDATE snippets SET hidden = FALSE WHERE id = ?", "unhidden"
+	default:
+		return nil, fmt.Errorf("models: unknown bulk action %q", action)
+	}
+
+	var results []BulkResult
+
+	err := withRetry(func() error {
+		results = nil
+
+		tx, err := m.DB.Begin()
+		if err != nil {
+			return err
+		}
+
+		defer tx.Rollback()
+
+		for _, id := range ids {
+			var count int
+
+			err = tx.QueryRow("SELECT COUNT(*) FROM snippets WHERE id = ? FOR UPDATE", id).Scan(&count)
+			if err != nil {
+				return err
+			}
+
+			if count == 0 {
+				results = append(results, BulkResult{ID: id, Status: "not_found"})
+				continue
+			}
+
+			_, err = tx.Exec(stmt, id)
+			if err != nil {
+				return err
+			}
+
+			results = append(results, BulkResult{ID: id, Status: status})
+		}
+
+		return tx.Commit()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
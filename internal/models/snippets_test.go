@@ -0,0 +1,808 @@
+package models
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/assert"
+)
+
+func TestSnippetModelOfTheDay(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{DB: db}
+
+	day := time.Date(2024, 3, 17, 0, 0, 0, 0, time.UTC)
+
+	_, err := m.OfTheDay(day)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	for i := 1; i <= 5; i++ {
+		_, err := m.Insert(1, fmt.Sprintf("Snippet %d", i), "An old silent pond...", "", false, 7)
+		assert.NilError(t, err)
+	}
+
+	first, err := m.OfTheDay(day)
+	assert.NilError(t, err)
+
+	second, err := m.OfTheDay(day.Add(23*time.Hour + 59*time.Minute))
+	assert.NilError(t, err)
+	assert.Equal(t, second.ID, first.ID)
+
+	differs := false
+	for i := 1; i <= 30; i++ {
+		other, err := m.OfTheDay(day.AddDate(0, 0, i))
+		assert.NilError(t, err)
+
+		if other.ID != first.ID {
+			differs = true
+			break
+		}
+	}
+	assert.Equal(t, differs, true)
+}
+
+func TestSnippetModelLatestAfter(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{DB: db}
+
+	for i := 1; i <= 7; i++ {
+		_, err := m.Insert(1, fmt.Sprintf("Snippet %d", i), "An old silent pond...", "", false, 7)
+		assert.NilError(t, err)
+	}
+
+	seen := map[int]int{}
+	after := 0
+	previous := 0
+
+	for {
+		snippets, err := m.LatestAfter(after, 3)
+		assert.NilError(t, err)
+
+		if len(snippets) == 0 {
+			break
+		}
+
+		for _, s := range snippets {
+			if previous != 0 && s.ID >= previous {
+				t.Errorf("got id %d after %d; want strictly descending ids", s.ID, previous)
+			}
+			previous = s.ID
+			seen[s.ID]++
+		}
+
+		after = snippets[len(snippets)-1].ID
+	}
+
+	assert.Equal(t, len(seen), 7)
+	for id, n := range seen {
+		if n != 1 {
+			t.Errorf("snippet %d returned %d times; want 1", id, n)
+		}
+	}
+}
+
+func TestSnippetModelContentReader(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{DB: db}
+
+	_, err := m.ContentReader(1, 0)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	content := strings.Repeat("é", contentChunkBytes+10)
+
+	id, err := m.Insert(1, "Large", content, "", false, 7)
+	assert.NilError(t, err)
+
+	r, err := m.ContentReader(id, 0)
+	assert.NilError(t, err)
+
+	got, err := io.ReadAll(r)
+	assert.NilError(t, err)
+	assert.Equal(t, string(got) == content, true)
+
+	// Seek into the second chunk, mid-way through a character.
+	size, err := r.Seek(0, io.SeekEnd)
+	assert.NilError(t, err)
+	assert.Equal(t, size, int64(len(content)))
+
+	_, err = r.Seek(contentChunkBytes+1, io.SeekStart)
+	assert.NilError(t, err)
+
+	got, err = io.ReadAll(r)
+	assert.NilError(t, err)
+	assert.Equal(t, string(got) == content[contentChunkBytes+1:], true)
+}
+
+func TestSnippetModelExtendExpiry(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{DB: db}
+
+	id, err := m.Insert(1, "Popular", "An old silent pond...", "", false, 1)
+	assert.NilError(t, err)
+
+	before, err := m.Get(id)
+	assert.NilError(t, err)
+
+	err = m.ExtendExpiry(id, before.Expires)
+	assert.NilError(t, err)
+
+	// A second extension based on the stale expiry is a no-op.
+	err = m.ExtendExpiry(id, before.Expires)
+	assert.NilError(t, err)
+
+	after, err := m.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, after.Expires.Sub(before.Expires), 24*time.Hour)
+}
+
+func TestSnippetModelTitleExistsForUser(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{DB: db}
+
+	_, err := m.Insert(1, "O snail", "Climb Mount Fuji,", "", false, 7)
+	assert.NilError(t, err)
+
+	tests := []struct {
+		name   string
+		userID int
+		title  string
+		want   bool
+	}{
+		{name: "Same title", userID: 1, title: "O snail", want: true},
+		{name: "Different case", userID: 1, title: "o snail", want: false},
+		{name: "Other title", userID: 1, title: "An old silent pond", want: false},
+		{name: "Other user", userID: 2, title: "O snail", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exists, err := m.TitleExistsForUser(tt.userID, tt.title)
+			assert.NilError(t, err)
+			assert.Equal(t, exists, tt.want)
+		})
+	}
+}
+
+func TestSnippetModelLatestLimit(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{DB: db}
+
+	for i := 1; i <= 5; i++ {
+		_, err := m.Insert(1, fmt.Sprintf("Snippet %d", i), "An old silent pond...", "", false, 7)
+		assert.NilError(t, err)
+	}
+
+	for _, limit := range []int{1, 3, 10} {
+		snippets, err := m.Latest(limit)
+		assert.NilError(t, err)
+		assert.Equal(t, len(snippets), min(limit, 5))
+	}
+}
+
+func TestSnippetModelBetween(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{DB: db}
+
+	for i := 1; i <= 5; i++ {
+		_, err := m.Insert(1, fmt.Sprintf("Snippet %d", i), "An old silent pond...", "", false, 7)
+		assert.NilError(t, err)
+	}
+
+	now := time.Now().UTC()
+
+	f := Filters{Page: 2, PageSize: 2, Sort: "created", SortSafelist: []string{"created", "-created"}}
+
+	snippets, total, err := m.Between(now.Add(-time.Hour), now.Add(time.Hour), f)
+	assert.NilError(t, err)
+	assert.Equal(t, total, 5)
+	assert.Equal(t, len(snippets), 2)
+	assert.Equal(t, snippets[0].Title, "Snippet 3")
+
+	f.Page, f.Sort = 1, "-created"
+
+	snippets, _, err = m.Between(now.Add(-time.Hour), now.Add(time.Hour), f)
+	assert.NilError(t, err)
+	assert.Equal(t, snippets[0].Title, "Snippet 5")
+
+	snippets, total, err = m.Between(now.AddDate(-1, 0, 0), now.AddDate(0, 0, -1), f)
+	assert.NilError(t, err)
+	assert.Equal(t, total, 0)
+	assert.Equal(t, len(snippets), 0)
+}
+
+func TestSnippetModelList(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{DB: db}
+
+	for i := 1; i <= 3; i++ {
+		_, err := m.Insert(1, fmt.Sprintf("Snippet %d", i), "An old silent pond...", "", false, 7)
+		assert.NilError(t, err)
+	}
+
+	_, err := m.Insert(1, "Private", "A private haiku...", "", true, 7)
+	assert.NilError(t, err)
+
+	f := Filters{Page: 1, PageSize: 2, Sort: "-created", SortSafelist: []string{"created", "-created"}}
+
+	snippets, total, err := m.List(f)
+	assert.NilError(t, err)
+	assert.Equal(t, total, 3)
+	assert.Equal(t, len(snippets), 2)
+	assert.Equal(t, snippets[0].Title, "Snippet 3")
+
+	f.Page = 2
+
+	snippets, _, err = m.List(f)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 1)
+	assert.Equal(t, snippets[0].Title, "Snippet 1")
+}
+
+func TestSnippetModelMonthlyCounts(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{DB: db}
+
+	// 23:30 UTC on 31 January is already February in UTC+1, but the counts
+	// group by the stored UTC time.
+	created := []string{
+		"2024-01-01 00:00:00",
+		"2024-01-31 23:30:00",
+		"2024-02-01 00:30:00",
+		"2024-02-29 23:59:59",
+		"2024-03-01 00:00:00",
+	}
+
+	for _, c := range created {
+		_, err := db.Exec(`INSERT INTO snippets (user_id, title, content, created, expires)
+		VALUES (1, 'Snippet', 'An old silent pond...', ?, DATE_ADD(UTC_TIMESTAMP(), INTERVAL 1 DAY))`, c)
+		assert.NilError(t, err)
+	}
+
+	_, err := db.Exec(`INSERT INTO snippets (user_id, title, content, created, expires)
+	VALUES (1, 'Expired', 'An old silent pond...', '2024-01-15 12:00:00', '2024-01-16 12:00:00')`)
+	assert.NilError(t, err)
+
+	counts, err := m.MonthlyCounts()
+	assert.NilError(t, err)
+
+	want := []MonthCount{
+		{Year: 2024, Month: time.March, Count: 1},
+		{Year: 2024, Month: time.February, Count: 2},
+		{Year: 2024, Month: time.January, Count: 2},
+	}
+
+	assert.Equal(t, len(counts), len(want))
+	for i := range want {
+		assert.Equal(t, counts[i], want[i])
+	}
+}
+
+func TestMonthCountRange(t *testing.T) {
+	c := MonthCount{Year: 2024, Month: time.February}
+
+	assert.Equal(t, c.Start(), time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, c.End(), time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC))
+}
+
+func TestSnippetModelViewStats(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{DB: db}
+
+	id, err := m.Insert(1, "Popular", "An old silent pond...", "", false, 1)
+	assert.NilError(t, err)
+
+	for _, referrer := range []string{"news.example.com", "news.example.com", "blog.example.com", ""} {
+		err = m.RecordView(id, referrer)
+		assert.NilError(t, err)
+	}
+
+	stats, err := m.ViewStats(id)
+	assert.NilError(t, err)
+
+	assert.Equal(t, stats.Total, 4)
+	assert.Equal(t, len(stats.Days), 1)
+	assert.Equal(t, stats.Days[0].Views, 4)
+	assert.Equal(t, len(stats.Referrers), 2)
+	assert.Equal(t, stats.Referrers[0], ReferrerViews{Referrer: "news.example.com", Views: 2})
+	assert.Equal(t, stats.Referrers[1], ReferrerViews{Referrer: "blog.example.com", Views: 1})
+}
+
+func TestSnippetModelInsertUntil(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{DB: db}
+
+	expires := time.Now().Add(36 * time.Hour).UTC().Truncate(time.Second)
+
+	id, err := m.InsertUntil(1, "Exact", "An old silent pond...", "", false, expires)
+	assert.NilError(t, err)
+
+	s, err := m.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Expires.Equal(expires), true)
+}
+
+func TestSnippetModelBulk(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{DB: db}
+
+	first, err := m.Insert(1, "First", "An old silent pond...", "", false, 7)
+	assert.NilError(t, err)
+
+	second, err := m.Insert(1, "Second", "A frog jumps into the pond,", "", false, 7)
+	assert.NilError(t, err)
+
+	results, err := m.Bulk(BulkHide, []int{first, 9999})
+	assert.NilError(t, err)
+	assert.Equal(t, len(results), 2)
+	assert.Equal(t, results[0], BulkResult{ID: first, Status: "hidden"})
+	assert.Equal(t, results[1], BulkResult{ID: 9999, Status: "not_found"})
+
+	_, err = m.Get(first)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	results, err = m.Bulk(BulkUnhide, []int{first})
+	assert.NilError(t, err)
+	assert.Equal(t, results[0], BulkResult{ID: first, Status: "unhidden"})
+
+	_, err = m.Get(first)
+	assert.NilError(t, err)
+
+	results, err = m.Bulk(BulkDelete, []int{first, second})
+	assert.NilError(t, err)
+	assert.Equal(t, results[1], BulkResult{ID: second, Status: "deleted"})
+
+	_, err = m.Get(second)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	_, err = m.Bulk("archive", []int{first})
+	assert.Equal(t, err != nil, true)
+}
+
+func TestSnippetModelLastCreatedAt(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{DB: db}
+
+	_, err := m.LastCreatedAt(1)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	_, err = m.Insert(1, "O snail", "Climb Mount Fuji,", "", false, 7)
+	assert.NilError(t, err)
+
+	created, err := m.LastCreatedAt(1)
+	assert.NilError(t, err)
+	assert.Equal(t, time.Since(created) < time.Minute, true)
+}
+
+func TestSnippetModelEncryptedContent(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	c, err := NewContentCipher(bytes.Repeat([]byte{1}, 32))
+	assert.NilError(t, err)
+
+	m := SnippetModel{DB: db, Cipher: c}
+
+	private, err := m.Insert(1, "Secret", "An old silent pond...", "", true, 7)
+	assert.NilError(t, err)
+
+	public, err := m.Insert(1, "Open", "A frog jumps into the pond,", "", false, 7)
+	assert.NilError(t, err)
+
+	var stored string
+
+	err = db.QueryRow("SELECT content FROM snippets WHERE id = ?", private).Scan(&stored)
+	assert.NilError(t, err)
+	assert.Equal(t, stored == "An old silent pond...", false)
+
+	err = db.QueryRow("SELECT content FROM snippets WHERE id = ?", public).Scan(&stored)
+	assert.NilError(t, err)
+	assert.Equal(t, stored, "A frog jumps into the pond,")
+
+	s, err := m.Get(private)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Content, "An old silent pond...")
+	assert.Equal(t, s.Private, true)
+
+	r, err := m.ContentReader(private, 1)
+	assert.NilError(t, err)
+
+	got, err := io.ReadAll(r)
+	assert.NilError(t, err)
+	assert.Equal(t, string(got), "An old silent pond...")
+
+	_, err = m.ContentReader(private, 2)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	wrong, err := NewContentCipher(bytes.Repeat([]byte{2}, 32))
+	assert.NilError(t, err)
+
+	_, err = (&SnippetModel{DB: db, Cipher: wrong}).Get(private)
+	assert.Equal(t, errors.Is(err, ErrDecrypt), true)
+
+	_, err = (&SnippetModel{DB: db}).Get(private)
+	assert.Equal(t, errors.Is(err, ErrDecrypt), true)
+}
+
+func TestStripControlChars(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{name: "Null byte", value: "O snail\x00", want: "O snail"},
+		{name: "Other controls", value: "O\x07 sn\x1bail\u0085", want: "O snail"},
+		{name: "Whitespace kept", value: "O\tsnail\r\n", want: "O\tsnail\r\n"},
+		{name: "Non-ASCII kept", value: "カタツムリ", want: "カタツムリ"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, stripControlChars(tt.value), tt.want)
+		})
+	}
+}
+
+func TestSnippetModelInsertStripsTitleControlChars(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{DB: db}
+
+	id, err := m.Insert(1, "O\x00 snail", "Climb Mount Fuji,", "", false, 7)
+	assert.NilError(t, err)
+
+	s, err := m.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Title, "O snail")
+}
+
+func TestSnippetModelFeatured(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{DB: db}
+
+	live, err := m.Insert(1, "Live", "An old silent pond...", "", false, 7)
+	assert.NilError(t, err)
+
+	expiring, err := m.Insert(1, "Expiring", "A frog jumps into the pond,", "", false, 7)
+	assert.NilError(t, err)
+
+	private, err := m.Insert(1, "Private", "splash! Silence again.", "", true, 7)
+	assert.NilError(t, err)
+
+	featured, err := m.Featured(10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(featured), 0)
+
+	assert.NilError(t, m.SetFeatured(live, true))
+	assert.NilError(t, m.SetFeatured(expiring, true))
+
+	err = m.SetFeatured(private, true)
+	assert.Equal(t, errors.Is(err, ErrNotFeaturable), true)
+
+	err = m.SetFeatured(999, true)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	featured, err = m.Featured(10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(featured), 2)
+	assert.Equal(t, featured[0].ID, expiring)
+
+	// An expired snippet drops out of the featured list and cannot be
+	// featured again.
+	_, err = db.Exec("UPDATE snippets SET expires = DATE_SUB(UTC_TIMESTAMP(), INTERVAL 1 MINUTE) WHERE id = ?", expiring)
+	assert.NilError(t, err)
+
+	featured, err = m.Featured(10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(featured), 1)
+	assert.Equal(t, featured[0].ID, live)
+
+	err = m.SetFeatured(expiring, true)
+	assert.Equal(t, errors.Is(err, ErrNotFeaturable), true)
+
+	assert.NilError(t, m.SetFeatured(live, false))
+
+	featured, err = m.Featured(10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(featured), 0)
+}
+
+func TestSnippetModelSetTags(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{DB: db}
+
+	id, err := m.Insert(1, "O snail", "Climb Mount Fuji,", "", false, 7)
+	assert.NilError(t, err)
+
+	assert.NilError(t, m.SetTags(id, []string{"sql", "go"}))
+
+	s, err := m.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, strings.Join(s.Tags, ","), "go,sql")
+
+	assert.NilError(t, m.SetTags(id, []string{"rust"}))
+
+	s, err = m.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, strings.Join(s.Tags, ","), "rust")
+}
+
+func TestSnippetModelWithTx(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{DB: db}
+
+	insert := func(title string) (*sql.Tx, int) {
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tm := m.WithTx(tx)
+
+		id, err := tm.Insert(1, title, "Climb Mount Fuji,", "", false, 7)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.NilError(t, tm.SetTags(id, []string{"go"}))
+
+		return tx, id
+	}
+
+	tx, id := insert("Rolled back")
+	assert.NilError(t, tx.Rollback())
+
+	_, err := m.Get(id)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	var tags int
+	err = db.QueryRow("SELECT COUNT(*) FROM snippet_tags WHERE snippet_id = ?", id).Scan(&tags)
+	assert.NilError(t, err)
+	assert.Equal(t, tags, 0)
+
+	tx, id = insert("Committed")
+	assert.NilError(t, tx.Commit())
+
+	s, err := m.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Title, "Committed")
+	assert.Equal(t, strings.Join(s.Tags, ","), "go")
+}
+
+func TestSnippetModelTagsFor(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{DB: db}
+
+	tagged, err := m.Insert(1, "Tagged", "Climb Mount Fuji,", "", false, 7)
+	assert.NilError(t, err)
+	assert.NilError(t, m.SetTags(tagged, []string{"sql", "go"}))
+
+	untagged, err := m.Insert(1, "Untagged", "Climb Mount Fuji,", "", false, 7)
+	assert.NilError(t, err)
+
+	tags, err := m.TagsFor([]int{tagged, untagged})
+	assert.NilError(t, err)
+	assert.Equal(t, len(tags), 1)
+	assert.Equal(t, strings.Join(tags[tagged], ","), "go,sql")
+
+	tags, err = m.TagsFor(nil)
+	assert.NilError(t, err)
+	assert.Equal(t, len(tags), 0)
+}
+
+func TestSnippetModelCounts(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{DB: db}
+
+	for _, title := range []string{"Today", "Last week", "Last month", "Expired"} {
+		_, err := m.Insert(1, title, "An old silent pond...", "", false, 365)
+		assert.NilError(t, err)
+	}
+
+	_, err := db.Exec("UPDATE snippets SET created = DATE_SUB(UTC_TIMESTAMP(), INTERVAL 3 DAY) WHERE title = 'Last week'")
+	assert.NilError(t, err)
+
+	_, err = db.Exec("UPDATE snippets SET created = DATE_SUB(UTC_TIMESTAMP(), INTERVAL 30 DAY) WHERE title IN ('Last month', 'Expired')")
+	assert.NilError(t, err)
+
+	_, err = db.Exec("UPDATE snippets SET expires = DATE_SUB(UTC_TIMESTAMP(), INTERVAL 1 DAY) WHERE title = 'Expired'")
+	assert.NilError(t, err)
+
+	counts, err := m.Counts()
+	assert.NilError(t, err)
+	assert.Equal(t, counts, SnippetCounts{Total: 4, Live: 3, Expired: 1, CreatedDay: 1, CreatedWeek: 2})
+}
+
+func TestSnippetModelLatestByUser(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{DB: db}
+
+	_, err := m.Insert(1, "Alice public", "An old silent pond...", "", false, 7)
+	assert.NilError(t, err)
+
+	_, err = m.Insert(1, "Alice private", "A frog jumps into the pond", "", true, 7)
+	assert.NilError(t, err)
+
+	hidden, err := m.Insert(1, "Alice hidden", "Splash! Silence again.", "", false, 7)
+	assert.NilError(t, err)
+
+	_, err = db.Exec("UPDATE snippets SET hidden = TRUE WHERE id = ?", hidden)
+	assert.NilError(t, err)
+
+	_, err = m.Insert(2, "Bob public", "Over the wintry forest", "", false, 7)
+	assert.NilError(t, err)
+
+	snippets, err := m.LatestByUser(1, 10, true)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 2)
+	assert.Equal(t, snippets[0].Title, "Alice private")
+	assert.Equal(t, snippets[0].Private, true)
+	assert.Equal(t, snippets[1].Title, "Alice public")
+
+	snippets, err = m.LatestByUser(1, 1, true)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 1)
+
+	snippets, err = m.LatestByUser(1, 10, false)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 1)
+	assert.Equal(t, snippets[0].Title, "Alice public")
+}
+
+func TestSnippetModelPins(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{DB: db}
+
+	var ids []int
+	for i := 1; i <= 3; i++ {
+		id, err := m.Insert(1, fmt.Sprintf("Snippet %d", i), "An old silent pond...", "", false, 7)
+		assert.NilError(t, err)
+		ids = append(ids, id)
+	}
+
+	private, err := m.Insert(1, "Private", "A private haiku...", "", true, 7)
+	assert.NilError(t, err)
+
+	assert.NilError(t, m.Pin(1, ids[1], 2))
+	assert.NilError(t, m.Pin(1, ids[0], 2))
+	assert.NilError(t, m.Pin(1, ids[0], 2))
+
+	err = m.Pin(1, ids[2], 2)
+	assert.Equal(t, errors.Is(err, ErrTooManyPins), true)
+
+	pinned, err := m.Pinned(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(pinned), 2)
+	assert.Equal(t, pinned[0].ID, ids[1])
+	assert.Equal(t, pinned[1].ID, ids[0])
+
+	assert.NilError(t, m.Unpin(1, ids[1]))
+	assert.NilError(t, m.Unpin(1, ids[1]))
+	assert.NilError(t, m.Pin(1, private, 2))
+
+	pinned, err = m.Pinned(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(pinned), 1)
+	assert.Equal(t, pinned[0].ID, ids[0])
+
+	tx, err := db.Begin()
+	assert.NilError(t, err)
+	assert.NilError(t, m.WithTx(tx).Pin(1, ids[2], 2))
+	assert.NilError(t, tx.Rollback())
+
+	pinned, err = m.Pinned(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(pinned), 1)
+}
@@ -1,26 +1,95 @@
 CREATE TABLE snippets (
     id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NULL,
     title VARCHAR(100) NOT NULL,
     content TEXT NOT NULL,
+    language VARCHAR(32) NOT NULL DEFAULT '',
     created DATETIME NOT NULL,
-    expires DATETIME NOT NULL
+    expires DATETIME NOT NULL,
+    hidden BOOLEAN NOT NULL DEFAULT FALSE,
+    private BOOLEAN NOT NULL DEFAULT FALSE,
+    encrypted BOOLEAN NOT NULL DEFAULT FALSE,
+    featured BOOLEAN NOT NULL DEFAULT FALSE
 );
 
 CREATE INDEX idx_snippets_created ON snippets(created);
 
+CREATE TABLE snippet_views (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    viewed DATETIME NOT NULL,
+    referrer VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+CREATE INDEX idx_snippet_views_snippet_viewed ON snippet_views(snippet_id, viewed);
+
+CREATE TABLE snippet_tags (
+    snippet_id INTEGER NOT NULL,
+    tag VARCHAR(32) NOT NULL,
+    PRIMARY KEY (snippet_id, tag)
+);
+
 CREATE TABLE users (
     id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
     name VARCHAR(255) NOT NULL,
     email VARCHAR(255) NOT NULL,
     hashed_password CHAR(60) NOT NULL,
-    created DATETIME NOT NULL
+    created DATETIME NOT NULL,
+    pending_email VARCHAR(255) NULL,
+    activated BOOLEAN NOT NULL DEFAULT TRUE,
+    admin BOOLEAN NOT NULL DEFAULT FALSE,
+    terms_accepted_at DATETIME NULL
 );
 
 ALTER TABLE users ADD CONSTRAINT users_uc_email UNIQUE (email);
 
-INSERT INTO users (name, email, hashed_password, created) VALUES (
+ALTER TABLE users ADD CONSTRAINT users_chk_email_normalized CHECK (BINARY email = BINARY LOWER(TRIM(email)));
+
+INSERT INTO users (name, email, hashed_password, created, admin) VALUES (
 'Alice Jones',
 'alice@example.com',
 '$2a$12$NuTjWXm3KKntReFwyBVHyuf/to.HEwTy.eS206TNfkGfr6HzGJSWG',
-'2022-01-01 09:18:24'
-);
\ No newline at end of file
+'2022-01-01 09:18:24',
+TRUE
+);
+
+CREATE TABLE tokens (
+    hash BINARY(32) PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    expiry DATETIME NOT NULL,
+    scope VARCHAR(32) NOT NULL
+);
+
+CREATE TABLE invites (
+    hash BINARY(32) PRIMARY KEY,
+    created_by INTEGER NOT NULL,
+    created DATETIME NOT NULL,
+    used DATETIME NULL
+);
+
+CREATE TABLE drafts (
+    user_id INTEGER NOT NULL PRIMARY KEY,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    expires INTEGER NOT NULL,
+    updated DATETIME NOT NULL
+);
+
+CREATE TABLE comments (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    body TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    deleted BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX idx_comments_snippet_created ON comments(snippet_id, created);
+
+CREATE TABLE snippet_pins (
+    snippet_id INTEGER NOT NULL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    pinned DATETIME(6) NOT NULL
+);
+
+CREATE INDEX idx_snippet_pins_user_pinned ON snippet_pins(user_id, pinned);
\ No newline at end of file
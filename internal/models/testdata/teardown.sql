@@ -1,3 +1,17 @@
+DROP TABLE snippet_pins;
+
+DROP TABLE comments;
+
+DROP TABLE drafts;
+
+DROP TABLE invites;
+
+DROP TABLE tokens;
+
 DROP TABLE users;
 
+DROP TABLE snippet_views;
+
+DROP TABLE snippet_tags;
+
 DROP TABLE snippets;
\ No newline at end of file
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	ScopeAuthentication = "authentication"
+	ScopeEmailChange    = "email-change"
+)
+
+type Token struct {
+	Plaintext string    `json:"token"`
+	Hash      []byte    `json:"-"`
+	UserID    int       `json:"-"`
+	Expiry    time.Time `json:"expiry"`
+	Scope     string    `json:"-"`
+}
+
+type TokenModel struct {
+	DB *sql.DB
+}
+
+type TokenModelInterface interface {
+	New(userID int, ttl time.Duration, scope string) (*Token, error)
+	UserID(scope, plaintext string) (int, error)
+	DeleteAllForUser(scope string, userID int) error
+}
+
+func generateToken(userID int, ttl time.Duration, scope string) *Token {
+	token := &Token{
+		Plaintext: rand.Text(),
+		UserID:    userID,
+		Expiry:    time.Now().Add(ttl),
+		Scope:     scope,
+	}
+
+	hash := sha256.Sum256([]byte(token.Plaintext))
+	token.Hash = hash[:]
+
+	return token
+}
+
+func (m *TokenModel) New(userID int, ttl time.Duration, scope string) (*Token, error) {
+	token := generateToken(userID, ttl, scope)
+
+	stmt := `INSERT INTO tokens (hash, user_id, expiry, scope)
+	VALUES (?, ?, ?, ?)`
+
+	err := withRetry(func() error {
+		_, err := m.DB.Exec(stmt, token.Hash, token.UserID, token.Expiry.UTC(), token.Scope)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// UserID returns the ID of the user owning the given unexpired token.
+func (m *TokenModel) UserID(scope, plaintext string) (int, error) {
+	hash := sha256.Sum256([]byte(plaintext))
+
+	stmt := `SELECT user_id FROM tokens
+	WHERE hash = ? AND scope = ? AND expiry > UTC_TIMESTAMP()`
+
+	var userID int
+
+	err := withReconnect(func() error {
+		return m.DB.QueryRow(stmt, hash[:], scope).Scan(&userID)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		} else {
+			return 0, err
+		}
+	}
+
+	return userID, nil
+}
+
+func (m *TokenModel) DeleteAllForUser(scope string, userID int) error {
+	stmt := `DELETE FROM tokens WHERE scope = ? AND user_id = ?`
+
+	return withRetry(func() error {
+		_, err := m.DB.Exec(stmt, scope, userID)
+		return err
+	})
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Vadim-Makhnev/snippetbox/internal/assert"
+)
+
+func TestTokenModel(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := TokenModel{db}
+
+	token, err := m.New(1, time.Hour, ScopeAuthentication)
+	assert.NilError(t, err)
+	assert.Equal(t, len(token.Plaintext), 26)
+
+	userID, err := m.UserID(ScopeAuthentication, token.Plaintext)
+	assert.NilError(t, err)
+	assert.Equal(t, userID, 1)
+
+	_, err = m.UserID("other", token.Plaintext)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	expired, err := m.New(1, -time.Hour, ScopeAuthentication)
+	assert.NilError(t, err)
+
+	_, err = m.UserID(ScopeAuthentication, expired.Plaintext)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	err = m.DeleteAllForUser(ScopeAuthentication, 1)
+	assert.NilError(t, err)
+
+	_, err = m.UserID(ScopeAuthentication, token.Plaintext)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+}
@@ -0,0 +1,11 @@
+package models
+
+import "database/sql"
+
+// DBTX is what *sql.DB and *sql.Tx have in common, so that a model's
+// statements can run on the pool or in a caller's transaction alike.
+type DBTX interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
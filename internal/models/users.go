@@ -2,7 +2,9 @@ package models
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"strings"
 	"time"
 
@@ -16,28 +18,116 @@ type User struct {
 	Email          string
 	HashedPassword []byte
 	Created        time.Time
+	Activated      bool
+	// TermsAcceptedAt is when the user accepted the terms of service at
+	// signup, or zero if they didn't.
+	TermsAcceptedAt time.Time
+}
+
+// MarshalJSON is the representation of a user in every API response. It
+// lists the exported fields one by one, so anything added to User, the
+// password hash included, stays out of responses until it is added here.
+func (u User) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		ID        int       `json:"id"`
+		Name      string    `json:"name"`
+		Email     string    `json:"email"`
+		Created   time.Time `json:"created"`
+		Activated bool      `json:"activated"`
+	}{
+		ID:        u.ID,
+		Name:      u.Name,
+		Email:     u.Email,
+		Created:   u.Created,
+		Activated: u.Activated,
+	})
+}
+
+// UserCounts are aggregate numbers of user accounts.
+type UserCounts struct {
+	Total     int `json:"total"`
+	Activated int `json:"activated"`
+}
+
+// UserPage is one page of users found by Search. Page is the page actually
+// returned, which may differ from the one asked for if that was out of range.
+type UserPage struct {
+	Users    []User
+	Page     int
+	LastPage int
+	Total    int
 }
 
 type UserModel struct {
 	DB *sql.DB
+
+	// tx, set by WithTx, is the caller's transaction the statements run in.
+	tx *sql.Tx
 }
 
 type UserModelInterface interface {
-	Insert(name, email, password string) error
+	WithTx(tx *sql.Tx) UserModelInterface
+	Insert(name, email, password string, termsAccepted bool) error
 	Authenticate(email, password string) (int, error)
 	Exists(id int) (bool, error)
+	IsAdmin(id int) (bool, error)
+	Get(id int) (*User, error)
+	GetByEmail(email string) (*User, error)
+	GetMany(ids []int) (map[int]User, error)
+	PasswordUpdate(id int, currentPassword, newPassword string) error
+	SetPendingEmail(id int, currentPassword, newEmail string) error
+	ConfirmEmail(id int) error
+	Counts() (UserCounts, error)
+	Search(q string, f Filters) (UserPage, error)
+}
+
+// NormalizeEmail returns the form emails are stored and looked up in, so
+// addresses differing only in case or surrounding space are the same account.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// WithTx returns a copy of the model whose statements run in tx, so that a
+// signup and the invite it uses persist together or not at all. Committing
+// is up to the caller, and a write in tx is not retried.
+func (m *UserModel) WithTx(tx *sql.Tx) UserModelInterface {
+	return &UserModel{DB: m.DB, tx: tx}
 }
 
-func (m *UserModel) Insert(name, email, password string) error {
+// db returns what the model's statements run on: its transaction if it has
+// one, or else the pool.
+func (m *UserModel) db() DBTX {
+	if m.tx != nil {
+		return m.tx
+	}
+
+	return m.DB
+}
+
+// retry runs a write with withRetry, or just once in a transaction.
+func (m *UserModel) retry(fn func() error) error {
+	if m.tx != nil {
+		return fn()
+	}
+
+	return withRetry(fn)
+}
+
+// Insert adds a user. With termsAccepted, the current time is recorded as
+// when they accepted the terms of service.
+func (m *UserModel) Insert(name, email, password string, termsAccepted bool) error {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
 	if err != nil {
 		return err
 	}
 
-	stmt := `INSERT INTO users (name, email, hashed_password, created)
-	VALUES(?, ?, ?, UTC_TIMESTAMP())`
+	stmt := `INSERT INTO users (name, email, hashed_password, created, terms_accepted_at)
+	VALUES(?, ?, ?, UTC_TIMESTAMP(), IF(?, UTC_TIMESTAMP(), NULL))`
 
-	_, err = m.DB.Exec(stmt, name, email, string(hashedPassword))
+	err = m.retry(func() error {
+		_, err := m.db().Exec(stmt, name, NormalizeEmail(email), string(hashedPassword), termsAccepted)
+		return err
+	})
 	if err != nil {
 		var mySQLError *mysql.MySQLError
 		if errors.As(err, &mySQLError) {
@@ -57,7 +147,9 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 
 	stmt := "SELECT id, hashed_password FROM users WHERE email = ?"
 
-	err := m.DB.QueryRow(stmt, email).Scan(&id, &hashedPassword)
+	err := withReconnect(func() error {
+		return m.db().QueryRow(stmt, NormalizeEmail(email)).Scan(&id, &hashedPassword)
+	})
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return 0, ErrInvalidCredentials
@@ -83,6 +175,308 @@ func (m *UserModel) Exists(id int) (bool, error) {
 
 	stmt := "SELECT EXISTS(SELECT true FROM users WHERE id = ?)"
 
-	err := m.DB.QueryRow(stmt, id).Scan(&exists)
+	err := withReconnect(func() error {
+		return m.db().QueryRow(stmt, id).Scan(&exists)
+	})
 	return exists, err
 }
+
+// IsAdmin reports whether the user may use the admin pages. Admins are
+// appointed directly in the database.
+func (m *UserModel) IsAdmin(id int) (bool, error) {
+	var admin bool
+
+	stmt := "SELECT EXISTS(SELECT true FROM users WHERE id = ? AND admin)"
+
+	err := withReconnect(func() error {
+		return m.db().QueryRow(stmt, id).Scan(&admin)
+	})
+	return admin, err
+}
+
+func (m *UserModel) Get(id int) (*User, error) {
+	var u User
+	var termsAcceptedAt sql.NullTime
+
+	stmt := "SELECT id, name, email, created, activated, terms_accepted_at FROM users WHERE id = ?"
+
+	err := withReconnect(func() error {
+		return m.db().QueryRow(stmt, id).Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.Activated, &termsAcceptedAt)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	u.TermsAcceptedAt = termsAcceptedAt.Time
+
+	return &u, nil
+}
+
+func (m *UserModel) GetByEmail(email string) (*User, error) {
+	var u User
+	var termsAcceptedAt sql.NullTime
+
+	stmt := "SELECT id, name, email, created, activated, terms_accepted_at FROM users WHERE email = ?"
+
+	err := withReconnect(func() error {
+		return m.db().QueryRow(stmt, NormalizeEmail(email)).Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.Activated, &termsAcceptedAt)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	u.TermsAcceptedAt = termsAcceptedAt.Time
+
+	return &u, nil
+}
+
+// Counts returns the number of users in total and of activated users.
+func (m *UserModel) Counts() (UserCounts, error) {
+	var c UserCounts
+
+	stmt := "SELECT COUNT(*), COALESCE(SUM(activated), 0) FROM users"
+
+	err := withReconnect(func() error {
+		return m.db().QueryRow(stmt).Scan(&c.Total, &c.Activated)
+	})
+	if err != nil {
+		return UserCounts{}, err
+	}
+
+	return c, nil
+}
+
+// escapeLike escapes the LIKE wildcards in s so it matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// Search returns a page of users whose name or email contains q, ignoring
+// case, in the order of f.Sort and then by ID. An empty q matches every user.
+// A page past the results is clamped to the last one. Password hashes are not
+// selected.
+func (m *UserModel) Search(q string, f Filters) (UserPage, error) {
+	pattern := "%" + escapeLike(strings.ToLower(strings.TrimSpace(q))) + "%"
+
+	var p UserPage
+
+	err := withReconnect(func() error {
+		return m.db().QueryRow("SELECT COUNT(*) FROM users WHERE LOWER(name) LIKE ? OR email LIKE ?", pattern, pattern).Scan(&p.Total)
+	})
+	if err != nil {
+		return UserPage{}, err
+	}
+
+	p.LastPage = f.LastPage(p.Total)
+	p.Page = min(f.Page, p.LastPage)
+	f.Page = p.Page
+
+	stmt := fmt.Sprintf(`SELECT id, name, email, created, activated FROM users
+	WHERE LOWER(name) LIKE ? OR email LIKE ?
+	ORDER BY %s %s, id LIMIT ? OFFSET ?`, f.sortColumn(), f.sortDirection())
+
+	var rows *sql.Rows
+
+	err = withReconnect(func() (err error) {
+		rows, err = m.db().Query(stmt, pattern, pattern, f.limit(), f.offset())
+		return err
+	})
+	if err != nil {
+		return UserPage{}, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var u User
+
+		err = rows.Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.Activated)
+		if err != nil {
+			return UserPage{}, err
+		}
+
+		p.Users = append(p.Users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return UserPage{}, err
+	}
+
+	return p, nil
+}
+
+// GetMany fetches all of the given users in a single query, keyed by ID.
+// IDs with no matching user are absent from the returned map.
+func (m *UserModel) GetMany(ids []int) (map[int]User, error) {
+	users := make(map[int]User, len(ids))
+
+	if len(ids) == 0 {
+		return users, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	stmt := "SELECT id, name, email, created, activated FROM users WHERE id IN (" + placeholders + ")"
+
+	var rows *sql.Rows
+
+	err := withReconnect(func() (err error) {
+		rows, err = m.db().Query(stmt, args...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var u User
+
+		err = rows.Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.Activated)
+		if err != nil {
+			return nil, err
+		}
+
+		users[u.ID] = u
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// checkPassword returns ErrInvalidCredentials unless password is the
+// user's current password.
+func (m *UserModel) checkPassword(id int, password string) error {
+	var hashedPassword []byte
+
+	stmt := "SELECT hashed_password FROM users WHERE id = ?"
+
+	err := withReconnect(func() error {
+		return m.db().QueryRow(stmt, id).Scan(&hashedPassword)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		} else {
+			return err
+		}
+	}
+
+	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrInvalidCredentials
+		} else {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PasswordUpdate replaces the user's password after checking the current one,
+// returning ErrInvalidCredentials if it doesn't match.
+func (m *UserModel) PasswordUpdate(id int, currentPassword, newPassword string) error {
+	err := m.checkPassword(id, currentPassword)
+	if err != nil {
+		return err
+	}
+
+	newHashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), 12)
+	if err != nil {
+		return err
+	}
+
+	stmt := "UPDATE users SET hashed_password = ? WHERE id = ?"
+
+	return m.retry(func() error {
+		_, err := m.db().Exec(stmt, string(newHashedPassword), id)
+		return err
+	})
+}
+
+// SetPendingEmail records newEmail as the address the user is changing to,
+// after checking their current password. The current email stays in use
+// until ConfirmEmail. It returns ErrDuplicateEmail if another account already
+// has the address.
+func (m *UserModel) SetPendingEmail(id int, currentPassword, newEmail string) error {
+	err := m.checkPassword(id, currentPassword)
+	if err != nil {
+		return err
+	}
+
+	newEmail = NormalizeEmail(newEmail)
+
+	var taken bool
+
+	err = withReconnect(func() error {
+		return m.db().QueryRow("SELECT EXISTS(SELECT true FROM users WHERE email = ?)", newEmail).Scan(&taken)
+	})
+	if err != nil {
+		return err
+	}
+
+	if taken {
+		return ErrDuplicateEmail
+	}
+
+	stmt := "UPDATE users SET pending_email = ? WHERE id = ?"
+
+	return m.retry(func() error {
+		_, err := m.db().Exec(stmt, newEmail, id)
+		return err
+	})
+}
+
+// ConfirmEmail makes the user's pending email their email. It returns
+// ErrNoRecord if no change is pending and ErrDuplicateEmail if the address
+// was taken in the meantime.
+func (m *UserModel) ConfirmEmail(id int) error {
+	stmt := `UPDATE users SET email = pending_email, pending_email = NULL
+	WHERE id = ? AND pending_email IS NOT NULL`
+
+	var result sql.Result
+
+	err := m.retry(func() error {
+		var err error
+		result, err = m.db().Exec(stmt, id)
+		return err
+	})
+	if err != nil {
+		var mySQLError *mysql.MySQLError
+		if errors.As(err, &mySQLError) {
+			if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "users_uc_email") {
+				return ErrDuplicateEmail
+			}
+		}
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
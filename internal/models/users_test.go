@@ -1,7 +1,13 @@
 package models
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/Vadim-Makhnev/snippetbox/internal/assert"
 )
@@ -37,7 +43,7 @@ func TestUserModelExists(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 
 			db := newTestDB(t)
-			m := UserModel{db}
+			m := UserModel{DB: db}
 
 			exists, err := m.Exists(tt.userID)
 			assert.Equal(t, exists, tt.want)
@@ -45,3 +51,237 @@ func TestUserModelExists(t *testing.T) {
 		})
 	}
 }
+
+func TestUserModelEmailCase(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := UserModel{DB: db}
+
+	err := m.Insert("Alice", " Alice@Example.COM ", "pa$$word", false)
+	assert.Equal(t, errors.Is(err, ErrDuplicateEmail), true)
+
+	err = m.Insert("Bob", "Bob@Example.com", "pa$$word", false)
+	assert.NilError(t, err)
+
+	u, err := m.GetByEmail("bob@example.com")
+	assert.NilError(t, err)
+	assert.Equal(t, u.Email, "bob@example.com")
+
+	id, err := m.Authenticate("BOB@example.com", "pa$$word")
+	assert.NilError(t, err)
+	assert.Equal(t, id, u.ID)
+}
+
+func TestUserModelGetMany(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := UserModel{DB: db}
+
+	users, err := m.GetMany([]int{1, 2})
+	assert.NilError(t, err)
+	assert.Equal(t, len(users), 1)
+	assert.Equal(t, users[1].Email, "alice@example.com")
+
+	users, err = m.GetMany(nil)
+	assert.NilError(t, err)
+	assert.Equal(t, len(users), 0)
+}
+
+func TestUserModelPasswordUpdate(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := UserModel{DB: db}
+
+	err := m.PasswordUpdate(1, "wrong password", "new pa$$word")
+	assert.Equal(t, errors.Is(err, ErrInvalidCredentials), true)
+
+	err = m.PasswordUpdate(1, "pa$$word", "new pa$$word")
+	assert.NilError(t, err)
+
+	id, err := m.Authenticate("alice@example.com", "new pa$$word")
+	assert.NilError(t, err)
+	assert.Equal(t, id, 1)
+
+	err = m.PasswordUpdate(2, "pa$$word", "new pa$$word")
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+}
+
+func TestUserModelEmailChange(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := UserModel{DB: db}
+
+	err := m.Insert("Bob", "bob@example.com", "pa$$word", false)
+	assert.NilError(t, err)
+
+	err = m.SetPendingEmail(1, "wrong", "alice@new.example.com")
+	assert.Equal(t, errors.Is(err, ErrInvalidCredentials), true)
+
+	err = m.SetPendingEmail(1, "pa$$word", "BOB@example.com")
+	assert.Equal(t, errors.Is(err, ErrDuplicateEmail), true)
+
+	err = m.ConfirmEmail(1)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	err = m.SetPendingEmail(1, "pa$$word", "Alice@New.example.com")
+	assert.NilError(t, err)
+
+	// The old address keeps working until the change is confirmed.
+	_, err = m.GetByEmail("alice@example.com")
+	assert.NilError(t, err)
+
+	err = m.ConfirmEmail(1)
+	assert.NilError(t, err)
+
+	u, err := m.GetByEmail("alice@new.example.com")
+	assert.NilError(t, err)
+	assert.Equal(t, u.ID, 1)
+
+	_, err = m.GetByEmail("alice@example.com")
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+}
+
+func TestUserMarshalJSON(t *testing.T) {
+	hash := []byte("$2a$12$7K8Q2vPzT9mXc3LwR5nYeO0fJ1hG6sD4aB8uV2iC9kE3qW7xZ5tMy")
+
+	u := User{
+		ID:             7,
+		Name:           "Alice",
+		Email:          "alice@example.com",
+		HashedPassword: hash,
+		Created:        time.Date(2024, 3, 17, 10, 15, 0, 0, time.UTC),
+		Activated:      true,
+	}
+
+	data, err := json.Marshal(u)
+	assert.NilError(t, err)
+
+	assert.Equal(t, string(data), `{"id":7,"name":"Alice","email":"alice@example.com","created":"2024-03-17T10:15:00Z","activated":true}`)
+	assert.Equal(t, bytes.Contains(data, hash), false)
+	assert.Equal(t, bytes.Contains(data, []byte(base64.StdEncoding.EncodeToString(hash))), false)
+
+	data, err = json.Marshal(map[string]any{"user": &u})
+	assert.NilError(t, err)
+
+	assert.Equal(t, bytes.Contains(data, hash), false)
+	assert.Equal(t, bytes.Contains(data, []byte(base64.StdEncoding.EncodeToString(hash))), false)
+}
+
+func TestUserModelCounts(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := UserModel{DB: db}
+
+	err := m.Insert("Bob", "bob@example.com", "pa$$word", false)
+	assert.NilError(t, err)
+
+	err = m.Insert("Carol", "carol@example.com", "pa$$word", false)
+	assert.NilError(t, err)
+
+	_, err = db.Exec("UPDATE users SET activated = FALSE WHERE email = 'carol@example.com'")
+	assert.NilError(t, err)
+
+	counts, err := m.Counts()
+	assert.NilError(t, err)
+	assert.Equal(t, counts, UserCounts{Total: 3, Activated: 2})
+}
+
+func TestUserModelSearch(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := UserModel{DB: db}
+
+	for i := range 24 {
+		err := m.Insert(fmt.Sprintf("User %02d", i), fmt.Sprintf("user%02d@example.org", i), "pa$$word", false)
+		assert.NilError(t, err)
+	}
+
+	f := Filters{Page: 1, PageSize: 20, Sort: "id", SortSafelist: []string{"id", "-id", "name", "-name"}}
+
+	page, err := m.Search("alice@EXAMPLE", f)
+	assert.NilError(t, err)
+	assert.Equal(t, page.Total, 1)
+	assert.Equal(t, len(page.Users), 1)
+	assert.Equal(t, page.Users[0].Email, "alice@example.com")
+	assert.Equal(t, page.Users[0].HashedPassword == nil, true)
+
+	f.Page = 2
+
+	page, err = m.Search("example.org", f)
+	assert.NilError(t, err)
+	assert.Equal(t, page.Total, 24)
+	assert.Equal(t, page.Page, 2)
+	assert.Equal(t, page.LastPage, 2)
+	assert.Equal(t, len(page.Users), 4)
+	assert.Equal(t, page.Users[0].Email, "user20@example.org")
+
+	f.Page = 99
+
+	page, err = m.Search("", f)
+	assert.NilError(t, err)
+	assert.Equal(t, page.Page, 2)
+	assert.Equal(t, len(page.Users), 5)
+
+	f.Page, f.Sort = 1, "-name"
+
+	page, err = m.Search("example.org", f)
+	assert.NilError(t, err)
+	assert.Equal(t, page.Users[0].Name, "User 23")
+
+	page, err = m.Search("_", f)
+	assert.NilError(t, err)
+	assert.Equal(t, page.Total, 0)
+	assert.Equal(t, page.Page, 1)
+}
+
+func TestUserModelTermsAccepted(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := UserModel{DB: db}
+
+	err := m.Insert("Bob", "bob@example.com", "pa$$word", true)
+	assert.NilError(t, err)
+
+	err = m.Insert("Carol", "carol@example.com", "pa$$word", false)
+	assert.NilError(t, err)
+
+	bob, err := m.GetByEmail("bob@example.com")
+	assert.NilError(t, err)
+	assert.Equal(t, time.Since(bob.TermsAcceptedAt) < time.Minute, true)
+
+	carol, err := m.GetByEmail("carol@example.com")
+	assert.NilError(t, err)
+	assert.Equal(t, carol.TermsAcceptedAt.IsZero(), true)
+
+	alice, err := m.Get(1)
+	assert.NilError(t, err)
+	assert.Equal(t, alice.TermsAcceptedAt.IsZero(), true)
+}
@@ -4,6 +4,7 @@ import (
 	"regexp"
 	"slices"
 	"strings"
+	"unicode"
 	"unicode/utf8"
 )
 
@@ -56,3 +57,11 @@ func MinChars(value string, n int) bool {
 func Matches(value string, rx *regexp.Regexp) bool {
 	return rx.MatchString(value)
 }
+
+// NoControlChars reports whether value is free of control characters such
+// as null bytes. Tabs, newlines and carriage returns are allowed.
+func NoControlChars(value string) bool {
+	return !strings.ContainsFunc(value, func(r rune) bool {
+		return unicode.IsControl(r) && r != '\t' && r != '\n' && r != '\r'
+	})
+}
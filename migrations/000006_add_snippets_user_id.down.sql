@@ -0,0 +1,5 @@
+USE snippetbox;
+
+ALTER TABLE snippets DROP FOREIGN KEY fk_snippets_user_id;
+
+ALTER TABLE snippets DROP COLUMN user_id;
\ No newline at end of file
@@ -0,0 +1,5 @@
+USE snippetbox;
+
+ALTER TABLE snippets ADD COLUMN user_id INTEGER NULL;
+
+ALTER TABLE snippets ADD CONSTRAINT fk_snippets_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL;
\ No newline at end of file
@@ -0,0 +1,9 @@
+USE snippetbox;
+
+CREATE TABLE IF NOT EXISTS tokens (
+    hash BINARY(32) PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    expiry DATETIME NOT NULL,
+    scope VARCHAR(32) NOT NULL,
+    CONSTRAINT fk_tokens_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
\ No newline at end of file
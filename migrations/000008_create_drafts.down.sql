@@ -0,0 +1,3 @@
+USE snippetbox;
+
+DROP TABLE IF EXISTS drafts;
\ No newline at end of file
@@ -0,0 +1,10 @@
+USE snippetbox;
+
+CREATE TABLE IF NOT EXISTS drafts (
+    user_id INTEGER NOT NULL PRIMARY KEY,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    expires INTEGER NOT NULL,
+    updated DATETIME NOT NULL,
+    CONSTRAINT fk_drafts_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
\ No newline at end of file
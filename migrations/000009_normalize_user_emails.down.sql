@@ -0,0 +1,3 @@
+USE snippetbox;
+
+ALTER TABLE users DROP CHECK users_chk_email_normalized;
\ No newline at end of file
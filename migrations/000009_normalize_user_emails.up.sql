@@ -0,0 +1,5 @@
+USE snippetbox;
+
+UPDATE users SET email = LOWER(TRIM(email));
+
+ALTER TABLE users ADD CONSTRAINT users_chk_email_normalized CHECK (BINARY email = BINARY LOWER(TRIM(email)));
\ No newline at end of file
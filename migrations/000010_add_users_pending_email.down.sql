@@ -0,0 +1,3 @@
+USE snippetbox;
+
+ALTER TABLE users DROP COLUMN pending_email;
\ No newline at end of file
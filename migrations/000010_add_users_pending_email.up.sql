@@ -0,0 +1,3 @@
+USE snippetbox;
+
+ALTER TABLE users ADD COLUMN pending_email VARCHAR(255) NULL;
\ No newline at end of file
@@ -0,0 +1,3 @@
+USE snippetbox;
+
+ALTER TABLE users DROP COLUMN activated;
\ No newline at end of file
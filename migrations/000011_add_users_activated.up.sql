@@ -0,0 +1,3 @@
+USE snippetbox;
+
+ALTER TABLE users ADD COLUMN activated BOOLEAN NOT NULL DEFAULT TRUE;
\ No newline at end of file
@@ -0,0 +1,3 @@
+USE snippetbox;
+
+DROP TABLE IF EXISTS snippet_views;
\ No newline at end of file
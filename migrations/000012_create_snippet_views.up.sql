@@ -0,0 +1,11 @@
+USE snippetbox;
+
+CREATE TABLE IF NOT EXISTS snippet_views (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    viewed DATETIME NOT NULL,
+    referrer VARCHAR(255) NOT NULL DEFAULT '',
+    CONSTRAINT fk_snippet_views_snippet_id FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_snippet_views_snippet_viewed ON snippet_views(snippet_id, viewed);
\ No newline at end of file
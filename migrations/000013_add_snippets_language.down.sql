@@ -0,0 +1,3 @@
+USE snippetbox;
+
+ALTER TABLE snippets DROP COLUMN language;
\ No newline at end of file
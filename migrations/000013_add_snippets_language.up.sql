@@ -0,0 +1,3 @@
+USE snippetbox;
+
+ALTER TABLE snippets ADD COLUMN language VARCHAR(32) NOT NULL DEFAULT '';
\ No newline at end of file
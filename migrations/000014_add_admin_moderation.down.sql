@@ -0,0 +1,5 @@
+USE snippetbox;
+
+ALTER TABLE snippets DROP COLUMN hidden;
+
+ALTER TABLE users DROP COLUMN admin;
\ No newline at end of file
@@ -0,0 +1,5 @@
+USE snippetbox;
+
+ALTER TABLE users ADD COLUMN admin BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE snippets ADD COLUMN hidden BOOLEAN NOT NULL DEFAULT FALSE;
\ No newline at end of file
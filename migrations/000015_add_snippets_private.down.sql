@@ -0,0 +1,5 @@
+USE snippetbox;
+
+ALTER TABLE snippets DROP COLUMN encrypted;
+
+ALTER TABLE snippets DROP COLUMN private;
\ No newline at end of file
@@ -0,0 +1,5 @@
+USE snippetbox;
+
+ALTER TABLE snippets ADD COLUMN private BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE snippets ADD COLUMN encrypted BOOLEAN NOT NULL DEFAULT FALSE;
\ No newline at end of file
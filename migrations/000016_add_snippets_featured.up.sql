@@ -0,0 +1,3 @@
+USE snippetbox;
+
+ALTER TABLE snippets ADD COLUMN featured BOOLEAN NOT NULL DEFAULT FALSE;
\ No newline at end of file
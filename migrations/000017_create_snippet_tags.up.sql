@@ -0,0 +1,10 @@
+USE snippetbox;
+
+CREATE TABLE IF NOT EXISTS snippet_tags (
+    snippet_id INTEGER NOT NULL,
+    tag VARCHAR(32) NOT NULL,
+    PRIMARY KEY (snippet_id, tag),
+    CONSTRAINT fk_snippet_tags_snippet_id FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_snippet_tags_tag ON snippet_tags(tag);
\ No newline at end of file
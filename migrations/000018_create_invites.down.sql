@@ -0,0 +1,3 @@
+USE snippetbox;
+
+DROP TABLE IF EXISTS invites;
\ No newline at end of file
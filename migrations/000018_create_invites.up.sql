@@ -0,0 +1,9 @@
+USE snippetbox;
+
+CREATE TABLE IF NOT EXISTS invites (
+    hash BINARY(32) PRIMARY KEY,
+    created_by INTEGER NOT NULL,
+    created DATETIME NOT NULL,
+    used DATETIME NULL,
+    CONSTRAINT fk_invites_created_by FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE CASCADE
+);
\ No newline at end of file
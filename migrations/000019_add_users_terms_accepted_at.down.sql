@@ -0,0 +1,3 @@
+USE snippetbox;
+
+ALTER TABLE users DROP COLUMN terms_accepted_at;
\ No newline at end of file
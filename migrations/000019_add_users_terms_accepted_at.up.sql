@@ -0,0 +1,3 @@
+USE snippetbox;
+
+ALTER TABLE users ADD COLUMN terms_accepted_at DATETIME NULL;
\ No newline at end of file
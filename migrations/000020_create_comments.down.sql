@@ -0,0 +1,3 @@
+USE snippetbox;
+
+DROP TABLE IF EXISTS comments;
\ No newline at end of file
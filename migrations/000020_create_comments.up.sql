@@ -0,0 +1,13 @@
+USE snippetbox;
+
+CREATE TABLE IF NOT EXISTS comments (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    body TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    CONSTRAINT fk_comments_snippet_id FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE,
+    CONSTRAINT fk_comments_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_comments_snippet_created ON comments(snippet_id, created);
\ No newline at end of file
@@ -0,0 +1,3 @@
+USE snippetbox;
+
+ALTER TABLE comments DROP COLUMN deleted;
\ No newline at end of file
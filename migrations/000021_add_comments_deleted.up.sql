@@ -0,0 +1,3 @@
+USE snippetbox;
+
+ALTER TABLE comments ADD COLUMN deleted BOOLEAN NOT NULL DEFAULT FALSE;
\ No newline at end of file
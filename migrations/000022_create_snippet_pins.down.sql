@@ -0,0 +1,3 @@
+USE snippetbox;
+
+DROP TABLE IF EXISTS snippet_pins;
\ No newline at end of file
@@ -0,0 +1,11 @@
+USE snippetbox;
+
+CREATE TABLE IF NOT EXISTS snippet_pins (
+    snippet_id INTEGER NOT NULL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    pinned DATETIME(6) NOT NULL,
+    CONSTRAINT fk_snippet_pins_snippet_id FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE,
+    CONSTRAINT fk_snippet_pins_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_snippet_pins_user_pinned ON snippet_pins(user_id, pinned);
\ No newline at end of file
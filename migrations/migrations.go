@@ -0,0 +1,8 @@
+// Package migrations embeds the SQL migrations applied with golang-migrate,
+// so the server can tell whether a database schema is up to date.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var Files embed.FS
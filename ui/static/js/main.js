@@ -5,4 +5,48 @@ for (var i = 0; i < navLinks.length; i++) {
 		link.classList.add("live");
 		break;
 	}
-}
\ No newline at end of file
+}
+
+var timeZone = Intl.DateTimeFormat().resolvedOptions().timeZone;
+if (timeZone && document.cookie.indexOf("tz=" + timeZone) == -1) {
+	document.cookie = "tz=" + timeZone + "; path=/; max-age=31536000; SameSite=Lax";
+}
+
+var createForm = document.querySelector("form[action='/snippet/create']");
+if (createForm) {
+	var draftTimer = null;
+	var saveDraft = function () {
+		var expires = createForm.querySelector("input[name='expires']:checked");
+		fetch("/api/v1/drafts", {
+			method: "POST",
+			headers: {
+				"Content-Type": "application/json",
+				"X-CSRF-Token": createForm.elements["csrf_token"].value
+			},
+			body: JSON.stringify({
+				title: createForm.elements["title"].value,
+				content: createForm.elements["content"].value,
+				expires: expires ? parseInt(expires.value, 10) : 0
+			})
+		});
+	};
+	var preview = document.querySelector(".preview");
+	var previewTimer = null;
+	var updatePreview = function () {
+		var body = new URLSearchParams();
+		body.append("csrf_token", createForm.elements["csrf_token"].value);
+		body.append("content", createForm.elements["content"].value);
+		fetch("/snippet/preview", {method: "POST", body: body})
+			.then(function (rs) { return rs.ok ? rs.text() : ""; })
+			.then(function (html) { preview.innerHTML = html; });
+	};
+	createForm.addEventListener("input", function () {
+		clearTimeout(draftTimer);
+		draftTimer = setTimeout(saveDraft, 1000);
+		clearTimeout(previewTimer);
+		previewTimer = setTimeout(updatePreview, 300);
+	});
+	createForm.addEventListener("submit", function () {
+		clearTimeout(draftTimer);
+	});
+}